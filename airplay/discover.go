@@ -0,0 +1,72 @@
+// Package airplay sends PCM audio to AirPlay (RAOP) speakers
+package airplay
+
+import (
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/mdns"
+)
+
+// DiscoverTimeout is how long Discover waits for speakers to answer
+const DiscoverTimeout = 2 * time.Second
+
+// Device is an AirPlay speaker on the LAN
+type Device struct {
+	Name      string `json:"name"` // e.g. "Kitchen"
+	Host      string `json:"host"`
+	Port      int    `json:"port"`
+	Supported bool   `json:"supported"` // Accepts unencrypted PCM, which is what Sender sends
+}
+
+// Addr returns the host:port of the speaker's RTSP server
+func (d Device) Addr() string {
+	return net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
+}
+
+// Discover searches the LAN for AirPlay speakers with mDNS for the given time
+func Discover(timeout time.Duration) ([]Device, error) {
+	instances, err := mdns.Browse("_raop._tcp", timeout)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]Device, 0, len(instances))
+	for _, inst := range instances {
+		// RAOP instances are named "<MAC address>@<speaker name>"
+		_, name, ok := strings.Cut(inst.Name, "@")
+		if !ok {
+			name = inst.Name
+		}
+		devices = append(devices, Device{
+			Name:      name,
+			Host:      inst.Host,
+			Port:      inst.Port,
+			Supported: txtHas(inst.TXT, "et", "0") && txtHas(inst.TXT, "cn", "0"),
+		})
+	}
+	return devices, nil
+}
+
+// FindDevice discovers the speaker with the given name (case-insensitive)
+func FindDevice(name string) (Device, bool) {
+	devices, _ := Discover(DiscoverTimeout)
+	for _, d := range devices {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return Device{}, false
+}
+
+// txtHas reports whether a comma-separated TXT value lists v. Speakers that
+// omit the key accept the defaults, which include v = "0".
+func txtHas(txt map[string]string, key, v string) bool {
+	list, ok := txt[key]
+	if !ok {
+		return true
+	}
+	return slices.Contains(strings.Split(list, ","), v)
+}
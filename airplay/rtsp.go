@@ -0,0 +1,104 @@
+package airplay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rtspTimeout bounds each RTSP request
+const rtspTimeout = 10 * time.Second
+
+// rtspResponse is the status and headers of an RTSP response
+type rtspResponse struct {
+	status int
+	header textproto.MIMEHeader
+}
+
+// rtspConn is the RTSP control connection to a speaker
+type rtspConn struct {
+	conn     net.Conn
+	reader   *textproto.Reader
+	cseq     int
+	session  string
+	instance string // Client-Instance / DACP-ID identifying this sender
+}
+
+// dialRTSP connects to a speaker's RTSP server
+func dialRTSP(addr, instance string) (*rtspConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, rtspTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &rtspConn{
+		conn:     conn,
+		reader:   textproto.NewReader(bufio.NewReader(conn)),
+		instance: instance,
+	}, nil
+}
+
+// do sends a request and reads the response, failing on non-2xx statuses
+func (c *rtspConn) do(method, uri string, header map[string]string, contentType, body string) (*rtspResponse, error) {
+	c.cseq++
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s RTSP/1.0\r\n", method, uri)
+	fmt.Fprintf(&b, "CSeq: %d\r\n", c.cseq)
+	b.WriteString("User-Agent: radiko-tui\r\n")
+	fmt.Fprintf(&b, "Client-Instance: %s\r\nDACP-ID: %s\r\n", c.instance, c.instance)
+	if c.session != "" {
+		fmt.Fprintf(&b, "Session: %s\r\n", c.session)
+	}
+	for k, v := range header {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+	}
+	if body != "" {
+		fmt.Fprintf(&b, "Content-Type: %s\r\nContent-Length: %d\r\n", contentType, len(body))
+	}
+	b.WriteString("\r\n")
+	b.WriteString(body)
+
+	c.conn.SetDeadline(time.Now().Add(rtspTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+
+	line, err := c.reader.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	proto, rest, _ := strings.Cut(line, " ")
+	code, _, _ := strings.Cut(rest, " ")
+	status, err := strconv.Atoi(code)
+	if !strings.HasPrefix(proto, "RTSP/") || err != nil {
+		return nil, fmt.Errorf("invalid RTSP response: %q", line)
+	}
+	mime, err := c.reader.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := strconv.Atoi(mime.Get("Content-Length")); n > 0 {
+		if _, err := io.CopyN(io.Discard, c.reader.R, int64(n)); err != nil {
+			return nil, err
+		}
+	}
+	if status < 200 || status > 299 {
+		return nil, fmt.Errorf("%s: %s", method, rest)
+	}
+	return &rtspResponse{status: status, header: mime}, nil
+}
+
+// transportParams parses "key=value" parameters of a Transport header
+func transportParams(transport string) map[string]string {
+	params := make(map[string]string)
+	for _, part := range strings.Split(transport, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		params[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return params
+}
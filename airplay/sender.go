@@ -0,0 +1,315 @@
+package airplay
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SampleRate is the rate of the PCM Sender expects (s16le, stereo)
+const SampleRate = 44100
+
+const (
+	framesPerPacket = 352
+	frameSize       = 4          // 16-bit stereo
+	latency         = SampleRate // Frames the speaker buffers before playing (1s)
+	maxAhead        = SampleRate // Frames sent ahead of the clock at most
+)
+
+// ErrClosed is returned by Write after Close
+var ErrClosed = errors.New("airplay sender closed")
+
+// Sender streams PCM to a speaker: RTSP for the session, RTP over UDP for
+// the audio, plus the control (sync) and timing channels
+type Sender struct {
+	Device Device
+
+	rtspMu  sync.Mutex
+	rtsp    *rtspConn
+	uri     string
+	audio   *net.UDPConn
+	control *net.UDPConn
+	timing  *net.UDPConn
+	ctrlTo  *net.UDPAddr // Speaker's control port
+
+	mu       sync.Mutex
+	pending  []byte // PCM not yet filling a packet
+	seq      uint16
+	rtptime  uint32 // Timestamp of the next packet
+	ssrc     uint32
+	first    bool
+	start    time.Time // Wall clock of startRTP
+	startRTP uint32
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Dial opens a session with a speaker
+func Dial(d Device) (*Sender, error) {
+	if !d.Supported {
+		return nil, fmt.Errorf("%s requires encryption or another codec (AirPlay 2 only speakers are not supported)", d.Name)
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	instance := fmt.Sprintf("%X", id)
+	rtsp, err := dialRTSP(d.Addr(), instance)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sender{
+		Device: d,
+		rtsp:   rtsp,
+		ssrc:   binary.BigEndian.Uint32(id[4:]),
+		seq:    binary.BigEndian.Uint16(id[2:]),
+		first:  true,
+		done:   make(chan struct{}),
+	}
+	if err := s.setup(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// setup announces the stream, opens the UDP channels and starts recording
+func (s *Sender) setup() error {
+	local := s.rtsp.conn.LocalAddr().(*net.TCPAddr).IP
+	remote := s.rtsp.conn.RemoteAddr().(*net.TCPAddr).IP
+	sessionID := s.ssrc
+	s.uri = fmt.Sprintf("rtsp://%s/%d", local, sessionID)
+
+	sdp := fmt.Sprintf("v=0\r\n"+
+		"o=iTunes %d 0 IN IP4 %s\r\n"+
+		"s=iTunes\r\n"+
+		"c=IN IP4 %s\r\n"+
+		"t=0 0\r\n"+
+		"m=audio 0 RTP/AVP 96\r\n"+
+		"a=rtpmap:96 L16/%d/2\r\n", sessionID, local, remote, SampleRate)
+	if _, err := s.rtsp.do("ANNOUNCE", s.uri, nil, "application/sdp", sdp); err != nil {
+		return err
+	}
+
+	var err error
+	if s.control, err = net.ListenUDP("udp", &net.UDPAddr{IP: local}); err != nil {
+		return err
+	}
+	if s.timing, err = net.ListenUDP("udp", &net.UDPAddr{IP: local}); err != nil {
+		return err
+	}
+	go s.answerTiming()
+	go s.drainControl()
+
+	resp, err := s.rtsp.do("SETUP", s.uri, map[string]string{
+		"Transport": fmt.Sprintf("RTP/AVP/UDP;unicast;interleaved=0-1;mode=record;control_port=%d;timing_port=%d",
+			s.control.LocalAddr().(*net.UDPAddr).Port, s.timing.LocalAddr().(*net.UDPAddr).Port),
+	}, "", "")
+	if err != nil {
+		return err
+	}
+	// Drop parameters such as ";timeout=60"
+	s.rtsp.session, _, _ = strings.Cut(resp.header.Get("Session"), ";")
+	params := transportParams(resp.header.Get("Transport"))
+	serverPort, _ := strconv.Atoi(params["server_port"])
+	controlPort, _ := strconv.Atoi(params["control_port"])
+	if serverPort == 0 {
+		return errors.New("speaker did not return an audio port")
+	}
+	if s.audio, err = net.DialUDP("udp", nil, &net.UDPAddr{IP: remote, Port: serverPort}); err != nil {
+		return err
+	}
+	s.ctrlTo = &net.UDPAddr{IP: remote, Port: controlPort}
+
+	if _, err := s.rtsp.do("RECORD", s.uri, map[string]string{
+		"Range":    "npt=0-",
+		"RTP-Info": fmt.Sprintf("seq=%d;rtptime=%d", s.seq, s.rtptime),
+	}, "", ""); err != nil {
+		return err
+	}
+	go s.syncLoop()
+	return nil
+}
+
+// Write sends s16le stereo PCM at SampleRate, pacing it to real time
+func (s *Sender) Write(pcm []byte) (int, error) {
+	select {
+	case <-s.done:
+		return 0, ErrClosed
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, pcm...)
+	const packetBytes = framesPerPacket * frameSize
+	for len(s.pending) >= packetBytes {
+		if err := s.sendPacket(s.pending[:packetBytes]); err != nil {
+			return 0, err
+		}
+		s.pending = s.pending[packetBytes:]
+	}
+	s.pending = append([]byte(nil), s.pending...)
+	return len(pcm), nil
+}
+
+// sendPacket sends one RTP packet, waiting when far ahead of the clock and
+// skipping ahead (silence) when the input fell behind
+func (s *Sender) sendPacket(pcm []byte) error {
+	if s.first {
+		s.start = time.Now()
+		s.startRTP = s.rtptime
+	}
+	ahead := int64(int32(s.rtptime - s.clockRTP()))
+	if ahead > maxAhead {
+		time.Sleep(time.Duration(ahead-maxAhead) * time.Second / SampleRate)
+	} else if ahead < 0 {
+		s.rtptime = s.clockRTP()
+	}
+
+	packet := make([]byte, 12, 12+len(pcm))
+	packet[0] = 0x80
+	packet[1] = 0x60 // Payload type 96
+	if s.first {
+		packet[1] |= 0x80 // Marker
+	}
+	binary.BigEndian.PutUint16(packet[2:], s.seq)
+	binary.BigEndian.PutUint32(packet[4:], s.rtptime)
+	binary.BigEndian.PutUint32(packet[8:], s.ssrc)
+	// L16 is big-endian
+	for i := 0; i+1 < len(pcm); i += 2 {
+		packet = append(packet, pcm[i+1], pcm[i])
+	}
+
+	if _, err := s.audio.Write(packet); err != nil {
+		return err
+	}
+	s.first = false
+	s.seq++
+	s.rtptime += framesPerPacket
+	return nil
+}
+
+// clockRTP returns the timestamp that should be playing now
+func (s *Sender) clockRTP() uint32 {
+	return s.startRTP + uint32(time.Since(s.start).Seconds()*SampleRate)
+}
+
+// syncLoop tells the speaker every second which timestamp is playing
+func (s *Sender) syncLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	firstSync := true
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		started := !s.first
+		now := s.clockRTP()
+		s.mu.Unlock()
+		if !started {
+			continue
+		}
+
+		packet := make([]byte, 20)
+		packet[0] = 0x80
+		if firstSync {
+			packet[0] = 0x90 // Extension bit on the first sync
+		}
+		packet[1] = 0xd4
+		binary.BigEndian.PutUint16(packet[2:], 7)
+		binary.BigEndian.PutUint32(packet[4:], now-latency)
+		putNTP(packet[8:], time.Now())
+		binary.BigEndian.PutUint32(packet[16:], now)
+		s.control.WriteToUDP(packet, s.ctrlTo)
+		firstSync = false
+	}
+}
+
+// answerTiming replies to the speaker's clock requests
+func (s *Sender) answerTiming() {
+	buf := make([]byte, 128)
+	for {
+		n, from, err := s.timing.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		received := time.Now()
+		if n < 32 || buf[1]&0x7f != 0x52 {
+			continue
+		}
+		reply := make([]byte, 32)
+		reply[0] = 0x80
+		reply[1] = 0xd3
+		binary.BigEndian.PutUint16(reply[2:], 7)
+		copy(reply[8:16], buf[24:32]) // Their send time
+		putNTP(reply[16:], received)
+		putNTP(reply[24:], time.Now())
+		s.timing.WriteToUDP(reply, from)
+	}
+}
+
+// drainControl discards retransmit requests on the control channel
+func (s *Sender) drainControl() {
+	buf := make([]byte, 1500)
+	for {
+		if _, _, err := s.control.ReadFromUDP(buf); err != nil {
+			return
+		}
+	}
+}
+
+// putNTP writes an NTP timestamp (seconds since 1900, 32.32 fixed point)
+func putNTP(b []byte, t time.Time) {
+	const ntpEpochOffset = 2208988800
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:], uint32((uint64(t.Nanosecond())<<32)/1e9))
+}
+
+// SetVolume sets the speaker volume (0.0-1.0)
+func (s *Sender) SetVolume(level float64) error {
+	// AirPlay volume is -30 (quiet) to 0 dB, -144 mutes
+	db := -144.0
+	if level > 0 {
+		db = -30 + 30*min(level, 1)
+	}
+	s.rtspMu.Lock()
+	defer s.rtspMu.Unlock()
+	_, err := s.rtsp.do("SET_PARAMETER", s.uri, nil, "text/parameters", fmt.Sprintf("volume: %f\r\n", db))
+	return err
+}
+
+// Close ends the session and releases the connections
+func (s *Sender) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.rtspMu.Lock()
+		if s.rtsp.session != "" {
+			s.rtsp.do("TEARDOWN", s.uri, nil, "", "")
+		}
+		s.rtspMu.Unlock()
+		s.rtsp.conn.Close()
+		for _, c := range []*net.UDPConn{s.audio, s.control, s.timing} {
+			if c != nil {
+				c.Close()
+			}
+		}
+	})
+	return nil
+}
+
+// Done is closed when the session is closed
+func (s *Sender) Done() <-chan struct{} {
+	return s.done
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+const (
+	AreaCheckURL = "https://radiko.jp/area"
+	GeoIPURL     = "http://ip-api.com/json/?fields=status,countryCode,region"
+)
+
+// areaCheckPattern matches the area ID in radiko's area check response,
+// e.g. <span class="JP13">TOKYO JAPAN</span>
+var areaCheckPattern = regexp.MustCompile(`class="(JP\d+|OUT)"`)
+
+// ErrOutsideJapan is returned by DetectArea when the caller is not in Japan
+var ErrOutsideJapan = errors.New("outside of Japan")
+
+// DetectArea returns the area of the caller's IP address from radiko's area
+// check, falling back to a GeoIP lookup when radiko doesn't answer. Outside
+// Japan radiko places no one, which is an error.
+func (c *Client) DetectArea(ctx context.Context) (string, error) {
+	areaID, err := c.radikoArea(ctx)
+	if err != nil && !errors.Is(err, ErrOutsideJapan) {
+		areaID, err = c.geoIPArea(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+	if model.FindAreaByID(areaID) == nil {
+		return "", fmt.Errorf("unknown area: %s", areaID)
+	}
+	return areaID, nil
+}
+
+// radikoArea asks radiko which area the caller is in
+func (c *Client) radikoArea(ctx context.Context) (string, error) {
+	resp, err := c.get(ctx, AreaCheckURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to check area: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to check area: status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	m := areaCheckPattern.FindSubmatch(data)
+	if m == nil {
+		return "", fmt.Errorf("no area in area check response")
+	}
+	if string(m[1]) == "OUT" {
+		return "", ErrOutsideJapan
+	}
+	return string(m[1]), nil
+}
+
+// geoIPArea looks the caller's prefecture up by IP address
+func (c *Client) geoIPArea(ctx context.Context) (string, error) {
+	resp, err := c.get(ctx, GeoIPURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up location: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var geo struct {
+		Status      string `json:"status"`
+		CountryCode string `json:"countryCode"`
+		Region      string `json:"region"` // ISO 3166-2 subdivision, e.g. "13" for Tokyo
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&geo); err != nil {
+		return "", fmt.Errorf("failed to parse location JSON: %w", err)
+	}
+	if geo.Status != "success" {
+		return "", fmt.Errorf("failed to look up location")
+	}
+	if geo.CountryCode != "JP" {
+		return "", ErrOutsideJapan
+	}
+	prefecture, err := strconv.Atoi(geo.Region)
+	if err != nil {
+		return "", fmt.Errorf("unknown prefecture: %s", geo.Region)
+	}
+	return fmt.Sprintf("JP%d", prefecture), nil
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errForbidden is a playlist refused to the auth token
+var errForbidden = errors.New("status code 403")
+
+// AreaError is returned when radiko refuses a station's stream to the area
+// of the auth token. Authenticating in HomeArea plays it.
+type AreaError struct {
+	StationID string
+	AreaID    string // Area of the auth token ("" = unknown)
+	HomeArea  string // Area the station plays in ("" = unknown)
+}
+
+func (e *AreaError) Error() string {
+	msg := fmt.Sprintf("station %s is not available", e.StationID)
+	if e.AreaID != "" {
+		msg += " in " + e.AreaID
+	}
+	if e.HomeArea != "" {
+		msg += fmt.Sprintf(" (plays in %s)", e.HomeArea)
+	}
+	return msg
+}
+
+// newAreaError returns the AreaError of a station refused to a token,
+// looking up the station's home area
+func (c *Client) newAreaError(ctx context.Context, stationID, authToken string) *AreaError {
+	e := &AreaError{StationID: stationID, AreaID: c.tokenArea(authToken)}
+	if home, err := c.GetStationArea(ctx, stationID); err == nil && home != e.AreaID {
+		e.HomeArea = home
+	}
+	return e
+}
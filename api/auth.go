@@ -1,12 +1,15 @@
 package api
 
 import (
+	"context"
 	"encoding/base64"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
-	"radiko-tui/model"
+	"github.com/kanoshiou/radiko-tui/model"
 )
 
 type authInfo struct {
@@ -24,37 +27,48 @@ func init() {
 	fullKeyBin, _ = base64.StdEncoding.DecodeString(fullKeyB64)
 }
 
-func Auth(areaID string) string {
+// authenticate gets a new auth token for an area.
+// Callers go through Token, which caches and renews the tokens.
+func (c *Client) authenticate(ctx context.Context, areaID string) (string, error) {
 	// Generate random device info for this authentication session
 	deviceInfo := model.GenRandomDeviceInfo()
+	key, app := authKeySettings()
 
-	auth := auth1(deviceInfo)
+	auth, err := c.auth1(ctx, app, deviceInfo)
+	if err != nil {
+		return "", err
+	}
 
 	offset, length := auth.offset, auth.length
+	if offset < 0 || length <= 0 || offset+length > len(key) {
+		// Not a key of this app (anymore)
+		return "", fmt.Errorf("auth1: key offset %d and length %d do not fit the auth key", offset, length)
+	}
 
-	// Slice fullKeyBin to get a new byte slice
-	partial := fullKeyBin[offset : offset+length]
+	// Slice the key to get a new byte slice
+	partial := key[offset : offset+length]
 	// Base64 encode partial to get a string
 	partialKey := base64.StdEncoding.EncodeToString(partial)
 
 	auth.partialKey = partialKey
 
-	auth2(auth, areaID, deviceInfo)
-	return auth.token
+	if err := c.auth2(ctx, auth, app, areaID, deviceInfo); err != nil {
+		return "", err
+	}
+	return auth.token, nil
 }
 
-func auth1(deviceInfo model.RandomDeviceInfo) authInfo {
+func (c *Client) auth1(ctx context.Context, app string, deviceInfo model.RandomDeviceInfo) (authInfo, error) {
 	url := "https://radiko.jp/v2/api/auth1"
 	method := "GET"
 
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 
 	if err != nil {
-		return authInfo{}
+		return authInfo{}, err
 	}
 	req.Header.Add("User-Agent", deviceInfo.UserAgent)
-	req.Header.Add("x-radiko-app", "aSmartPhone7a")
+	req.Header.Add("x-radiko-app", app)
 	req.Header.Add("x-radiko-app-version", deviceInfo.AppVersion)
 	req.Header.Add("x-radiko-device", deviceInfo.Device)
 	req.Header.Add("x-radiko-user", deviceInfo.UserID)
@@ -62,25 +76,36 @@ func auth1(deviceInfo model.RandomDeviceInfo) authInfo {
 	req.Header.Add("Host", "radiko.jp")
 	req.Header.Add("Connection", "keep-alive")
 
-	res, err := client.Do(req)
+	res, err := c.do(req)
 	if err != nil {
-		return authInfo{}
+		return authInfo{}, fmt.Errorf("auth1: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return authInfo{}, fmt.Errorf("auth1: status code %d", res.StatusCode)
 	}
 	header := res.Header
+	token := header.Get("x-radiko-authtoken")
+	if token == "" {
+		return authInfo{}, fmt.Errorf("auth1: no auth token in the response")
+	}
 	length, _ := strconv.Atoi(header.Get("x-radiko-keylength"))
 	offset, _ := strconv.Atoi(header.Get("x-radiko-keyoffset"))
-	return authInfo{token: header.Get("x-radiko-authtoken"), length: length, offset: offset}
+	return authInfo{token: token, length: length, offset: offset}, nil
 }
 
-func auth2(auth authInfo, areaID string, deviceInfo model.RandomDeviceInfo) {
-	url := "https://radiko.jp/v2/api/auth2"
+// auth2 activates the token from auth1 for an area. radiko answers with the
+// area it accepted, e.g. "JP13,東京都,tokyo Japan".
+func (c *Client) auth2(ctx context.Context, auth authInfo, app, areaID string, deviceInfo model.RandomDeviceInfo) error {
+	// Premium members authenticate with their session to play any area
+	url := withSession("https://radiko.jp/v2/api/auth2")
 	method := "GET"
 
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 
 	if err != nil {
-		return
+		return err
 	}
 
 	// Generate GPS coordinates based on the area
@@ -94,7 +119,7 @@ func auth2(auth authInfo, areaID string, deviceInfo model.RandomDeviceInfo) {
 	req.Header.Add("sec-ch-ua", "\"Not.A/Brand\";v=\"8\", \"Chromium\";v=\"114\", \"Microsoft Edge\";v=\"114\"")
 	req.Header.Add("sec-ch-ua-mobile", "?0")
 	req.Header.Add("sec-ch-ua-platform", "\"Windows\"")
-	req.Header.Add("x-radiko-app", "aSmartPhone7a")
+	req.Header.Add("x-radiko-app", app)
 	req.Header.Add("x-radiko-app-version", deviceInfo.AppVersion)
 	req.Header.Add("x-radiko-authtoken", auth.token)
 	req.Header.Add("x-radiko-connection", "wifi")
@@ -105,16 +130,21 @@ func auth2(auth authInfo, areaID string, deviceInfo model.RandomDeviceInfo) {
 	req.Header.Add("Accept", "*/*")
 	req.Header.Add("Host", "radiko.jp")
 
-	res, err := client.Do(req)
+	res, err := c.do(req)
 	if err != nil {
-		return
+		return fmt.Errorf("auth2: %w", err)
 	}
 	defer res.Body.Close()
 
-	body, err := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("auth2: status code %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return
+		return fmt.Errorf("auth2: failed to read response body: %w", err)
+	}
+	if strings.TrimSpace(string(body)) == "" {
+		return fmt.Errorf("auth2: no area in the response")
 	}
-	// Silently process response, do not output to terminal
-	_ = body
+	return nil
 }
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/kanoshiou/radiko-tui/radikotest"
+)
+
+// testClient starts a fake radiko and returns a client of it, without a
+// rate limit
+func testClient(t *testing.T) (*Client, *radikotest.Server) {
+	t.Helper()
+	srv := radikotest.NewServer()
+	t.Cleanup(srv.Close)
+	c := NewClient(srv.Client(), "")
+	c.SetRateLimit(0, 1)
+	return c, srv
+}
+
+// roundTripFunc is an http.RoundTripper of a function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestAuthenticate(t *testing.T) {
+	for _, areaID := range []string{"JP13", "JP27"} {
+		t.Run(areaID, func(t *testing.T) {
+			c, srv := testClient(t)
+
+			token, err := c.authenticate(context.Background(), areaID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !srv.ValidToken(token) {
+				t.Errorf("token %q was not accepted by auth2", token)
+			}
+			if got := srv.TokenArea(token); got != areaID {
+				t.Errorf("token area = %q, want %q", got, areaID)
+			}
+			if n := srv.Requests("/v2/api/auth1"); n != 1 {
+				t.Errorf("auth1 requests = %d, want 1", n)
+			}
+			if n := srv.Requests("/v2/api/auth2"); n != 1 {
+				t.Errorf("auth2 requests = %d, want 1", n)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRejected(t *testing.T) {
+	c, srv := testClient(t)
+	ctx := context.Background()
+	// auth2 without the partial key is refused
+	next := srv.Client().Transport
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/v2/api/auth2" {
+			req = req.Clone(req.Context())
+			req.Header.Del("X-Radiko-Partialkey")
+		}
+		return next.RoundTrip(req)
+	})}
+	c.SetHTTPClient(client, "")
+
+	if token, err := c.authenticate(ctx, "JP13"); err == nil {
+		t.Fatalf("authenticate = %q, want an error", token)
+	}
+	// A failed authentication is not cached
+	for range 2 {
+		if _, err := c.Token(ctx, "JP13"); err == nil {
+			t.Fatal("Token succeeded with auth2 refused")
+		}
+	}
+	if n := srv.Requests("/v2/api/auth1"); n != 3 {
+		t.Errorf("auth1 requests = %d, want 3", n)
+	}
+}
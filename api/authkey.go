@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// DefaultAuthApp is the radiko app (x-radiko-app) whose key is built in
+const DefaultAuthApp = "aSmartPhone7a"
+
+// AuthKeyOptions replaces the key that partial keys are cut from, for when
+// radiko rotates it before a new release ships. The first of Key, File and
+// URL that is set is used.
+type AuthKeyOptions struct {
+	Key  string // Key in base64
+	File string // File holding the key, raw or in base64, e.g. extracted from radiko's app
+	URL  string // URL serving the key, raw or in base64, fetched when the options are set
+	App  string // x-radiko-app the key belongs to (empty = DefaultAuthApp)
+}
+
+var (
+	authKeyMu sync.RWMutex
+	authKey   []byte // nil = fullKeyBin
+	authApp   = DefaultAuthApp
+)
+
+// SetAuthKey makes authentication use another key. Options without a key
+// go back to the built-in one.
+func SetAuthKey(ctx context.Context, opts AuthKeyOptions) error {
+	var key []byte
+	switch {
+	case opts.Key != "":
+		decoded, err := base64.StdEncoding.DecodeString(opts.Key)
+		if err != nil {
+			return fmt.Errorf("invalid auth key: %w", err)
+		}
+		key = decoded
+	case opts.File != "":
+		data, err := os.ReadFile(opts.File)
+		if err != nil {
+			return fmt.Errorf("failed to read auth key: %w", err)
+		}
+		key = decodeKey(data)
+	case opts.URL != "":
+		data, err := defaultClient.fetchBody(ctx, opts.URL)
+		if err != nil {
+			return fmt.Errorf("failed to fetch auth key: %w", err)
+		}
+		key = decodeKey(data)
+	}
+	if key != nil && len(key) == 0 {
+		return fmt.Errorf("auth key is empty")
+	}
+
+	app := opts.App
+	if app == "" {
+		app = DefaultAuthApp
+	}
+	authKeyMu.Lock()
+	authKey, authApp = key, app
+	authKeyMu.Unlock()
+	return nil
+}
+
+// authKeySettings returns the key partial keys are cut from and its app
+func authKeySettings() ([]byte, string) {
+	authKeyMu.RLock()
+	defer authKeyMu.RUnlock()
+	if authKey == nil {
+		return fullKeyBin, authApp
+	}
+	return authKey, authApp
+}
+
+// decodeKey returns a key file's bytes, decoded when they are base64
+func decodeKey(data []byte) []byte {
+	text := bytes.TrimSpace(data)
+	if decoded, err := base64.StdEncoding.DecodeString(string(text)); err == nil && len(decoded) > 0 {
+		return decoded
+	}
+	return data
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures the disk cache of station lists and program guides
+type CacheOptions struct {
+	Dir         string        // Cache directory (empty = no cache)
+	StationsTTL time.Duration // How long a station list is served without asking radiko
+	ProgramsTTL time.Duration // How long a program guide is served without asking radiko
+}
+
+// DefaultCacheOptions keeps station lists for a day and program guides for
+// an hour, without a directory
+var DefaultCacheOptions = CacheOptions{
+	StationsTTL: 24 * time.Hour,
+	ProgramsTTL: time.Hour,
+}
+
+// cacheSlowAfter is how long radiko may take before a stale cached response
+// is served instead
+const cacheSlowAfter = 5 * time.Second
+
+var (
+	cacheMu   sync.RWMutex
+	cacheOpts = DefaultCacheOptions
+)
+
+// SetCache sets the disk cache of station lists and program guides
+func SetCache(opts CacheOptions) error {
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+			return fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+	cacheMu.Lock()
+	cacheOpts = opts
+	cacheMu.Unlock()
+	return nil
+}
+
+// cacheSettings returns the cache options in use
+func cacheSettings() CacheOptions {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
+	return cacheOpts
+}
+
+// CacheStatus tells whether responses were served from the disk cache
+// because radiko failed or was slow
+type CacheStatus struct {
+	mu     sync.Mutex
+	cached bool
+	stored time.Time // When the oldest cached response served was fetched
+}
+
+type cacheStatusKey struct{}
+
+// WithCacheStatus returns a context whose requests report cached responses
+// to the returned status
+func WithCacheStatus(ctx context.Context) (context.Context, *CacheStatus) {
+	s := &CacheStatus{}
+	return context.WithValue(ctx, cacheStatusKey{}, s), s
+}
+
+// Cached reports whether a cached response was served, and when it was
+// fetched from radiko
+func (s *CacheStatus) Cached() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stored, s.cached
+}
+
+// record notes a cached response fetched at stored
+func (s *CacheStatus) record(stored time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.cached || stored.Before(s.stored) {
+		s.stored = stored
+	}
+	s.cached = true
+}
+
+// validators are the ETag and Last-Modified of a cached body, sent back to
+// radiko to ask whether it changed
+type validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// fetchCached fetches a URL and parses its body. A cached body younger
+// than ttl is parsed without asking radiko; an older one is validated with
+// a conditional request, and parsed when radiko fails or takes longer than
+// cacheSlowAfter. Only bodies that parse are cached.
+func (c *Client) fetchCached(ctx context.Context, url string, ttl time.Duration, parse func([]byte) error) error {
+	dir := cacheSettings().Dir
+	if dir == "" || ttl <= 0 {
+		data, err := c.fetchBody(ctx, url)
+		if err != nil {
+			return err
+		}
+		return parse(data)
+	}
+
+	sum := sha256.Sum256([]byte(url))
+	path := filepath.Join(dir, hex.EncodeToString(sum[:]))
+	cached, err := os.ReadFile(path)
+	usable := err == nil && parse(cached) == nil
+	var stored time.Time
+	if usable {
+		if info, err := os.Stat(path); err == nil {
+			stored = info.ModTime()
+		}
+		if time.Since(stored) < ttl {
+			return nil
+		}
+	}
+
+	fetchCtx := ctx
+	var sent validators
+	if usable {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithTimeout(ctx, cacheSlowAfter)
+		defer cancel()
+		sent = readValidators(path)
+	}
+	data, received, err := c.fetchConditional(fetchCtx, url, sent)
+	if err == nil {
+		if data == nil {
+			// Not modified: the cached body is current again
+			now := time.Now()
+			os.Chtimes(path, now, now)
+			return nil
+		}
+		if err = parse(data); err == nil {
+			writeCached(path, data, received)
+			return nil
+		}
+	}
+	if !usable || ctx.Err() != nil || parse(cached) != nil {
+		return err
+	}
+	if s, ok := ctx.Value(cacheStatusKey{}).(*CacheStatus); ok {
+		s.record(stored)
+	}
+	return nil
+}
+
+// fetchBody fetches a URL that must answer 200 OK
+func (c *Client) fetchBody(ctx context.Context, url string) ([]byte, error) {
+	data, _, err := c.fetchConditional(ctx, url, validators{})
+	return data, err
+}
+
+// fetchConditional fetches a URL unless it still matches the validators,
+// returning its body and validators, or a nil body when it is unchanged
+func (c *Client) fetchConditional(ctx context.Context, url string, v validators) ([]byte, validators, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, validators{}, err
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, validators{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && v != (validators{}) {
+		return nil, v, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, validators{}, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, validators{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return data, validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, nil
+}
+
+// readValidators returns the validators stored beside a cached body
+func readValidators(path string) validators {
+	var v validators
+	if data, err := os.ReadFile(path + ".meta"); err == nil {
+		json.Unmarshal(data, &v)
+	}
+	return v
+}
+
+// writeCached stores a body with its validators. The old validators go
+// first, so a body is never validated with another body's.
+func writeCached(path string, data []byte, v validators) {
+	os.Remove(path + ".meta")
+	if !writeCache(path, data) || v == (validators{}) {
+		return
+	}
+	if meta, err := json.Marshal(v); err == nil {
+		writeCache(path+".meta", meta)
+	}
+}
+
+// writeCache stores a file, replacing the cached one at once so readers
+// never see it half written, and reports whether it did. Failures only
+// cost the cache.
+func writeCache(path string, data []byte) bool {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return false
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err == nil
+}
@@ -1,50 +1,185 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
-	"radiko-tui/model"
+	"github.com/kanoshiou/radiko-tui/model"
 )
 
 const (
-	StationListURLFmt = "https://api.radiko.jp/program/v3/now/%s.xml"
+	StationListURLFmt = "https://radiko.jp/v3/station/list/%s.xml"
+	AllStationsURL    = "https://radiko.jp/v3/station/region/full.xml"
 	StreamURLFmt      = "https://radiko.jp/v3/station/stream/pc_html5/%s.xml"
+	StationLogoURLFmt = "https://radiko.jp/v2/static/station/logo/%s/224x100.png"
 )
 
-// GetStations retrieves the list of stations for a specified area
-func GetStations(areaID string) ([]model.Station, error) {
-	url := fmt.Sprintf(StationListURLFmt, areaID)
-	resp, err := http.Get(url)
+// GetStationLogoURL returns the logo image URL for a station
+func GetStationLogoURL(stationID string) string {
+	return fmt.Sprintf(StationLogoURLFmt, stationID)
+}
+
+// GetStations retrieves the list of stations for a specified area, with
+// their logos, banner, website and areafree/timefree flags
+func (c *Client) GetStations(ctx context.Context, areaID string) ([]model.Station, error) {
+	var radikoStations model.RadikoStations
+	err := c.fetchCached(ctx, fmt.Sprintf(StationListURLFmt, areaID), cacheSettings().StationsTTL, func(data []byte) error {
+		var parsed model.RadikoStations
+		if err := xml.Unmarshal(data, &parsed); err != nil {
+			return fmt.Errorf("failed to parse station list XML: %w", err)
+		}
+		radikoStations = parsed
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch station list: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch station list: status code %d", resp.StatusCode)
+	return radikoStations.Stations, nil
+}
+
+// GetAllStations retrieves the stations of every area, each with its home
+// area, for radiko premium (area-free) members
+func (c *Client) GetAllStations(ctx context.Context) ([]model.Station, error) {
+	regions, err := c.fetchRegions(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	// Stations heard in several areas are listed once
+	var stations []model.Station
+	seen := make(map[string]bool)
+	for _, region := range regions.Regions {
+		for _, station := range region.Stations {
+			if !seen[station.ID] {
+				seen[station.ID] = true
+				stations = append(stations, station)
+			}
+		}
+	}
+	return stations, nil
+}
+
+// fetchRegions fetches the station list of every area
+func (c *Client) fetchRegions(ctx context.Context) (model.RadikoRegions, error) {
+	var regions model.RadikoRegions
+	err := c.fetchCached(ctx, AllStationsURL, cacheSettings().StationsTTL, func(data []byte) error {
+		var parsed model.RadikoRegions
+		if err := xml.Unmarshal(data, &parsed); err != nil {
+			return fmt.Errorf("failed to parse station list XML: %w", err)
+		}
+		regions = parsed
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return model.RadikoRegions{}, fmt.Errorf("failed to fetch station list: %w", err)
 	}
+	return regions, nil
+}
 
-	var radikoStations model.RadikoStations
-	if err := xml.Unmarshal(data, &radikoStations); err != nil {
-		return nil, fmt.Errorf("failed to parse station list XML: %w", err)
+var (
+	stationAreasMu      sync.Mutex
+	stationAreas        map[string]string // Station ID → home area ID
+	stationAreasExpires time.Time
+	lookedUpAreas       = make(map[string]string) // Station ID → area ID, of stations missing from stationAreas
+)
+
+// GetStationAreas returns the home area of every station, from radiko's
+// list of every area. It is kept in memory for the StationsTTL of the
+// cache options, and concurrent callers share one fetch.
+func (c *Client) GetStationAreas(ctx context.Context) (map[string]string, error) {
+	stationAreasMu.Lock()
+	defer stationAreasMu.Unlock()
+	if stationAreas != nil && time.Now().Before(stationAreasExpires) {
+		return stationAreas, nil
 	}
 
-	return radikoStations.Stations, nil
+	regions, err := c.fetchRegions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	areas := make(map[string]string)
+	for _, region := range regions.Regions {
+		for _, station := range region.Stations {
+			areaID := station.AreaID
+			if areaID == "" {
+				areaID = region.AreaID
+			}
+			if _, ok := areas[station.ID]; !ok && areaID != "" {
+				areas[station.ID] = areaID
+			}
+		}
+	}
+
+	ttl := cacheSettings().StationsTTL
+	if ttl <= 0 {
+		ttl = DefaultCacheOptions.StationsTTL
+	}
+	stationAreas, stationAreasExpires = areas, time.Now().Add(ttl)
+	return areas, nil
+}
+
+// Stream is a live stream of a station
+type Stream struct {
+	URL      string    // Master playlist URL, ready to play with the auth token
+	AreaFree bool      // Plays outside the station's area (radiko premium)
+	Variants []Variant // Qualities of the master playlist, empty when it has none or couldn't be read
+}
+
+// GetStreamURLs returns the live streams of a station in radiko's order,
+// with the variants of each master playlist. A premium session gets only
+// the area-free streams when the station has them. When radiko refuses
+// every stream to the token's area, the error is an *AreaError.
+func (c *Client) GetStreamURLs(ctx context.Context, stationID, authToken string) ([]Stream, error) {
+	urls, err := c.playlistCreateURLs(ctx, stationID, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no stream URLs found for station %s", stationID)
+	}
+
+	streams := make([]Stream, len(urls))
+	lsid := model.GenLsid()
+	for i, u := range urls {
+		streams[i] = Stream{
+			URL:      fmt.Sprintf("%s?station_id=%s&l=30&lsid=%s&type=b", u.PlaylistCreateURL, stationID, lsid),
+			AreaFree: u.AreaFree == 1,
+		}
+	}
+
+	// A playlist whose variants can't be read still plays as it is, unless
+	// it was refused
+	var wg sync.WaitGroup
+	errs := make([]error, len(streams))
+	for i := range streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			streams[i].Variants, errs[i] = c.GetVariants(ctx, streams[i].URL, authToken)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if !errors.Is(err, errForbidden) {
+			return streams, nil
+		}
+	}
+	return nil, c.newAreaError(ctx, stationID, authToken)
 }
 
-func GetStreamURLs(stationID string) ([]string, error) {
-	url := fmt.Sprintf(StreamURLFmt, stationID)
-	resp, err := http.Get(url)
+// playlistCreateURLs returns a station's live or timefree playlist URLs
+// in radiko's order. Outside the station's area only the area-free URLs
+// play, which a premium session gets alone when the station has them.
+func (c *Client) playlistCreateURLs(ctx context.Context, stationID string, timefree bool) ([]model.URL, error) {
+	resp, err := c.get(ctx, fmt.Sprintf(StreamURLFmt, stationID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch stream URL for station %s: %w", stationID, err)
 	}
@@ -64,17 +199,19 @@ func GetStreamURLs(stationID string) ([]string, error) {
 		return nil, fmt.Errorf("failed to parse stream URL XML: %w", err)
 	}
 
-	if len(radikoURLs.URLs) == 0 {
-		return nil, fmt.Errorf("no stream URLs found for station %s", stationID)
-	}
-
-	var urls []string
+	var urls, areaFreeURLs []model.URL
 	for _, u := range radikoURLs.URLs {
-		if u.PlaylistCreateURL != "" {
-			urls = append(urls, u.PlaylistCreateURL)
+		if u.PlaylistCreateURL == "" || (u.TimeFree == 1) != timefree {
+			continue
+		}
+		urls = append(urls, u)
+		if u.AreaFree == 1 {
+			areaFreeURLs = append(areaFreeURLs, u)
 		}
 	}
-
+	if AreaFree() && len(areaFreeURLs) > 0 {
+		return areaFreeURLs, nil
+	}
 	return urls, nil
 }
 
@@ -89,13 +226,13 @@ func init() {
 }
 
 // GetCurrentProgram retrieves the current program for a station
-func GetCurrentProgram(stationID string) (*model.Program, error) {
+func (c *Client) GetCurrentProgram(ctx context.Context, stationID string) (*model.Program, error) {
 	now := time.Now().In(jst)
 	dateStr := now.Format("20060102")
 	timeStr := now.Format("20060102150405")
 
 	// Try to get program for current date
-	prog, err := getProgramForDate(stationID, dateStr, timeStr)
+	prog, err := c.getProgramForDate(ctx, stationID, dateStr, timeStr)
 	if err != nil {
 		return nil, err
 	}
@@ -110,7 +247,7 @@ func GetCurrentProgram(stationID string) (*model.Program, error) {
 	yesterday := now.AddDate(0, 0, -1)
 	yesterdayStr := yesterday.Format("20060102")
 
-	prog, err = getProgramForDate(stationID, yesterdayStr, timeStr)
+	prog, err = c.getProgramForDate(ctx, stationID, yesterdayStr, timeStr)
 	if err != nil {
 		return nil, err
 	}
@@ -118,26 +255,43 @@ func GetCurrentProgram(stationID string) (*model.Program, error) {
 	return prog, nil
 }
 
-// getProgramForDate retrieves program data for a specific date and finds the current program
-func getProgramForDate(stationID, dateStr, timeStr string) (*model.Program, error) {
-	url := fmt.Sprintf(ProgramURLFmt, dateStr, stationID)
-	resp, err := http.Get(url)
+// GetPrograms retrieves the program schedule of a station for a broadcast date
+func (c *Client) GetPrograms(ctx context.Context, stationID string, date time.Time) ([]model.Program, error) {
+	progResp, err := c.fetchProgramJSON(ctx, fmt.Sprintf(ProgramURLFmt, date.In(jst).Format("20060102"), stationID))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to fetch programs: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	for _, station := range progResp.Stations {
+		if station.StationID == stationID {
+			return station.Programs.Program, nil
+		}
 	}
+	return nil, fmt.Errorf("no programs found for station %s", stationID)
+}
 
-	data, err := io.ReadAll(resp.Body)
+// fetchProgramJSON fetches and parses a program JSON, from the cache when
+// fresh
+func (c *Client) fetchProgramJSON(ctx context.Context, url string) (*model.ProgramResponse, error) {
+	var progResp model.ProgramResponse
+	err := c.fetchCached(ctx, url, cacheSettings().ProgramsTTL, func(data []byte) error {
+		var parsed model.ProgramResponse
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return fmt.Errorf("failed to parse program JSON: %w", err)
+		}
+		progResp = parsed
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return &progResp, nil
+}
 
-	var progResp model.ProgramResponse
-	if err := json.Unmarshal(data, &progResp); err != nil {
+// getProgramForDate retrieves program data for a specific date and finds the current program
+func (c *Client) getProgramForDate(ctx context.Context, stationID, dateStr, timeStr string) (*model.Program, error) {
+	progResp, err := c.fetchProgramJSON(ctx, fmt.Sprintf(ProgramURLFmt, dateStr, stationID))
+	if err != nil {
 		return nil, err
 	}
 
@@ -169,7 +323,7 @@ func getProgramForDate(stationID, dateStr, timeStr string) (*model.Program, erro
 
 // BatchStationResponse represents the response from batchGetStations API
 type BatchStationResponse struct {
-	OK          bool             `json:"ok"`
+	OK          bool               `json:"ok"`
 	StationList []BatchStationInfo `json:"stationList"`
 }
 
@@ -180,11 +334,39 @@ type BatchStationInfo struct {
 	PrefecturesList []string `json:"prefecturesList"`
 }
 
-// GetStationArea retrieves the area ID for a given station
-// Returns the first available prefecture from prefecturesList
-func GetStationArea(stationID string) (string, error) {
+// GetStationArea returns the home area of a station, looked up in
+// GetStationAreas. Stations missing from it, such as ones that started
+// after the list was fetched, are asked about one by one and remembered.
+func (c *Client) GetStationArea(ctx context.Context, stationID string) (string, error) {
+	if areas, err := c.GetStationAreas(ctx); err == nil {
+		if areaID, ok := areas[stationID]; ok {
+			return areaID, nil
+		}
+	} else if ctx.Err() != nil {
+		return "", err
+	}
+
+	stationAreasMu.Lock()
+	areaID, ok := lookedUpAreas[stationID]
+	stationAreasMu.Unlock()
+	if ok {
+		return areaID, nil
+	}
+	areaID, err := c.lookupStationArea(ctx, stationID)
+	if err != nil {
+		return "", err
+	}
+	stationAreasMu.Lock()
+	lookedUpAreas[stationID] = areaID
+	stationAreasMu.Unlock()
+	return areaID, nil
+}
+
+// lookupStationArea asks radiko for the area of one station, returning the
+// first of its prefecturesList
+func (c *Client) lookupStationArea(ctx context.Context, stationID string) (string, error) {
 	url := fmt.Sprintf("https://radiko.jp/api/stations/batchGetStations?stationId=%s", stationID)
-	resp, err := http.Get(url)
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch station info: %w", err)
 	}
@@ -216,3 +398,21 @@ func GetStationArea(stationID string) (string, error) {
 	return prefectures[0], nil
 }
 
+// Ping checks that radiko's API is reachable within timeout
+func (c *Client) Ping(ctx context.Context, timeout time.Duration) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(StationListURLFmt, "JP13"), nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: c.HTTPClient().Transport, Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("radiko unreachable: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("radiko unreachable: status code %d", resp.StatusCode)
+	}
+	return nil
+}
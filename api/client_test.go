@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// stationIDs returns the IDs of stations
+func stationIDs(stations []model.Station) []string {
+	ids := make([]string, len(stations))
+	for i, st := range stations {
+		ids[i] = st.ID
+	}
+	return ids
+}
+
+func TestGetStations(t *testing.T) {
+	c, _ := testClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		areaID string
+		want   []string
+	}{
+		{"JP13", []string{"TBS", "QRR"}},
+		{"JP27", []string{"ABC"}},
+	}
+	for _, tt := range tests {
+		stations, err := c.GetStations(ctx, tt.areaID)
+		if err != nil {
+			t.Errorf("GetStations(%s): %v", tt.areaID, err)
+			continue
+		}
+		if got := stationIDs(stations); !slices.Equal(got, tt.want) {
+			t.Errorf("GetStations(%s) = %v, want %v", tt.areaID, got, tt.want)
+		}
+	}
+
+	stations, err := c.GetStations(ctx, "JP13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := stations[0]; st.Name != "TBSラジオ" || st.AsciiName != "TBS RADIO" || !st.AreaFree {
+		t.Errorf("TBS = %+v", st)
+	}
+}
+
+func TestGetStationArea(t *testing.T) {
+	c, _ := testClient(t)
+	ctx := context.Background()
+
+	for stationID, want := range map[string]string{"TBS": "JP13", "ABC": "JP27"} {
+		got, err := c.GetStationArea(ctx, stationID)
+		if err != nil {
+			t.Errorf("GetStationArea(%s): %v", stationID, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("GetStationArea(%s) = %q, want %q", stationID, got, want)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	c, srv := testClient(t)
+
+	if err := c.Ping(context.Background(), 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests("/v3/station/list/JP13.xml"); n != 1 {
+		t.Errorf("station list requests = %d, want 1", n)
+	}
+}
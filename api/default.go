@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// The package-level functions make their requests with the default client
+
+// DetectArea calls Client.DetectArea with the default client
+func DetectArea(ctx context.Context) (string, error) {
+	return defaultClient.DetectArea(ctx)
+}
+
+// GetStations calls Client.GetStations with the default client
+func GetStations(ctx context.Context, areaID string) ([]model.Station, error) {
+	return defaultClient.GetStations(ctx, areaID)
+}
+
+// GetAllStations calls Client.GetAllStations with the default client
+func GetAllStations(ctx context.Context) ([]model.Station, error) {
+	return defaultClient.GetAllStations(ctx)
+}
+
+// GetStationAreas calls Client.GetStationAreas with the default client
+func GetStationAreas(ctx context.Context) (map[string]string, error) {
+	return defaultClient.GetStationAreas(ctx)
+}
+
+// GetStationArea calls Client.GetStationArea with the default client
+func GetStationArea(ctx context.Context, stationID string) (string, error) {
+	return defaultClient.GetStationArea(ctx, stationID)
+}
+
+// Token calls Client.Token with the default client
+func Token(ctx context.Context, areaID string) (string, error) {
+	return defaultClient.Token(ctx, areaID)
+}
+
+// InvalidateToken calls Client.InvalidateToken with the default client
+func InvalidateToken(areaID string) {
+	defaultClient.InvalidateToken(areaID)
+}
+
+// SubscribeToken calls Client.SubscribeToken with the default client
+func SubscribeToken(areaID string, refresh func(token string)) (unsubscribe func()) {
+	return defaultClient.SubscribeToken(areaID, refresh)
+}
+
+// RenewTokens calls Client.RenewTokens with the default client
+func RenewTokens(ctx context.Context) error {
+	return defaultClient.RenewTokens(ctx)
+}
+
+// GetStreamURLs calls Client.GetStreamURLs with the default client
+func GetStreamURLs(ctx context.Context, stationID, authToken string) ([]Stream, error) {
+	return defaultClient.GetStreamURLs(ctx, stationID, authToken)
+}
+
+// GetVariants calls Client.GetVariants with the default client
+func GetVariants(ctx context.Context, playlistURL, authToken string) ([]Variant, error) {
+	return defaultClient.GetVariants(ctx, playlistURL, authToken)
+}
+
+// GetTimefreeStreamURL calls Client.GetTimefreeStreamURL with the default client
+func GetTimefreeStreamURL(ctx context.Context, stationID string, ft, to time.Time) (string, error) {
+	return defaultClient.GetTimefreeStreamURL(ctx, stationID, ft, to)
+}
+
+// GetCurrentProgram calls Client.GetCurrentProgram with the default client
+func GetCurrentProgram(ctx context.Context, stationID string) (*model.Program, error) {
+	return defaultClient.GetCurrentProgram(ctx, stationID)
+}
+
+// GetNowPrograms calls Client.GetNowPrograms with the default client
+func GetNowPrograms(ctx context.Context, areaID string) ([]model.StationSchedule, error) {
+	return defaultClient.GetNowPrograms(ctx, areaID)
+}
+
+// GetTodayPrograms calls Client.GetTodayPrograms with the default client
+func GetTodayPrograms(ctx context.Context, stationID string) ([]model.Program, error) {
+	return defaultClient.GetTodayPrograms(ctx, stationID)
+}
+
+// GetPrograms calls Client.GetPrograms with the default client
+func GetPrograms(ctx context.Context, stationID string, date time.Time) ([]model.Program, error) {
+	return defaultClient.GetPrograms(ctx, stationID, date)
+}
+
+// GetWeeklyPrograms calls Client.GetWeeklyPrograms with the default client
+func GetWeeklyPrograms(ctx context.Context, stationID string) ([]model.Program, error) {
+	return defaultClient.GetWeeklyPrograms(ctx, stationID)
+}
+
+// SearchPrograms calls Client.SearchPrograms with the default client
+func SearchPrograms(ctx context.Context, q SearchQuery) ([]model.SearchResult, error) {
+	return defaultClient.SearchPrograms(ctx, q)
+}
+
+// GetNowOnAir calls Client.GetNowOnAir with the default client
+func GetNowOnAir(ctx context.Context, stationID string) (*model.Track, error) {
+	return defaultClient.GetNowOnAir(ctx, stationID)
+}
+
+// GetSongs calls Client.GetSongs with the default client
+func GetSongs(ctx context.Context, stationID string, from, to time.Time) ([]model.Song, error) {
+	return defaultClient.GetSongs(ctx, stationID, from, to)
+}
+
+// Ping calls Client.Ping with the default client
+func Ping(ctx context.Context, timeout time.Duration) error {
+	return defaultClient.Ping(ctx, timeout)
+}
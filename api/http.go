@@ -0,0 +1,118 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ClientOptions configures the HTTP client of the requests to radiko
+type ClientOptions struct {
+	Proxy     string        // Proxy URL, e.g. "http://proxy.example.jp:8080" (empty = HTTP_PROXY/HTTPS_PROXY/NO_PROXY)
+	Timeout   time.Duration // Bound on each request (0 = none)
+	UserAgent string        // User-Agent of requests that set none (authentication keeps the app's)
+	TLS       *tls.Config   // e.g. extra root CAs for a TLS-inspecting proxy
+}
+
+// Client makes the requests to radiko. Each client has its own HTTP
+// client, auth tokens and rate limit; the package-level functions use a
+// default client, set up with SetClientOptions, SetHTTPClient and
+// SetRateLimit.
+type Client struct {
+	mu      sync.RWMutex
+	http    *http.Client
+	ua      string
+	tokens  *tokenCache
+	limiter *rateLimiter
+}
+
+// NewClient returns a client making its requests with httpClient (nil for
+// http.DefaultClient), with a User-Agent for those that set none ("" for
+// Go's default), at the default rate limit
+func NewClient(httpClient *http.Client, ua string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		http:    httpClient,
+		ua:      ua,
+		tokens:  newTokenCache(),
+		limiter: newRateLimiter(DefaultRateLimit, DefaultRateBurst),
+	}
+}
+
+// defaultClient makes the requests of the package-level functions
+var defaultClient = NewClient(nil, "")
+
+// NewHTTPClient returns a client with the given options
+func NewHTTPClient(opts ClientOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL: %s", opts.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if opts.TLS != nil {
+		transport.TLSClientConfig = opts.TLS
+	}
+	return &http.Client{Transport: transport, Timeout: opts.Timeout}, nil
+}
+
+// SetClientOptions makes every api request use a client with the given
+// options
+func SetClientOptions(opts ClientOptions) error {
+	client, err := NewHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	SetHTTPClient(client, opts.UserAgent)
+	return nil
+}
+
+// SetHTTPClient makes every request of the package-level functions use a
+// client, with a User-Agent for those that set none ("" for Go's default)
+func SetHTTPClient(client *http.Client, ua string) {
+	defaultClient.SetHTTPClient(client, ua)
+}
+
+// SetHTTPClient makes the client's requests use an HTTP client, with a
+// User-Agent for those that set none
+func (c *Client) SetHTTPClient(client *http.Client, ua string) {
+	c.mu.Lock()
+	c.http = client
+	c.ua = ua
+	c.mu.Unlock()
+}
+
+// HTTPClient returns the client of the api requests, for other requests
+// to radiko such as HLS segments
+func HTTPClient() *http.Client {
+	return defaultClient.HTTPClient()
+}
+
+// HTTPClient returns the HTTP client the client's requests use
+func (c *Client) HTTPClient() *http.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.http
+}
+
+// send sends a request once with the client's HTTP client, when its rate
+// limit allows
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if err := c.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	client, ua := c.http, c.ua
+	c.mu.RUnlock()
+	if ua != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	return client.Do(req)
+}
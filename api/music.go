@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// NowOnAirURLFmt is the now-on-air feed URL format (station_id)
+const NowOnAirURLFmt = "https://radiko.jp/v3/feed/pc/noa/%s.xml"
+
+// trackMaxAge is how long after its start a track is taken to be over when
+// the feed has nothing newer, as during talk
+const trackMaxAge = 15 * time.Minute
+
+// SongsURLFmt is the NowOnAir music history URL format (station_id, from, to)
+const SongsURLFmt = "https://api.radiko.jp/music/api/v1/noas/%s?start_time_gte=%s&end_time_lt=%s"
+
+// GetSongs retrieves the songs a station played between from and to
+func (c *Client) GetSongs(ctx context.Context, stationID string, from, to time.Time) ([]model.Song, error) {
+	songsURL := fmt.Sprintf(SongsURLFmt, stationID,
+		url.QueryEscape(from.In(jst).Format(time.RFC3339)),
+		url.QueryEscape(to.In(jst).Format(time.RFC3339)))
+	resp, err := c.get(ctx, songsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch songs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch songs: status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var songResp model.SongResponse
+	if err := json.Unmarshal(data, &songResp); err != nil {
+		return nil, fmt.Errorf("failed to parse songs JSON: %w", err)
+	}
+	return songResp.Data, nil
+}
+
+// GetNowOnAir returns the track a station is playing, or nil when it is
+// not playing music
+func (c *Client) GetNowOnAir(ctx context.Context, stationID string) (*model.Track, error) {
+	resp, err := c.get(ctx, fmt.Sprintf(NowOnAirURLFmt, stationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch now on air: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch now on air: status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var feed model.NowOnAirFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse now on air XML: %w", err)
+	}
+	if len(feed.Tracks) == 0 || feed.Tracks[0].Title == "" {
+		return nil, nil
+	}
+	track := feed.Tracks[0]
+	if start, err := track.StartTime(); err == nil && time.Since(start) > trackMaxAge {
+		return nil, nil
+	}
+	return &track, nil
+}
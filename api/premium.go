@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	LoginURL  = "https://radiko.jp/v4/api/member/login"
+	LogoutURL = "https://radiko.jp/v4/api/member/logout"
+)
+
+// Session is a logged in radiko premium member
+type Session struct {
+	ID       string // radiko_session cookie
+	AreaFree bool   // Stations of every area can be played
+}
+
+var (
+	sessionMu sync.RWMutex
+	session   *Session
+)
+
+// Login logs in to radiko premium with the member's email and password,
+// with the default client. The session is used by the authentications of
+// every client until Logout.
+func Login(ctx context.Context, email, password string) (*Session, error) {
+	resp, err := defaultClient.postForm(ctx, LoginURL, url.Values{"mail": {email}, "pass": {password}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to log in: status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var login struct {
+		RadikoSession string      `json:"radiko_session"`
+		AreaFree      json.Number `json:"areafree"`
+	}
+	if err := json.Unmarshal(data, &login); err != nil {
+		return nil, fmt.Errorf("failed to parse login JSON: %w", err)
+	}
+	if login.RadikoSession == "" {
+		return nil, fmt.Errorf("login rejected: check the email and password")
+	}
+
+	s := &Session{ID: login.RadikoSession, AreaFree: login.AreaFree == "1"}
+	sessionMu.Lock()
+	session = s
+	sessionMu.Unlock()
+	defaultClient.clearTokens() // Tokens from before the login are bound to one area
+	return s, nil
+}
+
+// Logout ends the premium session, if any
+func Logout(ctx context.Context) error {
+	sessionMu.Lock()
+	s := session
+	session = nil
+	sessionMu.Unlock()
+	if s == nil {
+		return nil
+	}
+	defaultClient.clearTokens()
+
+	resp, err := defaultClient.postForm(ctx, LogoutURL, url.Values{"radiko_session": {s.ID}})
+	if err != nil {
+		return fmt.Errorf("failed to log out: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// CurrentSession returns the premium session, or nil when not logged in
+func CurrentSession() *Session {
+	sessionMu.RLock()
+	defer sessionMu.RUnlock()
+	return session
+}
+
+// AreaFree reports whether the premium session can play every area
+func AreaFree() bool {
+	s := CurrentSession()
+	return s != nil && s.AreaFree
+}
+
+// withSession adds the premium session to a radiko API URL
+func withSession(rawURL string) string {
+	s := CurrentSession()
+	if s == nil {
+		return rawURL
+	}
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + "radiko_session=" + url.QueryEscape(s.ID)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// Program XML API URL formats
+const (
+	NowProgramsURLFmt    = "https://api.radiko.jp/program/v3/now/%s.xml"             // Area ID
+	DateProgramsURLFmt   = "https://api.radiko.jp/program/v3/date/%s/station/%s.xml" // Date YYYYMMDD, station ID
+	WeeklyProgramsURLFmt = "https://api.radiko.jp/program/v3/weekly/%s.xml"          // Station ID
+)
+
+// GetNowPrograms returns the stations of an area with the program each has
+// on air
+func (c *Client) GetNowPrograms(ctx context.Context, areaID string) ([]model.StationSchedule, error) {
+	programs, err := c.fetchPrograms(ctx, fmt.Sprintf(NowProgramsURLFmt, areaID), 0)
+	if err != nil {
+		return nil, err
+	}
+	return programs.Stations, nil
+}
+
+// GetTodayPrograms returns a station's programs of the current broadcast day
+func (c *Client) GetTodayPrograms(ctx context.Context, stationID string) ([]model.Program, error) {
+	day := model.BroadcastDay(time.Now()).Format("20060102")
+	return c.stationPrograms(ctx, fmt.Sprintf(DateProgramsURLFmt, day, stationID), stationID)
+}
+
+// GetWeeklyPrograms returns a station's programs of the past and coming
+// week, oldest first
+func (c *Client) GetWeeklyPrograms(ctx context.Context, stationID string) ([]model.Program, error) {
+	return c.stationPrograms(ctx, fmt.Sprintf(WeeklyProgramsURLFmt, stationID), stationID)
+}
+
+// stationPrograms fetches a program XML and returns the station's programs
+func (c *Client) stationPrograms(ctx context.Context, url, stationID string) ([]model.Program, error) {
+	programs, err := c.fetchPrograms(ctx, url, cacheSettings().ProgramsTTL)
+	if err != nil {
+		return nil, err
+	}
+	for _, station := range programs.Stations {
+		if station.ID == stationID {
+			return station.Programs, nil
+		}
+	}
+	return nil, fmt.Errorf("no programs found for station %s", stationID)
+}
+
+// fetchPrograms fetches and parses a program XML, from the cache while
+// younger than ttl (0 = never)
+func (c *Client) fetchPrograms(ctx context.Context, url string, ttl time.Duration) (*model.RadikoPrograms, error) {
+	var programs model.RadikoPrograms
+	err := c.fetchCached(ctx, url, ttl, func(data []byte) error {
+		var parsed model.RadikoPrograms
+		if err := xml.Unmarshal(data, &parsed); err != nil {
+			return fmt.Errorf("failed to parse program XML: %w", err)
+		}
+		programs = parsed
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch programs: %w", err)
+	}
+	return &programs, nil
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimit and DefaultRateBurst let bursts of 10 requests through,
+// such as loading a station list with its programs, and 5 per second after
+const (
+	DefaultRateLimit = 5.0
+	DefaultRateBurst = 10
+)
+
+// rateLimiter is a token bucket of a client's requests to radiko
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens added per second (0 = unlimited)
+	burst  float64 // Bucket size
+	tokens float64
+	last   time.Time // When tokens was last brought up to date
+}
+
+// newRateLimiter returns a limiter of rate requests per second, with bursts
+// of up to burst requests. A rate of 0 means no limit.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	l := &rateLimiter{}
+	l.set(rate, burst)
+	return l
+}
+
+// SetRateLimit limits the requests of the package-level functions to rate
+// per second, with bursts of up to burst requests. A rate of 0 removes the
+// limit.
+func SetRateLimit(rate float64, burst int) {
+	defaultClient.SetRateLimit(rate, burst)
+}
+
+// SetRateLimit limits the client's requests to rate per second, with bursts
+// of up to burst requests. A rate of 0 removes the limit.
+func (c *Client) SetRateLimit(rate float64, burst int) {
+	c.limiter.set(rate, burst)
+}
+
+// set changes the rate and burst, starting with a full bucket
+func (l *rateLimiter) set(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = float64(max(burst, 1))
+	l.tokens = l.burst
+	l.last = time.Time{}
+}
+
+// wait takes a token, waiting for one until ctx ends
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	// Taking the token ahead keeps waiters in line: each one waits for its own
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the token back for the requests still waiting
+		l.mu.Lock()
+		l.tokens = min(l.burst, l.tokens+1)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
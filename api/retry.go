@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryPolicy decides how requests to radiko are retried after network
+// errors and passing server errors
+type RetryPolicy struct {
+	Retries    int           // Attempts after the first (0 = no retries)
+	Backoff    time.Duration // Delay before the first retry, doubled for each next one
+	MaxBackoff time.Duration // Longest delay between attempts
+	Jitter     float64       // Fraction of each delay added or removed at random (0-1)
+	RetryOn    []int         // Status codes worth retrying
+}
+
+// DefaultRetryPolicy retries three times over about 3.5 seconds
+var DefaultRetryPolicy = RetryPolicy{
+	Retries:    3,
+	Backoff:    500 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+	Jitter:     0.2,
+	RetryOn:    []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+var (
+	retryMu     sync.RWMutex
+	retryPolicy = DefaultRetryPolicy
+)
+
+// SetRetryPolicy sets how every api request is retried
+func SetRetryPolicy(p RetryPolicy) {
+	retryMu.Lock()
+	retryPolicy = p
+	retryMu.Unlock()
+}
+
+// delay returns the wait before retry n (0 for the first), honoring a
+// Retry-After in seconds from the previous response
+func (p RetryPolicy) delay(n int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			d := time.Duration(secs) * time.Second
+			if p.MaxBackoff > 0 && d > p.MaxBackoff {
+				d = p.MaxBackoff
+			}
+			return d
+		}
+	}
+	d := p.Backoff << n
+	if p.MaxBackoff > 0 && (d > p.MaxBackoff || d < 0) {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// do sends a request, retrying it as the retry policy says. A request with
+// a body is retried only when it can be rewound.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	retryMu.RLock()
+	p := retryPolicy
+	retryMu.RUnlock()
+
+	for n := 0; ; n++ {
+		resp, err := c.send(req)
+		retry := err != nil || slices.Contains(p.RetryOn, resp.StatusCode)
+		if !retry || n >= p.Retries || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		wait := p.delay(n, resp)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// get fetches a URL with retries
+func (c *Client) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+// postForm posts a form with retries
+func (c *Client) postForm(ctx context.Context, rawURL string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req)
+}
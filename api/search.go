@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+const SearchURL = "https://radiko.jp/v3/api/program/search"
+
+// SearchFilter limits a program search to live or timefree programs
+type SearchFilter string
+
+const (
+	SearchAll      SearchFilter = ""       // Past and upcoming programs
+	SearchLive     SearchFilter = "future" // Programs on air now or later
+	SearchTimefree SearchFilter = "past"   // Programs that can be played with timefree
+)
+
+// SearchQuery is a program search
+type SearchQuery struct {
+	Keyword string
+	AreaID  string       // Stations of an area only ("" = every area)
+	From    time.Time    // First broadcast day (zero = no limit)
+	To      time.Time    // Last broadcast day (zero = no limit)
+	Filter  SearchFilter // Live or timefree programs only
+	Limit   int          // Maximum number of results (0 = radiko's default)
+}
+
+// searchResponse is the program search JSON
+type searchResponse struct {
+	Data []struct {
+		StationID   string `json:"station_id"`
+		Title       string `json:"title"`
+		Performer   string `json:"performer"`
+		Description string `json:"description"`
+		Info        string `json:"info"`
+		StartTime   string `json:"start_time"` // 2006-01-02 15:04:05
+		EndTime     string `json:"end_time"`
+		ProgramURL  string `json:"program_url"`
+		Img         string `json:"img"`
+		Status      string `json:"status"`
+	} `json:"data"`
+}
+
+// searchTimeLayout is the layout of the program search times
+const searchTimeLayout = "2006-01-02 15:04:05"
+
+// SearchPrograms searches radiko's programs by keyword, in the title,
+// performers and description
+func (c *Client) SearchPrograms(ctx context.Context, q SearchQuery) ([]model.SearchResult, error) {
+	v := url.Values{
+		"key":    {q.Keyword},
+		"filter": {string(q.Filter)},
+		"app_id": {"pc"},
+	}
+	if q.AreaID != "" {
+		v.Set("area_id", q.AreaID)
+		v.Set("cur_area_id", q.AreaID)
+	}
+	if !q.From.IsZero() {
+		v.Set("start_day", q.From.In(jst).Format("2006-01-02"))
+	}
+	if !q.To.IsZero() {
+		v.Set("end_day", q.To.In(jst).Format("2006-01-02"))
+	}
+	if q.Limit > 0 {
+		v.Set("row_limit", strconv.Itoa(q.Limit))
+	}
+
+	resp, err := c.get(ctx, SearchURL+"?"+v.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to search programs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to search programs: status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var search searchResponse
+	if err := json.Unmarshal(data, &search); err != nil {
+		return nil, fmt.Errorf("failed to parse search JSON: %w", err)
+	}
+
+	results := make([]model.SearchResult, 0, len(search.Data))
+	for _, d := range search.Data {
+		start, err := time.ParseInLocation(searchTimeLayout, d.StartTime, jst)
+		if err != nil {
+			continue
+		}
+		end, err := time.ParseInLocation(searchTimeLayout, d.EndTime, jst)
+		if err != nil {
+			continue
+		}
+		results = append(results, model.SearchResult{
+			StationID: d.StationID,
+			Program: model.Program{
+				Ft:    start.Format("20060102150405"),
+				To:    end.Format("20060102150405"),
+				Title: d.Title,
+				Pfm:   d.Performer,
+				Desc:  d.Description,
+				Info:  d.Info,
+				URL:   d.ProgramURL,
+				Img:   d.Img,
+			},
+			Status: d.Status,
+		})
+	}
+	return results, nil
+}
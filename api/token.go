@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TokenTTL is how long an area's auth token is reused. radiko tokens stay
+// valid for about 70 minutes.
+const TokenTTL = 50 * time.Minute
+
+// tokenRefreshBefore is how long before expiry a token in use is renewed in
+// the background
+const tokenRefreshBefore = 10 * time.Minute
+
+// cachedToken is an area's auth token
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
+
+// tokenCache holds a client's auth token per area. That of the default
+// client is the one token lifecycle of the TUI, the recorder and the server.
+type tokenCache struct {
+	mu      sync.Mutex
+	tokens  map[string]cachedToken
+	pending map[string]chan struct{}          // Area ID → closed when its authentication ends
+	subs    map[string]map[*tokenSub]struct{} // Area ID → holders of its token
+}
+
+// tokenSub receives the renewed tokens of an area
+type tokenSub struct {
+	refresh func(token string)
+}
+
+// newTokenCache returns an empty token cache
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		tokens:  make(map[string]cachedToken),
+		pending: make(map[string]chan struct{}),
+		subs:    make(map[string]map[*tokenSub]struct{}),
+	}
+}
+
+// Token returns an auth token for an area, reusing the cached one while it
+// is valid. A token close to expiry is returned and renewed in the
+// background, so the next play gets a fresh one without waiting.
+func (c *Client) Token(ctx context.Context, areaID string) (string, error) {
+	c.tokens.mu.Lock()
+	cached, ok := c.tokens.tokens[areaID]
+	c.tokens.mu.Unlock()
+	if ok {
+		left := time.Until(cached.expires)
+		if left > 0 {
+			if left < tokenRefreshBefore {
+				// Not bound to the caller, which has its token already
+				go c.refreshToken(context.WithoutCancel(ctx), areaID)
+			}
+			return cached.token, nil
+		}
+	}
+	return c.refreshToken(ctx, areaID)
+}
+
+// InvalidateToken drops an area's cached token, e.g. when radiko rejected
+// it, so the next Token authenticates again
+func (c *Client) InvalidateToken(areaID string) {
+	c.tokens.mu.Lock()
+	delete(c.tokens.tokens, areaID)
+	c.tokens.mu.Unlock()
+}
+
+// tokenArea returns the area a cached token was got for, or ""
+func (c *Client) tokenArea(token string) string {
+	c.tokens.mu.Lock()
+	defer c.tokens.mu.Unlock()
+	for areaID, cached := range c.tokens.tokens {
+		if cached.token == token {
+			return areaID
+		}
+	}
+	return ""
+}
+
+// SubscribeToken hands every new token got for an area (by Token, its
+// background renewal or RenewTokens) to refresh, e.g. to restart a running
+// stream with the new token, until the returned function is called
+func (c *Client) SubscribeToken(areaID string, refresh func(token string)) (unsubscribe func()) {
+	sub := &tokenSub{refresh: refresh}
+	t := c.tokens
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.subs[areaID] == nil {
+		t.subs[areaID] = make(map[*tokenSub]struct{})
+	}
+	t.subs[areaID][sub] = struct{}{}
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.subs[areaID], sub)
+		if len(t.subs[areaID]) == 0 {
+			delete(t.subs, areaID)
+		}
+	}
+}
+
+// RenewTokens renews the tokens of the subscribed areas that expire soon or
+// were dropped, which hands them to the subscribers. Call it about once a
+// minute while streams are running.
+func (c *Client) RenewTokens(ctx context.Context) error {
+	c.tokens.mu.Lock()
+	var due []string
+	for areaID := range c.tokens.subs {
+		if cached, ok := c.tokens.tokens[areaID]; !ok || time.Until(cached.expires) <= tokenRefreshBefore {
+			due = append(due, areaID)
+		}
+	}
+	c.tokens.mu.Unlock()
+
+	var errs []error
+	for _, areaID := range due {
+		if _, err := c.refreshToken(ctx, areaID); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", areaID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// clearTokens drops every cached token, as when the premium session changes
+func (c *Client) clearTokens() {
+	c.tokens.mu.Lock()
+	c.tokens.tokens = make(map[string]cachedToken)
+	c.tokens.mu.Unlock()
+}
+
+// refreshToken authenticates for an area, caches the token and hands it to
+// the area's subscribers. Callers refreshing the same area at once share one
+// authentication.
+func (c *Client) refreshToken(ctx context.Context, areaID string) (string, error) {
+	t := c.tokens
+	t.mu.Lock()
+	if wait, ok := t.pending[areaID]; ok {
+		t.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		t.mu.Lock()
+		cached, ok := t.tokens[areaID]
+		t.mu.Unlock()
+		if !ok || time.Now().After(cached.expires) {
+			return "", fmt.Errorf("authentication failed")
+		}
+		return cached.token, nil
+	}
+	done := make(chan struct{})
+	t.pending[areaID] = done
+	t.mu.Unlock()
+
+	token, err := c.authenticate(ctx, areaID)
+
+	t.mu.Lock()
+	delete(t.pending, areaID)
+	if err != nil {
+		close(done)
+		t.mu.Unlock()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		return "", fmt.Errorf("authentication failed: %w", err)
+	}
+	t.tokens[areaID] = cachedToken{token: token, expires: time.Now().Add(TokenTTL)}
+	close(done)
+	var subs []*tokenSub
+	for sub := range t.subs[areaID] {
+		subs = append(subs, sub)
+	}
+	t.mu.Unlock()
+
+	// Outside the lock, since subscribers may ask for tokens themselves
+	for _, sub := range subs {
+		sub.refresh(token)
+	}
+	return token, nil
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenCached(t *testing.T) {
+	c, srv := testClient(t)
+	ctx := context.Background()
+
+	first, err := c.Token(ctx, "JP13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Token(ctx, "JP13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("second token = %q, want the cached %q", second, first)
+	}
+	if n := srv.Requests("/v2/api/auth1"); n != 1 {
+		t.Errorf("auth1 requests = %d, want 1", n)
+	}
+
+	// Another area gets a token of its own
+	osaka, err := c.Token(ctx, "JP27")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if osaka == first || srv.TokenArea(osaka) != "JP27" {
+		t.Errorf("JP27 token %q plays in %q", osaka, srv.TokenArea(osaka))
+	}
+}
+
+func TestTokenConcurrent(t *testing.T) {
+	c, srv := testClient(t)
+	ctx := context.Background()
+
+	const callers = 8
+	got := make([]string, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := c.Token(ctx, "JP13")
+			if err != nil {
+				t.Error(err)
+			}
+			got[i] = token
+		}()
+	}
+	wg.Wait()
+
+	for i, token := range got {
+		if token != got[0] {
+			t.Errorf("caller %d got %q, want %q", i, token, got[0])
+		}
+	}
+	if n := srv.Requests("/v2/api/auth1"); n != 1 {
+		t.Errorf("auth1 requests = %d, want 1", n)
+	}
+}
+
+func TestTokenInvalidate(t *testing.T) {
+	c, srv := testClient(t)
+	ctx := context.Background()
+
+	first, err := c.Token(ctx, "JP13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.InvalidateToken("JP13")
+	second, err := c.Token(ctx, "JP13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Error("Token returned the invalidated token")
+	}
+	if !srv.ValidToken(second) {
+		t.Errorf("token %q was not accepted by auth2", second)
+	}
+	if n := srv.Requests("/v2/api/auth1"); n != 2 {
+		t.Errorf("auth1 requests = %d, want 2", n)
+	}
+}
+
+func TestTokenRenewedBeforeExpiry(t *testing.T) {
+	c, srv := testClient(t)
+	ctx := context.Background()
+	cache := c.tokens
+
+	old, err := c.Token(ctx, "JP13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.mu.Lock()
+	cache.tokens["JP13"] = cachedToken{token: old, expires: time.Now().Add(time.Minute)}
+	cache.mu.Unlock()
+
+	// The token about to expire is still handed out, and renewed meanwhile
+	if token, err := c.Token(ctx, "JP13"); err != nil || token != old {
+		t.Fatalf("Token = %q, %v, want %q", token, err, old)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cache.mu.Lock()
+		renewed := cache.tokens["JP13"].token
+		cache.mu.Unlock()
+		if renewed != old {
+			if !srv.ValidToken(renewed) {
+				t.Errorf("renewed token %q was not accepted by auth2", renewed)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("token was not renewed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSubscribeToken(t *testing.T) {
+	c, srv := testClient(t)
+	ctx := context.Background()
+
+	got := make(chan string, 2)
+	unsubscribe := c.SubscribeToken("JP13", func(token string) { got <- token })
+	defer unsubscribe()
+
+	token, err := c.Token(ctx, "JP13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := <-got; sent != token {
+		t.Errorf("subscriber got %q, want %q", sent, token)
+	}
+
+	// A dropped token is renewed for the subscriber
+	c.InvalidateToken("JP13")
+	if err := c.RenewTokens(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case renewed := <-got:
+		if renewed == token || !srv.ValidToken(renewed) {
+			t.Errorf("subscriber got %q after renewal", renewed)
+		}
+	default:
+		t.Fatal("subscriber got no renewed token")
+	}
+
+	// Other areas are not handed to it
+	if _, err := c.Token(ctx, "JP27"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case token := <-got:
+		t.Errorf("JP13 subscriber got a token %q of JP27", token)
+	default:
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Variant is one stream of an HLS master playlist
+type Variant struct {
+	URL       string // Absolute media playlist URL
+	Bandwidth int    // Bits per second
+	Codecs    string // e.g. "mp4a.40.5"
+}
+
+// Quality selects a variant of a master playlist by bandwidth
+type Quality string
+
+const (
+	QualityDefault Quality = ""     // The master playlist as it is
+	QualityLow     Quality = "low"  // Lowest bandwidth variant, for mobile data
+	QualityHigh    Quality = "high" // Highest bandwidth variant
+)
+
+// PreferredStream returns the stream radiko's own player uses, the last
+// one listed
+func PreferredStream(streams []Stream) Stream {
+	return streams[len(streams)-1]
+}
+
+// Select returns the variant of a stream at a quality, or the master
+// playlist itself when it has nothing to choose from
+func (s Stream) Select(q Quality) Variant {
+	if q == QualityDefault || len(s.Variants) < 2 {
+		return Variant{URL: s.URL}
+	}
+	best := s.Variants[0]
+	for _, v := range s.Variants[1:] {
+		if q == QualityLow && v.Bandwidth < best.Bandwidth || q == QualityHigh && v.Bandwidth > best.Bandwidth {
+			best = v
+		}
+	}
+	return best
+}
+
+// GetVariants fetches an HLS master playlist (a playlist_create_url with its
+// query) and returns its variants in playlist order. A media playlist has no
+// variants, in which case the result is empty.
+func (c *Client) GetVariants(ctx context.Context, playlistURL, authToken string) ([]Variant, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, playlistURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Radiko-AuthToken", authToken)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("failed to fetch playlist: %w", errForbidden)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch playlist: status code %d", resp.StatusCode)
+	}
+
+	base, err := url.Parse(playlistURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseVariants(resp.Body, base)
+}
+
+// parseVariants parses the EXT-X-STREAM-INF entries of a master playlist
+func parseVariants(r io.Reader, base *url.URL) ([]Variant, error) {
+	var variants []Variant
+	var pending *Variant
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bandwidth, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			pending = &Variant{Bandwidth: bandwidth, Codecs: attrs["CODECS"]}
+		case line == "" || strings.HasPrefix(line, "#"):
+		case pending != nil:
+			// The URI line following EXT-X-STREAM-INF
+			u, err := base.Parse(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid variant URL %q: %w", line, err)
+			}
+			pending.URL = u.String()
+			variants = append(variants, *pending)
+			pending = nil
+		}
+	}
+	return variants, scanner.Err()
+}
+
+// parseAttributes parses an HLS attribute list (KEY=value,KEY="quoted, value")
+func parseAttributes(list string) map[string]string {
+	attrs := make(map[string]string)
+	for list != "" {
+		key, rest, ok := strings.Cut(list, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(key)] = value
+		list = rest
+	}
+	return attrs
+}
@@ -0,0 +1,64 @@
+package cast
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/mdns"
+)
+
+// DiscoverTimeout is how long Discover waits for devices to answer
+const DiscoverTimeout = 2 * time.Second
+
+// Device is a Chromecast or Google Home device on the LAN
+type Device struct {
+	Name string `json:"name"` // Friendly name, e.g. "Living Room speaker"
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// Addr returns the host:port of the device's cast channel
+func (d Device) Addr() string {
+	return net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
+}
+
+// Discover searches the LAN for cast devices with mDNS for the given time
+func Discover(timeout time.Duration) ([]Device, error) {
+	instances, err := mdns.Browse("_googlecast._tcp", timeout)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]Device, 0, len(instances))
+	for _, inst := range instances {
+		name := inst.TXT["fn"]
+		if name == "" {
+			name = inst.Name
+		}
+		devices = append(devices, Device{Name: name, Host: inst.Host, Port: inst.Port})
+	}
+	return devices, nil
+}
+
+// FindDevice discovers the device with the given friendly name (case-insensitive)
+func FindDevice(name string) (Device, bool) {
+	devices, _ := Discover(DiscoverTimeout)
+	for _, d := range devices {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return Device{}, false
+}
+
+// LocalIP returns the address of this machine on the network of the device,
+// for URLs the device has to reach
+func LocalIP(d Device) (net.IP, error) {
+	conn, err := net.Dial("udp", d.Addr())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
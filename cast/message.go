@@ -0,0 +1,88 @@
+package cast
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// message is the part of the CastMessage protobuf the session uses:
+//
+//	message CastMessage {
+//	  required ProtocolVersion protocol_version = 1; // CASTV2_1_0 = 0
+//	  required string source_id = 2;
+//	  required string destination_id = 3;
+//	  required string namespace = 4;
+//	  required PayloadType payload_type = 5; // STRING = 0
+//	  optional string payload_utf8 = 6;
+//	  optional bytes payload_binary = 7;
+//	}
+type message struct {
+	source      string
+	destination string
+	namespace   string
+	payload     string
+}
+
+// errInvalidMessage is returned for malformed protobuf data
+var errInvalidMessage = errors.New("invalid cast message")
+
+// encodeMessage encodes a CastMessage with a string payload
+func encodeMessage(namespace, source, destination, payload string) []byte {
+	var b []byte
+	b = append(b, 1<<3|0, 0) // protocol_version
+	b = appendString(b, 2, source)
+	b = appendString(b, 3, destination)
+	b = appendString(b, 4, namespace)
+	b = append(b, 5<<3|0, 0) // payload_type
+	b = appendString(b, 6, payload)
+	return b
+}
+
+// appendString appends a length-delimited field
+func appendString(b []byte, field int, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|2))
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// decodeMessage decodes a CastMessage, skipping fields it does not use
+func decodeMessage(b []byte) (message, error) {
+	var msg message
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return msg, errInvalidMessage
+		}
+		b = b[n:]
+		field, wireType := key>>3, key&7
+
+		switch wireType {
+		case 0: // Varint
+			_, n := binary.Uvarint(b)
+			if n <= 0 {
+				return msg, errInvalidMessage
+			}
+			b = b[n:]
+		case 2: // Length-delimited
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return msg, errInvalidMessage
+			}
+			value := string(b[n : n+int(size)])
+			b = b[n+int(size):]
+			switch field {
+			case 2:
+				msg.source = value
+			case 3:
+				msg.destination = value
+			case 4:
+				msg.namespace = value
+			case 6:
+				msg.payload = value
+			}
+		default:
+			return msg, errInvalidMessage
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,320 @@
+package cast
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cast channel namespaces
+const (
+	nsConnection = "urn:x-cast:com.google.cast.tp.connection"
+	nsHeartbeat  = "urn:x-cast:com.google.cast.tp.heartbeat"
+	nsReceiver   = "urn:x-cast:com.google.cast.receiver"
+	nsMedia      = "urn:x-cast:com.google.cast.media"
+)
+
+// defaultMediaReceiver is the app ID of Google's Default Media Receiver
+const defaultMediaReceiver = "CC1AD845"
+
+const (
+	senderID   = "sender-0"
+	receiverID = "receiver-0"
+
+	heartbeatInterval = 5 * time.Second
+	requestTimeout    = 10 * time.Second
+	maxMessageSize    = 64 << 10
+)
+
+// ErrClosed is returned once the connection to the device is lost
+var ErrClosed = errors.New("cast connection closed")
+
+// Session is a connection to a cast device playing a stream
+type Session struct {
+	Device Device
+
+	conn    net.Conn
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu           sync.Mutex
+	pending      map[int64]chan payload // Waiting for the response to a request
+	transportID  string                 // Receiver app session to send media commands to
+	appSessionID string
+	volume       float64
+	muted        bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// payload is the JSON payload of a cast message
+type payload map[string]any
+
+// Connect opens a cast channel to a device
+func Connect(d Device) (*Session, error) {
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	// Cast devices present certificates signed by Google's device CA
+	conn, err := tls.DialWithDialer(dialer, "tcp", d.Addr(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		Device:  d,
+		conn:    conn,
+		pending: make(map[int64]chan payload),
+		volume:  -1,
+		done:    make(chan struct{}),
+	}
+	if err := s.send(nsConnection, receiverID, payload{"type": "CONNECT"}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go s.readLoop()
+	go s.heartbeat()
+
+	// Learn the current volume
+	if _, err := s.request(nsReceiver, receiverID, payload{"type": "GET_STATUS"}); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load launches the media receiver on the device and plays a live stream
+func (s *Session) Load(url, contentType, title, imageURL string) error {
+	status, err := s.request(nsReceiver, receiverID, payload{"type": "LAUNCH", "appId": defaultMediaReceiver})
+	if err != nil {
+		return err
+	}
+	s.updateReceiver(status)
+	s.mu.Lock()
+	transportID := s.transportID
+	s.mu.Unlock()
+	if transportID == "" {
+		return errors.New("media receiver did not start")
+	}
+
+	if err := s.send(nsConnection, transportID, payload{"type": "CONNECT"}); err != nil {
+		return err
+	}
+
+	metadata := payload{"metadataType": 0, "title": title}
+	if imageURL != "" {
+		metadata["images"] = []payload{{"url": imageURL}}
+	}
+	status, err = s.request(nsMedia, transportID, payload{
+		"type":     "LOAD",
+		"autoplay": true,
+		"media": payload{
+			"contentId":   url,
+			"contentType": contentType,
+			"streamType":  "LIVE",
+			"metadata":    metadata,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if status["type"] != "MEDIA_STATUS" {
+		return fmt.Errorf("device could not play the stream (%v)", status["type"])
+	}
+	return nil
+}
+
+// SetVolume sets the device volume (0.0-1.0)
+func (s *Session) SetVolume(level float64) error {
+	level = max(0, min(1, level))
+	status, err := s.request(nsReceiver, receiverID, payload{"type": "SET_VOLUME", "volume": payload{"level": level}})
+	if err == nil {
+		s.updateReceiver(status)
+	}
+	return err
+}
+
+// SetMuted mutes or unmutes the device
+func (s *Session) SetMuted(muted bool) error {
+	status, err := s.request(nsReceiver, receiverID, payload{"type": "SET_VOLUME", "volume": payload{"muted": muted}})
+	if err == nil {
+		s.updateReceiver(status)
+	}
+	return err
+}
+
+// Volume returns the device volume (-1 if unknown) and whether it is muted
+func (s *Session) Volume() (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.volume, s.muted
+}
+
+// Stop quits the media receiver, which stops playback, and closes the session
+func (s *Session) Stop() error {
+	s.mu.Lock()
+	appSessionID := s.appSessionID
+	s.mu.Unlock()
+
+	var err error
+	if appSessionID != "" {
+		_, err = s.request(nsReceiver, receiverID, payload{"type": "STOP", "sessionId": appSessionID})
+	}
+	s.Close()
+	return err
+}
+
+// Close closes the connection, leaving the device playing
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.conn.Close()
+	})
+}
+
+// Done is closed when the connection to the device is lost or closed
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// request sends a message and waits for the response with the same request ID
+func (s *Session) request(namespace, destination string, p payload) (payload, error) {
+	id := s.nextID.Add(1)
+	p["requestId"] = id
+	ch := make(chan payload, 1)
+	s.mu.Lock()
+	s.pending[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	if err := s.send(namespace, destination, p); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-s.done:
+		return nil, ErrClosed
+	case <-time.After(requestTimeout):
+		return nil, fmt.Errorf("no response to %v", p["type"])
+	}
+}
+
+// send writes one message: a length-prefixed CastMessage
+func (s *Session) send(namespace, destination string, p payload) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	msg := encodeMessage(namespace, senderID, destination, string(data))
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(msg)))
+	frame = append(frame, msg...)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+	_, err = s.conn.Write(frame)
+	return err
+}
+
+// heartbeat pings the device, which drops silent connections
+func (s *Session) heartbeat() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.send(nsHeartbeat, receiverID, payload{"type": "PING"}); err != nil {
+				s.Close()
+				return
+			}
+		}
+	}
+}
+
+// readLoop dispatches incoming messages until the connection ends
+func (s *Session) readLoop() {
+	defer s.Close()
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(s.conn, header); err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header)
+		if size > maxMessageSize {
+			return
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(s.conn, buf); err != nil {
+			return
+		}
+
+		msg, err := decodeMessage(buf)
+		if err != nil {
+			continue
+		}
+		var p payload
+		if json.Unmarshal([]byte(msg.payload), &p) != nil {
+			continue
+		}
+
+		switch {
+		case msg.namespace == nsHeartbeat && p["type"] == "PING":
+			s.send(nsHeartbeat, msg.source, payload{"type": "PONG"})
+			continue
+		case msg.namespace == nsConnection && p["type"] == "CLOSE":
+			// The device or the media receiver (stopped from another sender
+			// or on the device) ended the session
+			return
+		case p["type"] == "RECEIVER_STATUS":
+			s.updateReceiver(p)
+		}
+
+		if id, ok := p["requestId"].(float64); ok && id != 0 {
+			s.mu.Lock()
+			ch := s.pending[int64(id)]
+			s.mu.Unlock()
+			if ch != nil {
+				ch <- p
+			}
+		}
+	}
+}
+
+// updateReceiver records the volume and media receiver session from a RECEIVER_STATUS
+func (s *Session) updateReceiver(p payload) {
+	status, _ := p["status"].(map[string]any)
+	if status == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if volume, ok := status["volume"].(map[string]any); ok {
+		if level, ok := volume["level"].(float64); ok {
+			s.volume = level
+		}
+		if muted, ok := volume["muted"].(bool); ok {
+			s.muted = muted
+		}
+	}
+	apps, _ := status["applications"].([]any)
+	for _, a := range apps {
+		app, _ := a.(map[string]any)
+		if app["appId"] == defaultMediaReceiver {
+			s.transportID, _ = app["transportId"].(string)
+			s.appSessionID, _ = app["sessionId"].(string)
+		}
+	}
+}
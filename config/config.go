@@ -1,16 +1,188 @@
 package config
 
 import (
-	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 )
 
 // Config represents application configuration
 type Config struct {
-	LastStationID string  `json:"last_station_id"` // Last played station ID
-	Volume        float64 `json:"volume"`          // Volume 0.0-1.0
-	AreaID        string  `json:"area_id"`         // Current area ID
+	LastStationID     string                     `json:"last_station_id"`               // Last played station ID
+	Volume            float64                    `json:"volume"`                        // Volume 0.0-1.0
+	AreaID            string                     `json:"area_id"`                       // Current area ID
+	SampleRate        int                        `json:"sample_rate"`                   // Audio device sample rate (0 = detected, else native 48kHz)
+	RecordDir         string                     `json:"record_dir,omitempty"`          // Recordings directory, created when needed (default: the Downloads directory)
+	RecordFormat      string                     `json:"record_format,omitempty"`       // Default recording format: aac, m4a, mp3, flac
+	RecordTemplate    string                     `json:"record_template,omitempty"`     // Recording filename template, e.g. "{station}/{date}_{program}"
+	RecordLossless    bool                       `json:"record_lossless,omitempty"`     // Keep the original HLS segments instead of re-encoding
+	Loudnorm          bool                       `json:"loudnorm,omitempty"`            // Normalize the loudness of finished recordings (-16 LUFS)
+	Retention         Retention                  `json:"retention"`                     // Automatic cleanup of old recordings
+	PostRecordCommand string                     `json:"post_record_command,omitempty"` // Command run after each recording (RADIKO_* env vars)
+	TranscribeCommand string                     `json:"transcribe_command,omitempty"`  // Transcription command run on each recording (stdout is saved)
+	TranscriptFormat  string                     `json:"transcript_format,omitempty"`   // Transcript file extension: txt (default), srt, vtt...
+	Upload            *Upload                    `json:"upload,omitempty"`              // Upload finished recordings to S3 or WebDAV
+	RecordMargin      *Margin                    `json:"record_margin,omitempty"`       // Default margins for scheduled and program recordings
+	ServerURL         string                     `json:"server_url,omitempty"`          // Server of client mode, as -server-url
+	DefaultProfile    string                     `json:"default_profile,omitempty"`     // Profile used without -profile (read from config.json only)
+	ServerAuth        *ServerAuth                `json:"server_auth,omitempty"`         // Authentication for server mode (and the token sent in client mode)
+	Server            *Server                    `json:"server,omitempty"`              // Server mode settings, reloaded on SIGHUP
+	Premium           *Premium                   `json:"premium,omitempty"`             // radiko premium login to play every area
+	HTTP              *HTTP                      `json:"http,omitempty"`                // Requests to radiko
+	Cache             *Cache                     `json:"cache,omitempty"`               // Disk cache of station lists and program guides
+	AuthKey           *AuthKey                   `json:"auth_key,omitempty"`            // Replaces the built-in auth key after radiko rotates it
+	Favorites         []string                   `json:"favorites,omitempty"`           // Favorite station IDs, in the order they are listed
+	Keys              map[string][]string        `json:"keys,omitempty"`                // TUI key bindings by action, e.g. {"record": ["R"]}
+	Theme             string                     `json:"theme,omitempty"`               // TUI color theme: "default" or one of themes
+	Themes            map[string]Theme           `json:"themes,omitempty"`              // Custom color themes by name
+	Stations          map[string]StationSettings `json:"stations,omitempty"`            // Per-station overrides by station ID
+	Schedules         []Schedule                 `json:"schedules,omitempty"`           // Scheduled recordings
+	Rules             []Rule                     `json:"rules,omitempty"`               // Keyword auto-record rules
+}
+
+// StationSettings overrides settings while a station is selected. Zero
+// values keep the global ones.
+type StationSettings struct {
+	Volume     *float64 `json:"volume,omitempty"`      // Volume 0.0-1.0 while the station plays
+	Quality    string   `json:"quality,omitempty"`     // HLS variant played: low or high (default: radiko's playlist as it is)
+	Alias      string   `json:"alias,omitempty"`       // Name shown instead of radiko's
+	AutoRecord bool     `json:"auto_record,omitempty"` // Record the station whenever it is played
+}
+
+// Schedule represents a scheduled recording.
+// Either Cron or Weekdays+Start must be set; times are in Japan time (JST).
+type Schedule struct {
+	ID        string   `json:"id"`                 // Unique identifier
+	StationID string   `json:"station_id"`         // Station to record
+	Name      string   `json:"name,omitempty"`     // Optional label used in filenames
+	Cron      string   `json:"cron,omitempty"`     // Cron expression, e.g. "0 1 * * tue"
+	Weekdays  []string `json:"weekdays,omitempty"` // Weekly rule days, e.g. ["mon", "fri"]
+	Start     string   `json:"start,omitempty"`    // Weekly rule start time "HH:MM"
+	Duration  int      `json:"duration"`           // Recording length in minutes
+	Format    string   `json:"format,omitempty"`   // Output format (overrides record_format)
+	Margin    *Margin  `json:"margin,omitempty"`   // Margins (overrides record_margin)
+	Disabled  bool     `json:"disabled,omitempty"` // Skip this schedule
+}
+
+// Margin extends scheduled recordings to tolerate clock drift and overruns
+type Margin struct {
+	Before int `json:"before"` // Seconds to start early
+	After  int `json:"after"`  // Seconds to keep recording after the end
+}
+
+// Rule automatically records programs in the weekly EPG whose title or
+// performer contains the keyword (case- and width-insensitive)
+type Rule struct {
+	ID         string   `json:"id"`                    // Unique identifier
+	Keyword    string   `json:"keyword"`               // Text to look for in the title or performer
+	StationIDs []string `json:"station_ids,omitempty"` // Stations to search (empty = stations in area_id)
+	Format     string   `json:"format,omitempty"`      // Output format (overrides record_format)
+	Disabled   bool     `json:"disabled,omitempty"`    // Skip this rule
+}
+
+// Upload configures where finished recordings are uploaded
+type Upload struct {
+	Type      string `json:"type"`                 // "s3" or "webdav"
+	URL       string `json:"url,omitempty"`        // WebDAV folder URL, or S3 endpoint for S3-compatible storage
+	Prefix    string `json:"prefix,omitempty"`     // Folder / key prefix for uploaded files
+	Bucket    string `json:"bucket,omitempty"`     // S3 bucket
+	Region    string `json:"region,omitempty"`     // S3 region (default us-east-1)
+	AccessKey string `json:"access_key,omitempty"` // S3 access key ID
+	SecretKey string `json:"secret_key,omitempty"` // S3 secret access key
+	Username  string `json:"username,omitempty"`   // WebDAV user
+	Password  string `json:"password,omitempty"`   // WebDAV password (app password for Nextcloud)
+}
+
+// ServerAuth protects the server's endpoints. Either or both methods can be set.
+type ServerAuth struct {
+	Token    string `json:"token,omitempty"`    // Bearer token (also accepted as ?token=)
+	Username string `json:"username,omitempty"` // Basic auth user
+	Password string `json:"password,omitempty"` // Basic auth password
+}
+
+// HTTP configures the requests to radiko. Zero values keep the defaults.
+type HTTP struct {
+	Retries      int     `json:"retries,omitempty"`        // Retries after a failed request (default 3, -1 = none)
+	BackoffMs    int     `json:"backoff_ms,omitempty"`     // Delay before the first retry, doubled for each next one (default 500)
+	MaxBackoffMs int     `json:"max_backoff_ms,omitempty"` // Longest delay between retries (default 10000)
+	Jitter       float64 `json:"jitter,omitempty"`         // Fraction of each delay randomized (default 0.2)
+	RetryOn      []int   `json:"retry_on,omitempty"`       // Status codes retried (default 429, 500, 502, 503, 504)
+	RateLimit    float64 `json:"rate_limit,omitempty"`     // Requests per second after a burst (default 5, -1 = unlimited)
+	RateBurst    int     `json:"rate_burst,omitempty"`     // Requests let through at once (default 10)
+
+	Proxy              string `json:"proxy,omitempty"`                // Proxy URL (default HTTP_PROXY/HTTPS_PROXY)
+	TimeoutSeconds     int    `json:"timeout_seconds,omitempty"`      // Bound on each API request (default none)
+	UserAgent          string `json:"user_agent,omitempty"`           // User-Agent of API requests
+	CACert             string `json:"ca_cert,omitempty"`              // PEM file of extra root CAs, e.g. of a TLS-inspecting proxy
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"` // Don't verify radiko's certificates
+}
+
+// Cache configures the disk cache of station lists and program guides,
+// served while fresh and whenever radiko is unreachable
+type Cache struct {
+	Disabled           bool   `json:"disabled,omitempty"`             // Always ask radiko
+	Dir                string `json:"dir,omitempty"`                  // Cache directory (default radiko-tui in the user cache directory)
+	StationsTTLMinutes int    `json:"stations_ttl_minutes,omitempty"` // Station lists served without asking radiko (default 1440)
+	ProgramsTTLMinutes int    `json:"programs_ttl_minutes,omitempty"` // Program guides served without asking radiko (default 60)
+}
+
+// AuthKey replaces the key of radiko's authentication. The first of key,
+// file and url that is set is used.
+type AuthKey struct {
+	Key  string `json:"key,omitempty"`  // Key in base64
+	File string `json:"file,omitempty"` // File holding the key, raw or in base64
+	URL  string `json:"url,omitempty"`  // URL serving the key, raw or in base64, fetched at startup
+	App  string `json:"app,omitempty"`  // x-radiko-app the key belongs to (default aSmartPhone7a)
+}
+
+// Theme colors the TUI with hex colors ("#RRGGBB"). Colors left out keep
+// their defaults.
+type Theme struct {
+	Primary      string `json:"primary,omitempty"`       // Title and selected station
+	Secondary    string `json:"secondary,omitempty"`     // Current area and selected playing station
+	Accent       string `json:"accent,omitempty"`        // Volume, focus indicator and favorites
+	Text         string `json:"text,omitempty"`          // Station and area names
+	DimText      string `json:"dim_text,omitempty"`      // Station IDs and status messages
+	Playing      string `json:"playing,omitempty"`       // Playing station
+	Region       string `json:"region,omitempty"`        // Selected area
+	Warning      string `json:"warning,omitempty"`       // Reconnection status
+	Recording    string `json:"recording,omitempty"`     // Recording marks
+	Error        string `json:"error,omitempty"`         // Error messages
+	Program      string `json:"program,omitempty"`       // Program and track titles
+	SelectedText string `json:"selected_text,omitempty"` // Text on selected lines
+}
+
+// Premium holds the radiko premium (area-free) member login
+type Premium struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Server holds server mode settings that can change without a restart.
+// Command-line flags given explicitly take precedence.
+type Server struct {
+	GraceSeconds         int            `json:"grace_seconds,omitempty"`           // Seconds to keep ffmpeg alive after the last client leaves
+	MaxClients           int            `json:"max_clients,omitempty"`             // Maximum concurrent stream clients (0 = unlimited)
+	MaxClientsPerIP      int            `json:"max_clients_per_ip,omitempty"`      // Maximum concurrent stream clients per IP (0 = unlimited)
+	MaxClientsPerStation map[string]int `json:"max_clients_per_station,omitempty"` // Maximum concurrent stream clients of a station ID
+	AllowIP              []string       `json:"allow_ip,omitempty"`                // CIDR ranges or IPs allowed to connect
+	DenyIP               []string       `json:"deny_ip,omitempty"`                 // CIDR ranges or IPs rejected
+	DLNAAllow            []string       `json:"dlna_allow,omitempty"`              // CIDR ranges or IPs whose DLNA renderers skip server_auth
+	TrustedProxies       []string       `json:"trusted_proxies,omitempty"`         // Reverse proxies whose client IP headers are believed
+	LogLevel             string         `json:"log_level,omitempty"`               // info (default), warn or error
+	SlowClient           string         `json:"slow_client,omitempty"`             // buffer (default), drop or disconnect
+	SlowClientBuffer     int            `json:"slow_client_buffer,omitempty"`      // Seconds a slow client may fall behind
+	IdleTimeout          int            `json:"idle_timeout,omitempty"`            // Seconds a client may stop reading before it is disconnected
+}
+
+// Retention limits how many recordings are kept. Zero values disable a limit.
+// Only files recorded by radiko-tui are ever deleted.
+type Retention struct {
+	MaxTotalMB         int `json:"max_total_mb,omitempty"`          // Delete the oldest recordings beyond this total size
+	MaxAgeDays         int `json:"max_age_days,omitempty"`          // Delete recordings older than this
+	MaxFilesPerStation int `json:"max_files_per_station,omitempty"` // Keep only the newest N recordings per station
 }
 
 // DefaultConfig returns the default configuration
@@ -22,8 +194,9 @@ func DefaultConfig() Config {
 	}
 }
 
-// getConfigPath returns the configuration file path
-func getConfigPath() (string, error) {
+// Dir returns the application config directory, creating it if needed.
+// It holds passwords and tokens, so only its owner may read it.
+func Dir() (string, error) {
 	// Get user config directory
 	configDir, err := os.UserConfigDir()
 	if err != nil {
@@ -33,15 +206,65 @@ func getConfigPath() (string, error) {
 
 	// Create application config directory
 	appConfigDir := filepath.Join(configDir, "radiko-tui")
-	if err := os.MkdirAll(appConfigDir, 0755); err != nil {
+	if err := os.MkdirAll(appConfigDir, 0700); err != nil {
 		return "", err
 	}
+	// Created readable by all by older versions
+	if info, err := os.Stat(appConfigDir); err == nil && info.Mode().Perm()&0077 != 0 {
+		if err := os.Chmod(appConfigDir, info.Mode().Perm()&0700); err != nil {
+			return "", err
+		}
+	}
+	return appConfigDir, nil
+}
 
-	return filepath.Join(appConfigDir, "config.json"), nil
+// getConfigPath returns the configuration file path: RADIKO_TUI_CONFIG, or
+// the file of the selected profile
+func getConfigPath() (string, error) {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path, nil
+	}
+	appConfigDir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	name, err := selectedProfile(appConfigDir)
+	if err != nil {
+		return "", err
+	}
+	if name == MainProfile {
+		return configFile(appConfigDir, "config"), nil
+	}
+	return configFile(appConfigDir, "config."+name), nil
 }
 
-// Load loads the configuration
+// Exists reports whether the config file was saved before, i.e. this is
+// not the first run
+func Exists() bool {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(configPath)
+	return err == nil
+}
+
+// Load loads the configuration, overridden by the RADIKO_TUI_* environment
+// variables. An invalid override is an error, returned with the file's
+// configuration.
 func Load() (Config, error) {
+	cfg, err := load()
+	if err != nil {
+		return cfg, err
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// load loads the configuration file
+func load() (Config, error) {
 	configPath, err := getConfigPath()
 	if err != nil {
 		return DefaultConfig(), err
@@ -57,8 +280,8 @@ func Load() (Config, error) {
 	}
 
 	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return DefaultConfig(), err
+	if err := unmarshal(configPath, data, &cfg); err != nil {
+		return DefaultConfig(), fmt.Errorf("%s: %w", filepath.Base(configPath), err)
 	}
 
 	// Validate volume range
@@ -76,34 +299,169 @@ func Load() (Config, error) {
 	return cfg, nil
 }
 
-// Save saves the configuration
+// saveMu serializes the read-modify-write of Update
+var saveMu sync.Mutex
+
+// Save saves the configuration. The file is replaced at once, so a crash
+// or a concurrent reader never sees it half written.
 func Save(cfg Config) error {
 	configPath, err := getConfigPath()
 	if err != nil {
 		return err
 	}
 
-	data, err := json.MarshalIndent(cfg, "", "  ")
+	data, err := marshal(configPath, cfg)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	return writeFile(configPath, data)
+}
+
+// writeFile writes data to a temporary file next to path, then renames it
+// over path. The file holds passwords and tokens, so it is readable by its
+// owner only: the owner's permissions of the file it replaces are kept,
+// those of anyone else dropped.
+func writeFile(path string, data []byte) error {
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm() & 0700
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Once renamed, there is nothing left to remove
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Update loads the configuration, changes it with fn and saves it, without
+// losing changes made at the same time by other Update calls. It returns
+// the saved configuration.
+func Update(fn func(cfg *Config)) (Config, error) {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	// The environment overrides are not saved
+	cfg, err := load()
+	if err != nil {
+		// A file that can't be read must not be overwritten with defaults
+		return cfg, err
+	}
+	fn(&cfg)
+	return cfg, Save(cfg)
 }
 
 // SaveConfig saves the configuration (station, volume, area)
+// Other settings are preserved from the existing config file
 func SaveConfig(stationID string, volume float64, areaID string) error {
-	cfg := Config{
-		LastStationID: stationID,
-		Volume:        volume,
-		AreaID:        areaID,
+	_, err := Update(func(cfg *Config) {
+		cfg.LastStationID = stationID
+		cfg.Volume = volume
+		cfg.AreaID = areaID
+	})
+	return err
+}
+
+// IsFavorite reports whether a station is a favorite
+func (c *Config) IsFavorite(stationID string) bool {
+	return slices.Contains(c.Favorites, stationID)
+}
+
+// AddFavorite appends a station to the favorites, reporting whether it
+// wasn't one yet
+func (c *Config) AddFavorite(stationID string) bool {
+	if stationID == "" || c.IsFavorite(stationID) {
+		return false
+	}
+	c.Favorites = append(c.Favorites, stationID)
+	return true
+}
+
+// RemoveFavorite removes a station from the favorites, reporting whether it
+// was one
+func (c *Config) RemoveFavorite(stationID string) bool {
+	i := slices.Index(c.Favorites, stationID)
+	if i < 0 {
+		return false
+	}
+	c.Favorites = slices.Delete(c.Favorites, i, i+1)
+	return true
+}
+
+// MoveFavorite moves a favorite by offset places (negative = up), stopping
+// at either end. It reports whether the order changed.
+func (c *Config) MoveFavorite(stationID string, offset int) bool {
+	i := slices.Index(c.Favorites, stationID)
+	if i < 0 {
+		return false
+	}
+	j := min(max(i+offset, 0), len(c.Favorites)-1)
+	if i == j {
+		return false
+	}
+	c.Favorites = slices.Insert(slices.Delete(c.Favorites, i, i+1), j, stationID)
+	return true
+}
+
+// AssignScheduleIDs gives the schedules without an ID one made of the
+// station and their position, e.g. "QRR-2", and saves them, so that they can
+// be deleted by that ID later
+func AssignScheduleIDs(cfg *Config) error {
+	if !assignScheduleIDs(cfg.Schedules) {
+		return nil
+	}
+	_, err := Update(func(saved *Config) {
+		assignScheduleIDs(saved.Schedules)
+	})
+	return err
+}
+
+// assignScheduleIDs fills in the missing schedule IDs, reporting whether
+// there were any
+func assignScheduleIDs(schedules []Schedule) bool {
+	taken := make(map[string]bool)
+	for _, sched := range schedules {
+		taken[sched.ID] = true
+	}
+	assigned := false
+	for i := range schedules {
+		if schedules[i].ID != "" {
+			continue
+		}
+		for n := i + 1; ; n++ {
+			id := fmt.Sprintf("%s-%d", schedules[i].StationID, n)
+			if !taken[id] {
+				schedules[i].ID = id
+				taken[id] = true
+				break
+			}
+		}
+		assigned = true
 	}
-	return Save(cfg)
+	return assigned
 }
 
-// SaveLastStation saves the last played station (backwards compatible)
+// SaveLastStation saves the last played station (backwards compatible),
+// keeping the saved area
 func SaveLastStation(stationID string, volume float64) error {
-	// Load existing config first to preserve AreaID
-	existing, _ := Load()
-	return SaveConfig(stationID, volume, existing.AreaID)
+	_, err := Update(func(cfg *Config) {
+		cfg.LastStationID = stationID
+		cfg.Volume = volume
+	})
+	return err
 }
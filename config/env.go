@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// Environment variables overriding the config file, e.g. in containers
+const (
+	EnvArea      = "RADIKO_TUI_AREA"       // Area ID, e.g. JP13
+	EnvVolume    = "RADIKO_TUI_VOLUME"     // Volume 0-100
+	EnvServerURL = "RADIKO_TUI_SERVER_URL" // Server of client mode
+	EnvConfig    = "RADIKO_TUI_CONFIG"     // Config file, used instead of the profile's
+	EnvLogLevel  = "RADIKO_TUI_LOG_LEVEL"  // Server log level
+)
+
+// applyEnv overrides settings of cfg with the environment variables set
+func applyEnv(cfg *Config) error {
+	if areaID := os.Getenv(EnvArea); areaID != "" {
+		if model.FindAreaByID(areaID) == nil {
+			return fmt.Errorf("%s: unknown area %q", EnvArea, areaID)
+		}
+		cfg.AreaID = areaID
+	}
+	if volume := os.Getenv(EnvVolume); volume != "" {
+		percent, err := strconv.Atoi(volume)
+		if err != nil || percent < 0 || percent > 100 {
+			return fmt.Errorf("%s: volume must be 0-100: %q", EnvVolume, volume)
+		}
+		cfg.Volume = float64(percent) / 100
+	}
+	if serverURL := os.Getenv(EnvServerURL); serverURL != "" {
+		cfg.ServerURL = serverURL
+	}
+	if level := os.Getenv(EnvLogLevel); level != "" {
+		if cfg.Server == nil {
+			cfg.Server = &Server{}
+		}
+		cfg.Server.LogLevel = level
+	}
+	return nil
+}
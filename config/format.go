@@ -0,0 +1,97 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configExts are the extensions of config files looked for, in order. The
+// extension gives the format.
+var configExts = []string{".json", ".toml", ".yaml", ".yml"}
+
+// unmarshal parses a config file in the format of its extension. TOML and
+// YAML go through JSON, whose field names they share.
+func unmarshal(path string, data []byte, cfg *Config) error {
+	var generic any
+	switch filepath.Ext(path) {
+	case ".toml":
+		var m map[string]any
+		if _, err := toml.Decode(string(data), &m); err != nil {
+			return err
+		}
+		generic = m
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+	default:
+		return json.Unmarshal(data, cfg)
+	}
+	data, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, cfg)
+}
+
+// marshal formats a config file in the format of its extension
+func marshal(path string, cfg Config) ([]byte, error) {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(path)
+	if ext != ".toml" && ext != ".yaml" && ext != ".yml" {
+		return data, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic map[string]any
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	plainValues(generic)
+	var buf bytes.Buffer
+	if ext == ".toml" {
+		err = toml.NewEncoder(&buf).Encode(generic)
+	} else {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err = enc.Encode(generic)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// plainValues turns the JSON numbers of v into integers or floats, and drops
+// nulls, which TOML can't hold
+func plainValues(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if e == nil {
+				delete(v, k)
+				continue
+			}
+			v[k] = plainValues(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = plainValues(e)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// MainProfile is the profile of config.json itself
+const MainProfile = "default"
+
+// profileName matches the names of profiles, which are part of file names
+var profileName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var (
+	profileMu sync.Mutex
+	profile   string // Selected profile ("" = default_profile of config.json)
+)
+
+// SetProfile makes Load and Save use the config file of a profile, e.g.
+// config.home.json for "home". An empty name goes back to default_profile
+// of config.json, and MainProfile to config.json itself.
+func SetProfile(name string) error {
+	if name != "" && !profileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (letters, digits, - and _)", name)
+	}
+	profileMu.Lock()
+	profile = name
+	profileMu.Unlock()
+	return nil
+}
+
+// selectedProfile returns the profile set with SetProfile, or else
+// default_profile of the main config file
+func selectedProfile(dir string) (string, error) {
+	profileMu.Lock()
+	name := profile
+	profileMu.Unlock()
+	if name != "" {
+		return name, nil
+	}
+
+	path := configFile(dir, "config")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return MainProfile, nil
+	}
+	var main Config
+	if err := unmarshal(path, data, &main); err != nil || main.DefaultProfile == "" {
+		// Load reports the broken file
+		return MainProfile, nil
+	}
+	if !profileName.MatchString(main.DefaultProfile) {
+		return "", fmt.Errorf("invalid default_profile %q (letters, digits, - and _)", main.DefaultProfile)
+	}
+	return main.DefaultProfile, nil
+}
+
+// configFile returns the config file named base in dir: the first with one
+// of configExts that exists, or the JSON one
+func configFile(dir, base string) string {
+	for _, ext := range configExts {
+		path := filepath.Join(dir, base+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, base+configExts[0])
+}
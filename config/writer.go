@@ -0,0 +1,66 @@
+package config
+
+import "sync"
+
+// write is a change queued for the config file
+type write struct {
+	fn   func(cfg *Config) // nil for Flush
+	done chan error
+}
+
+var (
+	writerOnce sync.Once
+	writes     chan write
+)
+
+// Queue queues a change to the config file and returns at once. Changes
+// are saved in the order they were queued, by a single goroutine; the
+// returned channel receives the result of the save.
+func Queue(fn func(cfg *Config)) <-chan error {
+	writerOnce.Do(func() {
+		writes = make(chan write, 64)
+		go writer()
+	})
+	done := make(chan error, 1)
+	writes <- write{fn: fn, done: done}
+	return done
+}
+
+// Flush waits until the changes queued before are saved
+func Flush() error {
+	return <-Queue(nil)
+}
+
+// writer saves the queued changes, those queued meanwhile in one go
+func writer() {
+	for w := range writes {
+		batch := []write{w}
+	drain:
+		for {
+			select {
+			case w := <-writes:
+				batch = append(batch, w)
+			default:
+				break drain
+			}
+		}
+
+		var fns []func(cfg *Config)
+		for _, w := range batch {
+			if w.fn != nil {
+				fns = append(fns, w.fn)
+			}
+		}
+		var err error
+		if len(fns) > 0 {
+			_, err = Update(func(cfg *Config) {
+				for _, fn := range fns {
+					fn(cfg)
+				}
+			})
+		}
+		for _, w := range batch {
+			w.done <- err
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// tempConfig points the config file at a temporary directory and returns
+// the file's path
+func tempConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv(EnvConfig, path)
+	return path
+}
+
+func TestQueueConcurrent(t *testing.T) {
+	path := tempConfig(t)
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("S%02d", i)
+			Queue(func(cfg *Config) { cfg.Favorites = append(cfg.Favorites, id) })
+		}()
+	}
+	wg.Wait()
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Favorites) != 50 {
+		t.Errorf("%d favorites saved, want 50: %v", len(cfg.Favorites), cfg.Favorites)
+	}
+	for i := range 50 {
+		if id := fmt.Sprintf("S%02d", i); !slices.Contains(cfg.Favorites, id) {
+			t.Errorf("change adding %s was lost", id)
+		}
+	}
+
+	// Only the config is left, written by its owner only
+	files, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("files next to the config: %v", files)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("config mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestQueueOrder(t *testing.T) {
+	tempConfig(t)
+
+	var results []<-chan error
+	for i := 1; i <= 10; i++ {
+		volume := float64(i) / 10
+		results = append(results, Queue(func(cfg *Config) { cfg.Volume = volume }))
+	}
+	for _, done := range results {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Volume != 1 {
+		t.Errorf("volume = %v, want the last change (1)", cfg.Volume)
+	}
+}
+
+func TestQueueUnreadable(t *testing.T) {
+	path := tempConfig(t)
+	invalid := []byte("{not json")
+	if err := os.WriteFile(path, invalid, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-Queue(func(cfg *Config) { cfg.Volume = 0.5 }); err == nil {
+		t.Error("saving over an invalid config succeeded")
+	}
+	// The file is not replaced with defaults
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(invalid) {
+		t.Errorf("config = %q, %v; want it untouched", data, err)
+	}
+}
@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
-	"radiko-tui/api"
-	"radiko-tui/config"
-	"radiko-tui/server"
-	"radiko-tui/tui"
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/config"
+	"github.com/kanoshiou/radiko-tui/model"
+	"github.com/kanoshiou/radiko-tui/recorder"
+	"github.com/kanoshiou/radiko-tui/server"
+	"github.com/kanoshiou/radiko-tui/tui"
 )
 
 // defaultServerURL can be set at build time via -ldflags "-X main.defaultServerURL=http://..."
@@ -17,42 +27,581 @@ var defaultServerURL string
 func main() {
 	// Parse command line arguments
 	volumePercent := flag.Int("volume", -1, "Initial volume (0-100), -1 means use saved config")
+	sampleRate := flag.Int("sample-rate", 0, "Audio device sample rate, e.g. 44100, when it isn't 48000 (0 means use saved config, else detect on Linux or native 48000)")
 	serverMode := flag.Bool("server", false, "Run in server mode (HTTP streaming)")
 	port := flag.Int("port", 8080, "Server port (server mode only)")
 	graceSeconds := flag.Int("grace", 10, "Seconds to keep ffmpeg alive after last client disconnects (server mode only)")
+	opusBitrate := flag.Int("opus-bitrate", server.DefaultOpusBitrate, "Default bitrate (kbps) of the Opus endpoint (server mode only)")
+	podcast := flag.Bool("podcast", false, "Serve recordings as a podcast feed at /podcast.xml (server mode only)")
+	maxClients := flag.Int("max-clients", 0, "Maximum concurrent stream clients, 0 means unlimited (server mode only)")
+	maxClientsPerIP := flag.Int("max-clients-per-ip", 0, "Maximum concurrent stream clients per IP, 0 means unlimited (server mode only)")
+	allowIP := flag.String("allow-ip", "", "Comma-separated CIDR ranges or IPs allowed to connect (server mode only)")
+	denyIP := flag.String("deny-ip", "", "Comma-separated CIDR ranges or IPs rejected (server mode only)")
+	trustedProxies := flag.String("trusted-proxies", "", "Comma-separated CIDR ranges or IPs of reverse proxies whose client IP headers are believed (server mode only)")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file for HTTPS (server mode only)")
+	tlsKey := flag.String("tls-key", "", "TLS private key file for HTTPS (server mode only)")
+	autocertHosts := flag.String("autocert", "", "Comma-separated hostnames to get Let's Encrypt certificates for (server mode only)")
+	dlna := flag.Bool("dlna", false, "Announce the stations on the LAN as a DLNA media server (server mode only)")
+	dlnaName := flag.String("dlna-name", "", "Name shown on DLNA devices, default is radiko-tui (hostname) (server mode only)")
+	dlnaAllow := flag.String("dlna-allow", "", "Comma-separated CIDR ranges or IPs whose DLNA renderers may skip authentication (server mode only)")
+	multicast := flag.String("multicast", "", "Comma-separated STATION[/pcm]@GROUP:PORT outputs multicast on the LAN, e.g. QRR@239.255.42.1:5004 (server mode only)")
+	announce := flag.Bool("announce", false, "Announce the server on the LAN via mDNS and SSDP, for -server-url auto (server mode only)")
+	rtspPort := flag.Int("rtsp-port", 0, "Serve the stations over RTSP on this port, e.g. 8554, 0 means disabled (server mode only)")
+	corsOrigins := flag.String("cors-origins", "", "Comma-separated origins of browser frontends allowed to call the API, or * for any (server mode only)")
+	corsMethods := flag.String("cors-methods", "GET,HEAD,POST,DELETE", "Comma-separated HTTP methods allowed for -cors-origins (server mode only)")
+	accessLog := flag.String("access-log", "", "Write an access log to this file, - for stdout (server mode only)")
+	accessLogFormat := flag.String("access-log-format", server.AccessLogCombined, "Access log format: common, combined or json (server mode only)")
+	basePath := flag.String("base-path", "", "Serve every route under this path prefix, e.g. /radiko behind a reverse proxy (server mode only)")
+	logLevel := flag.String("log-level", server.LogLevelInfo, "Server log level: info, warn or error (server mode only)")
+	slowClient := flag.String("slow-client", server.SlowClientBuffer, "What to do with clients that can't keep up: buffer, drop or disconnect (server mode only)")
+	prebuffer := flag.Int("prebuffer", server.DefaultPrebuffer, "Seconds of recent audio sent to new clients at once, 0 to start at the live edge (server mode only)")
+	slowClientBuffer := flag.Int("slow-client-buffer", server.DefaultSlowClientBuffer, "Seconds a slow client may fall behind before it skips ahead or is disconnected (server mode only)")
+	idleTimeout := flag.Int("idle-timeout", server.DefaultIdleTimeout, "Seconds a client may stop reading its stream before it is disconnected (server mode only)")
+	podcastFeed := flag.String("podcast-feed", "", "Write a podcast RSS feed of the recordings to this file and exit")
+	podcastURL := flag.String("podcast-url", "", "Base URL at which the recordings directory is published (for -podcast-feed)")
 
 	// Use build-time default if available
-	serverURL := flag.String("server-url", defaultServerURL, "Connect to remote server (client mode, no local ffmpeg needed)")
+	serverURL := flag.String("server-url", defaultServerURL, "Connect to remote server (client mode, no local ffmpeg needed), auto to find one on the LAN")
+	serverAdmin := flag.String("server-admin", "", "Show the streams, clients and recordings of the server at this URL (or auto), with force-stop")
+	profile := flag.String("profile", "", "Config profile, e.g. home to use config.home.json (default is default_profile of config.json)")
 	flag.Parse()
 
+	if err := config.SetProfile(*profile); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	// Flags given on the command line win over config.json on reload
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	// Write podcast feed
+	if *podcastFeed != "" {
+		writePodcastFeed(*podcastFeed, *podcastURL)
+		return
+	}
+
 	// Server mode
 	if *serverMode {
-		runServer(*port, *graceSeconds)
+		runServer(serverOptions{
+			port:             *port,
+			graceSeconds:     *graceSeconds,
+			opusBitrate:      *opusBitrate,
+			podcast:          *podcast,
+			maxClients:       *maxClients,
+			maxClientsPerIP:  *maxClientsPerIP,
+			allowIP:          *allowIP,
+			denyIP:           *denyIP,
+			trustedProxies:   *trustedProxies,
+			tlsCert:          *tlsCert,
+			tlsKey:           *tlsKey,
+			autocertHosts:    *autocertHosts,
+			dlna:             *dlna,
+			dlnaName:         *dlnaName,
+			dlnaAllow:        *dlnaAllow,
+			multicast:        *multicast,
+			rtspPort:         *rtspPort,
+			announce:         *announce,
+			corsOrigins:      *corsOrigins,
+			corsMethods:      *corsMethods,
+			accessLog:        *accessLog,
+			accessLogFormat:  *accessLogFormat,
+			basePath:         *basePath,
+			logLevel:         *logLevel,
+			slowClient:       *slowClient,
+			slowClientBuffer: *slowClientBuffer,
+			idleTimeout:      *idleTimeout,
+			prebuffer:        *prebuffer,
+			explicit:         explicit,
+		})
 		return
 	}
 
-	// Client mode (connect to remote server)
+	// Admin view of a remote server
+	if *serverAdmin != "" {
+		runAdmin(resolveServerURL(*serverAdmin))
+		return
+	}
+
+	// Client mode (connect to remote server), to the server of the profile
+	// unless given on the command line
+	if !explicit["server-url"] {
+		if cfg, err := config.Load(); err == nil && cfg.ServerURL != "" {
+			*serverURL = cfg.ServerURL
+		}
+	}
 	if *serverURL != "" {
-		runTUI(*volumePercent, *serverURL)
+		runTUI(*volumePercent, *sampleRate, resolveServerURL(*serverURL))
 		return
 	}
 
 	// Normal TUI mode (local ffmpeg)
-	runTUI(*volumePercent, "")
+	runTUI(*volumePercent, *sampleRate, "")
+}
+
+// serverOptions holds the server mode flags
+type serverOptions struct {
+	port             int
+	graceSeconds     int
+	opusBitrate      int
+	podcast          bool
+	maxClients       int
+	maxClientsPerIP  int
+	allowIP          string
+	denyIP           string
+	trustedProxies   string
+	tlsCert          string
+	tlsKey           string
+	autocertHosts    string
+	dlna             bool
+	dlnaName         string
+	dlnaAllow        string
+	multicast        string
+	rtspPort         int
+	announce         bool
+	corsOrigins      string
+	corsMethods      string
+	accessLog        string
+	accessLogFormat  string
+	basePath         string
+	logLevel         string
+	slowClient       string
+	slowClientBuffer int
+	idleTimeout      int
+	prebuffer        int
+	explicit         map[string]bool // Flags given on the command line
 }
 
 // runServer starts the HTTP streaming server
-func runServer(port int, graceSeconds int) {
+func runServer(opts serverOptions) {
 	fmt.Println("🚀 サーバーモードで起動中...")
-	s := server.NewServer(port, graceSeconds)
+
+	// Without its settings the server would start without auth and access
+	// rules, so it does not start at all
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("❌ 設定の読み込みに失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Start scheduled recordings alongside the server
+	applyHTTPSettings(cfg)
+	applyCacheSettings(cfg)
+	applyAuthKeySettings(cfg)
+	if loginPremium(cfg) {
+		defer api.Logout(context.Background())
+	}
+	defaults, err := recorder.OptionsFromConfig(cfg)
+	if err != nil {
+		fmt.Printf("⚠ 録音設定エラー: %v\n", err)
+	}
+	if _, err := defaults.Retention.Apply(); err != nil {
+		fmt.Printf("⚠ 録音の整理に失敗しました: %v\n", err)
+	}
+	if defaults.Uploader != nil {
+		fmt.Printf("☁️ アップロード先: %s\n", defaults.Uploader)
+		go recorder.UploadPending(defaults.Uploader, recorder.OutputDir(cfg))
+	}
+	recordings := recorder.NewManager(defaults)
+	defer recordings.StopAll()
+	if err := config.AssignScheduleIDs(&cfg); err != nil {
+		fmt.Printf("⚠ 予約IDの保存に失敗しました: %v\n", err)
+	}
+	sched, err := recorder.NewScheduler(cfg.Schedules, recordings)
+	if err != nil {
+		fmt.Printf("⚠ 予約設定エラー: %v\n", err)
+	}
+	sched.SetMargin(cfg.RecordMargin)
+	if err := sched.SetRules(cfg.Rules, cfg.AreaID); err != nil {
+		fmt.Printf("⚠ 自動予約ルールエラー: %v\n", err)
+	}
+	sched.Start()
+	defer sched.Stop()
+
+	s := server.NewServer(opts.port, opts.graceSeconds)
+	s.SetOpusBitrate(opts.opusBitrate)
+	s.SetPrebuffer(opts.prebuffer)
+	s.SetScheduler(sched)
+	if err := applyServerSettings(s, cfg, opts); err != nil {
+		fmt.Printf("❌ サーバー設定エラー: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.accessLog != "" {
+		out := os.Stdout
+		if opts.accessLog != "-" {
+			f, err := os.OpenFile(opts.accessLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				fmt.Printf("❌ アクセスログを開けません: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := s.SetAccessLog(out, opts.accessLogFormat); err != nil {
+			fmt.Printf("❌ アクセスログの設定エラー: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	s.SetBasePath(opts.basePath)
+	if opts.corsOrigins != "" {
+		s.SetCORS(strings.Split(opts.corsOrigins, ","), strings.Split(opts.corsMethods, ","))
+	}
+	s.SetAreas([]string{cfg.AreaID})
+	if opts.podcast {
+		s.EnablePodcast()
+	}
+	if opts.dlna {
+		s.EnableDLNA(opts.dlnaName)
+	}
+	if opts.rtspPort > 0 {
+		s.EnableRTSP(opts.rtspPort)
+	}
+	if opts.announce {
+		s.EnableAnnounce()
+	}
+	if opts.multicast != "" {
+		for _, spec := range strings.Split(opts.multicast, ",") {
+			stationID, format, group, err := server.ParseMulticast(spec)
+			if err == nil {
+				err = s.StartMulticast(stationID, format, group)
+			}
+			if err != nil {
+				fmt.Printf("❌ マルチキャストの設定エラー: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	if dir, err := config.Dir(); err == nil {
+		if err := s.SetStatsFile(filepath.Join(dir, "stats.json")); err != nil {
+			fmt.Printf("⚠ 統計の読み込みに失敗しました: %v\n", err)
+		}
+	}
+	if opts.autocertHosts != "" {
+		dir, err := config.Dir()
+		if err != nil {
+			fmt.Printf("❌ 証明書キャッシュを作成できません: %v\n", err)
+			os.Exit(1)
+		}
+		s.SetAutocert(strings.Split(opts.autocertHosts, ","), filepath.Join(dir, "autocert"))
+	} else if opts.tlsCert != "" || opts.tlsKey != "" {
+		if opts.tlsCert == "" || opts.tlsKey == "" {
+			fmt.Println("❌ -tls-cert と -tls-key の両方を指定してください")
+			os.Exit(1)
+		}
+		s.SetTLS(opts.tlsCert, opts.tlsKey)
+	}
+
+	// Stop gracefully on Ctrl+C / SIGTERM so that recordings are finalized too
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		ctx, cancel := context.WithTimeout(context.Background(), server.ShutdownTimeout)
+		defer cancel()
+		s.Shutdown(ctx)
+	}()
+
+	// Reload the settings that need no restart on SIGHUP, keeping the streams
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			cfg, err := config.Load()
+			if err == nil {
+				err = applyServerSettings(s, cfg, opts)
+			}
+			if err != nil {
+				fmt.Printf("⚠ 設定の再読み込みに失敗しました: %v\n", err)
+				continue
+			}
+			s.LogReloaded()
+		}
+	}()
+
 	if err := s.Start(); err != nil {
 		fmt.Printf("❌ サーバーエラー: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// applyHTTPSettings applies the http section of config.json (retries,
+// proxy, timeout and TLS) to the requests to radiko
+func applyHTTPSettings(cfg config.Config) {
+	if cfg.HTTP == nil {
+		return
+	}
+	p := api.DefaultRetryPolicy
+	switch {
+	case cfg.HTTP.Retries < 0:
+		p.Retries = 0
+	case cfg.HTTP.Retries > 0:
+		p.Retries = cfg.HTTP.Retries
+	}
+	if cfg.HTTP.BackoffMs > 0 {
+		p.Backoff = time.Duration(cfg.HTTP.BackoffMs) * time.Millisecond
+	}
+	if cfg.HTTP.MaxBackoffMs > 0 {
+		p.MaxBackoff = time.Duration(cfg.HTTP.MaxBackoffMs) * time.Millisecond
+	}
+	if cfg.HTTP.Jitter > 0 {
+		p.Jitter = min(cfg.HTTP.Jitter, 1)
+	}
+	if len(cfg.HTTP.RetryOn) > 0 {
+		p.RetryOn = cfg.HTTP.RetryOn
+	}
+	api.SetRetryPolicy(p)
+
+	if cfg.HTTP.RateLimit != 0 || cfg.HTTP.RateBurst > 0 {
+		rate, burst := api.DefaultRateLimit, api.DefaultRateBurst
+		if cfg.HTTP.RateLimit != 0 {
+			rate = max(cfg.HTTP.RateLimit, 0)
+		}
+		if cfg.HTTP.RateBurst > 0 {
+			burst = cfg.HTTP.RateBurst
+		}
+		api.SetRateLimit(rate, burst)
+	}
+
+	opts := api.ClientOptions{
+		Proxy:     cfg.HTTP.Proxy,
+		Timeout:   time.Duration(cfg.HTTP.TimeoutSeconds) * time.Second,
+		UserAgent: cfg.HTTP.UserAgent,
+	}
+	if cfg.HTTP.CACert != "" || cfg.HTTP.InsecureSkipVerify {
+		opts.TLS = &tls.Config{InsecureSkipVerify: cfg.HTTP.InsecureSkipVerify}
+		if cfg.HTTP.CACert != "" {
+			pem, err := os.ReadFile(cfg.HTTP.CACert)
+			if err != nil {
+				fmt.Printf("⚠ CA証明書を読み込めません: %v\n", err)
+			} else {
+				pool, err := x509.SystemCertPool()
+				if err != nil {
+					pool = x509.NewCertPool()
+				}
+				if !pool.AppendCertsFromPEM(pem) {
+					fmt.Printf("⚠ CA証明書に証明書がありません: %s\n", cfg.HTTP.CACert)
+				}
+				opts.TLS.RootCAs = pool
+			}
+		}
+	}
+	if err := api.SetClientOptions(opts); err != nil {
+		fmt.Printf("⚠ HTTP設定エラー: %v\n", err)
+		return
+	}
+	if cfg.HTTP.Proxy != "" {
+		// ffmpeg streams from radiko itself and reads the proxy from the environment
+		os.Setenv("http_proxy", cfg.HTTP.Proxy)
+		os.Setenv("https_proxy", cfg.HTTP.Proxy)
+	}
+}
+
+// applyCacheSettings applies the cache section of config.json. Responses
+// are cached in the user cache directory unless disabled.
+func applyCacheSettings(cfg config.Config) {
+	opts := api.DefaultCacheOptions
+	c := cfg.Cache
+	if c == nil {
+		c = &config.Cache{}
+	}
+	if c.Disabled {
+		return
+	}
+	opts.Dir = c.Dir
+	if opts.Dir == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return
+		}
+		opts.Dir = filepath.Join(dir, "radiko-tui")
+	}
+	if c.StationsTTLMinutes > 0 {
+		opts.StationsTTL = time.Duration(c.StationsTTLMinutes) * time.Minute
+	}
+	if c.ProgramsTTLMinutes > 0 {
+		opts.ProgramsTTL = time.Duration(c.ProgramsTTLMinutes) * time.Minute
+	}
+	if err := api.SetCache(opts); err != nil {
+		fmt.Printf("⚠ キャッシュ設定エラー: %v\n", err)
+	}
+}
+
+// applyAuthKeySettings applies the auth_key section of config.json, falling
+// back to the built-in key when it cannot be loaded
+func applyAuthKeySettings(cfg config.Config) {
+	k := cfg.AuthKey
+	if k == nil {
+		return
+	}
+	opts := api.AuthKeyOptions{Key: k.Key, File: k.File, URL: k.URL, App: k.App}
+	if err := api.SetAuthKey(context.Background(), opts); err != nil {
+		fmt.Printf("⚠ 認証キーを読み込めませんでした。内蔵のキーを使用します: %v\n", err)
+	}
+}
+
+// loginPremium logs in to radiko premium when config.json has a premium
+// login, reporting whether it succeeded
+func loginPremium(cfg config.Config) bool {
+	if cfg.Premium == nil || cfg.Premium.Email == "" {
+		return false
+	}
+	fmt.Println("🔑 radikoプレミアムにログイン中...")
+	session, err := api.Login(context.Background(), cfg.Premium.Email, cfg.Premium.Password)
+	if err != nil {
+		fmt.Printf("⚠ プレミアムログインに失敗しました。エリア内の放送局のみ再生できます: %v\n", err)
+		return false
+	}
+	if session.AreaFree {
+		fmt.Println("✓ ログイン成功 (エリアフリー: 全国の放送局を再生できます)")
+	} else {
+		fmt.Println("✓ ログイン成功 (エリアフリー未契約)")
+	}
+	return true
+}
+
+// applyServerSettings applies the settings that can change while the server
+// runs: the server section and server_auth of config.json, overridden by the
+// flags given on the command line
+func applyServerSettings(s *server.Server, cfg config.Config, opts serverOptions) error {
+	var settings config.Server
+	if cfg.Server != nil {
+		settings = *cfg.Server
+	}
+	if opts.explicit["grace"] || settings.GraceSeconds == 0 {
+		settings.GraceSeconds = opts.graceSeconds
+	}
+	if opts.explicit["max-clients"] {
+		settings.MaxClients = opts.maxClients
+	}
+	if opts.explicit["max-clients-per-ip"] {
+		settings.MaxClientsPerIP = opts.maxClientsPerIP
+	}
+	if opts.explicit["allow-ip"] {
+		settings.AllowIP = strings.Split(opts.allowIP, ",")
+	}
+	if opts.explicit["deny-ip"] {
+		settings.DenyIP = strings.Split(opts.denyIP, ",")
+	}
+	if opts.explicit["dlna-allow"] {
+		settings.DLNAAllow = strings.Split(opts.dlnaAllow, ",")
+	}
+	if opts.explicit["trusted-proxies"] {
+		settings.TrustedProxies = strings.Split(opts.trustedProxies, ",")
+	}
+	if opts.explicit["log-level"] {
+		settings.LogLevel = opts.logLevel
+	}
+	if opts.explicit["slow-client"] || settings.SlowClient == "" {
+		settings.SlowClient = opts.slowClient
+	}
+	if opts.explicit["slow-client-buffer"] || settings.SlowClientBuffer == 0 {
+		settings.SlowClientBuffer = opts.slowClientBuffer
+	}
+	if opts.explicit["idle-timeout"] || settings.IdleTimeout == 0 {
+		settings.IdleTimeout = opts.idleTimeout
+	}
+
+	if err := s.SetLogLevel(settings.LogLevel); err != nil {
+		return err
+	}
+	if err := s.SetSlowClientPolicy(settings.SlowClient, settings.SlowClientBuffer); err != nil {
+		return err
+	}
+	s.SetIdleTimeout(settings.IdleTimeout)
+	if err := s.SetAccess(settings.AllowIP, settings.DenyIP); err != nil {
+		return fmt.Errorf("IP制限: %w", err)
+	}
+	if err := s.SetTrustedProxies(settings.TrustedProxies); err != nil {
+		return fmt.Errorf("信頼するプロキシ: %w", err)
+	}
+	if err := s.SetDLNAAllow(settings.DLNAAllow); err != nil {
+		return fmt.Errorf("DLNAの許可ネットワーク: %w", err)
+	}
+	s.SetGraceSeconds(settings.GraceSeconds)
+	s.SetLimits(settings.MaxClients, settings.MaxClientsPerIP)
+	s.SetStationLimits(settings.MaxClientsPerStation)
+	var auth config.ServerAuth
+	if cfg.ServerAuth != nil {
+		auth = *cfg.ServerAuth
+	}
+	s.SetAuth(auth.Token, auth.Username, auth.Password)
+	return nil
+}
+
+// writePodcastFeed writes an RSS feed of the finished recordings to path
+func writePodcastFeed(path, baseURL string) {
+	entries, err := recorder.History()
+	if err != nil {
+		fmt.Printf("❌ 録音履歴の読み込みに失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := recorder.FeedOptions{Link: baseURL}
+	if baseURL != "" {
+		cfg, _ := config.Load()
+		opts.EnclosureURL = recorder.RelativeEnclosureURL(recorder.OutputDir(cfg), baseURL)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Printf("❌ フィードの作成に失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	if err := recorder.WriteFeed(f, entries, opts); err != nil {
+		fmt.Printf("❌ フィードの作成に失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ %d 件の録音を %s に書き出しました\n", len(entries), path)
+}
+
+// resolveServerURL returns the server URL given on the command line, finding
+// a server announcing itself on the LAN (-announce) for "auto"
+func resolveServerURL(serverURL string) string {
+	if serverURL != "auto" {
+		return serverURL
+	}
+	fmt.Println("🔎 LAN上のサーバーを探しています...")
+	servers, err := server.Discover(3 * time.Second)
+	if err != nil {
+		fmt.Printf("❌ サーバーを探せません: %v\n", err)
+		os.Exit(1)
+	}
+	if len(servers) == 0 {
+		fmt.Println("❌ サーバーが見つかりません。サーバーを -announce 付きで起動してください")
+		os.Exit(1)
+	}
+	for _, srv := range servers {
+		fmt.Printf("   %s: %s\n", srv.Name, srv.URL)
+	}
+	return servers[0].URL
+}
+
+// runAdmin starts the admin view of a remote server
+func runAdmin(serverURL string) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("⚠ 設定の読み込みに失敗しました。デフォルト設定を使用します: %v\n", err)
+		cfg = config.DefaultConfig()
+	}
+	var token string
+	if cfg.ServerAuth != nil {
+		token = cfg.ServerAuth.Token
+	}
+	if err := tui.ApplyTheme(cfg); err != nil {
+		fmt.Printf("⚠ テーマ設定エラー: %v\n", err)
+	}
+
+	fmt.Printf("🔗 サーバーに接続: %s\n", serverURL)
+	if err := tui.RunAdmin(serverURL, token); err != nil {
+		fmt.Printf("❌ サーバー管理エラー: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 // runTUI starts the terminal UI mode (local or client)
-func runTUI(volumePercent int, serverURL string) {
+func runTUI(volumePercent int, sampleRate int, serverURL string) {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -70,28 +619,62 @@ func runTUI(volumePercent int, serverURL string) {
 		}
 	}
 
+	// If sample rate is specified via command line, override config
+	if sampleRate > 0 {
+		cfg.SampleRate = sampleRate
+	}
+
+	applyHTTPSettings(cfg)
+	applyCacheSettings(cfg)
+	applyAuthKeySettings(cfg)
+
+	// Start in the listener's own area on the first run
+	if !config.Exists() && os.Getenv(config.EnvArea) == "" {
+		fmt.Println("📍 エリアを検出中...")
+		if areaID, err := api.DetectArea(context.Background()); err != nil {
+			fmt.Printf("⚠ エリアを検出できませんでした。%s を使用します: %v\n", cfg.AreaID, err)
+		} else {
+			cfg.AreaID = areaID
+			fmt.Printf("✓ %s (%s)\n", model.FindAreaByID(areaID).Name, areaID)
+		}
+	}
+
 	var authToken string
 	if serverURL == "" {
+		if loginPremium(cfg) {
+			defer api.Logout(context.Background())
+		}
 		// Get authentication token (Local mode only)
 		fmt.Println("🔐 認証中...")
-		authToken = api.Auth(cfg.AreaID)
-		fmt.Println("✓ 認証成功")
+		authToken, err = api.Token(context.Background(), cfg.AreaID)
+		if err != nil {
+			fmt.Printf("⚠ 認証に失敗しました: %v\n", err)
+		} else {
+			fmt.Println("✓ 認証成功")
+		}
 	} else {
 		fmt.Printf("🔗 サーバーに接続: %s\n", serverURL)
 	}
 
 	// Get station list
 	fmt.Printf("📡 %s 地域の放送局リストを取得中...\n", cfg.AreaID)
-	stations, err := api.GetStations(cfg.AreaID)
-	if err != nil {
-		fmt.Printf("❌ 放送局リストの取得に失敗しました: %v\n", err)
-		os.Exit(1)
-	}
-	fmt.Printf("✓ %d 局を検出しました\n", len(stations))
-
-	if len(stations) == 0 {
+	ctx, cache := api.WithCacheStatus(context.Background())
+	stations, err := api.GetStations(ctx, cfg.AreaID)
+	_, offline := cache.Cached()
+	switch {
+	case err != nil:
+		// Start anyway and keep trying in the background
+		fmt.Printf("⚠ 放送局リストの取得に失敗しました。オフラインで起動します: %v\n", err)
+		offline = true
+	case offline:
+		stored, _ := cache.Cached()
+		fmt.Printf("✓ %d 局を検出しました\n", len(stations))
+		fmt.Printf("⚠ radiko に接続できないため、キャッシュ (%s 取得) を使用します\n", stored.Format("01/02 15:04"))
+	case len(stations) == 0:
 		fmt.Println("❌ 利用可能な放送局がありません")
 		os.Exit(1)
+	default:
+		fmt.Printf("✓ %d 局を検出しました\n", len(stations))
 	}
 
 	// Display last played station
@@ -101,7 +684,7 @@ func runTUI(volumePercent int, serverURL string) {
 
 	// Run TUI
 	fmt.Println("🚀 インターフェースを起動中...")
-	err = tui.Run(stations, authToken, cfg, serverURL)
+	err = tui.Run(stations, offline, authToken, cfg, serverURL)
 	if err != nil {
 		fmt.Printf("❌ インターフェースエラー: %v\n", err)
 		os.Exit(1)
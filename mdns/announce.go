@@ -0,0 +1,237 @@
+package mdns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+// announceTTL is how long (seconds) browsers may cache the records
+const announceTTL = 120
+
+// servicesName lists the services of the LAN (DNS-SD service enumeration)
+const servicesName = "_services._dns-sd._udp.local."
+
+// Service is a service instance to announce
+type Service struct {
+	Instance string            // Instance name, e.g. "radiko-tui (myhost)"
+	Service  string            // Service type, e.g. "_radiko-tui._tcp"
+	Port     int               // Service port
+	TXT      map[string]string // TXT record keys and values
+}
+
+// responder answers mDNS queries for one service instance
+type responder struct {
+	service  dnsmessage.Name // e.g. "_radiko-tui._tcp.local."
+	instance dnsmessage.Name // e.g. "radiko-tui (myhost)._radiko-tui._tcp.local."
+	host     dnsmessage.Name // e.g. "radiko-tui-myhost.local."
+	port     uint16
+	txt      []string
+}
+
+// Announce announces a service instance on every LAN interface and answers
+// the queries for it until ctx is done, then says goodbye. It returns an
+// error when the mDNS port can't be opened.
+func Announce(ctx context.Context, svc Service) error {
+	r, err := newResponder(svc)
+	if err != nil {
+		return err
+	}
+
+	// Go opens the port with SO_REUSEADDR, so the system responder (Avahi,
+	// Bonjour) keeps working alongside
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return err
+	}
+	pc := ipv4.NewPacketConn(conn)
+	ifaces := multicastInterfaces()
+	for _, ifi := range ifaces {
+		pc.JoinGroup(&ifi, mdnsGroup) // Fails harmlessly when already joined
+	}
+	pc.SetMulticastTTL(255)
+
+	go func() {
+		// Announce twice, a second apart, as RFC 6762 asks
+		r.announce(pc, ifaces, announceTTL)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			r.announce(pc, ifaces, announceTTL)
+			<-ctx.Done()
+		}
+		r.announce(pc, ifaces, 0)
+		conn.Close()
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil // Closed on ctx done
+		}
+		r.answer(conn, buf[:n], from)
+	}
+}
+
+// newResponder builds the DNS names of a service instance
+func newResponder(svc Service) (*responder, error) {
+	service := strings.ToLower(strings.TrimSuffix(svc.Service, ".")) + ".local."
+	serviceName, err := dnsmessage.NewName(service)
+	if err != nil {
+		return nil, err
+	}
+	// Dots would split the instance label
+	instance := strings.ReplaceAll(svc.Instance, ".", "-")
+	instanceName, err := dnsmessage.NewName(instance + "." + service)
+	if err != nil {
+		return nil, err
+	}
+	hostName, err := dnsmessage.NewName(hostLabel(svc.Instance) + ".local.")
+	if err != nil {
+		return nil, err
+	}
+	r := &responder{service: serviceName, instance: instanceName, host: hostName, port: uint16(svc.Port)}
+	for k, v := range svc.TXT {
+		r.txt = append(r.txt, k+"="+v)
+	}
+	if len(r.txt) == 0 {
+		r.txt = []string{""} // A TXT record can't be empty
+	}
+	return r, nil
+}
+
+// hostLabel turns an instance name into a host name label of its own, so as
+// not to clash with the system's
+func hostLabel(instance string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(instance) {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			b.WriteRune(c)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// records returns the PTR, SRV, TXT and A records of the instance, with ip
+// as the host's address
+func (r *responder) records(ip net.IP, ttl uint32) []dnsmessage.Resource {
+	header := func(name dnsmessage.Name, typ dnsmessage.Type, flush bool) dnsmessage.ResourceHeader {
+		class := dnsmessage.ClassINET
+		if flush {
+			class |= 1 << 15 // Cache-flush: the records are unique to this host
+		}
+		return dnsmessage.ResourceHeader{Name: name, Type: typ, Class: class, TTL: ttl}
+	}
+	records := []dnsmessage.Resource{
+		{Header: header(r.service, dnsmessage.TypePTR, false), Body: &dnsmessage.PTRResource{PTR: r.instance}},
+		{Header: header(r.instance, dnsmessage.TypeSRV, true), Body: &dnsmessage.SRVResource{Target: r.host, Port: r.port}},
+		{Header: header(r.instance, dnsmessage.TypeTXT, true), Body: &dnsmessage.TXTResource{TXT: r.txt}},
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		var a [4]byte
+		copy(a[:], ip4)
+		records = append(records, dnsmessage.Resource{Header: header(r.host, dnsmessage.TypeA, true), Body: &dnsmessage.AResource{A: a}})
+	}
+	return records
+}
+
+// announce multicasts the records on each interface; a TTL of 0 says goodbye
+func (r *responder) announce(pc *ipv4.PacketConn, ifaces []net.Interface, ttl uint32) {
+	for _, ifi := range ifaces {
+		if err := pc.SetMulticastInterface(&ifi); err != nil {
+			continue
+		}
+		msg := dnsmessage.Message{
+			Header:  dnsmessage.Header{Response: true, Authoritative: true},
+			Answers: r.records(interfaceIPv4(ifi), ttl),
+		}
+		if packet, err := msg.Pack(); err == nil {
+			pc.WriteTo(packet, nil, mdnsGroup)
+		}
+	}
+}
+
+// answer replies to a query for the service, the instance or its host.
+// Queries from a port other than 5353 get a unicast reply, others a
+// multicast one.
+func (r *responder) answer(conn *net.UDPConn, packet []byte, from *net.UDPAddr) {
+	var query dnsmessage.Message
+	if err := query.Unpack(packet); err != nil || query.Response {
+		return
+	}
+
+	// The address the querier reached us at is the one to hand back
+	c, err := net.DialUDP("udp4", nil, from)
+	if err != nil {
+		return
+	}
+	local := c.LocalAddr().(*net.UDPAddr).IP
+	c.Close()
+	all := r.records(local, announceTTL)
+
+	var answers []dnsmessage.Resource
+	var questions []dnsmessage.Question
+	for _, q := range query.Questions {
+		name := strings.ToLower(q.Name.String())
+		switch {
+		case name == servicesName && (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL):
+			answers = append(answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: announceTTL},
+				Body:   &dnsmessage.PTRResource{PTR: r.service},
+			})
+		case name == strings.ToLower(r.service.String()) && (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL):
+			answers = append(answers, all...) // The PTR with the rest as the data to resolve it
+		case name == strings.ToLower(r.instance.String()) || name == strings.ToLower(r.host.String()):
+			for _, rr := range all {
+				if strings.EqualFold(rr.Header.Name.String(), name) && (q.Type == rr.Header.Type || q.Type == dnsmessage.TypeALL) {
+					answers = append(answers, rr)
+				}
+			}
+		default:
+			continue
+		}
+		questions = append(questions, q)
+	}
+	if len(answers) == 0 {
+		return
+	}
+
+	msg := dnsmessage.Message{
+		Header:  dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: answers,
+	}
+	to := mdnsGroup
+	if from.Port != mdnsGroup.Port {
+		// Legacy unicast query: echo the ID and questions
+		msg.Header.ID = query.Header.ID
+		msg.Questions = questions
+		to = from
+	}
+	if reply, err := msg.Pack(); err == nil {
+		conn.WriteToUDP(reply, to)
+	}
+}
+
+// interfaceIPv4 returns the first IPv4 address of an interface
+func interfaceIPv4(ifi net.Interface) net.IP {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return ipnet.IP.To4()
+		}
+	}
+	return nil
+}
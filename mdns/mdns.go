@@ -0,0 +1,187 @@
+// Package mdns finds DNS-SD services (Chromecast, AirPlay) on the LAN
+package mdns
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+// mdnsGroup is the mDNS multicast address
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Instance is an announced service instance
+type Instance struct {
+	Name string            // Instance name, e.g. "Chromecast-1234"
+	Host string            // IP address
+	Port int               // Service port
+	TXT  map[string]string // TXT record keys and values
+}
+
+// Browse searches the LAN for instances of a service (e.g. "_googlecast._tcp")
+// for the given time
+func Browse(service string, timeout time.Duration) ([]Instance, error) {
+	service = strings.ToLower(strings.TrimSuffix(service, ".")) + ".local."
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	query, err := ptrQuery(service)
+	if err != nil {
+		return nil, err
+	}
+
+	// Queries from a port other than 5353 are answered by unicast, so
+	// there is no need to share the mDNS port with the system responder
+	pc := ipv4.NewPacketConn(conn)
+	sent := false
+	for _, ifi := range multicastInterfaces() {
+		if pc.SetMulticastInterface(&ifi) == nil {
+			if _, err := pc.WriteTo(query, nil, mdnsGroup); err == nil {
+				sent = true
+			}
+		}
+	}
+	if !sent {
+		if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+			return nil, err
+		}
+	}
+
+	found := newBrowser(service)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break // Deadline reached
+		}
+		found.parse(buf[:n], from.IP)
+	}
+	return found.instances(), nil
+}
+
+// ptrQuery builds a PTR query for a service
+func ptrQuery(service string) ([]byte, error) {
+	name, err := dnsmessage.NewName(service)
+	if err != nil {
+		return nil, err
+	}
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}
+	return msg.Pack()
+}
+
+// multicastInterfaces returns the interfaces that can send mDNS queries
+func multicastInterfaces() []net.Interface {
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var ifaces []net.Interface
+	for _, ifi := range all {
+		if ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagMulticast != 0 && ifi.Flags&net.FlagLoopback == 0 {
+			ifaces = append(ifaces, ifi)
+		}
+	}
+	return ifaces
+}
+
+// record is what is known about one announced instance
+type record struct {
+	target string // Host name from the SRV record
+	port   int
+	txt    map[string]string
+	from   net.IP // Address the answer came from
+}
+
+// browser collects records from mDNS answers
+type browser struct {
+	service string
+	records map[string]*record // By instance name (full DNS name)
+	hosts   map[string]net.IP  // A records by host name
+}
+
+// newBrowser creates a browser for a service
+func newBrowser(service string) *browser {
+	return &browser{service: service, records: make(map[string]*record), hosts: make(map[string]net.IP)}
+}
+
+// record returns the entry of an instance, creating it
+func (b *browser) record(instance string, from net.IP) *record {
+	rec, ok := b.records[instance]
+	if !ok {
+		rec = &record{from: from, txt: make(map[string]string)}
+		b.records[instance] = rec
+	}
+	return rec
+}
+
+// parse records the answers of one mDNS response
+func (b *browser) parse(packet []byte, from net.IP) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(packet); err != nil {
+		return
+	}
+	records := append(msg.Answers, msg.Additionals...)
+	for _, rr := range records {
+		name := rr.Header.Name.String()
+		switch body := rr.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if instance := body.PTR.String(); strings.EqualFold(name, b.service) && b.instanceOf(instance) {
+				b.record(instance, from)
+			}
+		case *dnsmessage.SRVResource:
+			if b.instanceOf(name) {
+				rec := b.record(name, from)
+				rec.target = strings.ToLower(body.Target.String())
+				rec.port = int(body.Port)
+			}
+		case *dnsmessage.TXTResource:
+			if b.instanceOf(name) {
+				rec := b.record(name, from)
+				for _, txt := range body.TXT {
+					k, v, _ := strings.Cut(txt, "=")
+					rec.txt[strings.ToLower(k)] = v
+				}
+			}
+		case *dnsmessage.AResource:
+			b.hosts[strings.ToLower(name)] = net.IP(body.A[:])
+		}
+	}
+}
+
+// instances returns the complete instances, sorted by name
+func (b *browser) instances() []Instance {
+	var instances []Instance
+	for name, rec := range b.records {
+		if rec.port == 0 {
+			continue
+		}
+		host := rec.from
+		if ip, ok := b.hosts[rec.target]; ok {
+			host = ip
+		}
+		instances = append(instances, Instance{
+			Name: name[:len(name)-len(b.service)-1],
+			Host: host.String(),
+			Port: rec.port,
+			TXT:  rec.txt,
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+	return instances
+}
+
+// instanceOf reports whether a record name is an instance of the service
+func (b *browser) instanceOf(name string) bool {
+	return len(name) > len(b.service)+1 && strings.EqualFold(name[len(name)-len(b.service)-1:], "."+b.service)
+}
@@ -1,5 +1,10 @@
 package model
 
+import (
+	"encoding/xml"
+	"time"
+)
+
 // ProgramResponse represents the program API response
 type ProgramResponse struct {
 	Stations []StationProgram `json:"stations"`
@@ -17,10 +22,58 @@ type Programs struct {
 	Program []Program `json:"program"`
 }
 
-// Program represents a single program
+// Program represents a single program, from the JSON or the XML API
 type Program struct {
-	Ft    string `json:"ft"`    // Start time YYYYMMDDHHMMSS
-	To    string `json:"to"`    // End time YYYYMMDDHHMMSS
-	Title string `json:"title"` // Program title
-	Pfm   string `json:"pfm"`   // Host/Performer
+	Ft    string `json:"ft" xml:"ft,attr"`          // Start time YYYYMMDDHHMMSS
+	To    string `json:"to" xml:"to,attr"`          // End time YYYYMMDDHHMMSS
+	Title string `json:"title" xml:"title"`         // Program title
+	Pfm   string `json:"pfm" xml:"pfm"`             // Host/Performer
+	Desc  string `json:"desc,omitempty" xml:"desc"` // Description (HTML)
+	Info  string `json:"info,omitempty" xml:"info"` // Program notes (HTML)
+	URL   string `json:"url,omitempty" xml:"url"`   // Program website
+	Img   string `json:"img,omitempty" xml:"img"`   // Program image URL
+}
+
+// SearchResult is a program found by radiko's program search
+type SearchResult struct {
+	StationID string `json:"station_id"`
+	Program
+	Status string `json:"status"` // "past" (timefree), "now" or "future"
+}
+
+// RadikoPrograms is the program XML of an area or a station
+type RadikoPrograms struct {
+	XMLName  xml.Name          `xml:"radiko"`
+	Stations []StationSchedule `xml:"stations>station"`
+}
+
+// StationSchedule is a station's programs in the program XML, over one or
+// more broadcast days
+type StationSchedule struct {
+	ID       string    `xml:"id,attr"`
+	Name     string    `xml:"name"`
+	Programs []Program `xml:"progs>prog"`
+}
+
+// JST is the Japan time zone used by radiko program times
+var JST = time.FixedZone("JST", 9*60*60)
+
+// programTimeLayout is the layout of Ft/To (YYYYMMDDHHMMSS)
+const programTimeLayout = "20060102150405"
+
+// BroadcastDay returns the radiko broadcast day of t, which runs from 5:00
+// to 29:00 JST
+func BroadcastDay(t time.Time) time.Time {
+	t = t.In(JST).Add(-5 * time.Hour)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, JST)
+}
+
+// StartTime returns the program start time
+func (p Program) StartTime() (time.Time, error) {
+	return time.ParseInLocation(programTimeLayout, p.Ft, JST)
+}
+
+// EndTime returns the program end time
+func (p Program) EndTime() (time.Time, error) {
+	return time.ParseInLocation(programTimeLayout, p.To, JST)
 }
@@ -106,6 +106,10 @@ var AllRegions = []Region{
 	},
 }
 
+// AllAreasID is the pseudo area listing the stations of every area, which
+// radiko premium (area-free) members can play
+const AllAreasID = "ALL"
+
 // AllAreas returns a flattened list of all areas
 func AllAreas() []Area {
 	var areas []Area
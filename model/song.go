@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// SongResponse represents the NowOnAir music API response
+type SongResponse struct {
+	Data []Song `json:"data"`
+}
+
+// Song represents a track played on air
+type Song struct {
+	Title     string `json:"title"`                // Track title
+	Artist    string `json:"artist_name"`          // Artist name
+	StartedAt string `json:"displayed_start_time"` // Start time (RFC 3339)
+}
+
+// StartTime returns when the song started playing
+func (s Song) StartTime() (time.Time, error) {
+	return time.Parse(time.RFC3339, s.StartedAt)
+}
+
+// NowOnAirFeed represents radiko's now-on-air feed of a station, newest
+// track first
+type NowOnAirFeed struct {
+	Tracks []Track `xml:"item"`
+}
+
+// Track represents a song in the now-on-air feed
+type Track struct {
+	Stamp      string `xml:"stamp,attr"`     // Start time "2006-01-02 15:04:05" (JST)
+	Title      string `xml:"title,attr"`     // Track title
+	Artist     string `xml:"artist,attr"`    // Artist name
+	Image      string `xml:"img,attr"`       // Artwork URL
+	ImageLarge string `xml:"img_large,attr"` // Large artwork URL
+}
+
+// StartTime returns when the track started playing
+func (t Track) StartTime() (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04:05", t.Stamp, JST)
+}
+
+// Artwork returns the largest artwork URL of the track, or ""
+func (t Track) Artwork() string {
+	if t.ImageLarge != "" {
+		return t.ImageLarge
+	}
+	return t.Image
+}
+
+// String returns "artist - title", or the title alone
+func (t Track) String() string {
+	if t.Artist == "" {
+		return t.Title
+	}
+	return t.Artist + " - " + t.Title
+}
@@ -2,14 +2,74 @@ package model
 
 import "encoding/xml"
 
+// RadikoStations is the station list XML of an area
 type RadikoStations struct {
-	XMLName  xml.Name  `xml:"radiko"`
-	Stations []Station `xml:"stations>station"`
+	XMLName  xml.Name  `xml:"stations"`
+	AreaID   string    `xml:"area_id,attr"`
+	Stations []Station `xml:"station"`
+}
+
+// RadikoRegions is the station list XML of every area, by region
+type RadikoRegions struct {
+	XMLName xml.Name         `xml:"region"`
+	Regions []RadikoStations `xml:"stations"`
 }
 
 type Station struct {
-	ID   string `xml:"id,attr"`
-	Name string `xml:"name"`
+	ID        string `xml:"id"`
+	Name      string `xml:"name"`
+	AsciiName string `xml:"ascii_name"` // Name in latin letters, e.g. "TBS RADIO"
+	Ruby      string `xml:"ruby"`       // Reading of the name in kana
+	Logos     []Logo `xml:"logo"`       // Logo in several sizes
+	Banner    string `xml:"banner"`     // Banner image URL
+	Website   string `xml:"href"`       // Station website
+	AreaFree  bool   `xml:"areafree"`   // Can be heard outside its area with radiko premium
+	TimeFree  bool   `xml:"timefree"`   // Past programs can be played (timefree)
+	AreaID    string `xml:"area_id"`    // Home area, only in the list of every area
+}
+
+// Availability tells whether a station plays for a listener
+type Availability int
+
+const (
+	FreeArea       Availability = iota // Plays: the listener is in its area or has an area-free session
+	PremiumOnly                        // Plays outside its area with radiko premium only
+	AreaRestricted                     // Plays in its own area only
+)
+
+// Availability returns whether the station plays for a listener in areaID,
+// with or without a radiko premium area-free session. Stations of an area's
+// list, which have no area ID, play in that area.
+func (s Station) Availability(areaID string, areaFree bool) Availability {
+	switch {
+	case s.AreaID == "" || s.AreaID == areaID:
+		return FreeArea
+	case !s.AreaFree:
+		return AreaRestricted
+	case areaFree:
+		return FreeArea
+	default:
+		return PremiumOnly
+	}
+}
+
+// Logo is a station logo image
+type Logo struct {
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+	URL    string `xml:",chardata"`
+}
+
+// LogoURL returns the URL of the station's largest logo, or "" when the
+// list had none
+func (s Station) LogoURL() string {
+	var best Logo
+	for _, logo := range s.Logos {
+		if logo.Width*logo.Height > best.Width*best.Height {
+			best = logo
+		}
+	}
+	return best.URL
 }
 
 type RadikoURLs struct {
@@ -0,0 +1,169 @@
+// Package radiko is a client for radiko.jp, the Japanese internet radio:
+// station lists, program guides and search, now-on-air tracks, and
+// authenticated live and timefree stream URLs. It is the stable entry point
+// to the code radiko-tui itself runs on.
+//
+//	c := radiko.NewClient(radiko.Options{})
+//	stations, err := c.Stations(ctx, "JP13")
+//	token, err := c.Token(ctx, "JP13")
+//	streams, err := c.StreamURLs(ctx, "TBS", token)
+//	// Play radiko.PreferredStream(streams).URL with the header
+//	// X-Radiko-AuthToken: token
+//
+// Each client caches its own auth tokens per area and limits its own
+// requests; clients do not share either with each other or with radiko-tui.
+package radiko
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// Types of radiko's data
+type (
+	Station         = model.Station
+	Program         = model.Program
+	StationSchedule = model.StationSchedule
+	SearchResult    = model.SearchResult
+	Track           = model.Track
+	Song            = model.Song
+	Area            = model.Area
+	Availability    = model.Availability
+	Stream          = api.Stream
+	Variant         = api.Variant
+	Quality         = api.Quality
+	SearchQuery     = api.SearchQuery
+)
+
+const (
+	QualityDefault = api.QualityDefault // The master playlist as it is
+	QualityLow     = api.QualityLow     // Lowest bandwidth variant
+	QualityHigh    = api.QualityHigh    // Highest bandwidth variant
+)
+
+// JST is Japan time, in which radiko's schedules are given
+var JST = model.JST
+
+// Options configures a client. Zero values keep the defaults.
+type Options struct {
+	HTTPClient *http.Client // Client of the requests (default http.DefaultClient)
+	UserAgent  string       // User-Agent of requests that set none (authentication keeps the app's)
+	RateLimit  float64      // Requests per second (default 5, negative = no limit)
+	RateBurst  int          // Requests made at once before RateLimit applies (default 10)
+}
+
+// Client talks to radiko
+type Client struct {
+	api *api.Client
+}
+
+// NewClient returns a client with the given options
+func NewClient(opts Options) *Client {
+	c := &Client{api: api.NewClient(opts.HTTPClient, opts.UserAgent)}
+	rate, burst := opts.RateLimit, opts.RateBurst
+	if rate == 0 {
+		rate = api.DefaultRateLimit
+	}
+	if rate < 0 {
+		rate = 0
+	}
+	if burst == 0 {
+		burst = api.DefaultRateBurst
+	}
+	c.api.SetRateLimit(rate, burst)
+	return c
+}
+
+// Areas returns the 47 prefectures radiko serves, JP1 to JP47
+func Areas() []Area {
+	return model.AllAreas()
+}
+
+// DetectArea returns the area of the caller's IP address, e.g. "JP13"
+func (c *Client) DetectArea(ctx context.Context) (string, error) {
+	return c.api.DetectArea(ctx)
+}
+
+// Stations returns the stations of an area
+func (c *Client) Stations(ctx context.Context, areaID string) ([]Station, error) {
+	return c.api.GetStations(ctx, areaID)
+}
+
+// AllStations returns the stations of every area, each with its home area
+func (c *Client) AllStations(ctx context.Context) ([]Station, error) {
+	return c.api.GetAllStations(ctx)
+}
+
+// StationArea returns the home area of a station
+func (c *Client) StationArea(ctx context.Context, stationID string) (string, error) {
+	return c.api.GetStationArea(ctx, stationID)
+}
+
+// Token returns an auth token for an area, valid for about an hour. Any
+// area can be authenticated from anywhere.
+func (c *Client) Token(ctx context.Context, areaID string) (string, error) {
+	return c.api.Token(ctx, areaID)
+}
+
+// InvalidateToken drops the cached token of an area, e.g. after radiko
+// rejected it
+func (c *Client) InvalidateToken(areaID string) {
+	c.api.InvalidateToken(areaID)
+}
+
+// StreamURLs returns the live streams of a station, playable with a token
+// of the station's area
+func (c *Client) StreamURLs(ctx context.Context, stationID, token string) ([]Stream, error) {
+	return c.api.GetStreamURLs(ctx, stationID, token)
+}
+
+// PreferredStream returns the stream radiko's own player uses
+func PreferredStream(streams []Stream) Stream {
+	return api.PreferredStream(streams)
+}
+
+// TimefreeStreamURL returns the playlist URL of what a station broadcast
+// between two times, playable with a token of the station's area
+func (c *Client) TimefreeStreamURL(ctx context.Context, stationID string, ft, to time.Time) (string, error) {
+	return c.api.GetTimefreeStreamURL(ctx, stationID, ft, to)
+}
+
+// CurrentProgram returns the program a station is broadcasting, or nil
+func (c *Client) CurrentProgram(ctx context.Context, stationID string) (*Program, error) {
+	return c.api.GetCurrentProgram(ctx, stationID)
+}
+
+// NowPrograms returns the programs every station of an area is broadcasting
+func (c *Client) NowPrograms(ctx context.Context, areaID string) ([]StationSchedule, error) {
+	return c.api.GetNowPrograms(ctx, areaID)
+}
+
+// Programs returns a station's programs of the broadcast day of date
+// (05:00 to 29:00 JST)
+func (c *Client) Programs(ctx context.Context, stationID string, date time.Time) ([]Program, error) {
+	return c.api.GetPrograms(ctx, stationID, date)
+}
+
+// WeeklyPrograms returns a station's programs of the past and coming week
+func (c *Client) WeeklyPrograms(ctx context.Context, stationID string) ([]Program, error) {
+	return c.api.GetWeeklyPrograms(ctx, stationID)
+}
+
+// Search searches past and upcoming programs
+func (c *Client) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	return c.api.SearchPrograms(ctx, q)
+}
+
+// NowOnAir returns the track a station is playing, or nil
+func (c *Client) NowOnAir(ctx context.Context, stationID string) (*Track, error) {
+	return c.api.GetNowOnAir(ctx, stationID)
+}
+
+// Songs returns the songs a station played between two times
+func (c *Client) Songs(ctx context.Context, stationID string, from, to time.Time) ([]Song, error) {
+	return c.api.GetSongs(ctx, stationID, from, to)
+}
@@ -0,0 +1,74 @@
+package radiko
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kanoshiou/radiko-tui/radikotest"
+)
+
+func TestClientToken(t *testing.T) {
+	srv := radikotest.NewServer()
+	defer srv.Close()
+	ctx := context.Background()
+	a := NewClient(Options{HTTPClient: srv.Client()})
+	b := NewClient(Options{HTTPClient: srv.Client(), RateLimit: -1})
+
+	first, err := a.Token(ctx, "JP13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := a.Token(ctx, "JP13"); err != nil || again != first {
+		t.Errorf("second Token = %q, %v, want the cached %q", again, err, first)
+	}
+
+	// Clients do not share tokens
+	other, err := b.Token(ctx, "JP13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Error("two clients got the same token")
+	}
+	if n := srv.Requests("/v2/api/auth1"); n != 2 {
+		t.Errorf("auth1 requests = %d, want 2", n)
+	}
+
+	a.InvalidateToken("JP13")
+	renewed, err := a.Token(ctx, "JP13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed == first || !srv.ValidToken(renewed) {
+		t.Errorf("token after InvalidateToken = %q", renewed)
+	}
+	if again, err := b.Token(ctx, "JP13"); err != nil || again != other {
+		t.Errorf("other client's token = %q, %v, want %q", again, err, other)
+	}
+}
+
+func TestClientStreamURLs(t *testing.T) {
+	srv := radikotest.NewServer()
+	defer srv.Close()
+	ctx := context.Background()
+	c := NewClient(Options{HTTPClient: srv.Client()})
+
+	stations, err := c.Stations(ctx, "JP27")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 1 || stations[0].ID != "ABC" {
+		t.Fatalf("JP27 stations = %+v, want ABC", stations)
+	}
+	token, err := c.Token(ctx, "JP27")
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams, err := c.StreamURLs(ctx, "ABC", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PreferredStream(streams).URL == "" {
+		t.Errorf("no preferred stream in %+v", streams)
+	}
+}
@@ -0,0 +1,52 @@
+package player
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deviceRateTimeout bounds the query of the sound server
+const deviceRateTimeout = 2 * time.Second
+
+// DeviceSampleRate returns the sample rate of the default audio output, or 0
+// if it is unknown. It is asked from PulseAudio or PipeWire on Linux, where
+// ALSA may play the 48kHz audio at the wrong speed; other systems convert
+// the rate themselves.
+func DeviceSampleRate() int {
+	if runtime.GOOS != "linux" {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deviceRateTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "pactl", "info")
+	cmd.Env = append(os.Environ(), "LC_ALL=C") // Untranslated field names
+	out, err := cmd.Output()
+	if err != nil {
+		return 0
+	}
+	return parsePactlRate(string(out))
+}
+
+// parsePactlRate returns the rate of the default sample specification in
+// the output of `pactl info` (e.g. "s16le 2ch 44100Hz"), or 0
+func parsePactlRate(out string) int {
+	for _, line := range strings.Split(out, "\n") {
+		spec, ok := strings.CutPrefix(strings.TrimSpace(line), "Default Sample Specification:")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(spec) {
+			if num, ok := strings.CutSuffix(field, "Hz"); ok {
+				if rate, err := strconv.Atoi(num); err == nil && rate > 0 {
+					return rate
+				}
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,22 @@
+package player
+
+import "testing"
+
+func TestParsePactlRate(t *testing.T) {
+	tests := []struct {
+		out  string
+		want int
+	}{
+		{"Server Name: pulseaudio\nDefault Sample Specification: s16le 2ch 44100Hz\nDefault Channel Map: front-left,front-right\n", 44100},
+		{"Server Name: PulseAudio (on PipeWire 1.0.5)\nDefault Sample Specification: float32le 2ch 48000Hz\n", 48000},
+		{"\tDefault Sample Specification: s16le 2ch 96000Hz", 96000},
+		{"Default Sample Specification: n/a", 0},
+		{"Connection failure: Connection refused\n", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := parsePactlRate(tt.out); got != tt.want {
+			t.Errorf("parsePactlRate(%q) = %d, want %d", tt.out, got, tt.want)
+		}
+	}
+}
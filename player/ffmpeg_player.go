@@ -6,10 +6,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 	"os/exec"
-	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
@@ -46,15 +43,8 @@ type FFmpegPlayer struct {
 	onReconnect      func() string
 	reconnectStatus  ReconnectStatus // Reconnection status (for TUI to query)
 	lastError        string          // Last error message
+	outputRate       int             // Audio device sample rate (0 = native 48kHz)
 
-	// Recording related fields
-	recording       bool
-	recordCmd       *exec.Cmd
-	recordCtx       context.Context
-	recordCancel    context.CancelFunc
-	recordFilePath  string
-	recordStation   string
-	recordStartTime time.Time
 }
 
 // NewFFmpegPlayer creates a new ffmpeg player
@@ -91,6 +81,23 @@ func (p *FFmpegPlayer) UpdateAuthToken(token string) {
 	p.authToken = token
 }
 
+// SetOutputSampleRate sets the sample rate used for the audio device.
+// PCM from ffmpeg is resampled in-process when it differs from 48kHz.
+// Must be called before the first Play.
+func (p *FFmpegPlayer) SetOutputSampleRate(rate int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outputRate = rate
+}
+
+// getOutputSampleRate returns the audio device sample rate (caller must hold mu)
+func (p *FFmpegPlayer) getOutputSampleRate() int {
+	if p.outputRate > 0 {
+		return p.outputRate
+	}
+	return NativeSampleRate
+}
+
 // GetReconnectStatus returns the current reconnection status
 func (p *FFmpegPlayer) GetReconnectStatus() ReconnectStatus {
 	p.mu.Lock()
@@ -132,7 +139,7 @@ func (p *FFmpegPlayer) Play(streamURL string) error {
 	}
 
 	if p.otoContext == nil {
-		err = p.initAudio(48000, 2)
+		err = p.initAudio(p.getOutputSampleRate(), 2)
 		if err != nil {
 			return fmt.Errorf("failed to init audio: %w", err)
 		}
@@ -161,7 +168,7 @@ func (p *FFmpegPlayer) Play(streamURL string) error {
 	p.playing = true
 	p.lastDataTime = time.Now()
 
-	go p.pumpAudio(stdout)
+	go p.pumpAudio(NewResampler(stdout, NativeSampleRate, p.getOutputSampleRate(), 2))
 	go p.monitorPlayback()
 
 	return nil
@@ -403,124 +410,3 @@ func (p *FFmpegPlayer) Reconnect() error {
 
 	return nil
 }
-
-// getDownloadsDir returns the user's Downloads directory
-func getDownloadsDir() string {
-	homeDir, _ := os.UserHomeDir()
-	return filepath.Join(homeDir, "Downloads")
-}
-
-// StartRecording starts recording the current stream to a file
-func (p *FFmpegPlayer) StartRecording(stationName string) error {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	if !p.playing {
-		return fmt.Errorf("再生中でないと録音できません")
-	}
-
-	if p.recording {
-		return fmt.Errorf("既に録音中です")
-	}
-
-	// Create filename with timestamp
-	now := time.Now()
-	timestamp := now.Format("20060102_150405")
-	safeName := stationName
-	// Remove invalid characters for filename
-	for _, char := range []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|", " "} {
-		safeName = strings.ReplaceAll(safeName, char, "_")
-	}
-	filename := fmt.Sprintf("radiko_%s_%s.aac", safeName, timestamp)
-	downloadDir := getDownloadsDir()
-
-	// Ensure downloads directory exists
-	if err := os.MkdirAll(downloadDir, 0755); err != nil {
-		return fmt.Errorf("ダウンロードフォルダの作成に失敗しました: %w", err)
-	}
-
-	p.recordFilePath = filepath.Join(downloadDir, filename)
-	p.recordStation = stationName
-	p.recordStartTime = now
-
-	// Create context for recording
-	p.recordCtx, p.recordCancel = context.WithCancel(context.Background())
-
-	// Start ffmpeg for recording
-	p.recordCmd = exec.CommandContext(p.recordCtx, "ffmpeg",
-		"-headers", fmt.Sprintf("X-Radiko-AuthToken: %s", p.authToken),
-		"-i", p.streamURL,
-		"-c:a", "aac",
-		"-b:a", "128k",
-		"-y",
-		"-loglevel", "error",
-		p.recordFilePath,
-	)
-
-	err := p.recordCmd.Start()
-	if err != nil {
-		p.recordCancel()
-		return fmt.Errorf("録音の開始に失敗しました: %w", err)
-	}
-
-	p.recording = true
-	return nil
-}
-
-// StopRecording stops the current recording
-func (p *FFmpegPlayer) StopRecording() (string, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	if !p.recording {
-		return "", fmt.Errorf("録音していません")
-	}
-
-	filePath := p.recordFilePath
-
-	// Cancel the recording context to stop ffmpeg
-	if p.recordCancel != nil {
-		p.recordCancel()
-	}
-
-	// Wait for the command to finish
-	if p.recordCmd != nil && p.recordCmd.Process != nil {
-		p.recordCmd.Wait()
-	}
-
-	p.recording = false
-	p.recordCmd = nil
-	p.recordFilePath = ""
-	p.recordStation = ""
-
-	return filePath, nil
-}
-
-// IsRecording returns whether recording is in progress
-func (p *FFmpegPlayer) IsRecording() bool {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	return p.recording
-}
-
-// GetRecordingInfo returns information about the current recording
-func (p *FFmpegPlayer) GetRecordingInfo() (filePath string, duration time.Duration, stationName string) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	if !p.recording {
-		return "", 0, ""
-	}
-
-	return p.recordFilePath, time.Since(p.recordStartTime), p.recordStation
-}
-
-// ToggleRecording toggles recording on/off
-func (p *FFmpegPlayer) ToggleRecording(stationName string) (started bool, filePath string, err error) {
-	if p.IsRecording() {
-		filePath, err = p.StopRecording()
-		return false, filePath, err
-	}
-	err = p.StartRecording(stationName)
-	return true, "", err
-}
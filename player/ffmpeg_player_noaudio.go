@@ -2,10 +2,7 @@
 
 package player
 
-import (
-	"fmt"
-	"time"
-)
+import "fmt"
 
 // FFmpegPlayer is a stub player for server-only builds without audio support
 type FFmpegPlayer struct {
@@ -42,6 +39,9 @@ func (p *FFmpegPlayer) UpdateAuthToken(token string) {
 	p.authToken = token
 }
 
+// SetOutputSampleRate is a no-op in server-only mode
+func (p *FFmpegPlayer) SetOutputSampleRate(rate int) {}
+
 // GetReconnectStatus returns the current reconnection status
 func (p *FFmpegPlayer) GetReconnectStatus() ReconnectStatus {
 	return ReconnectNone
@@ -96,28 +96,3 @@ func (p *FFmpegPlayer) IsMuted() bool {
 func (p *FFmpegPlayer) Reconnect() error {
 	return fmt.Errorf("再接続はサポートされていません (noaudio build)")
 }
-
-// StartRecording is not supported in server-only mode
-func (p *FFmpegPlayer) StartRecording(stationName string) error {
-	return fmt.Errorf("録音はサポートされていません (noaudio build)")
-}
-
-// StopRecording is not supported in server-only mode
-func (p *FFmpegPlayer) StopRecording() (string, error) {
-	return "", fmt.Errorf("録音はサポートされていません (noaudio build)")
-}
-
-// IsRecording always returns false in server-only mode
-func (p *FFmpegPlayer) IsRecording() bool {
-	return false
-}
-
-// GetRecordingInfo returns empty values in server-only mode
-func (p *FFmpegPlayer) GetRecordingInfo() (filePath string, duration time.Duration, stationName string) {
-	return "", 0, ""
-}
-
-// ToggleRecording is not supported in server-only mode
-func (p *FFmpegPlayer) ToggleRecording(stationName string) (started bool, filePath string, err error) {
-	return false, "", fmt.Errorf("録音はサポートされていません (noaudio build)")
-}
@@ -1,8 +1,7 @@
 package player
 
-import "time"
-
-// Player defines the interface for audio playback
+// Player defines the interface for audio playback.
+// Recording is handled separately by the recorder package.
 type Player interface {
 	Play(urlOrID string) error
 	Stop()
@@ -17,10 +16,8 @@ type Player interface {
 
 	Reconnect() error
 
-	// Recording methods
-	StartRecording(stationName string) error
-	StopRecording() (string, error)
-	IsRecording() bool
-	GetRecordingInfo() (filePath string, duration time.Duration, stationName string)
-	ToggleRecording(stationName string) (started bool, filePath string, err error)
+	// SetOutputSampleRate sets the audio device's sample rate, to which the
+	// 48kHz audio is resampled (see DeviceSampleRate). Must be called before
+	// the first Play.
+	SetOutputSampleRate(rate int)
 }
@@ -0,0 +1,96 @@
+package player
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// NativeSampleRate is the sample rate of the PCM produced by ffmpeg and the server
+const NativeSampleRate = 48000
+
+// Resampler wraps an io.Reader of interleaved s16le PCM and converts it
+// from one sample rate to another using linear interpolation
+type Resampler struct {
+	reader   io.Reader
+	channels int
+	step     float64 // Input frames consumed per output frame
+	pos      float64 // Fractional read position within samples (in frames)
+	samples  []int16 // Decoded input samples not yet fully consumed
+	residue  []byte  // Buffer for incomplete input frames
+	buf      []byte
+	err      error
+}
+
+// NewResampler creates a resampler from inRate to outRate.
+// If the rates are equal the original reader is returned unchanged.
+func NewResampler(reader io.Reader, inRate, outRate, channels int) io.Reader {
+	if inRate <= 0 || outRate <= 0 || inRate == outRate {
+		return reader
+	}
+	return &Resampler{
+		reader:   reader,
+		channels: channels,
+		step:     float64(inRate) / float64(outRate),
+		buf:      make([]byte, 8192),
+	}
+}
+
+// Read fills p with frame-aligned resampled PCM
+func (r *Resampler) Read(p []byte) (int, error) {
+	frameSize := 2 * r.channels
+	outFrames := len(p) / frameSize
+	if outFrames == 0 {
+		return 0, nil
+	}
+
+	n := 0
+	for n < outFrames {
+		idx := int(r.pos)
+		if (idx+1)*r.channels >= len(r.samples) {
+			// Need more input: return what we have rather than blocking
+			if n > 0 || r.err != nil {
+				break
+			}
+			r.fill()
+			continue
+		}
+
+		frac := r.pos - float64(idx)
+		for c := 0; c < r.channels; c++ {
+			a := float64(r.samples[idx*r.channels+c])
+			b := float64(r.samples[(idx+1)*r.channels+c])
+			sample := int16(a + (b-a)*frac)
+			binary.LittleEndian.PutUint16(p[(n*r.channels+c)*2:], uint16(sample))
+		}
+		n++
+		r.pos += r.step
+	}
+
+	// Drop fully consumed input frames
+	if drop := int(r.pos); drop > 0 {
+		if drop*r.channels > len(r.samples) {
+			drop = len(r.samples) / r.channels
+		}
+		r.samples = append(r.samples[:0], r.samples[drop*r.channels:]...)
+		r.pos -= float64(drop)
+	}
+
+	if n == 0 {
+		return 0, r.err
+	}
+	return n * frameSize, nil
+}
+
+// fill reads the next chunk of input and decodes complete frames into samples
+func (r *Resampler) fill() {
+	frameSize := 2 * r.channels
+	m, err := r.reader.Read(r.buf)
+	r.err = err
+
+	data := append(r.residue, r.buf[:m]...)
+	alignedLen := (len(data) / frameSize) * frameSize
+	for i := 0; i < alignedLen; i += 2 {
+		r.samples = append(r.samples, int16(binary.LittleEndian.Uint16(data[i:])))
+	}
+	r.residue = append(r.residue[:0], data[alignedLen:]...)
+}
@@ -0,0 +1,84 @@
+package player
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+)
+
+// ramp returns frames of s16le PCM rising by 16 per frame, negated on the
+// second channel
+func ramp(frames, channels int) []byte {
+	data := make([]byte, frames*channels*2)
+	for i := range frames {
+		for c := range channels {
+			v := int16(i * 16)
+			if c == 1 {
+				v = -v
+			}
+			binary.LittleEndian.PutUint16(data[(i*channels+c)*2:], uint16(v))
+		}
+	}
+	return data
+}
+
+func TestNewResamplerSameRate(t *testing.T) {
+	r := bytes.NewReader(nil)
+	for _, out := range []int{48000, 0, -1} {
+		if got := NewResampler(r, 48000, out, 2); got != io.Reader(r) {
+			t.Errorf("NewResampler(48000 → %d) wrapped the reader", out)
+		}
+	}
+}
+
+func TestResampler(t *testing.T) {
+	const frames = 1000
+	tests := []struct {
+		inRate, outRate, channels int
+		oneByte                   bool // Input arrives a byte at a time
+	}{
+		{48000, 44100, 2, false},
+		{48000, 24000, 1, false},
+		{48000, 96000, 2, false},
+		{48000, 44100, 2, true},
+		{44100, 48000, 1, true},
+	}
+	for _, tt := range tests {
+		var in io.Reader = bytes.NewReader(ramp(frames, tt.channels))
+		if tt.oneByte {
+			in = iotest.OneByteReader(in)
+		}
+		out, err := io.ReadAll(NewResampler(in, tt.inRate, tt.outRate, tt.channels))
+		if err != nil {
+			t.Fatalf("%d → %d: %v", tt.inRate, tt.outRate, err)
+		}
+
+		frameSize := 2 * tt.channels
+		if len(out)%frameSize != 0 {
+			t.Fatalf("%d → %d: %d bytes is not whole frames", tt.inRate, tt.outRate, len(out))
+		}
+		// Every output frame lies between two input frames
+		step := float64(tt.inRate) / float64(tt.outRate)
+		want := int(float64(frames-1)/step) + 1
+		if got := len(out) / frameSize; got < want-1 || got > want {
+			t.Errorf("%d → %d: %d frames, want %d", tt.inRate, tt.outRate, got, want)
+		}
+		// Interpolating a ramp gives the ramp at the output positions, but
+		// for truncation
+		for k := range len(out) / frameSize {
+			for c := range tt.channels {
+				got := float64(int16(binary.LittleEndian.Uint16(out[(k*tt.channels+c)*2:])))
+				expect := float64(k) * step * 16
+				if c == 1 {
+					expect = -expect
+				}
+				if math.Abs(got-expect) > 2 {
+					t.Fatalf("%d → %d: frame %d channel %d = %v, want %v", tt.inRate, tt.outRate, k, c, got, expect)
+				}
+			}
+		}
+	}
+}
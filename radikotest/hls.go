@@ -0,0 +1,137 @@
+package radikotest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// SegmentDuration is the length of every HLS segment
+const SegmentDuration = 5 * time.Second
+
+// liveWindow is how many segments a live media playlist lists
+const liveWindow = 3
+
+// bandwidths are the variants of every master playlist
+var bandwidths = []int{48000, 96000}
+
+// silentFrame is an ADTS frame of AAC-LC silence, 48 kHz mono: a 7-byte
+// header for an 11-byte frame, then an empty single channel element
+var silentFrame = []byte{0xFF, 0xF1, 0x4C, 0x40, 0x01, 0x7F, 0xFC, 0x01, 0x40, 0x20, 0x07}
+
+// framesPerSegment is how many 1024-sample frames fill a segment
+var framesPerSegment = int(SegmentDuration.Seconds() * 48000 / 1024)
+
+// masterPlaylist writes a master playlist of the variants under dir
+func masterPlaylist(w http.ResponseWriter, dir, query string) {
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	fmt.Fprintln(w, "#EXTM3U")
+	for _, bw := range bandwidths {
+		fmt.Fprintf(w, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"mp4a.40.2\"\n", bw)
+		fmt.Fprintf(w, "%s/%d/chunklist.m3u8?%s\n", dir, bw, query)
+	}
+}
+
+// mediaPlaylist writes a media playlist of segments first to last (Unix
+// segment numbers), ended when it is complete
+func mediaPlaylist(w http.ResponseWriter, first, last int64, ended bool) {
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	fmt.Fprintln(w, "#EXTM3U")
+	fmt.Fprintln(w, "#EXT-X-VERSION:3")
+	fmt.Fprintf(w, "#EXT-X-TARGETDURATION:%d\n", int(SegmentDuration.Seconds()))
+	fmt.Fprintf(w, "#EXT-X-MEDIA-SEQUENCE:%d\n", first)
+	for n := first; n <= last; n++ {
+		fmt.Fprintf(w, "#EXTINF:%.3f,\n/hls/segment/%d.aac\n", SegmentDuration.Seconds(), n)
+	}
+	if ended {
+		fmt.Fprintln(w, "#EXT-X-ENDLIST")
+	}
+}
+
+// segmentAt returns the number of the segment playing at t
+func segmentAt(t time.Time) int64 {
+	return t.Unix() / int64(SegmentDuration.Seconds())
+}
+
+func (s *Server) handleLiveMaster(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	stationArea := s.stationArea(r.URL.Query().Get("station_id"))
+	if stationArea == "" {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	area := s.tokens[r.Header.Get("X-Radiko-AuthToken")]
+	s.mu.Unlock()
+	if area.areaID != stationArea && !area.areaFree {
+		// Out of the station's area
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+	masterPlaylist(w, "/hls/live", r.URL.RawQuery)
+}
+
+func (s *Server) handleLiveMedia(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	last := segmentAt(time.Now()) - 1 // The one being "recorded" isn't out yet
+	mediaPlaylist(w, last-liveWindow+1, last, false)
+}
+
+// timefreeRange parses a timefree playlist's ft and to, which must have
+// been broadcast
+func timefreeRange(r *http.Request) (time.Time, time.Time, error) {
+	ft, err := time.ParseInLocation("20060102150405", r.URL.Query().Get("ft"), model.JST)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := time.ParseInLocation("20060102150405", r.URL.Query().Get("to"), model.JST)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !to.After(ft) || to.After(time.Now()) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range")
+	}
+	return ft, to, nil
+}
+
+func (s *Server) handleTimefreeMaster(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	if _, _, err := timefreeRange(r); err != nil || s.stationArea(r.URL.Query().Get("station_id")) == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	masterPlaylist(w, "/hls/tf", r.URL.RawQuery)
+}
+
+func (s *Server) handleTimefreeMedia(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	ft, to, err := timefreeRange(r)
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	mediaPlaylist(w, segmentAt(ft), segmentAt(to.Add(-time.Nanosecond)), true)
+}
+
+func (s *Server) handleSegment(w http.ResponseWriter, r *http.Request) {
+	if _, err := strconv.ParseInt(strings.TrimSuffix(r.PathValue("file"), ".aac"), 10, 64); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "audio/aac")
+	for range framesPerSegment {
+		w.Write(silentFrame)
+	}
+}
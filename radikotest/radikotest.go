@@ -0,0 +1,499 @@
+// Package radikotest serves a fake radiko for integration tests:
+// authentication, station and program lists, program search, now-on-air,
+// stream URLs and HLS playlists of silence. Point the api package at it
+// with api.SetHTTPClient(srv.Client(), "").
+package radikotest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// Server is a fake radiko. Its fields may be changed before the requests
+// that read them.
+type Server struct {
+	*httptest.Server
+
+	AreaID   string                     // Area of the caller's IP address ("OUT" = outside Japan)
+	Stations map[string][]model.Station // Stations by area ID
+	AreaFree bool                       // Premium logins get area-free sessions
+
+	mu       sync.Mutex
+	issued   map[string]bool      // Tokens from auth1 waiting for auth2
+	tokens   map[string]tokenArea // Tokens that passed auth2
+	requests map[string]int       // Requests by path
+}
+
+// NewServer starts a fake radiko listing TBS and QRR in Tokyo (JP13) and
+// ABC in Osaka (JP27). Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		AreaID: "JP13",
+		Stations: map[string][]model.Station{
+			"JP13": {
+				{ID: "TBS", Name: "TBSラジオ", AsciiName: "TBS RADIO", AreaFree: true, TimeFree: true},
+				{ID: "QRR", Name: "文化放送", AsciiName: "JOQR", AreaFree: true, TimeFree: true},
+			},
+			"JP27": {
+				{ID: "ABC", Name: "ABCラジオ", AsciiName: "ABC RADIO", TimeFree: true},
+			},
+		},
+		AreaFree: true,
+		issued:   make(map[string]bool),
+		tokens:   make(map[string]tokenArea),
+		requests: make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v2/api/auth1", s.handleAuth1)
+	mux.HandleFunc("GET /v2/api/auth2", s.handleAuth2)
+	mux.HandleFunc("GET /area", s.handleArea)
+	mux.HandleFunc("GET /json/", s.handleGeoIP)
+	mux.HandleFunc("POST /v4/api/member/login", s.handleLogin)
+	mux.HandleFunc("POST /v4/api/member/logout", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /v3/station/list/{file}", s.handleStationList)
+	mux.HandleFunc("GET /v3/station/region/full.xml", s.handleAllStations)
+	mux.HandleFunc("GET /v3/station/stream/pc_html5/{file}", s.handleStreamURLs)
+	mux.HandleFunc("GET /api/stations/batchGetStations", s.handleBatchStations)
+	mux.HandleFunc("GET /program/v4/date/{date}/station/{file}", s.handleProgramJSON)
+	mux.HandleFunc("GET /program/v3/now/{file}", s.handleNowPrograms)
+	mux.HandleFunc("GET /program/v3/date/{date}/station/{file}", s.handleDatePrograms)
+	mux.HandleFunc("GET /program/v3/weekly/{file}", s.handleWeeklyPrograms)
+	mux.HandleFunc("GET /v3/api/program/search", s.handleSearch)
+	mux.HandleFunc("GET /v3/feed/pc/noa/{file}", s.handleNowOnAir)
+	mux.HandleFunc("GET /music/api/v1/noas/{stationID}", s.handleSongs)
+	mux.HandleFunc("GET /hls/live/playlist.m3u8", s.handleLiveMaster)
+	mux.HandleFunc("GET /hls/live/{bandwidth}/chunklist.m3u8", s.handleLiveMedia)
+	mux.HandleFunc("GET /hls/tf/playlist.m3u8", s.handleTimefreeMaster)
+	mux.HandleFunc("GET /hls/tf/{bandwidth}/chunklist.m3u8", s.handleTimefreeMedia)
+	mux.HandleFunc("GET /hls/segment/{file}", s.handleSegment)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// Client returns a client sending the requests for radiko's hosts (and the
+// GeoIP lookup) to the server
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	return &http.Client{Transport: &rewriteTransport{target: target, next: s.Server.Client().Transport}}
+}
+
+// Requests returns how many requests a path got, e.g. "/v2/api/auth1"
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// tokenArea is where a token plays
+type tokenArea struct {
+	areaID   string // Area of the location sent to auth2
+	areaFree bool   // Authenticated with a premium session
+}
+
+// ValidToken reports whether a token passed authentication
+func (s *Server) ValidToken(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.tokens[token]
+	return ok
+}
+
+// TokenArea returns the area a token was authenticated in, or ""
+func (s *Server) TokenArea(token string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[token].areaID
+}
+
+// rewriteTransport sends the requests for radiko's hosts to the server
+type rewriteTransport struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+// RoundTrip rewrites the request's host when it is radiko's
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	if host == "radiko.jp" || strings.HasSuffix(host, ".radiko.jp") || host == "ip-api.com" {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = t.target.Scheme
+		req.URL.Host = t.target.Host
+		req.Host = ""
+	}
+	return t.next.RoundTrip(req)
+}
+
+// areaIDs returns the areas with stations, sorted so that responses and
+// their ETags stay the same
+func (s *Server) areaIDs() []string {
+	return slices.Sorted(maps.Keys(s.Stations))
+}
+
+// stationArea returns the area of a station, or ""
+func (s *Server) stationArea(stationID string) string {
+	for _, areaID := range s.areaIDs() {
+		stations := s.Stations[areaID]
+		for _, st := range stations {
+			if st.ID == stationID {
+				return areaID
+			}
+		}
+	}
+	return ""
+}
+
+func (s *Server) handleAuth1(w http.ResponseWriter, r *http.Request) {
+	token := randomHex(16)
+	s.mu.Lock()
+	s.issued[token] = true
+	s.mu.Unlock()
+	w.Header().Set("X-Radiko-AuthToken", token)
+	w.Header().Set("X-Radiko-KeyLength", "16")
+	w.Header().Set("X-Radiko-KeyOffset", "0")
+	fmt.Fprintln(w, "please send a part of key")
+}
+
+func (s *Server) handleAuth2(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Radiko-AuthToken")
+	area := tokenArea{
+		areaID:   locationArea(r.Header.Get("X-Radiko-Location")),
+		areaFree: s.AreaFree && r.URL.Query().Get("radiko_session") != "",
+	}
+	if area.areaID == "" {
+		area.areaID = s.AreaID
+	}
+	s.mu.Lock()
+	ok := s.issued[token] && r.Header.Get("X-Radiko-PartialKey") != ""
+	if ok {
+		delete(s.issued, token)
+		s.tokens[token] = area
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	name := area.areaID
+	if a := model.FindAreaByID(area.areaID); a != nil {
+		name = a.Name
+	}
+	fmt.Fprintf(w, "%s,%s,tokyo Japan\r\n", area.areaID, name)
+}
+
+// locationArea returns the area whose capital is nearest to an
+// X-Radiko-Location ("lat,long,gps"), or ""
+func locationArea(location string) string {
+	var lat, long float64
+	if _, err := fmt.Sscanf(strings.ReplaceAll(location, ",", " "), "%f %f", &lat, &long); err != nil {
+		return ""
+	}
+	best, bestDist := "", math.Inf(1)
+	for i, c := range model.Coordinates {
+		if d := math.Hypot(c[0]-lat, c[1]-long); d < bestDist {
+			best, bestDist = fmt.Sprintf("JP%d", i+1), d
+		}
+	}
+	return best
+}
+
+// authorized checks a playlist request's token, answering 403 when invalid
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if !s.ValidToken(r.Header.Get("X-Radiko-AuthToken")) {
+		http.Error(w, "", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleArea(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, `document.write('<span class="%s">%s</span>');`, s.AreaID, s.AreaID)
+}
+
+func (s *Server) handleGeoIP(w http.ResponseWriter, r *http.Request) {
+	geo := map[string]string{"status": "success", "countryCode": "JP", "region": strings.TrimPrefix(s.AreaID, "JP")}
+	if s.AreaID == "OUT" {
+		geo = map[string]string{"status": "success", "countryCode": "US", "region": "CA"}
+	}
+	writeJSON(w, r, geo)
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("mail") == "" || r.FormValue("pass") == "" {
+		writeJSON(w, r, map[string]string{})
+		return
+	}
+	areaFree := "0"
+	if s.AreaFree {
+		areaFree = "1"
+	}
+	writeJSON(w, r, map[string]string{"radiko_session": randomHex(16), "areafree": areaFree})
+}
+
+func (s *Server) handleStationList(w http.ResponseWriter, r *http.Request) {
+	areaID := strings.TrimSuffix(r.PathValue("file"), ".xml")
+	stations, ok := s.Stations[areaID]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeXML(w, r, model.RadikoStations{AreaID: areaID, Stations: stations})
+}
+
+func (s *Server) handleAllStations(w http.ResponseWriter, r *http.Request) {
+	var regions model.RadikoRegions
+	for _, areaID := range s.areaIDs() {
+		stations := s.Stations[areaID]
+		list := model.RadikoStations{AreaID: areaID}
+		for _, st := range stations {
+			st.AreaID = areaID
+			list.Stations = append(list.Stations, st)
+		}
+		regions.Regions = append(regions.Regions, list)
+	}
+	writeXML(w, r, regions)
+}
+
+func (s *Server) handleBatchStations(w http.ResponseWriter, r *http.Request) {
+	type station struct {
+		ID              string   `json:"id"`
+		Name            string   `json:"name"`
+		PrefecturesList []string `json:"prefecturesList"`
+	}
+	list := []station{}
+	stationID := r.URL.Query().Get("stationId")
+	for _, areaID := range s.areaIDs() {
+		stations := s.Stations[areaID]
+		for _, st := range stations {
+			if st.ID == stationID {
+				list = append(list, station{ID: st.ID, Name: st.Name, PrefecturesList: []string{areaID}})
+			}
+		}
+	}
+	writeJSON(w, r, map[string]any{"ok": len(list) > 0, "stationList": list})
+}
+
+func (s *Server) handleStreamURLs(w http.ResponseWriter, r *http.Request) {
+	stationID := strings.TrimSuffix(r.PathValue("file"), ".xml")
+	if s.stationArea(stationID) == "" {
+		http.NotFound(w, r)
+		return
+	}
+	writeXML(w, r, model.RadikoURLs{URLs: []model.URL{
+		{PlaylistCreateURL: s.URL + "/hls/live/playlist.m3u8"},
+		{AreaFree: 1, PlaylistCreateURL: s.URL + "/hls/live/playlist.m3u8"},
+		{TimeFree: 1, PlaylistCreateURL: s.URL + "/hls/tf/playlist.m3u8"},
+	}})
+}
+
+// programs returns a station's programs of a broadcast day: one an hour
+// from 05:00 to 29:00 JST
+func programs(stationID string, day time.Time) []model.Program {
+	var progs []model.Program
+	start := time.Date(day.Year(), day.Month(), day.Day(), 5, 0, 0, 0, model.JST)
+	for h := 0; h < 24; h++ {
+		ft := start.Add(time.Duration(h) * time.Hour)
+		progs = append(progs, model.Program{
+			Ft:    ft.Format("20060102150405"),
+			To:    ft.Add(time.Hour).Format("20060102150405"),
+			Title: fmt.Sprintf("%s %02d時の番組", stationID, ft.Hour()),
+			Pfm:   "テスト出演者",
+		})
+	}
+	return progs
+}
+
+// parseDay parses a YYYYMMDD broadcast day
+func parseDay(date string) (time.Time, error) {
+	return time.ParseInLocation("20060102", date, model.JST)
+}
+
+func (s *Server) handleProgramJSON(w http.ResponseWriter, r *http.Request) {
+	stationID := strings.TrimSuffix(r.PathValue("file"), ".json")
+	day, err := parseDay(r.PathValue("date"))
+	if err != nil || s.stationArea(stationID) == "" {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, r, model.ProgramResponse{Stations: []model.StationProgram{{
+		StationID: stationID,
+		Programs:  model.Programs{Date: r.PathValue("date"), Program: programs(stationID, day)},
+	}}})
+}
+
+func (s *Server) handleNowPrograms(w http.ResponseWriter, r *http.Request) {
+	areaID := strings.TrimSuffix(r.PathValue("file"), ".xml")
+	now := time.Now()
+	var resp model.RadikoPrograms
+	for _, st := range s.Stations[areaID] {
+		var onAir []model.Program
+		at := now.In(model.JST).Format("20060102150405")
+		for _, prog := range programs(st.ID, model.BroadcastDay(now)) {
+			if prog.Ft <= at && at < prog.To {
+				onAir = append(onAir, prog)
+			}
+		}
+		resp.Stations = append(resp.Stations, model.StationSchedule{ID: st.ID, Name: st.Name, Programs: onAir})
+	}
+	writeXML(w, r, resp)
+}
+
+func (s *Server) handleDatePrograms(w http.ResponseWriter, r *http.Request) {
+	stationID := strings.TrimSuffix(r.PathValue("file"), ".xml")
+	day, err := parseDay(r.PathValue("date"))
+	if err != nil || s.stationArea(stationID) == "" {
+		http.NotFound(w, r)
+		return
+	}
+	writeXML(w, r, model.RadikoPrograms{Stations: []model.StationSchedule{{ID: stationID, Programs: programs(stationID, day)}}})
+}
+
+func (s *Server) handleWeeklyPrograms(w http.ResponseWriter, r *http.Request) {
+	stationID := strings.TrimSuffix(r.PathValue("file"), ".xml")
+	if s.stationArea(stationID) == "" {
+		http.NotFound(w, r)
+		return
+	}
+	today := model.BroadcastDay(time.Now())
+	var progs []model.Program
+	for d := -7; d <= 7; d++ {
+		progs = append(progs, programs(stationID, today.AddDate(0, 0, d))...)
+	}
+	writeXML(w, r, model.RadikoPrograms{Stations: []model.StationSchedule{{ID: stationID, Programs: progs}}})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	keyword := r.URL.Query().Get("key")
+	type result struct {
+		StationID string `json:"station_id"`
+		Title     string `json:"title"`
+		Performer string `json:"performer"`
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+		Status    string `json:"status"`
+	}
+	now := time.Now().In(model.JST).Format("20060102150405")
+	data := []result{}
+	for _, areaID := range s.areaIDs() {
+		stations := s.Stations[areaID]
+		if a := r.URL.Query().Get("area_id"); a != "" && a != areaID {
+			continue
+		}
+		for _, st := range stations {
+			for _, prog := range programs(st.ID, model.BroadcastDay(time.Now())) {
+				if !strings.Contains(prog.Title, keyword) {
+					continue
+				}
+				start, _ := prog.StartTime()
+				end, _ := prog.EndTime()
+				status := "future"
+				switch {
+				case prog.To <= now:
+					status = "past"
+				case prog.Ft <= now:
+					status = "now"
+				}
+				data = append(data, result{
+					StationID: st.ID,
+					Title:     prog.Title,
+					Performer: prog.Pfm,
+					StartTime: start.In(model.JST).Format("2006-01-02 15:04:05"),
+					EndTime:   end.In(model.JST).Format("2006-01-02 15:04:05"),
+					Status:    status,
+				})
+			}
+		}
+	}
+	writeJSON(w, r, map[string]any{"data": data})
+}
+
+func (s *Server) handleNowOnAir(w http.ResponseWriter, r *http.Request) {
+	stationID := strings.TrimSuffix(r.PathValue("file"), ".xml")
+	started := time.Now().Truncate(5 * time.Minute)
+	writeXML(w, r, struct {
+		XMLName xml.Name      `xml:"yamaha"`
+		Tracks  []model.Track `xml:"item"`
+	}{Tracks: []model.Track{{
+		Stamp:  started.In(model.JST).Format("2006-01-02 15:04:05"),
+		Title:  stationID + " のテスト曲",
+		Artist: "テストアーティスト",
+		Image:  s.URL + "/artwork.jpg",
+	}}})
+}
+
+func (s *Server) handleSongs(w http.ResponseWriter, r *http.Request) {
+	from, err := time.Parse(time.RFC3339, r.URL.Query().Get("start_time_gte"))
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse(time.RFC3339, r.URL.Query().Get("end_time_lt"))
+	if err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	// A song every five minutes
+	songs := []model.Song{}
+	for t := from.Truncate(5 * time.Minute); t.Before(to); t = t.Add(5 * time.Minute) {
+		if t.Before(from) {
+			continue
+		}
+		songs = append(songs, model.Song{
+			Title:     fmt.Sprintf("%s の曲", t.In(model.JST).Format("15:04")),
+			Artist:    "テストアーティスト",
+			StartedAt: t.In(model.JST).Format(time.RFC3339),
+		})
+	}
+	writeJSON(w, r, model.SongResponse{Data: songs})
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	data, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	serve(w, r, data)
+}
+
+// writeXML writes v as an XML response
+func writeXML(w http.ResponseWriter, r *http.Request, v any) {
+	data, _ := xml.Marshal(v)
+	w.Header().Set("Content-Type", "application/xml")
+	serve(w, r, append([]byte(xml.Header), data...))
+}
+
+// serve writes a response body with an ETag, answering conditional
+// requests for an unchanged body with 304 Not Modified
+func serve(w http.ResponseWriter, r *http.Request, data []byte) {
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// randomHex returns n random bytes in hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,148 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+)
+
+// Chapter is a chapter marker within a recording
+type Chapter struct {
+	Start time.Duration // Offset into the audio
+	End   time.Duration
+	Title string
+}
+
+// chaptersFor builds chapters for audio broadcast from start onwards: one
+// per program aired during the recording and one per song (NowOnAir).
+// offset maps a broadcast time to its position in the audio.
+// Returns nil if there is nothing to jump between.
+func chaptersFor(stationID string, start time.Time, length time.Duration, offset func(time.Time) time.Duration) []Chapter {
+	end := start.Add(length)
+	var chapters []Chapter
+	add := func(at time.Time, title string) {
+		pos := offset(at)
+		if pos < 0 {
+			pos = 0
+		}
+		if pos < length && title != "" {
+			chapters = append(chapters, Chapter{Start: pos, Title: title})
+		}
+	}
+
+	// Programs, from the broadcast days (starting at 5:00) covering the recording
+	broadcastDay := func(t time.Time) time.Time {
+		y, m, d := t.In(jst).Add(-5 * time.Hour).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, jst)
+	}
+	for day := broadcastDay(start); !day.After(broadcastDay(end)); day = day.AddDate(0, 0, 1) {
+		progs, err := api.GetPrograms(context.Background(), stationID, day)
+		if err != nil {
+			continue
+		}
+		for _, prog := range progs {
+			ps, err1 := prog.StartTime()
+			pe, err2 := prog.EndTime()
+			if err1 != nil || err2 != nil || !pe.After(start) || !ps.Before(end) {
+				continue
+			}
+			add(ps, prog.Title)
+		}
+	}
+
+	if songs, err := api.GetSongs(context.Background(), stationID, start, end); err == nil {
+		for _, song := range songs {
+			at, err := song.StartTime()
+			if err != nil || at.Before(start) {
+				continue
+			}
+			title := song.Title
+			if song.Artist != "" {
+				title = song.Artist + " - " + song.Title
+			}
+			add(at, title)
+		}
+	}
+
+	// Order by position, keeping the program when a song starts with it
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return chapters[i].Start < chapters[j].Start
+	})
+	var merged []Chapter
+	for _, c := range chapters {
+		if len(merged) > 0 && merged[len(merged)-1].Start == c.Start {
+			continue
+		}
+		merged = append(merged, c)
+	}
+	if len(merged) < 2 {
+		return nil
+	}
+	for i := range merged {
+		if i+1 < len(merged) {
+			merged[i].End = merged[i+1].Start
+		} else {
+			merged[i].End = length
+		}
+	}
+	merged[0].Start = 0 // Cover the audio before the first marker
+	return merged
+}
+
+// supportsChapters reports whether the container can hold chapter markers
+// (MP4 chapters, ID3 CHAP frames or Vorbis comments)
+func (f Format) supportsChapters() bool {
+	return f == FormatM4A || f == FormatMP3 || f == FormatFLAC
+}
+
+// writeChapters writes the chapters as an ffmpeg metadata file for -map_chapters.
+// The caller must remove the returned file.
+func writeChapters(chapters []Chapter) (string, error) {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for _, c := range chapters {
+		fmt.Fprintf(&b, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			c.Start.Milliseconds(), c.End.Milliseconds(), escapeFFMetadata(c.Title))
+	}
+
+	f, err := os.CreateTemp("", "radiko-chapters-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(b.String()); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// escapeFFMetadata escapes the characters that are special in ffmpeg metadata files
+func escapeFFMetadata(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", `\`+"\n").Replace(s)
+}
+
+// audioOffset returns the position in the audio of a broadcast time,
+// leaving out pauses and the interruptions the recording recovered from
+func (r *Recording) audioOffset(at time.Time) time.Duration {
+	r.mu.Lock()
+	skipped := append(append([]Gap(nil), r.gaps...), r.pauses...)
+	r.mu.Unlock()
+
+	offset := at.Sub(r.audioStart)
+	for _, gap := range skipped {
+		if gap.At.Before(at) {
+			missed := gap.Duration
+			if end := gap.At.Add(gap.Duration); end.After(at) {
+				missed = at.Sub(gap.At)
+			}
+			offset -= missed
+		}
+	}
+	return offset
+}
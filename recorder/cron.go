@@ -0,0 +1,167 @@
+package recorder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSpec is a parsed 5-field cron expression (minute hour day-of-month month day-of-week)
+type CronSpec struct {
+	minute [60]bool
+	hour   [24]bool
+	dom    [32]bool
+	month  [13]bool
+	dow    [7]bool
+
+	domAny bool // Day-of-month field started with "*" (e.g. "*/2")
+	dowAny bool // Day-of-week field started with "*"
+}
+
+var dowNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// ParseCron parses a standard 5-field cron expression.
+// Supports "*", lists ("1,3"), ranges ("1-5"), steps ("*/15", "0-30/10")
+// and English names for months and weekdays ("mon-fri", "jan").
+func ParseCron(expr string) (*CronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields: %q", expr)
+	}
+
+	// Like cron, both day fields must match when either starts with "*":
+	// "0 5 */2 * mon" is the odd days that are Mondays, not either
+	spec := &CronSpec{
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}
+
+	if err := parseCronField(fields[0], 0, 59, nil, spec.minute[:]); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, nil, spec.hour[:]); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, nil, spec.dom[:]); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, monthNames, spec.month[:]); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+
+	// Day of week accepts 0-7 where both 0 and 7 mean Sunday
+	var dow [8]bool
+	if err := parseCronField(fields[4], 0, 7, dowNames, dow[:]); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	copy(spec.dow[:], dow[:7])
+	if dow[7] {
+		spec.dow[0] = true
+	}
+
+	return spec, nil
+}
+
+// parseCronField parses a single cron field into the bitset
+func parseCronField(field string, min, max int, names map[string]int, bits []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return fmt.Errorf("invalid step %q", part)
+			}
+			step = s
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = parseCronValue(bounds[0], names); err != nil {
+				return err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseCronValue(bounds[1], names); err != nil {
+					return err
+				}
+			} else if step > 1 {
+				// "5/15" means starting at 5 through the max
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return fmt.Errorf("value out of range %q (%d-%d)", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits[v] = true
+		}
+	}
+	return nil
+}
+
+// parseCronValue parses a number or a name
+func parseCronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+// Matches reports whether t (truncated to the minute) satisfies the spec
+func (c *CronSpec) Matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[t.Month()] {
+		return false
+	}
+	return c.dayMatches(t)
+}
+
+// dayMatches applies the cron rule: when both day fields are restricted, either may match
+func (c *CronSpec) dayMatches(t time.Time) bool {
+	domOK := c.dom[t.Day()]
+	dowOK := c.dow[t.Weekday()]
+	if c.domAny || c.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// Next returns the first matching minute strictly after t, or the zero time
+// if nothing matches within the next 366 days
+func (c *CronSpec) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(1, 0, 1)
+
+	for t.Before(limit) {
+		if !c.month[t.Month()] || !c.dayMatches(t) {
+			// Skip to the start of the next day
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,68 @@
+package recorder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, 10, 16, 6, 30, 0, 0, jst) // A Friday
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		year := 2026
+		if month < time.October {
+			year = 2027
+		}
+		return time.Date(year, month, day, hour, minute, 0, 0, jst)
+	}
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", at(10, 16, 6, 45)},
+		{"30 6 * * *", at(10, 17, 6, 30)}, // Strictly after
+		{"0 5 * * mon-fri", at(10, 19, 5, 0)},
+		{"0 0 * * 7", at(10, 18, 0, 0)},
+		{"0 0 1 * *", at(11, 1, 0, 0)},
+		{"0 12 * jan,jul *", at(1, 1, 12, 0)},
+		// Both day fields restricted: either matches
+		{"0 5 13 * fri", at(10, 23, 5, 0)},
+		// A day field starting with "*" makes both match
+		{"0 5 */2 * sun", at(10, 25, 5, 0)},
+		{"0 5 1 * */2", at(11, 1, 5, 0)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		spec, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := spec.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %v, want %v", tt.expr, got, tt.want)
+		}
+		if !tt.want.IsZero() && !spec.Matches(tt.want) {
+			t.Errorf("%q: Matches(%v) = false", tt.expr, tt.want)
+		}
+	}
+}
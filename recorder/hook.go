@@ -0,0 +1,66 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// HookTimeout is how long a post-recording command may run before it is killed
+const HookTimeout = 30 * time.Minute
+
+// hookEnv returns the environment variables describing a finished recording
+func (r *Recording) hookEnv(endTime time.Time) []string {
+	program := r.Title
+	var performer, airDate string
+	if r.Metadata != nil {
+		if program == "" {
+			program = r.Metadata.Title
+		}
+		performer = r.Metadata.Performer
+		airDate = r.Metadata.AirDate.In(jst).Format(time.RFC3339)
+	}
+
+	return []string{
+		"RADIKO_FILE=" + r.FilePath,
+		"RADIKO_FORMAT=" + string(r.Format),
+		"RADIKO_STATION_ID=" + r.StationID,
+		"RADIKO_STATION=" + r.StationName,
+		"RADIKO_PROGRAM=" + program,
+		"RADIKO_PERFORMER=" + performer,
+		"RADIKO_AIR_DATE=" + airDate,
+		"RADIKO_START=" + r.StartTime.In(jst).Format(time.RFC3339),
+		"RADIKO_END=" + endTime.In(jst).Format(time.RFC3339),
+		fmt.Sprintf("RADIKO_DURATION=%d", int(r.Written().Seconds())),
+	}
+}
+
+// runHook runs the user's post-recording command through the shell with the
+// recording described in RADIKO_* environment variables
+func runHook(command string, env []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), HookTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(), env...)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("⚠️ 録音後コマンドが失敗しました: %v: %s", err, strings.TrimSpace(string(out)))
+		return
+	}
+	log.Printf("✅ 録音後コマンドを実行しました: %s", command)
+}
+
+// shellCommand runs a user-configured command line through the platform shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
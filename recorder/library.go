@@ -0,0 +1,61 @@
+package recorder
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// History returns the finished recordings that still exist on disk, newest first
+func History() ([]HistoryEntry, error) {
+	historyMu.Lock()
+	entries, err := loadHistory()
+	historyMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []HistoryEntry
+	for _, e := range entries {
+		info, err := os.Stat(e.Path)
+		if err != nil {
+			continue
+		}
+		if e.Size == 0 {
+			e.Size = info.Size()
+		}
+		existing = append(existing, e)
+	}
+	sort.SliceStable(existing, func(i, j int) bool {
+		return existing[i].Created.After(existing[j].Created)
+	})
+	return existing, nil
+}
+
+// MatchEntry reports whether a recording matches every word of query in its
+// station, program title, performer or tags. Matching ignores case, spaces
+// and full-width/half-width differences like keyword rules.
+func MatchEntry(e HistoryEntry, query string) bool {
+	text := normalizeKeyword(strings.Join(append([]string{e.StationID, e.Station, e.Title, e.Performer}, e.Tags...), "\x00"))
+	for _, word := range strings.Fields(query) {
+		if !strings.Contains(text, normalizeKeyword(word)) {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchHistory returns the finished recordings matching query, newest first
+func SearchHistory(query string) ([]HistoryEntry, error) {
+	entries, err := History()
+	if err != nil {
+		return nil, err
+	}
+	var matched []HistoryEntry
+	for _, e := range entries {
+		if MatchEntry(e, query) {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
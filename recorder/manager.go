@@ -0,0 +1,199 @@
+package recorder
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// Manager runs any number of recordings at the same time. Every recording has
+// its own auth token and ffmpeg pipeline, so stations from different areas can
+// be recorded together, independent of what the player is playing.
+type Manager struct {
+	mu         sync.Mutex
+	recordings map[string]*Recording // Keyed by recording ID
+	defaults   Options               // Output dir, format, template and retention for new recordings
+}
+
+// NewManager creates a recording manager with the given defaults
+func NewManager(defaults Options) *Manager {
+	return &Manager{
+		recordings: make(map[string]*Recording),
+		defaults:   defaults,
+	}
+}
+
+// Defaults returns the default recording options
+func (m *Manager) Defaults() Options {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.defaults
+}
+
+// SetFormat changes the default output format for new recordings
+func (m *Manager) SetFormat(format Format) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaults.Format = format
+}
+
+// withDefaults fills unset options from the manager defaults
+func (m *Manager) withDefaults(opts Options) Options {
+	d := m.Defaults()
+	if opts.OutputDir == "" {
+		opts.OutputDir = d.OutputDir
+	}
+	if opts.Format == "" {
+		opts.Format = d.Format
+	}
+	if opts.Template == "" {
+		opts.Template = d.Template
+	}
+	if !opts.Retention.Enabled() {
+		opts.Retention = d.Retention
+	}
+	if opts.PostCommand == "" {
+		opts.PostCommand = d.PostCommand
+	}
+	if opts.Uploader == nil {
+		opts.Uploader = d.Uploader
+	}
+	if opts.Transcribe == "" {
+		opts.Transcribe = d.Transcribe
+		opts.Transcript = d.Transcript
+	}
+	if !opts.Lossless {
+		opts.Lossless = d.Lossless
+	}
+	if !opts.Loudnorm {
+		opts.Loudnorm = d.Loudnorm
+	}
+	return opts
+}
+
+// reserve claims an ID so two recordings with the same ID cannot start at once
+func (m *Manager) reserve(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.recordings[id]; exists {
+		return fmt.Errorf("既に録音中です")
+	}
+	m.recordings[id] = nil
+	return nil
+}
+
+// add registers a started recording and removes it once it has finished
+func (m *Manager) add(id string, r *Recording, err error) (*Recording, error) {
+	m.mu.Lock()
+	if err != nil {
+		delete(m.recordings, id)
+		m.mu.Unlock()
+		return nil, err
+	}
+	r.ID = id
+	m.recordings[id] = r
+	m.mu.Unlock()
+
+	go func() {
+		<-r.Done()
+		m.mu.Lock()
+		if m.recordings[id] == r {
+			delete(m.recordings, id)
+		}
+		m.mu.Unlock()
+	}()
+	return r, nil
+}
+
+// Start starts a live recording. The ID identifies the recording in the
+// manager; an empty ID uses the station ID, so each station is recorded once.
+func (m *Manager) Start(id string, opts Options) (*Recording, error) {
+	if id == "" {
+		id = opts.StationID
+	}
+	if err := m.reserve(id); err != nil {
+		return nil, err
+	}
+	r, err := Start(m.withDefaults(opts))
+	return m.add(id, r, err)
+}
+
+// Download starts a timefree download of a past program (see Download)
+func (m *Manager) Download(id string, opts Options, prog model.Program) (*Recording, error) {
+	if id == "" {
+		id = fmt.Sprintf("%s-%s", opts.StationID, prog.Ft)
+	}
+	if err := m.reserve(id); err != nil {
+		return nil, err
+	}
+	r, err := Download(m.withDefaults(opts), prog)
+	return m.add(id, r, err)
+}
+
+// Get returns the running recording with the given ID, or nil
+func (m *Manager) Get(id string) *Recording {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.recordings[id]
+}
+
+// Stop stops the recording with the given ID
+func (m *Manager) Stop(id string) error {
+	r := m.Get(id)
+	if r == nil {
+		return fmt.Errorf("録音していません")
+	}
+	r.Stop()
+	return nil
+}
+
+// Pause pauses a recording by ID
+func (m *Manager) Pause(id string) error {
+	r := m.Get(id)
+	if r == nil {
+		return fmt.Errorf("録音していません")
+	}
+	return r.Pause()
+}
+
+// Resume resumes a paused recording by ID
+func (m *Manager) Resume(id string) error {
+	r := m.Get(id)
+	if r == nil {
+		return fmt.Errorf("録音していません")
+	}
+	r.Resume()
+	return nil
+}
+
+// Recordings returns the recordings in progress, oldest first
+func (m *Manager) Recordings() []*Recording {
+	m.mu.Lock()
+	recs := make([]*Recording, 0, len(m.recordings))
+	for _, r := range m.recordings {
+		if r != nil {
+			recs = append(recs, r)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].StartTime.Before(recs[j].StartTime)
+	})
+	return recs
+}
+
+// StopAll stops every recording in progress
+func (m *Manager) StopAll() {
+	var wg sync.WaitGroup
+	for _, r := range m.Recordings() {
+		wg.Add(1)
+		go func(r *Recording) {
+			defer wg.Done()
+			r.Stop()
+		}(r)
+	}
+	wg.Wait()
+}
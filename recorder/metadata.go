@@ -0,0 +1,110 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// Metadata holds the tags written into a recorded file
+type Metadata struct {
+	StationID string
+	Station   string    // Station name (album)
+	Title     string    // Program title
+	Performer string    // Performers (artist)
+	AirDate   time.Time // Broadcast start time
+	Chapters  []Chapter // Programs and songs within the recording (optional)
+}
+
+// MetadataFor builds metadata for a station; if prog is nil the program
+// currently on air is looked up (best effort)
+func MetadataFor(stationID, stationName string, prog *model.Program) *Metadata {
+	meta := &Metadata{
+		StationID: stationID,
+		Station:   stationName,
+		AirDate:   time.Now().In(jst),
+	}
+
+	if prog == nil {
+		prog, _ = api.GetCurrentProgram(context.Background(), stationID)
+	}
+	if prog != nil {
+		meta.Title = prog.Title
+		meta.Performer = prog.Pfm
+		if start, err := prog.StartTime(); err == nil {
+			meta.AirDate = start
+		}
+	}
+	return meta
+}
+
+// ffmpegArgs returns the -metadata options for ffmpeg
+func (m *Metadata) ffmpegArgs(format Format) []string {
+	title := m.Title
+	if title == "" {
+		title = fmt.Sprintf("%s %s", m.Station, m.AirDate.Format("2006-01-02 15:04"))
+	}
+
+	args := []string{
+		"-metadata", "title=" + title,
+		"-metadata", "album=" + m.Station,
+		"-metadata", "album_artist=" + m.Station,
+		"-metadata", "date=" + m.AirDate.Format("2006-01-02"),
+		"-metadata", "genre=Radio",
+		"-metadata", "comment=" + fmt.Sprintf("radiko %s %s", m.StationID, m.AirDate.Format("2006-01-02 15:04")),
+	}
+	if m.Performer != "" {
+		args = append(args, "-metadata", "artist="+m.Performer)
+	} else {
+		args = append(args, "-metadata", "artist="+m.Station)
+	}
+
+	switch format {
+	case FormatMP3:
+		args = append(args, "-id3v2_version", "3")
+	case FormatAAC:
+		args = append(args, "-write_id3v2", "1")
+	}
+	return args
+}
+
+// supportsArtwork reports whether the container can embed a cover image
+func (f Format) supportsArtwork() bool {
+	return f == FormatM4A || f == FormatMP3 || f == FormatFLAC
+}
+
+const artworkTimeout = 30 * time.Second // Bounds the station logo download
+
+// downloadArtwork saves the station logo to a temporary file, fetched
+// through the api's proxy and TLS settings. The caller must remove the
+// returned file.
+func (m *Metadata) downloadArtwork() (string, error) {
+	client := &http.Client{Transport: api.HTTPClient().Transport, Timeout: artworkTimeout}
+	resp, err := client.Get(api.GetStationLogoURL(m.StationID))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	f, err := os.CreateTemp("", "radiko-logo-*.png")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
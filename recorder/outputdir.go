@@ -0,0 +1,71 @@
+package recorder
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kanoshiou/radiko-tui/config"
+)
+
+// DefaultOutputDir returns the user's Downloads directory: XDG_DOWNLOAD_DIR
+// of the XDG user directories (e.g. ~/ダウンロード), or ~/Downloads
+func DefaultOutputDir() string {
+	homeDir, _ := os.UserHomeDir()
+	if dir := xdgDownloadDir(homeDir); dir != "" {
+		return dir
+	}
+	return filepath.Join(homeDir, "Downloads")
+}
+
+// OutputDir returns the directory recordings are saved to: record_dir of
+// the config, or DefaultOutputDir
+func OutputDir(cfg config.Config) string {
+	if cfg.RecordDir == "" {
+		return DefaultOutputDir()
+	}
+	if rest, ok := strings.CutPrefix(cfg.RecordDir, "~"); ok && (rest == "" || os.IsPathSeparator(rest[0])) {
+		homeDir, _ := os.UserHomeDir()
+		return filepath.Join(homeDir, rest)
+	}
+	return cfg.RecordDir
+}
+
+// xdgDownloadDir returns XDG_DOWNLOAD_DIR from the environment or
+// user-dirs.dirs, or "" when it is unset or disabled (set to the home directory)
+func xdgDownloadDir(homeDir string) string {
+	dir := os.Getenv("XDG_DOWNLOAD_DIR")
+	if dir == "" {
+		configHome := os.Getenv("XDG_CONFIG_HOME")
+		if configHome == "" {
+			configHome = filepath.Join(homeDir, ".config")
+		}
+		dir = userDirsEntry(filepath.Join(configHome, "user-dirs.dirs"), "XDG_DOWNLOAD_DIR")
+	}
+	if dir == "" {
+		return ""
+	}
+	dir = strings.ReplaceAll(dir, "$HOME", homeDir)
+	if !filepath.IsAbs(dir) || filepath.Clean(dir) == filepath.Clean(homeDir) {
+		return ""
+	}
+	return dir
+}
+
+// userDirsEntry returns the value of a KEY="value" line of user-dirs.dirs
+func userDirsEntry(path, key string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if ok && name == key {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
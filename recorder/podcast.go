@@ -0,0 +1,193 @@
+package recorder
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+)
+
+// EntryID returns a stable identifier for a recording (used in feed URLs)
+func EntryID(e HistoryEntry) string {
+	sum := sha1.Sum([]byte(e.Path))
+	return hex.EncodeToString(sum[:8]) + filepath.Ext(e.Path)
+}
+
+// FindRecording looks up a finished recording by its EntryID
+func FindRecording(id string) (HistoryEntry, bool) {
+	entries, err := History()
+	if err != nil {
+		return HistoryEntry{}, false
+	}
+	for _, e := range entries {
+		if EntryID(e) == id {
+			return e, true
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+// MimeType returns the audio MIME type for a recording file
+func MimeType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m4a":
+		return "audio/mp4"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".flac":
+		return "audio/flac"
+	default:
+		return "audio/aac"
+	}
+}
+
+// FeedOptions configures a podcast feed
+type FeedOptions struct {
+	Title        string                      // Channel title
+	Link         string                      // Channel link
+	EnclosureURL func(e HistoryEntry) string // URL at which the audio file is served
+}
+
+// FileEnclosureURL returns a file:// URL for a recording, for feeds read on the same machine
+func FileEnclosureURL(e HistoryEntry) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(e.Path)}).String()
+}
+
+// RelativeEnclosureURL returns an enclosure URL builder that maps recordings
+// below dir onto baseURL (e.g. when dir is published by a web server)
+func RelativeEnclosureURL(dir, baseURL string) func(e HistoryEntry) string {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return func(e HistoryEntry) string {
+		rel, err := filepath.Rel(dir, e.Path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return FileEnclosureURL(e)
+		}
+		var parts []string
+		for _, p := range strings.Split(filepath.ToSlash(rel), "/") {
+			parts = append(parts, url.PathEscape(p))
+		}
+		return baseURL + "/" + strings.Join(parts, "/")
+	}
+}
+
+// rss is the RSS 2.0 document with iTunes podcast extensions
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	ITunes  string     `xml:"xmlns:itunes,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Language    string    `xml:"language"`
+	Image       *rssImage `xml:"itunes:image,omitempty"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	Description string       `xml:"description"`
+	PubDate     string       `xml:"pubDate"`
+	GUID        rssGUID      `xml:"guid"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+	Author      string       `xml:"itunes:author,omitempty"`
+	Duration    string       `xml:"itunes:duration,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// WriteFeed writes an RSS podcast feed of the given recordings
+func WriteFeed(w io.Writer, entries []HistoryEntry, opts FeedOptions) error {
+	if opts.Title == "" {
+		opts.Title = "radiko-tui recordings"
+	}
+	if opts.EnclosureURL == nil {
+		opts.EnclosureURL = FileEnclosureURL
+	}
+
+	doc := rss{
+		Version: "2.0",
+		ITunes:  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		Channel: rssChannel{
+			Title:       opts.Title,
+			Link:        opts.Link,
+			Description: "Programs recorded from radiko",
+			Language:    "ja",
+		},
+	}
+
+	for _, e := range entries {
+		info, err := os.Stat(e.Path)
+		if err != nil {
+			continue
+		}
+
+		aired := e.AirDate
+		if aired.IsZero() {
+			aired = e.Created
+		}
+		title := e.Title
+		if title == "" {
+			title = e.Station
+		}
+		title = fmt.Sprintf("%s (%s)", title, aired.In(jst).Format("2006/01/02 15:04"))
+
+		description := e.Station
+		if e.Performer != "" {
+			description += " / " + e.Performer
+		}
+
+		item := rssItem{
+			Title:       title,
+			Description: description,
+			PubDate:     aired.Format(time.RFC1123Z),
+			GUID:        rssGUID{Value: EntryID(e)},
+			Enclosure: rssEnclosure{
+				URL:    opts.EnclosureURL(e),
+				Length: info.Size(),
+				Type:   MimeType(e.Path),
+			},
+			Author: e.Performer,
+		}
+		if e.Duration > 0 {
+			item.Duration = fmt.Sprintf("%d:%02d:%02d", e.Duration/3600, e.Duration/60%60, e.Duration%60)
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	// Use the logo of the most recent recording's station as the podcast artwork
+	if len(entries) > 0 && entries[0].StationID != "" {
+		doc.Channel.Image = &rssImage{Href: api.GetStationLogoURL(entries[0].StationID)}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
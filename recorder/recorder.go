@@ -0,0 +1,643 @@
+package recorder
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/config"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// Options describes a recording to start
+type Options struct {
+	StationID   string          // Station to record
+	StationName string          // Display name used in the filename (defaults to StationID)
+	Title       string          // Program title used in the filename (optional)
+	OutputDir   string          // Directory for the file (defaults to DefaultOutputDir)
+	Template    string          // Filename template relative to OutputDir (defaults to DefaultTemplate)
+	Retention   RetentionPolicy // Cleanup applied after the recording finishes
+	PostCommand string          // Shell command run after the recording is saved (optional)
+	Uploader    Uploader        // Remote storage the recording is uploaded to (optional)
+	Lossless    bool            // Capture the original HLS segments instead of re-encoding
+	Loudnorm    bool            // Normalize the loudness when the recording is saved
+	Transcribe  string          // Transcription command run after the recording is saved (optional)
+	Transcript  string          // Transcript file extension (defaults to DefaultTranscriptFormat)
+	Format      Format          // Output format (defaults to FormatAAC)
+	Program     *model.Program  // Program being recorded, used for tags (looked up if nil)
+	Duration    time.Duration   // Stop automatically after this long (0 = until Stop)
+	Tags        []string        // Labels stored in the recording index (optional)
+	PreRoll     time.Duration   // Timefree: also download this much before the program
+	PostRoll    time.Duration   // Timefree: also download this much after the program
+}
+
+// Recording is a single recording with its own auth and ffmpeg pipeline,
+// independent of whatever the player is currently playing
+type Recording struct {
+	ID          string // Identifier in the Manager (empty if started directly)
+	StationID   string
+	StationName string
+	Title       string
+	FilePath    string // Final file path (after transcoding)
+	Format      Format
+	Metadata    *Metadata // Tags written to the file when the recording ends
+	StartTime   time.Time
+	EndTime     time.Time     // Planned stop time (zero if recording until Stop)
+	Length      time.Duration // Total program length for timefree downloads
+
+	mu          sync.Mutex
+	rawPath     string    // ADTS file written by ffmpeg during recording
+	audioStart  time.Time // Broadcast time of the first audio (program start for timefree)
+	file        *os.File  // rawPath opened for appending, shared by resumed processes
+	retention   RetentionPolicy
+	postCommand string
+	uploader    Uploader
+	loudnorm    bool
+	tags        []string
+	transcribe  string
+	transcript  string
+	outputDir   string
+	resolve     func() (authToken, streamURL string, err error) // Re-resolves a live stream (nil for timefree)
+	ctx         context.Context
+	procCancel  context.CancelFunc // Ends the current ffmpeg process or capture
+	skipBacklog bool               // Start the next process at the live edge (after a pause)
+	paused      bool
+	pausedAt    time.Time
+	unpause     chan struct{}   // Closed by Resume
+	pauses      []Gap           // Finished pauses, excluded from Elapsed
+	lossless    bool            // Capture HLS segments (segments.go) instead of running ffmpeg
+	segments    map[string]bool // Segments already captured or skipped in lossless mode
+	saved       int             // Segments saved in lossless mode, numbering their files
+	cancel      context.CancelFunc
+	timer       *time.Timer
+	stopped     bool
+	base        time.Duration // Audio written by earlier ffmpeg processes
+	written     time.Duration // Audio duration written so far (from ffmpeg progress)
+	lastError   string        // Last error line printed by ffmpeg
+	exited      chan error    // Receives the result of the current ffmpeg process or capture
+	gaps        []Gap
+	err         error
+	done        chan struct{}
+}
+
+// Gap is an interruption in a live recording after which it resumed
+type Gap struct {
+	At       time.Time     // When the stream dropped
+	Duration time.Duration // How long until recording resumed
+}
+
+const (
+	// maxResumeAttempts is how many times in a row a dropped recording tries to resume
+	maxResumeAttempts = 10
+	// resumeBackoff is the delay before the first resume attempt, doubled on each failure
+	resumeBackoff = 2 * time.Second
+	// maxResumeBackoff caps the delay between resume attempts
+	maxResumeBackoff = 30 * time.Second
+	// resumeResetAfter is how long ffmpeg must run before the attempt counter resets
+	resumeResetAfter = time.Minute
+)
+
+// OptionsFromConfig returns the default recording options configured by the user
+func OptionsFromConfig(cfg config.Config) (Options, error) {
+	format, err := ParseFormat(cfg.RecordFormat)
+	if err != nil {
+		return Options{}, err
+	}
+	if cfg.RecordTemplate != "" {
+		if err := ValidateTemplate(cfg.RecordTemplate); err != nil {
+			return Options{}, err
+		}
+	}
+	uploader, err := NewUploader(cfg.Upload)
+	if err != nil {
+		return Options{}, err
+	}
+	return Options{
+		OutputDir:   OutputDir(cfg),
+		Format:      format,
+		Template:    cfg.RecordTemplate,
+		Retention:   RetentionFromConfig(cfg.Retention),
+		PostCommand: cfg.PostRecordCommand,
+		Uploader:    uploader,
+		Lossless:    cfg.RecordLossless,
+		Loudnorm:    cfg.Loudnorm,
+		Transcribe:  cfg.TranscribeCommand,
+		Transcript:  cfg.TranscriptFormat,
+	}, nil
+}
+
+// SanitizeFilename replaces characters that are invalid in filenames
+func SanitizeFilename(name string) string {
+	for _, char := range []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|", " "} {
+		name = strings.ReplaceAll(name, char, "_")
+	}
+	return name
+}
+
+// Start authenticates for the station's area, resolves the stream URL
+// and starts an ffmpeg process writing to a new file. If the stream drops,
+// the recording re-authenticates and keeps appending to the same file.
+func Start(opts Options) (*Recording, error) {
+	if err := prepare(&opts); err != nil {
+		return nil, err
+	}
+
+	stationID := opts.StationID
+	resolve := func() (string, string, error) {
+		return resolveLiveStream(context.Background(), stationID)
+	}
+	authToken, streamURL, err := resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	r, err := newRecording(opts, now)
+	if err != nil {
+		return nil, err
+	}
+	r.Metadata = MetadataFor(opts.StationID, opts.StationName, opts.Program)
+	r.resolve = resolve
+	if err := r.start(authToken, streamURL); err != nil {
+		return nil, err
+	}
+
+	if opts.Duration > 0 {
+		r.EndTime = now.Add(opts.Duration)
+		r.timer = time.AfterFunc(opts.Duration, func() { r.Stop() })
+	}
+
+	go r.wait()
+	return r, nil
+}
+
+// prepare validates options, fills defaults and ensures the output directory exists
+func prepare(opts *Options) error {
+	if opts.StationID == "" {
+		return fmt.Errorf("stationID is required")
+	}
+	if opts.StationName == "" {
+		opts.StationName = opts.StationID
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = DefaultOutputDir()
+	}
+	if opts.Format == "" {
+		opts.Format = FormatAAC
+	}
+
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("ffmpeg not found in PATH. Please install ffmpeg: %w", err)
+	}
+
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return fmt.Errorf("録音フォルダの作成に失敗しました: %w", err)
+	}
+	return nil
+}
+
+// authenticate gets an auth token for the station's home area
+func authenticate(ctx context.Context, stationID string) (string, error) {
+	areaID, err := api.GetStationArea(ctx, stationID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get station area: %w", err)
+	}
+
+	return api.Token(ctx, areaID)
+}
+
+// resolveLiveStream authenticates and builds the live stream URL for a station
+func resolveLiveStream(ctx context.Context, stationID string) (authToken, streamURL string, err error) {
+	authToken, err = authenticate(ctx, stationID)
+	if err != nil {
+		return "", "", err
+	}
+
+	streams, err := api.GetStreamURLs(ctx, stationID, authToken)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get stream URL: %w", err)
+	}
+	return authToken, api.PreferredStream(streams).URL, nil
+}
+
+// newRecording creates a recording with a filename expanded from the options' template
+func newRecording(opts Options, at time.Time) (*Recording, error) {
+	fields := TemplateFields{
+		StationID: opts.StationID,
+		Station:   opts.StationName,
+		Program:   opts.Title,
+		Time:      at,
+	}
+	if opts.Program != nil {
+		fields.Performer = opts.Program.Pfm
+	}
+
+	rawPath, err := OutputPath(opts.OutputDir, opts.Template, fields, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	return &Recording{
+		StationID:   opts.StationID,
+		StationName: opts.StationName,
+		Title:       opts.Title,
+		FilePath:    strings.TrimSuffix(rawPath, FormatAAC.Ext()) + opts.Format.Ext(),
+		Format:      opts.Format,
+		StartTime:   time.Now(),
+		audioStart:  at,
+		rawPath:     rawPath,
+		retention:   opts.Retention,
+		postCommand: opts.PostCommand,
+		uploader:    opts.Uploader,
+		outputDir:   opts.OutputDir,
+		lossless:    opts.Lossless,
+		loudnorm:    opts.Loudnorm,
+		tags:        opts.Tags,
+		transcribe:  opts.Transcribe,
+		transcript:  opts.Transcript,
+		segments:    make(map[string]bool),
+		done:        make(chan struct{}),
+	}, nil
+}
+
+// start opens the recording file and launches the first ffmpeg process
+func (r *Recording) start(authToken, inputURL string) error {
+	file, err := os.OpenFile(r.rawPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("録音ファイルの作成に失敗しました: %w", err)
+	}
+	r.file = file
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	if err := r.launch(authToken, inputURL); err != nil {
+		r.cancel()
+		file.Close()
+		os.Remove(r.rawPath)
+		return err
+	}
+	return nil
+}
+
+// launch starts an ffmpeg process appending ADTS frames to the recording file.
+// ADTS frames are self-contained, so the output of successive processes
+// concatenates into one playable file.
+func (r *Recording) launch(authToken, inputURL string) error {
+	// Each process gets its own context so Pause can end it without ending the recording
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.mu.Lock()
+	r.procCancel = cancel
+	skipBacklog := r.skipBacklog
+	r.skipBacklog = false
+	r.mu.Unlock()
+
+	if r.lossless {
+		return r.launchSegments(ctx, authToken, inputURL, skipBacklog)
+	}
+
+	args := []string{"-headers", fmt.Sprintf("X-Radiko-AuthToken: %s", authToken)}
+	if skipBacklog {
+		args = append(args, "-live_start_index", "-1") // Start at the newest segment
+	}
+	args = append(args,
+		"-i", inputURL,
+		"-c:a", "aac",
+		"-b:a", "128k",
+		"-f", "adts",
+		"-loglevel", "error",
+		"-nostats",
+		"-progress", "pipe:2",
+		"pipe:1",
+	)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdout = r.file
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to get stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("録音の開始に失敗しました: %w", err)
+	}
+
+	r.mu.Lock()
+	r.base = r.written
+	r.lastError = ""
+	r.mu.Unlock()
+
+	exited := make(chan error, 1)
+	r.exited = exited
+	go func() {
+		r.readProgress(stderr)
+		exited <- cmd.Wait()
+	}()
+	return nil
+}
+
+// readProgress parses ffmpeg -progress output to track how much audio has been written.
+// Other lines are ffmpeg error messages; the last one is kept for error reports.
+func (r *Recording) readProgress(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		value, ok := strings.CutPrefix(line, "out_time_us=")
+		if !ok {
+			if !strings.Contains(line, "=") && strings.TrimSpace(line) != "" {
+				r.mu.Lock()
+				r.lastError = line
+				r.mu.Unlock()
+			}
+			continue
+		}
+		us, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || us < 0 {
+			continue
+		}
+		r.mu.Lock()
+		r.written = r.base + time.Duration(us)*time.Microsecond
+		r.mu.Unlock()
+	}
+}
+
+// wait waits for ffmpeg to exit, resumes live recordings after network
+// failures, then transcodes the file and records the result
+func (r *Recording) wait() {
+	failures := 0
+	for {
+		launched := time.Now()
+		err := <-r.exited
+
+		r.mu.Lock()
+		stopped := r.stopped
+		paused := r.paused
+		unpause := r.unpause
+		lastError := r.lastError
+		r.mu.Unlock()
+		if stopped {
+			break
+		}
+		if paused {
+			// Wait for Resume, then reconnect at the live edge
+			select {
+			case <-unpause:
+			case <-r.ctx.Done():
+			}
+			if r.ctx.Err() != nil {
+				break // Stopped while paused
+			}
+			var authToken, streamURL string
+			authToken, streamURL, err = r.resolve()
+			if err == nil {
+				err = r.launch(authToken, streamURL)
+			}
+			if err == nil {
+				continue
+			}
+			lastError = ""
+		}
+		if err == nil && r.resolve == nil {
+			break // Timefree playlist fully downloaded
+		}
+		if err == nil {
+			err = fmt.Errorf("stream ended")
+		}
+		if lastError != "" {
+			err = fmt.Errorf("%w: %s", err, lastError)
+		}
+
+		// A process that ran for a while gets a fresh retry budget
+		if time.Since(launched) > resumeResetAfter {
+			failures = 0
+		}
+		if !r.resume(err, &failures) {
+			r.mu.Lock()
+			if !r.stopped {
+				r.err = fmt.Errorf("ffmpeg exited: %w", err)
+			}
+			r.mu.Unlock()
+			break
+		}
+	}
+
+	r.mu.Lock()
+	r.stopped = true
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.mu.Unlock()
+	r.cancel()
+	r.file.Close()
+
+	// Optional transcode step from the raw ADTS file
+	if info, statErr := os.Stat(r.rawPath); statErr == nil && info.Size() > 0 {
+		if r.Metadata != nil && r.Format.supportsChapters() {
+			r.Metadata.Chapters = chaptersFor(r.StationID, r.audioStart, r.Written(), r.audioOffset)
+		}
+		path, err := Transcode(r.rawPath, r.Format, r.Metadata, r.loudnorm)
+		r.mu.Lock()
+		r.FilePath = path
+		if err != nil && r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+
+		entry := HistoryEntry{
+			Path:      path,
+			StationID: r.StationID,
+			Station:   r.StationName,
+			Title:     r.Title,
+			Duration:  int(r.Written().Seconds()),
+			Format:    r.Format,
+			Tags:      r.tags,
+			Started:   r.audioStart,
+		}
+		if recErr := r.Err(); recErr != nil {
+			entry.Error = recErr.Error()
+		}
+		if r.Metadata != nil {
+			if entry.Title == "" {
+				entry.Title = r.Metadata.Title
+			}
+			entry.Performer = r.Metadata.Performer
+			entry.AirDate = r.Metadata.AirDate
+		}
+		if err := Register(entry, r.retention); err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+
+		// Run the user's hook in the background so a slow upload does not hold up Stop
+		if r.postCommand != "" && err == nil {
+			go runHook(r.postCommand, r.hookEnv(time.Now()))
+		}
+		if r.uploader != nil && err == nil {
+			go func() {
+				if err := Upload(r.uploader, r.outputDir, path); err != nil {
+					log.Printf("⚠️ %v", err)
+				}
+			}()
+		}
+		if r.transcribe != "" && err == nil {
+			go func(env []string) {
+				transcript, err := transcribe(r.transcribe, r.transcript, path, env)
+				if err != nil {
+					log.Printf("⚠️ %v", err)
+					return
+				}
+				log.Printf("📝 文字起こしを保存しました: %s", transcript)
+			}(r.hookEnv(time.Now()))
+		}
+	} else if r.lossless {
+		os.Remove(SegmentDir(r.rawPath)) // Only removed if no segment was saved
+	}
+
+	close(r.done)
+}
+
+// resume re-authenticates and restarts ffmpeg after the stream dropped,
+// retrying with backoff. It returns false if the recording should end.
+func (r *Recording) resume(cause error, failures *int) bool {
+	if r.resolve == nil {
+		return false // Timefree downloads are not resumed
+	}
+
+	dropped := time.Now()
+	log.Printf("⚠️ 録音が途切れました [%s]: %v", r.StationName, cause)
+
+	for *failures < maxResumeAttempts {
+		if !r.EndTime.IsZero() && time.Now().After(r.EndTime) {
+			return false
+		}
+
+		delay := resumeBackoff << *failures
+		if delay > maxResumeBackoff {
+			delay = maxResumeBackoff
+		}
+		*failures++
+		select {
+		case <-r.ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+
+		authToken, streamURL, err := r.resolve()
+		if err == nil {
+			err = r.launch(authToken, streamURL)
+		}
+		if err != nil {
+			log.Printf("⚠️ 録音の再開に失敗しました [%s] (%d/%d): %v", r.StationName, *failures, maxResumeAttempts, err)
+			continue
+		}
+
+		gap := time.Since(dropped)
+		r.mu.Lock()
+		r.gaps = append(r.gaps, Gap{At: dropped, Duration: gap})
+		r.mu.Unlock()
+		log.Printf("🔄 録音を再開しました [%s] (欠落 %s)", r.StationName, gap.Round(time.Second))
+		return true
+	}
+	return false
+}
+
+// Stop stops the recording and waits for ffmpeg to exit
+func (r *Recording) Stop() {
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+
+	r.cancel()
+	<-r.done
+}
+
+// Pause stops writing to the file (e.g. during commercials) until Resume.
+// Only live recordings can be paused.
+func (r *Recording) Pause() error {
+	r.mu.Lock()
+	if r.resolve == nil {
+		r.mu.Unlock()
+		return fmt.Errorf("タイムフリーのダウンロードは一時停止できません")
+	}
+	if r.stopped || r.paused {
+		r.mu.Unlock()
+		return nil
+	}
+	r.paused = true
+	r.pausedAt = time.Now()
+	r.unpause = make(chan struct{})
+	cancel := r.procCancel
+	r.mu.Unlock()
+
+	cancel()
+	log.Printf("⏸ 録音を一時停止しました [%s]", r.StationName)
+	return nil
+}
+
+// Resume continues a paused recording in the same file
+func (r *Recording) Resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.paused {
+		return
+	}
+	r.paused = false
+	r.skipBacklog = true // Do not record what was broadcast during the pause
+	r.pauses = append(r.pauses, Gap{At: r.pausedAt, Duration: time.Since(r.pausedAt)})
+	close(r.unpause)
+	log.Printf("▶️ 録音を再開しました [%s]", r.StationName)
+}
+
+// Paused reports whether the recording is paused
+func (r *Recording) Paused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paused
+}
+
+// Done returns a channel that is closed when the recording has ended
+func (r *Recording) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err returns the error that ended the recording, if it ended unexpectedly
+func (r *Recording) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Gaps returns the interruptions the recording has recovered from
+func (r *Recording) Gaps() []Gap {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Gap(nil), r.gaps...)
+}
+
+// Elapsed returns how long the recording has been running, excluding pauses
+func (r *Recording) Elapsed() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	elapsed := time.Since(r.StartTime)
+	for _, p := range r.pauses {
+		elapsed -= p.Duration
+	}
+	if r.paused {
+		elapsed -= time.Since(r.pausedAt)
+	}
+	return elapsed
+}
+
+// Written returns the duration of audio written to the file so far
+func (r *Recording) Written() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.written
+}
@@ -0,0 +1,193 @@
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/config"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// recoverySlack is how far a recording's start may be from a schedule
+// occurrence and still count as its recording
+const recoverySlack = 2 * time.Minute
+
+// scheduleStateMu guards the schedule state file
+var scheduleStateMu sync.Mutex
+
+// scheduleStatePath returns the file remembering when each schedule was first
+// seen, so that adding a schedule does not fetch its past week from timefree
+func scheduleStatePath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "schedule_state.json"), nil
+}
+
+// scheduleFirstSeen returns when each schedule was first seen, recording now
+// for schedules seen for the first time
+func scheduleFirstSeen(entries []scheduleEntry, now time.Time) (map[string]time.Time, error) {
+	scheduleStateMu.Lock()
+	defer scheduleStateMu.Unlock()
+
+	path, err := scheduleStatePath()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]time.Time)
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &seen); err != nil {
+			return nil, fmt.Errorf("予約状態の読み込みに失敗しました: %w", err)
+		}
+	}
+
+	changed := false
+	for _, entry := range entries {
+		if _, ok := seen[entry.schedule.ID]; !ok {
+			seen[entry.schedule.ID] = now
+			changed = true
+		}
+	}
+	if changed {
+		data, err := json.MarshalIndent(seen, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return seen, nil
+}
+
+// missedOccurrence is a scheduled recording that did not happen
+type missedOccurrence struct {
+	schedule config.Schedule
+	start    time.Time
+}
+
+// findMissed returns the occurrences within the timefree window that ended
+// without a complete recording in the index
+func (s *Scheduler) findMissed(now time.Time) ([]missedOccurrence, error) {
+	s.mu.Lock()
+	entries := append([]scheduleEntry(nil), s.entries...)
+	s.mu.Unlock()
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	seen, err := scheduleFirstSeen(entries, now)
+	if err != nil {
+		return nil, err
+	}
+	history, err := History()
+	if err != nil {
+		return nil, err
+	}
+
+	var missed []missedOccurrence
+	for _, entry := range entries {
+		sched := entry.schedule
+		s.mu.Lock()
+		before, after := s.margins(sched, false)
+		s.mu.Unlock()
+		length := time.Duration(sched.Duration) * time.Minute
+
+		since := now.Add(-TimefreeWindow).Add(before)
+		if first := seen[sched.ID]; first.After(since) {
+			since = first
+		}
+		for start := entry.spec.Next(since.In(jst)); !start.IsZero() && start.Add(length+after).Before(now); start = entry.spec.Next(start) {
+			if !recorded(history, sched.ID, start.Add(-before)) {
+				missed = append(missed, missedOccurrence{schedule: sched, start: start})
+			}
+		}
+	}
+	return missed, nil
+}
+
+// recorded reports whether the index holds a complete recording of a
+// schedule that started around the given time
+func recorded(history []HistoryEntry, scheduleID string, start time.Time) bool {
+	for _, e := range history {
+		if e.Error != "" || !slices.Contains(e.Tags, scheduleID) {
+			continue
+		}
+		if d := e.Started.Sub(start); d > -recoverySlack && d < recoverySlack {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverMissed fetches scheduled recordings missed while radiko-tui was not
+// running (or failed) from timefree, one at a time
+func (s *Scheduler) recoverMissed() {
+	defer s.wg.Done()
+
+	missed, err := s.findMissed(time.Now())
+	if err != nil {
+		log.Printf("⚠️ 録り逃した予約の確認に失敗しました: %v", err)
+		return
+	}
+	for _, m := range missed {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		log.Printf("⏪ 録り逃した予約をタイムフリーで取得します [%s]: %s", m.schedule.ID, m.start.In(jst).Format("2006/01/02 15:04"))
+		if rec := s.downloadMissed(m); rec != nil {
+			<-rec.Done()
+		}
+	}
+}
+
+// downloadMissed downloads one missed occurrence through timefree
+func (s *Scheduler) downloadMissed(m missedOccurrence) *Recording {
+	sched := m.schedule
+	end := m.start.Add(time.Duration(sched.Duration) * time.Minute)
+	prog := model.Program{
+		Ft: m.start.In(jst).Format("20060102150405"),
+		To: end.In(jst).Format("20060102150405"),
+	}
+	// Title and performers of the program on air at the scheduled time
+	day := m.start.In(jst).Add(-5 * time.Hour)
+	if progs, err := api.GetPrograms(context.Background(), sched.StationID, day); err == nil {
+		for _, p := range progs {
+			if p.Ft <= prog.Ft && prog.Ft < p.To {
+				prog.Title = p.Title
+				prog.Pfm = p.Pfm
+				break
+			}
+		}
+	}
+
+	opts := s.options(sched, nil)
+	opts.Title = prog.Title
+	opts.Program = &prog
+	opts.Tags = []string{sched.ID}
+	s.mu.Lock()
+	opts.PreRoll, opts.PostRoll = s.margins(sched, false)
+	s.mu.Unlock()
+
+	// Tracked under its own ID so the schedule can still fire while it downloads
+	occurrence := sched
+	occurrence.ID = fmt.Sprintf("%s-%s", sched.ID, prog.Ft)
+	rec, err := s.manager.Download(occurrence.ID, opts, prog)
+	if err != nil {
+		s.emit(Event{Type: EventFailed, Schedule: occurrence, Err: err})
+		return nil
+	}
+	go s.track(occurrence, rec)
+	return rec
+}
@@ -0,0 +1,195 @@
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/config"
+)
+
+// HistoryEntry is a finished recording in the recording index (recordings.json),
+// which powers the library view, search and retention. Retention only ever
+// deletes files listed in the index, never other files in the output directory.
+type HistoryEntry struct {
+	Path       string    `json:"path"`
+	StationID  string    `json:"station_id"`
+	Station    string    `json:"station"`
+	Title      string    `json:"title,omitempty"`
+	Performer  string    `json:"performer,omitempty"`
+	AirDate    time.Time `json:"air_date,omitempty"`
+	Duration   int       `json:"duration,omitempty"` // Seconds of audio
+	Format     Format    `json:"format,omitempty"`
+	Size       int64     `json:"size,omitempty"`    // File size in bytes
+	Tags       []string  `json:"tags,omitempty"`    // Labels, e.g. the schedule that made the recording
+	Started    time.Time `json:"started,omitempty"` // Broadcast time of the first audio
+	Created    time.Time `json:"created"`
+	Error      string    `json:"error,omitempty"`      // Why the recording ended early (incomplete)
+	Uploaded   time.Time `json:"uploaded,omitempty"`   // When the file was uploaded (zero if not yet)
+	Transcript string    `json:"transcript,omitempty"` // Transcript saved next to the recording
+}
+
+// RetentionPolicy limits the recordings kept on disk. Zero values disable a limit.
+type RetentionPolicy struct {
+	MaxTotalSize       int64         // Bytes
+	MaxAge             time.Duration // Age since the recording finished
+	MaxFilesPerStation int
+}
+
+// historyMu serializes access to the history file
+var historyMu sync.Mutex
+
+// RetentionFromConfig converts the config section to a policy
+func RetentionFromConfig(r config.Retention) RetentionPolicy {
+	return RetentionPolicy{
+		MaxTotalSize:       int64(r.MaxTotalMB) * 1024 * 1024,
+		MaxAge:             time.Duration(r.MaxAgeDays) * 24 * time.Hour,
+		MaxFilesPerStation: r.MaxFilesPerStation,
+	}
+}
+
+// Enabled returns whether any limit is set
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxTotalSize > 0 || p.MaxAge > 0 || p.MaxFilesPerStation > 0
+}
+
+// historyPath returns the path of the recording history file
+func historyPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "recordings.json"), nil
+}
+
+// loadHistory reads the history file (caller must hold historyMu)
+func loadHistory() ([]HistoryEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid recording history: %w", err)
+	}
+	return entries, nil
+}
+
+// saveHistory writes the history file (caller must hold historyMu)
+func saveHistory(entries []HistoryEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Register adds a finished recording to the history and applies the retention policy
+func Register(entry HistoryEntry, policy RetentionPolicy) error {
+	if entry.Created.IsZero() {
+		entry.Created = time.Now()
+	}
+	if info, err := os.Stat(entry.Path); err == nil && entry.Size == 0 {
+		entry.Size = info.Size()
+	}
+
+	historyMu.Lock()
+	entries, err := loadHistory()
+	if err == nil {
+		entries = append(entries, entry)
+		err = saveHistory(entries)
+	}
+	historyMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("録音履歴の保存に失敗しました: %w", err)
+	}
+
+	if policy.Enabled() {
+		_, err = policy.Apply()
+	}
+	return err
+}
+
+// Apply deletes recordings exceeding the policy, oldest first, and returns
+// the deleted paths. Files that no longer exist are dropped from the history.
+func (p RetentionPolicy) Apply() ([]string, error) {
+	if !p.Enabled() {
+		return nil, nil
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	entries, err := loadHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first, so limits keep the most recent recordings
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Created.After(entries[j].Created)
+	})
+
+	now := time.Now()
+	perStation := make(map[string]int)
+	var total int64
+	var overBudget bool
+	var kept []HistoryEntry
+	var removed []string
+
+	for _, e := range entries {
+		info, err := os.Stat(e.Path)
+		if err != nil {
+			continue // Deleted or moved by the user
+		}
+		e.Size = info.Size() // Also fills in entries indexed before sizes were recorded
+
+		station := e.StationID
+		if station == "" {
+			station = e.Station
+		}
+
+		expired := p.MaxAge > 0 && now.Sub(e.Created) > p.MaxAge
+		tooMany := p.MaxFilesPerStation > 0 && perStation[station] >= p.MaxFilesPerStation
+		if p.MaxTotalSize > 0 && total+e.Size > p.MaxTotalSize {
+			overBudget = true // Everything older goes too
+		}
+
+		if expired || tooMany || overBudget {
+			if err := os.Remove(e.Path); err != nil {
+				log.Printf("⚠️ 古い録音の削除に失敗しました: %v", err)
+				kept = append(kept, e)
+				continue
+			}
+			os.RemoveAll(SegmentDir(e.Path)) // Original segments of a lossless recording
+			if e.Transcript != "" {
+				os.Remove(e.Transcript)
+			}
+			log.Printf("🗑 古い録音を削除しました: %s", e.Path)
+			removed = append(removed, e.Path)
+			continue
+		}
+
+		perStation[station]++
+		total += e.Size
+		kept = append(kept, e)
+	}
+
+	return removed, saveHistory(kept)
+}
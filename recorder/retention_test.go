@@ -0,0 +1,117 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// tempConfigDir points the config directory, which holds the recording
+// history, at a temporary directory
+func tempConfigDir(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+}
+
+func TestRetentionApply(t *testing.T) {
+	type recording struct {
+		name, station string
+		age           time.Duration
+		size          int
+	}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		desc       string
+		policy     RetentionPolicy
+		recordings []recording
+		want       []string // Recordings kept
+	}{
+		{
+			"max age",
+			RetentionPolicy{MaxAge: 7 * day},
+			[]recording{{"new", "TBS", day, 10}, {"old", "TBS", 10 * day, 10}},
+			[]string{"new"},
+		},
+		{
+			"max files per station",
+			RetentionPolicy{MaxFilesPerStation: 2},
+			[]recording{{"t1", "TBS", time.Hour, 10}, {"t2", "TBS", 2 * time.Hour, 10}, {"t3", "TBS", 3 * time.Hour, 10}, {"q1", "QRR", 4 * time.Hour, 10}},
+			[]string{"t1", "t2", "q1"},
+		},
+		{
+			// Once over budget, every older recording goes, even small ones
+			"max total size",
+			RetentionPolicy{MaxTotalSize: 250},
+			[]recording{{"a", "TBS", time.Hour, 100}, {"b", "QRR", 2 * time.Hour, 200}, {"c", "TBS", 3 * time.Hour, 10}},
+			[]string{"a"},
+		},
+		{
+			"within limits",
+			RetentionPolicy{MaxAge: 7 * day, MaxTotalSize: 1000, MaxFilesPerStation: 5},
+			[]recording{{"a", "TBS", time.Hour, 100}, {"b", "QRR", day, 100}},
+			[]string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tempConfigDir(t)
+			dir := t.TempDir()
+			var entries []HistoryEntry
+			for _, rec := range tt.recordings {
+				path := filepath.Join(dir, rec.name+".aac")
+				if err := os.WriteFile(path, make([]byte, rec.size), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Mkdir(SegmentDir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				entries = append(entries, HistoryEntry{Path: path, StationID: rec.station, Created: time.Now().Add(-rec.age)})
+			}
+			// A recording deleted by hand only leaves the history
+			entries = append(entries, HistoryEntry{Path: filepath.Join(dir, "gone.aac"), StationID: "TBS", Created: time.Now()})
+			if err := saveHistory(entries); err != nil {
+				t.Fatal(err)
+			}
+
+			removed, err := tt.policy.Apply()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, rec := range tt.recordings {
+				path := filepath.Join(dir, rec.name+".aac")
+				kept := slices.Contains(tt.want, rec.name)
+				if _, err := os.Stat(path); (err == nil) != kept {
+					t.Errorf("%s exists = %v, want %v", rec.name, err == nil, kept)
+				}
+				if _, err := os.Stat(SegmentDir(path)); (err == nil) != kept {
+					t.Errorf("%s segments exist = %v, want %v", rec.name, err == nil, kept)
+				}
+				if slices.Contains(removed, path) == kept {
+					t.Errorf("%s in removed %v = %v", rec.name, removed, !kept)
+				}
+			}
+
+			history, err := loadHistory()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range history {
+				got = append(got, filepath.Base(e.Path))
+			}
+			var want []string
+			for _, name := range tt.want {
+				want = append(want, name+".aac")
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("history = %v, want %v", got, want)
+			}
+		})
+	}
+}
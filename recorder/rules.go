@@ -0,0 +1,214 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/config"
+	"github.com/kanoshiou/radiko-tui/model"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// RuleRefreshInterval is how often keyword rules are re-evaluated against the EPG
+	RuleRefreshInterval = 6 * time.Hour
+	// ruleLookahead is how many broadcast days (including today) rules search
+	ruleLookahead = 7
+)
+
+// normalizeKeyword folds case, full-width/half-width forms and spaces so that
+// "ｵｰﾙﾅｲﾄ" matches "オールナイト" and "ＡＮＮ" matches "ann"
+func normalizeKeyword(s string) string {
+	s = strings.ToLower(norm.NFKC.String(s))
+	return strings.Join(strings.Fields(s), "")
+}
+
+// MatchRule returns whether a program's title or performer contains the rule keyword
+func MatchRule(rule config.Rule, prog model.Program) bool {
+	keyword := normalizeKeyword(rule.Keyword)
+	if keyword == "" {
+		return false
+	}
+	return strings.Contains(normalizeKeyword(prog.Title), keyword) ||
+		strings.Contains(normalizeKeyword(prog.Pfm), keyword)
+}
+
+// SetRules sets the keyword rules evaluated by the scheduler. Stations of
+// rules without station_ids are taken from areaID. Must be called before Start.
+func (s *Scheduler) SetRules(rules []config.Rule, areaID string) error {
+	var valid []config.Rule
+	var errs []string
+	for i, rule := range rules {
+		if rule.Disabled {
+			continue
+		}
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		if strings.TrimSpace(rule.Keyword) == "" {
+			errs = append(errs, fmt.Sprintf("rule %s: keyword is required", rule.ID))
+			continue
+		}
+		if _, err := ParseFormat(rule.Format); err != nil {
+			errs = append(errs, fmt.Sprintf("rule %s: %v", rule.ID, err))
+			continue
+		}
+		valid = append(valid, rule)
+	}
+
+	s.mu.Lock()
+	s.rules = valid
+	s.areaID = areaID
+	s.mu.Unlock()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// runRules evaluates the keyword rules now and then every RuleRefreshInterval
+func (s *Scheduler) runRules() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(RuleRefreshInterval)
+	defer ticker.Stop()
+
+	s.applyRules()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.applyRules()
+		}
+	}
+}
+
+// ruleMatch is a program matching a keyword rule
+type ruleMatch struct {
+	stationID string
+	prog      model.Program
+}
+
+// applyRules searches the upcoming programs for every rule and schedules the
+// matching ones. Rules are searched with radiko's program search, falling
+// back to the EPG of their stations when it fails.
+func (s *Scheduler) applyRules() {
+	s.mu.Lock()
+	rules := s.rules
+	areaID := s.areaID
+	s.mu.Unlock()
+
+	// Station names of the area, also used for rules without station_ids
+	var areaStations []model.Station
+	names := make(map[string]string)
+	if areaID != "" {
+		if stations, err := api.GetStations(context.Background(), areaID); err == nil {
+			areaStations = stations
+			for _, st := range stations {
+				names[st.ID] = st.Name
+			}
+		} else {
+			log.Printf("⚠️ 自動予約: 放送局の取得に失敗しました: %v", err)
+		}
+	}
+
+	// Fetch each station's EPG once even if several rules search it
+	epg := make(map[string][]model.Program)
+	programs := func(stationID string) []model.Program {
+		if progs, ok := epg[stationID]; ok {
+			return progs
+		}
+		var progs []model.Program
+		day := time.Now().In(jst).Add(-5 * time.Hour) // Broadcast day starts at 5:00
+		for i := 0; i < ruleLookahead; i++ {
+			dayProgs, err := api.GetPrograms(context.Background(), stationID, day.AddDate(0, 0, i))
+			if err != nil {
+				break // Days beyond the published EPG
+			}
+			progs = append(progs, dayProgs...)
+		}
+		epg[stationID] = progs
+		return progs
+	}
+
+	now := time.Now()
+	for _, rule := range rules {
+		matches, err := searchRule(rule, areaID)
+		if err != nil {
+			log.Printf("⚠️ 自動予約: 番組検索に失敗しました。番組表から探します: %v", err)
+			stationIDs := rule.StationIDs
+			if len(stationIDs) == 0 {
+				for _, st := range areaStations {
+					stationIDs = append(stationIDs, st.ID)
+				}
+			}
+			matches = nil
+			for _, stationID := range stationIDs {
+				for _, prog := range programs(stationID) {
+					if MatchRule(rule, prog) {
+						matches = append(matches, ruleMatch{stationID: stationID, prog: prog})
+					}
+				}
+			}
+		}
+
+		var format Format // Empty uses the default format
+		if rule.Format != "" {
+			format, _ = ParseFormat(rule.Format)
+		}
+		for _, match := range matches {
+			prog := match.prog
+			name := names[match.stationID]
+			if name == "" {
+				name = match.stationID
+			}
+			if end, err := prog.EndTime(); err != nil || !now.Before(end) {
+				continue
+			}
+			// Programs already scheduled by an earlier pass are rejected here
+			if err := s.ScheduleProgram(match.stationID, name, prog, format); err != nil {
+				continue
+			}
+			s.emit(Event{Type: EventScheduled, Schedule: config.Schedule{ID: rule.ID, StationID: match.stationID, Name: name}, Program: &prog})
+		}
+	}
+}
+
+// searchRule finds the upcoming programs of a rule with radiko's program
+// search: on its stations, or those of areaID when it names none
+func searchRule(rule config.Rule, areaID string) ([]ruleMatch, error) {
+	day := time.Now().In(jst).Add(-5 * time.Hour)
+	query := api.SearchQuery{
+		Keyword: rule.Keyword,
+		Filter:  api.SearchLive,
+		From:    day,
+		To:      day.AddDate(0, 0, ruleLookahead-1),
+	}
+	if len(rule.StationIDs) == 0 {
+		query.AreaID = areaID
+	}
+	results, err := api.SearchPrograms(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []ruleMatch
+	for _, r := range results {
+		if len(rule.StationIDs) > 0 && !slices.Contains(rule.StationIDs, r.StationID) {
+			continue
+		}
+		// The search also looks at descriptions; rules match the title or performer
+		if MatchRule(rule, r.Program) {
+			matches = append(matches, ruleMatch{stationID: r.StationID, prog: r.Program})
+		}
+	}
+	return matches, nil
+}
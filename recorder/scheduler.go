@@ -0,0 +1,574 @@
+package recorder
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/config"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+var jst = model.JST
+
+const (
+	// ProgramPreMargin is how early a program recording starts before the EPG
+	// start time unless margins are configured
+	ProgramPreMargin = 1 * time.Minute
+	// ProgramPostMargin is how long a program recording continues after the EPG
+	// end time unless margins are configured
+	ProgramPostMargin = 2 * time.Minute
+)
+
+// EventType represents what happened to a scheduled recording
+type EventType int
+
+const (
+	EventStarted EventType = iota
+	EventFinished
+	EventFailed
+	EventScheduled // A keyword rule scheduled a program
+)
+
+// Event is emitted by the Scheduler when a scheduled recording changes state
+type Event struct {
+	Type      EventType
+	Schedule  config.Schedule
+	Recording *Recording     // nil when the recording failed to start
+	Program   *model.Program // Program scheduled by a rule (EventScheduled)
+	Err       error
+}
+
+// scheduleEntry is a schedule with its parsed cron spec
+type scheduleEntry struct {
+	schedule  config.Schedule
+	spec      *CronSpec
+	lastFired time.Time // Cron minute the schedule last fired for
+}
+
+// programEntry is a one-shot recording of a single EPG program
+type programEntry struct {
+	schedule config.Schedule
+	title    string
+	program  model.Program
+	start    time.Time // Program start minus the margin
+	end      time.Time // Program end plus the margin
+}
+
+// Scheduler starts and stops recordings at configured times,
+// independent of the player and the TUI focus
+type Scheduler struct {
+	mu       sync.Mutex
+	entries  []scheduleEntry
+	disabled []config.Schedule // Kept to be listed and deleted, never fired
+	programs []programEntry
+	active   map[string]*Recording // Keyed by schedule ID
+	manager  *Manager              // Runs the recordings (and supplies output defaults)
+	rules    []config.Rule         // Keyword auto-record rules
+	areaID   string                // Area whose stations rules search by default
+	margin   *config.Margin        // Default margins (nil = ProgramPreMargin/ProgramPostMargin for programs, none for cron)
+	onEvent  func(Event)
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// CronExpr returns the cron expression for a schedule, converting weekly rules
+func CronExpr(s config.Schedule) (string, error) {
+	if s.Cron != "" {
+		return s.Cron, nil
+	}
+	if len(s.Weekdays) == 0 || s.Start == "" {
+		return "", fmt.Errorf("schedule %s: cron or weekdays+start is required", s.ID)
+	}
+
+	t, err := time.Parse("15:04", s.Start)
+	if err != nil {
+		return "", fmt.Errorf("schedule %s: invalid start time %q", s.ID, s.Start)
+	}
+	return fmt.Sprintf("%d %d * * %s", t.Minute(), t.Hour(), strings.Join(s.Weekdays, ",")), nil
+}
+
+// NewScheduler creates a scheduler for the given schedules, which need an ID
+// (see config.AssignScheduleIDs). Recordings are started through manager, so
+// they run alongside any manual recordings. Disabled schedules are only
+// listed. Invalid schedules are reported in the returned error but do not
+// prevent the others from running.
+func NewScheduler(schedules []config.Schedule, manager *Manager) (*Scheduler, error) {
+	s := &Scheduler{
+		active:  make(map[string]*Recording),
+		manager: manager,
+		onEvent: func(e Event) {
+			logEvent(e)
+		},
+		stop: make(chan struct{}),
+	}
+
+	var errs []string
+	for i, sched := range schedules {
+		if sched.ID == "" {
+			errs = append(errs, fmt.Sprintf("schedule %d: id is required", i+1))
+			continue
+		}
+		if sched.Disabled {
+			s.disabled = append(s.disabled, sched)
+			continue
+		}
+		entry, err := newScheduleEntry(sched)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		s.entries = append(s.entries, entry)
+	}
+
+	if len(errs) > 0 {
+		return s, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return s, nil
+}
+
+// newScheduleEntry validates a schedule and parses its cron spec
+func newScheduleEntry(sched config.Schedule) (scheduleEntry, error) {
+	if sched.StationID == "" || sched.Duration <= 0 {
+		return scheduleEntry{}, fmt.Errorf("schedule %s: station_id and duration are required", sched.ID)
+	}
+	if _, err := ParseFormat(sched.Format); err != nil {
+		return scheduleEntry{}, fmt.Errorf("schedule %s: %v", sched.ID, err)
+	}
+	expr, err := CronExpr(sched)
+	if err != nil {
+		return scheduleEntry{}, err
+	}
+	spec, err := ParseCron(expr)
+	if err != nil {
+		return scheduleEntry{}, fmt.Errorf("schedule %s: %v", sched.ID, err)
+	}
+	return scheduleEntry{schedule: sched, spec: spec}, nil
+}
+
+// Schedules returns the cron and weekly schedules, the disabled ones last
+func (s *Scheduler) Schedules() []config.Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := make([]config.Schedule, 0, len(s.entries)+len(s.disabled))
+	for _, entry := range s.entries {
+		schedules = append(schedules, entry.schedule)
+	}
+	return append(schedules, s.disabled...)
+}
+
+// AddSchedule adds a cron or weekly schedule while the scheduler runs and
+// returns it with its ID, generated from the station when empty
+func (s *Scheduler) AddSchedule(sched config.Schedule) (config.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sched.ID == "" {
+		for i := len(s.entries) + 1; ; i++ {
+			sched.ID = fmt.Sprintf("%s-%d", sched.StationID, i)
+			if !s.hasID(sched.ID) {
+				break
+			}
+		}
+	} else if s.hasID(sched.ID) {
+		return sched, fmt.Errorf("schedule %s already exists", sched.ID)
+	}
+
+	entry, err := newScheduleEntry(sched)
+	if err != nil {
+		return sched, err
+	}
+	// Do not fire for the minute the schedule was added in
+	entry.lastFired = time.Now().In(jst).Truncate(time.Minute)
+	s.entries = append(s.entries, entry)
+	return sched, nil
+}
+
+// RemoveSchedule removes a schedule or a pending program recording,
+// reporting whether it existed. A recording in progress keeps running.
+func (s *Scheduler) RemoveSchedule(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, entry := range s.entries {
+		if entry.schedule.ID == id {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return true
+		}
+	}
+	for i, entry := range s.programs {
+		if entry.schedule.ID == id {
+			s.programs = append(s.programs[:i], s.programs[i+1:]...)
+			return true
+		}
+	}
+	for i, sched := range s.disabled {
+		if sched.ID == id {
+			s.disabled = append(s.disabled[:i], s.disabled[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// hasID reports whether a schedule or pending program uses an ID.
+// Must be called with s.mu held.
+func (s *Scheduler) hasID(id string) bool {
+	for _, entry := range s.entries {
+		if entry.schedule.ID == id {
+			return true
+		}
+	}
+	for _, entry := range s.programs {
+		if entry.schedule.ID == id {
+			return true
+		}
+	}
+	for _, sched := range s.disabled {
+		if sched.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Upcoming is a recording the scheduler will start
+type Upcoming struct {
+	Schedule config.Schedule
+	Title    string    // Program title (program recordings)
+	Start    time.Time // Including the margin
+	End      time.Time
+}
+
+// Upcoming returns the recordings starting before until, in start order
+func (s *Scheduler) Upcoming(until time.Time) []Upcoming {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var upcoming []Upcoming
+	now := time.Now()
+	for _, entry := range s.entries {
+		before, after := s.margins(entry.schedule, false)
+		duration := time.Duration(entry.schedule.Duration) * time.Minute
+		for t := entry.spec.Next(now.Add(before).In(jst)); !t.IsZero() && t.Before(until); t = entry.spec.Next(t) {
+			upcoming = append(upcoming, Upcoming{
+				Schedule: entry.schedule,
+				Start:    t.Add(-before),
+				End:      t.Add(duration + after),
+			})
+		}
+	}
+	for _, entry := range s.programs {
+		if entry.start.Before(until) {
+			upcoming = append(upcoming, Upcoming{
+				Schedule: entry.schedule,
+				Title:    entry.title,
+				Start:    entry.start,
+				End:      entry.end,
+			})
+		}
+	}
+	sort.Slice(upcoming, func(i, j int) bool { return upcoming[i].Start.Before(upcoming[j].Start) })
+	return upcoming
+}
+
+// logEvent is the default event handler
+func logEvent(e Event) {
+	switch e.Type {
+	case EventStarted:
+		log.Printf("⏺ 予約録音開始 [%s]: %s", e.Schedule.ID, e.Recording.FilePath)
+	case EventFinished:
+		log.Printf("⏹ 予約録音完了 [%s]: %s", e.Schedule.ID, e.Recording.FilePath)
+	case EventFailed:
+		log.Printf("❌ 予約録音失敗 [%s]: %v", e.Schedule.ID, e.Err)
+	case EventScheduled:
+		log.Printf("🔎 自動予約 [%s]: %s %s %s", e.Schedule.ID, e.Schedule.Name, e.Program.Ft, e.Program.Title)
+	}
+}
+
+// SetEventHandler sets the callback for schedule events (replaces the default logger)
+func (s *Scheduler) SetEventHandler(handler func(Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onEvent = handler
+}
+
+// SetMargin sets the default margins of scheduled and program recordings.
+// Schedules with their own margin keep it. Must be called before Start.
+func (s *Scheduler) SetMargin(margin *config.Margin) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.margin = margin
+}
+
+// margins returns how early a schedule starts and how long it overruns.
+// Must be called with s.mu held.
+func (s *Scheduler) margins(sched config.Schedule, program bool) (before, after time.Duration) {
+	m := sched.Margin
+	if m == nil {
+		m = s.margin
+	}
+	if m == nil {
+		if program {
+			return ProgramPreMargin, ProgramPostMargin
+		}
+		return 0, 0
+	}
+	return time.Duration(m.Before) * time.Second, time.Duration(m.After) * time.Second
+}
+
+// Start runs the scheduler (and keyword rules, if any) in the background.
+// Scheduled recordings missed since the last run are fetched from timefree.
+func (s *Scheduler) Start() {
+	s.wg.Add(2)
+	go s.run()
+	go s.recoverMissed()
+
+	s.mu.Lock()
+	hasRules := len(s.rules) > 0
+	s.mu.Unlock()
+	if hasRules {
+		s.wg.Add(1)
+		go s.runRules()
+	}
+}
+
+// run checks the schedules every few seconds and fires those matching the current minute
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	s.check(time.Now())
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.check(now)
+		}
+	}
+}
+
+// check fires every schedule whose cron spec matches the current minute
+// (once per minute), the margin before the minute starts
+func (s *Scheduler) check(now time.Time) {
+	type dueSchedule struct {
+		schedule config.Schedule
+		duration time.Duration
+	}
+
+	s.mu.Lock()
+	var due []dueSchedule
+	for i := range s.entries {
+		entry := &s.entries[i]
+		before, after := s.margins(entry.schedule, false)
+		minute := now.Add(before).In(jst).Truncate(time.Minute)
+		if !minute.After(entry.lastFired) {
+			continue
+		}
+		if _, running := s.active[entry.schedule.ID]; running {
+			continue
+		}
+		if entry.spec.Matches(minute) {
+			entry.lastFired = minute
+			duration := minute.Sub(now) + time.Duration(entry.schedule.Duration)*time.Minute + after
+			due = append(due, dueSchedule{entry.schedule, duration})
+		}
+	}
+	var duePrograms []programEntry
+	pending := s.programs[:0]
+	for _, entry := range s.programs {
+		if !now.Before(entry.start) {
+			duePrograms = append(duePrograms, entry)
+		} else {
+			pending = append(pending, entry)
+		}
+	}
+	s.programs = pending
+	s.mu.Unlock()
+
+	for _, d := range due {
+		go s.fire(d.schedule, nil, d.duration)
+	}
+	for _, entry := range duePrograms {
+		go s.fire(entry.schedule, &entry.program, time.Until(entry.end))
+	}
+}
+
+// ScheduleProgram records a single EPG program. The recording starts at the
+// program start time minus the margin (immediately if it is already on air)
+// and stops automatically at the program end time plus the margin.
+func (s *Scheduler) ScheduleProgram(stationID, stationName string, prog model.Program, format Format) error {
+	start, err := prog.StartTime()
+	if err != nil {
+		return fmt.Errorf("invalid program start time: %w", err)
+	}
+	end, err := prog.EndTime()
+	if err != nil {
+		return fmt.Errorf("invalid program end time: %w", err)
+	}
+	if !time.Now().Before(end) {
+		return fmt.Errorf("番組は既に終了しています")
+	}
+
+	entry := programEntry{
+		schedule: config.Schedule{
+			ID:        fmt.Sprintf("%s-%s", stationID, prog.Ft),
+			StationID: stationID,
+			Name:      stationName,
+			Format:    string(format),
+		},
+		title:   prog.Title,
+		program: prog,
+	}
+
+	s.mu.Lock()
+	before, after := s.margins(entry.schedule, true)
+	entry.start = start.Add(-before)
+	entry.end = end.Add(after)
+	if _, running := s.active[entry.schedule.ID]; running {
+		s.mu.Unlock()
+		return fmt.Errorf("既に録音中です")
+	}
+	for _, p := range s.programs {
+		if p.schedule.ID == entry.schedule.ID {
+			s.mu.Unlock()
+			return fmt.Errorf("既に予約済みです")
+		}
+	}
+
+	// Already on air: start right away instead of waiting for the next check
+	if !time.Now().Before(entry.start) {
+		s.mu.Unlock()
+		go s.fire(entry.schedule, &entry.program, time.Until(entry.end))
+		return nil
+	}
+
+	s.programs = append(s.programs, entry)
+	s.mu.Unlock()
+	return nil
+}
+
+// DownloadProgram downloads a past EPG program through timefree in the background.
+// Progress is reported through the same events as scheduled recordings.
+func (s *Scheduler) DownloadProgram(stationID, stationName string, prog model.Program, format Format) error {
+	sched := config.Schedule{
+		ID:        fmt.Sprintf("%s-%s", stationID, prog.Ft),
+		StationID: stationID,
+		Name:      stationName,
+		Format:    string(format),
+	}
+
+	s.mu.Lock()
+	if _, running := s.active[sched.ID]; running {
+		s.mu.Unlock()
+		return fmt.Errorf("既にダウンロード中です")
+	}
+	opts := s.options(sched, nil)
+	opts.PreRoll, opts.PostRoll = s.margins(sched, true)
+	s.mu.Unlock()
+
+	go func() {
+		rec, err := s.manager.Download(sched.ID, opts, prog)
+		if err != nil {
+			s.emit(Event{Type: EventFailed, Schedule: sched, Err: err})
+			return
+		}
+		s.track(sched, rec)
+	}()
+	return nil
+}
+
+// PendingPrograms returns the number of program recordings waiting to start
+func (s *Scheduler) PendingPrograms() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.programs)
+}
+
+// options builds recording options for a schedule (the manager fills in the defaults)
+func (s *Scheduler) options(sched config.Schedule, prog *model.Program) Options {
+	opts := Options{
+		StationID:   sched.StationID,
+		StationName: sched.Name,
+	}
+	if prog != nil {
+		opts.Title = prog.Title
+		opts.Program = prog
+	}
+	if f, err := ParseFormat(sched.Format); err == nil && sched.Format != "" {
+		opts.Format = f
+	}
+	return opts
+}
+
+// fire starts a scheduled recording and waits for it to finish
+func (s *Scheduler) fire(sched config.Schedule, prog *model.Program, duration time.Duration) {
+	opts := s.options(sched, prog)
+	opts.Duration = duration
+	if prog == nil {
+		opts.Tags = []string{sched.ID} // Find a series' recordings by its schedule ID
+	}
+	rec, err := s.manager.Start(sched.ID, opts)
+	if err != nil {
+		s.emit(Event{Type: EventFailed, Schedule: sched, Err: err})
+		return
+	}
+
+	s.track(sched, rec)
+}
+
+// track registers a running recording and emits events until it finishes
+func (s *Scheduler) track(sched config.Schedule, rec *Recording) {
+	s.mu.Lock()
+	s.active[sched.ID] = rec
+	s.mu.Unlock()
+	s.emit(Event{Type: EventStarted, Schedule: sched, Recording: rec})
+
+	<-rec.Done()
+
+	s.mu.Lock()
+	delete(s.active, sched.ID)
+	s.mu.Unlock()
+
+	if err := rec.Err(); err != nil {
+		s.emit(Event{Type: EventFailed, Schedule: sched, Recording: rec, Err: err})
+	} else {
+		s.emit(Event{Type: EventFinished, Schedule: sched, Recording: rec})
+	}
+}
+
+// emit delivers an event to the handler
+func (s *Scheduler) emit(e Event) {
+	s.mu.Lock()
+	handler := s.onEvent
+	s.mu.Unlock()
+	if handler != nil {
+		handler(e)
+	}
+}
+
+// Active returns the scheduled recordings currently in progress
+func (s *Scheduler) Active() []*Recording {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recs := make([]*Recording, 0, len(s.active))
+	for _, r := range s.active {
+		recs = append(recs, r)
+	}
+	return recs
+}
+
+// Stop stops the scheduler. Recordings in progress keep running until the
+// manager stops them.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
@@ -0,0 +1,266 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+)
+
+// maxPlaylistFailures is how many playlist reloads may fail in a row before
+// a lossless capture gives up (and a live recording resumes)
+const maxPlaylistFailures = 3
+
+// SegmentDir returns the directory in which a lossless recording keeps the
+// original HLS segments (next to the recording, without the extension)
+func SegmentDir(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".segments"
+}
+
+// mediaSegment is one entry of an HLS media playlist
+type mediaSegment struct {
+	url      string
+	duration time.Duration
+}
+
+// mediaPlaylist is the part of an HLS media playlist needed to capture it
+type mediaPlaylist struct {
+	targetDuration time.Duration
+	segments       []mediaSegment
+	ended          bool // #EXT-X-ENDLIST: no more segments will be added
+}
+
+// fetchPlaylist downloads an HLS playlist, following a master playlist to its first variant
+func fetchPlaylist(ctx context.Context, authToken, playlistURL string) (*mediaPlaylist, string, error) {
+	for range 2 {
+		body, err := fetchSegment(ctx, authToken, playlistURL)
+		if err != nil {
+			return nil, "", err
+		}
+		base, err := url.Parse(playlistURL)
+		if err != nil {
+			return nil, "", err
+		}
+
+		pl := &mediaPlaylist{}
+		var variant string
+		var duration time.Duration
+		streamInf := false
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			switch {
+			case line == "":
+			case strings.HasPrefix(line, "#EXT-X-STREAM-INF"):
+				streamInf = true
+			case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+				if sec, err := strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:")); err == nil {
+					pl.targetDuration = time.Duration(sec) * time.Second
+				}
+			case strings.HasPrefix(line, "#EXTINF:"):
+				value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+				if sec, err := strconv.ParseFloat(value, 64); err == nil {
+					duration = time.Duration(sec * float64(time.Second))
+				}
+			case line == "#EXT-X-ENDLIST":
+				pl.ended = true
+			case strings.HasPrefix(line, "#"):
+			default:
+				ref, err := base.Parse(line)
+				if err != nil {
+					continue
+				}
+				if streamInf {
+					if variant == "" {
+						variant = ref.String()
+					}
+					streamInf = false
+					continue
+				}
+				pl.segments = append(pl.segments, mediaSegment{url: ref.String(), duration: duration})
+				duration = 0
+			}
+		}
+
+		if variant == "" {
+			return pl, playlistURL, nil
+		}
+		playlistURL = variant
+	}
+	return nil, "", fmt.Errorf("nested master playlists are not supported")
+}
+
+// fetchSegment downloads a playlist or segment
+func fetchSegment(ctx context.Context, authToken, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Radiko-AuthToken", authToken)
+
+	resp, err := api.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: status %d", target, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// stripID3 removes the ID3v2 tags HLS puts in front of each AAC segment,
+// leaving the ADTS frames exactly as broadcast
+func stripID3(data []byte) []byte {
+	for len(data) >= 10 && string(data[:3]) == "ID3" {
+		size := int(data[6]&0x7f)<<21 | int(data[7]&0x7f)<<14 | int(data[8]&0x7f)<<7 | int(data[9]&0x7f)
+		size += 10
+		if data[5]&0x10 != 0 {
+			size += 10 // Footer
+		}
+		if size > len(data) {
+			return nil
+		}
+		data = data[size:]
+	}
+	return data
+}
+
+// launchSegments starts capturing the original HLS segments instead of an
+// ffmpeg process. Each segment is saved unmodified to the segment directory
+// and its ADTS frames are appended to the recording file, so the audio is
+// bit-exact and only remuxed when the recording ends.
+func (r *Recording) launchSegments(ctx context.Context, authToken, playlistURL string, skipBacklog bool) error {
+	dir := SegmentDir(r.rawPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("セグメント保存フォルダの作成に失敗しました: %w", err)
+	}
+
+	r.mu.Lock()
+	r.lastError = ""
+	r.mu.Unlock()
+
+	exited := make(chan error, 1)
+	r.exited = exited
+	go func() {
+		exited <- r.captureSegments(ctx, authToken, playlistURL, dir, skipBacklog)
+	}()
+	return nil
+}
+
+// captureSegments polls the playlist and saves new segments until the
+// playlist ends, the capture is cancelled or the stream fails. With
+// skipBacklog the segments already listed, except the newest, are skipped.
+func (r *Recording) captureSegments(ctx context.Context, authToken, playlistURL, dir string, skipBacklog bool) error {
+	failures := 0
+	for {
+		pl, mediaURL, err := fetchPlaylist(ctx, authToken, playlistURL)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			failures++
+			if failures >= maxPlaylistFailures {
+				return err
+			}
+			r.setLastError(err)
+		} else {
+			failures = 0
+			playlistURL = mediaURL // Reload the variant directly
+
+			if skipBacklog {
+				r.mu.Lock()
+				for i := 0; i < len(pl.segments)-1; i++ {
+					r.segments[segmentName(pl.segments[i].url)] = true
+				}
+				r.mu.Unlock()
+				skipBacklog = false
+			}
+
+			added := 0
+			for _, seg := range pl.segments {
+				name := segmentName(seg.url)
+				r.mu.Lock()
+				seen := r.segments[name]
+				r.mu.Unlock()
+				if seen {
+					continue
+				}
+				if err := r.saveSegment(ctx, authToken, seg, name, dir); err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					return err
+				}
+				added++
+			}
+			if pl.ended {
+				return nil
+			}
+			if added > 0 {
+				continue // Catch up (timefree) before waiting for new segments
+			}
+		}
+
+		wait := time.Second
+		if pl != nil && pl.targetDuration > 2*time.Second {
+			wait = pl.targetDuration / 2
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// saveSegment downloads one segment, keeps the original and appends its audio to the recording
+func (r *Recording) saveSegment(ctx context.Context, authToken string, seg mediaSegment, name, dir string) error {
+	data, err := fetchSegment(ctx, authToken, seg.url)
+	if err != nil {
+		return err
+	}
+
+	// Skipped segments are in r.segments too, so files are numbered apart
+	r.mu.Lock()
+	index := r.saved + 1
+	r.mu.Unlock()
+	if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%06d_%s", index, name)), data, 0644); err != nil {
+		return fmt.Errorf("セグメントの保存に失敗しました: %w", err)
+	}
+	if _, err := r.file.Write(stripID3(data)); err != nil {
+		return fmt.Errorf("録音ファイルへの書き込みに失敗しました: %w", err)
+	}
+
+	r.mu.Lock()
+	r.segments[name] = true
+	r.saved = index
+	r.written += seg.duration
+	r.mu.Unlock()
+	return nil
+}
+
+// segmentName identifies a segment across playlist reloads and re-authentication
+func segmentName(segmentURL string) string {
+	if u, err := url.Parse(segmentURL); err == nil {
+		return path.Base(u.Path)
+	}
+	return path.Base(segmentURL)
+}
+
+func (r *Recording) setLastError(err error) {
+	r.mu.Lock()
+	r.lastError = err.Error()
+	r.mu.Unlock()
+}
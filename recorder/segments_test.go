@@ -0,0 +1,36 @@
+package recorder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveSegmentNumbering(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("audio"))
+	}))
+	t.Cleanup(ts.Close)
+	dir := t.TempDir()
+	file, err := os.Create(filepath.Join(dir, "rec.aac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+
+	// Two segments of the backlog were skipped
+	r := &Recording{file: file, segments: map[string]bool{"a.aac": true, "b.aac": true}}
+	for _, name := range []string{"c.aac", "d.aac"} {
+		if err := r.saveSegment(context.Background(), "", mediaSegment{url: ts.URL + "/" + name}, name, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"000001_c.aac", "000002_d.aac"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("segment file: %v", err)
+		}
+	}
+}
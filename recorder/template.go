@@ -0,0 +1,138 @@
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultTemplate is the filename template used when none is configured.
+// Placeholders that expand to an empty value are dropped together with
+// their surrounding separator, so live recordings without a program title
+// become radiko_{station}_{date}_{time}.
+const DefaultTemplate = "radiko_{station}_{program}_{date}_{time}"
+
+// TemplateFields are the values available to a filename template
+type TemplateFields struct {
+	StationID string
+	Station   string
+	Program   string
+	Performer string
+	Time      time.Time // Recording start (or broadcast start for programs)
+}
+
+var placeholderRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// templateValues returns the placeholder values for the fields
+func (f TemplateFields) templateValues() map[string]string {
+	t := f.Time.In(jst)
+	return map[string]string{
+		"station":    f.Station,
+		"station_id": f.StationID,
+		"program":    f.Program,
+		"performer":  f.Performer,
+		"date":       t.Format("20060102"),
+		"time":       t.Format("150405"),
+		"year":       t.Format("2006"),
+		"month":      t.Format("01"),
+		"day":        t.Format("02"),
+		"hour":       t.Format("15"),
+		"minute":     t.Format("04"),
+		"weekday":    t.Format("Mon"),
+	}
+}
+
+// ValidateTemplate checks that a template only uses known placeholders
+func ValidateTemplate(tmpl string) error {
+	known := TemplateFields{}.templateValues()
+	for _, m := range placeholderRe.FindAllStringSubmatch(tmpl, -1) {
+		if _, ok := known[m[1]]; !ok {
+			return fmt.Errorf("unknown placeholder {%s} in filename template %q", m[1], tmpl)
+		}
+	}
+	if strings.TrimSpace(tmpl) == "" {
+		return fmt.Errorf("filename template is empty")
+	}
+	return nil
+}
+
+// ExpandTemplate expands a template like "{station}/{date}_{program}" into a
+// relative path without extension. Values are sanitized so they cannot add
+// directories; "/" in the template itself creates subdirectories.
+// A trailing recording format extension (e.g. ".m4a") is ignored because the
+// extension always follows the recording format.
+func ExpandTemplate(tmpl string, fields TemplateFields) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+	if err := ValidateTemplate(tmpl); err != nil {
+		return "", err
+	}
+	for _, f := range Formats {
+		tmpl = strings.TrimSuffix(tmpl, f.Ext())
+	}
+
+	values := fields.templateValues()
+	var parts []string
+	for _, component := range strings.Split(filepath.ToSlash(tmpl), "/") {
+		expanded := placeholderRe.ReplaceAllStringFunc(component, func(p string) string {
+			return SanitizeFilename(values[p[1:len(p)-1]])
+		})
+		expanded = cleanComponent(expanded)
+		if expanded == "" || expanded == "." || expanded == ".." {
+			continue
+		}
+		parts = append(parts, expanded)
+	}
+
+	if len(parts) == 0 {
+		return "", fmt.Errorf("filename template %q expanded to an empty name", tmpl)
+	}
+	return filepath.Join(parts...), nil
+}
+
+var repeatedSepRe = regexp.MustCompile(`[_\-]{2,}`)
+
+// cleanComponent collapses separators left behind by empty placeholders
+func cleanComponent(s string) string {
+	s = repeatedSepRe.ReplaceAllStringFunc(s, func(sep string) string {
+		return sep[:1]
+	})
+	return strings.Trim(s, "_- .")
+}
+
+// OutputPath expands the template under dir, creates any subdirectories and
+// returns a path for the raw ADTS recording. Neither it nor the file it is
+// transcoded to in format overwrites an existing file.
+func OutputPath(dir, tmpl string, fields TemplateFields, format Format) (string, error) {
+	rel, err := ExpandTemplate(tmpl, fields)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("録音フォルダの作成に失敗しました: %w", err)
+	}
+	return uniquePath(path, FormatAAC.Ext(), format.Ext()), nil
+}
+
+// uniquePath returns path+ext, adding a numeric suffix while the file or
+// the one it becomes, with the extension final, already exists
+func uniquePath(path, ext, final string) string {
+	base := path
+	for i := 2; ; i++ {
+		if !exists(base+ext) && !exists(base+final) {
+			return base + ext
+		}
+		base = fmt.Sprintf("%s_%d", path, i)
+	}
+}
+
+// exists reports whether a file exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
+}
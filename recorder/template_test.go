@@ -0,0 +1,85 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	fields := TemplateFields{
+		StationID: "TBS",
+		Station:   "TBSラジオ",
+		Program:   "森本毅郎 スタンバイ!",
+		Performer: "森本毅郎",
+		Time:      time.Date(2026, 10, 16, 6, 30, 0, 0, jst),
+	}
+
+	tests := []struct {
+		tmpl   string
+		fields TemplateFields
+		want   string
+	}{
+		{"", fields, "radiko_TBSラジオ_森本毅郎_スタンバイ!_20261016_063000"},
+		{"{station}/{date}_{program}", fields, filepath.Join("TBSラジオ", "20261016_森本毅郎_スタンバイ!")},
+		{"{station_id}/{year}/{month}/{day}_{hour}{minute}_{weekday}", fields, filepath.Join("TBS", "2026", "10", "16_0630_Fri")},
+		{"{station_id}_{date}.m4a", fields, "TBS_20261016"},
+		// Empty placeholders drop their separator
+		{"", TemplateFields{StationID: "TBS", Station: "TBS", Time: fields.Time}, "radiko_TBS_20261016_063000"},
+		{"{station}/{program}/{date}", TemplateFields{Station: "TBS", Time: fields.Time}, filepath.Join("TBS", "20261016")},
+		// Values cannot add directories
+		{"{program}", TemplateFields{Program: "../a/b:c", Time: fields.Time}, "a_b_c"},
+		{"../{station_id}", fields, "TBS"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandTemplate(tt.tmpl, tt.fields)
+		if err != nil {
+			t.Errorf("ExpandTemplate(%q): %v", tt.tmpl, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ExpandTemplate(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTemplateInvalid(t *testing.T) {
+	for _, tmpl := range []string{"{title}", " ", "{program}"} {
+		if got, err := ExpandTemplate(tmpl, TemplateFields{}); err == nil {
+			t.Errorf("ExpandTemplate(%q) = %q, want an error", tmpl, got)
+		}
+	}
+}
+
+func TestUniquePath(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "rec")
+
+	tests := []struct {
+		existing []string // Files present before
+		final    string
+		want     string
+	}{
+		{nil, ".aac", "rec.aac"},
+		{[]string{"rec.aac"}, ".aac", "rec_2.aac"},
+		{[]string{"rec.aac", "rec_2.aac"}, ".aac", "rec_3.aac"},
+		// The transcoded file of an earlier recording is not overwritten
+		{[]string{"rec.m4a"}, ".m4a", "rec_2.aac"},
+		{[]string{"rec.mp3", "rec_2.aac"}, ".mp3", "rec_3.aac"},
+		{[]string{"rec.mp3"}, ".flac", "rec.aac"},
+	}
+	for _, tt := range tests {
+		for _, name := range tt.existing {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := uniquePath(base, ".aac", tt.final); got != filepath.Join(dir, tt.want) {
+			t.Errorf("uniquePath with %v, %s = %s, want %s", tt.existing, tt.final, filepath.Base(got), tt.want)
+		}
+		for _, name := range tt.existing {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+}
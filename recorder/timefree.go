@@ -0,0 +1,77 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// TimefreeWindow is how far back radiko keeps programs available for timefree
+const TimefreeWindow = 7 * 24 * time.Hour
+
+// Download downloads a past program through timefree. Unlike Start it does not
+// record in real time: ffmpeg fetches the segments as fast as the network allows
+// and the returned Recording ends when the whole program has been written.
+func Download(opts Options, prog model.Program) (*Recording, error) {
+	start, err := prog.StartTime()
+	if err != nil {
+		return nil, fmt.Errorf("invalid program start time: %w", err)
+	}
+	end, err := prog.EndTime()
+	if err != nil {
+		return nil, fmt.Errorf("invalid program end time: %w", err)
+	}
+
+	now := time.Now()
+	if now.Before(end) {
+		return nil, fmt.Errorf("番組はまだ終了していません")
+	}
+	if now.Sub(start) > TimefreeWindow {
+		return nil, fmt.Errorf("タイムフリーの期間(7日間)を過ぎています")
+	}
+
+	if opts.Title == "" {
+		opts.Title = prog.Title
+	}
+	if err := prepare(&opts); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	authToken, err := authenticate(ctx, opts.StationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Margins widen the downloaded range, but never past what has been broadcast
+	from := start.Add(-opts.PreRoll)
+	to := end.Add(opts.PostRoll)
+	if to.After(now) {
+		to = end
+	}
+	playlistURL, err := api.GetTimefreeStreamURL(ctx, opts.StationID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timefree stream URL: %w", err)
+	}
+
+	// Name the file after the broadcast time rather than the download time
+	if opts.Program == nil {
+		opts.Program = &prog
+	}
+	r, err := newRecording(opts, start)
+	if err != nil {
+		return nil, err
+	}
+	r.Length = to.Sub(from)
+	r.audioStart = from
+	r.Metadata = MetadataFor(opts.StationID, opts.StationName, &prog)
+	if err := r.start(authToken, playlistURL); err != nil {
+		return nil, err
+	}
+
+	go r.wait()
+	return r, nil
+}
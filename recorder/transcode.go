@@ -0,0 +1,148 @@
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Format is the output format of a recording
+type Format string
+
+const (
+	FormatAAC  Format = "aac"  // Raw ADTS as received from ffmpeg (no transcode)
+	FormatM4A  Format = "m4a"  // AAC remuxed into MP4 without re-encoding
+	FormatMP3  Format = "mp3"  // Transcoded to MP3 (libmp3lame)
+	FormatFLAC Format = "flac" // Transcoded to FLAC
+)
+
+// Formats lists the supported output formats
+var Formats = []Format{FormatAAC, FormatM4A, FormatMP3, FormatFLAC}
+
+// ParseFormat parses a format name; an empty string means FormatAAC
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return FormatAAC, nil
+	}
+	f := Format(strings.ToLower(strings.TrimPrefix(s, ".")))
+	for _, known := range Formats {
+		if f == known {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported recording format %q (aac, m4a, mp3, flac)", s)
+}
+
+// Ext returns the file extension including the dot
+func (f Format) Ext() string {
+	if f == "" {
+		return ".aac"
+	}
+	return "." + string(f)
+}
+
+// LoudnormFilter normalizes to -16 LUFS, the usual loudness target for spoken-word podcasts
+const LoudnormFilter = "loudnorm=I=-16:TP=-1.5:LRA=11"
+
+// codecArgs returns the ffmpeg output options for the format. Normalized
+// audio has to be re-encoded, so AAC and M4A no longer copy the stream.
+func (f Format) codecArgs(loudnorm bool) []string {
+	var args []string
+	if loudnorm {
+		// loudnorm resamples to 192 kHz internally; keep radiko's 48 kHz
+		args = append(args, "-af", LoudnormFilter, "-ar", "48000")
+	}
+	switch f {
+	case FormatM4A:
+		if loudnorm {
+			return append(args, "-c:a", "aac", "-b:a", "128k", "-movflags", "+faststart")
+		}
+		return append(args, "-c:a", "copy", "-bsf:a", "aac_adtstoasc", "-movflags", "+faststart")
+	case FormatMP3:
+		return append(args, "-c:a", "libmp3lame", "-q:a", "2")
+	case FormatFLAC:
+		return append(args, "-c:a", "flac")
+	default:
+		if loudnorm {
+			return append(args, "-c:a", "aac", "-b:a", "128k")
+		}
+		return append(args, "-c:a", "copy")
+	}
+}
+
+// Transcode converts a raw ADTS recording to the given format with ffmpeg and
+// embeds meta as tags (and the station logo as artwork where supported),
+// optionally normalizing the loudness with LoudnormFilter.
+// On success the source file is removed and the new path is returned.
+// FormatAAC without metadata or normalization returns src unchanged.
+func Transcode(src string, format Format, meta *Metadata, loudnorm bool) (string, error) {
+	if format == "" {
+		format = FormatAAC
+	}
+	if format == FormatAAC && meta == nil && !loudnorm {
+		return src, nil
+	}
+
+	dst := strings.TrimSuffix(src, FormatAAC.Ext()) + format.Ext()
+	if dst == src {
+		// Tagging raw AAC in place: write to a temporary file first
+		dst = strings.TrimSuffix(src, FormatAAC.Ext()) + ".tagged" + format.Ext()
+		os.Remove(dst) // Left over from an interrupted run
+	} else if exists(dst) {
+		// OutputPath chose src so that this does not happen
+		return src, fmt.Errorf("変換先のファイルが既に存在します: %s", dst)
+	}
+
+	args := []string{"-i", src}
+	var artwork string
+	if meta != nil && format.supportsArtwork() {
+		if path, err := meta.downloadArtwork(); err == nil {
+			artwork = path
+			defer os.Remove(artwork)
+			args = append(args, "-i", artwork)
+		}
+	}
+
+	var chapters string
+	if meta != nil && len(meta.Chapters) > 0 && format.supportsChapters() {
+		if path, err := writeChapters(meta.Chapters); err == nil {
+			chapters = path
+			defer os.Remove(chapters)
+			args = append(args, "-i", chapters)
+		}
+	}
+
+	args = append(args, "-map", "0:a")
+	if artwork != "" {
+		args = append(args, "-map", "1:v", "-c:v", "copy", "-disposition:v:0", "attached_pic")
+	}
+	if chapters != "" {
+		input := 1
+		if artwork != "" {
+			input = 2
+		}
+		args = append(args, "-map_chapters", strconv.Itoa(input))
+	}
+	args = append(args, format.codecArgs(loudnorm)...)
+	if meta != nil {
+		args = append(args, meta.ffmpegArgs(format)...)
+	}
+	args = append(args, "-n", "-loglevel", "error", dst)
+
+	out, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		os.Remove(dst)
+		return src, fmt.Errorf("変換に失敗しました (%s): %v: %s", format, err, strings.TrimSpace(string(out)))
+	}
+
+	os.Remove(src)
+	if format == FormatAAC {
+		if err := os.Rename(dst, src); err != nil {
+			return dst, err
+		}
+		return src, nil
+	}
+	return dst, nil
+}
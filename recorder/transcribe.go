@@ -0,0 +1,103 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TranscribeTimeout is how long a transcription command may run before it is killed
+const TranscribeTimeout = 6 * time.Hour
+
+// DefaultTranscriptFormat is the transcript extension used when none is configured
+const DefaultTranscriptFormat = "txt"
+
+// TranscriptPath returns where the transcript of a recording is stored:
+// next to the audio, with the transcript format as extension
+func TranscriptPath(audioPath, format string) string {
+	format = strings.TrimPrefix(format, ".")
+	if format == "" {
+		format = DefaultTranscriptFormat
+	}
+	return strings.TrimSuffix(audioPath, filepath.Ext(audioPath)) + "." + format
+}
+
+// transcribe runs the user's transcription command (e.g. whisper.cpp) on a
+// finished recording. The command gets the recording in the RADIKO_* hook
+// variables plus RADIKO_WAV, a 16 kHz mono WAV copy as most speech
+// recognizers expect, and RADIKO_TRANSCRIPT, the transcript path. Commands
+// may write RADIKO_TRANSCRIPT themselves; otherwise what they print on
+// stdout is saved as the transcript.
+func transcribe(command, format, audioPath string, env []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), TranscribeTimeout)
+	defer cancel()
+
+	wav, err := os.CreateTemp("", "radiko-transcribe-*.wav")
+	if err != nil {
+		return "", err
+	}
+	wav.Close()
+	defer os.Remove(wav.Name())
+
+	out, err := exec.CommandContext(ctx, "ffmpeg",
+		"-i", audioPath,
+		"-ar", "16000",
+		"-ac", "1",
+		"-c:a", "pcm_s16le",
+		"-y", "-loglevel", "error",
+		wav.Name(),
+	).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("文字起こし用の変換に失敗しました: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	transcript := TranscriptPath(audioPath, format)
+	os.Remove(transcript) // Tell a file written by the command from a stale one
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Env = append(cmd.Env, "RADIKO_WAV="+wav.Name(), "RADIKO_TRANSCRIPT="+transcript)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("文字起こしに失敗しました: %v: %s", err, lastLine(stderr.String()))
+	}
+
+	if _, err := os.Stat(transcript); err != nil && strings.TrimSpace(stdout.String()) != "" {
+		if err := os.WriteFile(transcript, stdout.Bytes(), 0644); err != nil {
+			return "", fmt.Errorf("文字起こし結果の保存に失敗しました: %w", err)
+		}
+	}
+	if _, err := os.Stat(transcript); err != nil {
+		return "", fmt.Errorf("文字起こしコマンドが結果を出力しませんでした")
+	}
+	return transcript, setTranscript(audioPath, transcript)
+}
+
+// setTranscript records the transcript of a recording in the history
+func setTranscript(path, transcript string) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	entries, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if entries[i].Path == path {
+			entries[i].Transcript = transcript
+		}
+	}
+	return saveHistory(entries)
+}
+
+// lastLine returns the last non-empty line of command output
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,124 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/config"
+)
+
+// Uploader copies a finished recording to remote storage
+type Uploader interface {
+	// Upload stores the local file under key ("/"-separated, relative to the configured prefix)
+	Upload(ctx context.Context, localPath, key string) error
+	// String describes the destination for log messages
+	String() string
+}
+
+// uploadRetryDelays are the waits between upload attempts
+var uploadRetryDelays = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute}
+
+const uploadTimeout = 30 * time.Minute // Bounds one upload attempt
+
+// uploadClient returns the client of the uploads, through the api's proxy
+// and TLS settings
+func uploadClient() *http.Client {
+	return &http.Client{Transport: api.HTTPClient().Transport, Timeout: uploadTimeout}
+}
+
+// NewUploader creates the uploader described by the config (nil if not configured)
+func NewUploader(cfg *config.Upload) (Uploader, error) {
+	if cfg == nil || cfg.Type == "" {
+		return nil, nil
+	}
+	switch strings.ToLower(cfg.Type) {
+	case "s3":
+		return newS3Uploader(cfg)
+	case "webdav":
+		return newWebDAVUploader(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported upload type %q (s3, webdav)", cfg.Type)
+	}
+}
+
+// uploadKey returns the remote key for a recording, keeping the
+// subdirectories created by the filename template
+func uploadKey(outputDir, path string) string {
+	rel, err := filepath.Rel(outputDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Upload uploads a recording with retries and marks it as uploaded in the
+// history. A missing file fails at once, without retries.
+func Upload(u Uploader, outputDir, path string) error {
+	key := uploadKey(outputDir, path)
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if _, err = os.Stat(path); err != nil {
+			return fmt.Errorf("アップロードするファイルがありません: %w", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+		err = u.Upload(ctx, path, key)
+		cancel()
+		if err == nil {
+			break
+		}
+		if errors.Is(err, fs.ErrNotExist) || attempt >= len(uploadRetryDelays) {
+			return fmt.Errorf("アップロードに失敗しました (%s): %w", u, err)
+		}
+		log.Printf("⚠️ アップロード再試行 (%d/%d): %v", attempt+1, len(uploadRetryDelays), err)
+		time.Sleep(uploadRetryDelays[attempt])
+	}
+
+	log.Printf("☁️ アップロード完了: %s → %s", path, u)
+	return markUploaded(path)
+}
+
+// markUploaded records in the history that a recording has been uploaded
+func markUploaded(path string) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	entries, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if entries[i].Path == path {
+			entries[i].Uploaded = time.Now()
+		}
+	}
+	return saveHistory(entries)
+}
+
+// UploadPending uploads recordings that finished but were never uploaded,
+// e.g. because the storage was unreachable or the app quit first. Recordings
+// deleted since are skipped.
+func UploadPending(u Uploader, outputDir string) {
+	entries, err := History()
+	if err != nil {
+		log.Printf("⚠️ %v", err)
+		return
+	}
+	for _, e := range entries {
+		if !e.Uploaded.IsZero() || !exists(e.Path) {
+			continue
+		}
+		if err := Upload(u, outputDir, e.Path); err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+	}
+}
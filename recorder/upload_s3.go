@@ -0,0 +1,191 @@
+package recorder
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/config"
+)
+
+// s3Uploader uploads with a single SigV4-signed PUT (AWS S3, MinIO, R2, Wasabi...)
+type s3Uploader struct {
+	endpoint  *url.URL // Path-style endpoint for custom storage, nil for AWS virtual-hosted style
+	bucket    string
+	region    string
+	prefix    string
+	accessKey string
+	secretKey string
+}
+
+// newS3Uploader validates the S3 settings
+func newS3Uploader(cfg *config.Upload) (*s3Uploader, error) {
+	if cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("s3 upload requires bucket, access_key and secret_key")
+	}
+	u := &s3Uploader{
+		bucket:    cfg.Bucket,
+		region:    cfg.Region,
+		prefix:    strings.Trim(cfg.Prefix, "/"),
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+	}
+	if u.region == "" {
+		u.region = "us-east-1"
+	}
+	if cfg.URL != "" {
+		endpoint, err := url.Parse(cfg.URL)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.URL)
+		}
+		u.endpoint = endpoint
+	}
+	return u, nil
+}
+
+func (u *s3Uploader) String() string {
+	return fmt.Sprintf("s3://%s/%s", u.bucket, u.prefix)
+}
+
+// objectURL returns the URL of the object for key
+func (u *s3Uploader) objectURL(key string) *url.URL {
+	if u.prefix != "" {
+		key = u.prefix + "/" + key
+	}
+	target := &url.URL{
+		Scheme: "https",
+		Host:   fmt.Sprintf("%s.s3.%s.amazonaws.com", u.bucket, u.region),
+		Path:   "/" + key,
+	}
+	if u.endpoint != nil {
+		target.Scheme = u.endpoint.Scheme
+		target.Host = u.endpoint.Host
+		target.Path = strings.TrimSuffix(u.endpoint.Path, "/") + "/" + u.bucket + "/" + key
+	}
+	// SigV4 requires every byte except unreserved characters to be percent-encoded
+	target.RawPath = s3Escape(target.Path)
+	return target
+}
+
+// s3Escape percent-encodes a path as required by SigV4 (keeping "/")
+func s3Escape(path string) string {
+	var b strings.Builder
+	for _, c := range []byte(path) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// Upload PUTs the file as a single object
+func (u *s3Uploader) Upload(ctx context.Context, localPath, key string) error {
+	payloadHash, size, err := fileSHA256(localPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	target := u.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", MimeType(localPath))
+	u.sign(req, target, payloadHash, time.Now().UTC())
+
+	resp, err := uploadClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 PUT %s: status %d: %s", key, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sign adds AWS Signature Version 4 headers to the request
+func (u *s3Uploader) sign(req *http.Request, target *url.URL, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", target.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("content-type:%s\nhost:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n",
+		req.Header.Get("Content-Type"), target.Host, payloadHash, amzDate)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		target.EscapedPath(),
+		"", // No query string
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, u.region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+u.secretKey), date)
+	key = hmacSHA256(key, u.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		u.accessKey, scope, signedHeaders, signature))
+}
+
+// fileSHA256 returns the hex SHA-256 and size of a file
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
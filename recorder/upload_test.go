@@ -0,0 +1,39 @@
+package recorder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeUploader counts its uploads, which open the file like the real ones
+type fakeUploader struct {
+	calls int
+}
+
+func (u *fakeUploader) Upload(ctx context.Context, localPath, key string) error {
+	u.calls++
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (u *fakeUploader) String() string {
+	return "fake"
+}
+
+func TestUploadMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	u := &fakeUploader{}
+
+	// Without retries, which would wait for minutes
+	if err := Upload(u, dir, filepath.Join(dir, "gone.aac")); err == nil {
+		t.Fatal("Upload of a missing file succeeded")
+	}
+	if u.calls != 0 {
+		t.Errorf("uploads = %d, want 0", u.calls)
+	}
+}
@@ -0,0 +1,119 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/kanoshiou/radiko-tui/config"
+)
+
+// webdavUploader uploads with PUT to a WebDAV server (Nextcloud, ownCloud...)
+type webdavUploader struct {
+	base     *url.URL
+	prefix   string
+	username string
+	password string
+}
+
+// newWebDAVUploader validates the WebDAV settings
+func newWebDAVUploader(cfg *config.Upload) (*webdavUploader, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webdav upload requires url")
+	}
+	base, err := url.Parse(cfg.URL)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid webdav url %q", cfg.URL)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	base.RawPath = ""
+	return &webdavUploader{
+		base:     base,
+		prefix:   strings.Trim(cfg.Prefix, "/"),
+		username: cfg.Username,
+		password: cfg.Password,
+	}, nil
+}
+
+func (u *webdavUploader) String() string {
+	return u.base.String()
+}
+
+// resourceURL returns the URL of a "/"-separated path below the base URL
+func (u *webdavUploader) resourceURL(path string) string {
+	target := *u.base
+	target.Path += "/" + path
+	return target.String()
+}
+
+// do sends a request with the configured credentials
+func (u *webdavUploader) do(req *http.Request) (*http.Response, error) {
+	if u.username != "" {
+		req.SetBasicAuth(u.username, u.password)
+	}
+	return uploadClient().Do(req)
+}
+
+// mkcol creates the collections leading to key (existing ones are fine)
+func (u *webdavUploader) mkcol(ctx context.Context, key string) error {
+	parts := strings.Split(key, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/") + "/"
+		req, err := http.NewRequestWithContext(ctx, "MKCOL", u.resourceURL(dir), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := u.do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		// 405 Method Not Allowed means the collection already exists
+		if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("webdav MKCOL %s: status %d", dir, resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+// Upload creates the parent collections and PUTs the file
+func (u *webdavUploader) Upload(ctx context.Context, localPath, key string) error {
+	if u.prefix != "" {
+		key = u.prefix + "/" + key
+	}
+	if err := u.mkcol(ctx, key); err != nil {
+		return err
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.resourceURL(key), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", MimeType(localPath))
+
+	resp, err := u.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webdav PUT %s: status %d: %s", key, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,446 @@
+// Package rtsp serves audio streams over RTSP, with the audio sent as RTP
+// interleaved on the RTSP connection or over UDP
+package rtsp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	payloadType    = 96               // Dynamic RTP payload type of every stream
+	trackControl   = "trackID=0"      // Control URL of the only track, relative to the stream
+	sessionTimeout = 60 * time.Second // UDP sessions end without a request in this time
+	writeTimeout   = 10 * time.Second // Bounds each write to a client
+)
+
+// Stream is the audio published at an RTSP URL
+type Stream struct {
+	Name      string // Session name in the SDP
+	Encoding  string // rtpmap encoding, e.g. "L16/48000/2"
+	ClockRate int    // RTP timestamp units per second
+	FMTP      string // Format parameters, if any
+	Marker    bool   // Set the RTP marker bit on every packet
+
+	// Play sends the stream's packets until ctx ends, returning when the
+	// stream ends. send never blocks; the packets are paced by timestamp.
+	Play func(ctx context.Context, send func(Packet)) error
+}
+
+// Packet is the payload of an RTP packet
+type Packet struct {
+	Payload   []byte
+	Timestamp uint32 // In ClockRate units since Play started
+}
+
+// Server is an RTSP server
+type Server struct {
+	// Open returns the stream published at the request's URL, with the
+	// track suffix stripped from the path
+	Open func(r *http.Request) (*Stream, error)
+
+	// Authorize checks a request, returning the status to reply when it is
+	// rejected (401, 403) or 0. The request is not streaming yet.
+	Authorize func(r *http.Request) int
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// Serve accepts RTSP connections on l until ctx ends
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	s.mu.Lock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]*session)
+	}
+	s.mu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() { l.Close() })
+	defer stop()
+	go s.expireSessions(ctx)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// request is a parsed RTSP request
+type request struct {
+	*http.Request
+	cseq string
+}
+
+// response is an RTSP response to write
+type response struct {
+	status int
+	header http.Header
+	body   string
+}
+
+// conn is an RTSP client connection
+type conn struct {
+	net.Conn
+	wmu sync.Mutex // Serializes responses and interleaved packets
+}
+
+// write writes b with a deadline
+func (c *conn) write(b []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.Conn.Write(b)
+	return err
+}
+
+// serveConn handles the requests of a connection, ending its interleaved
+// sessions when it closes
+func (s *Server) serveConn(ctx context.Context, nc net.Conn) {
+	c := &conn{Conn: nc}
+	stop := context.AfterFunc(ctx, func() { nc.Close() })
+	defer stop()
+	defer nc.Close()
+	defer s.closeSessions(c)
+
+	br := bufio.NewReader(nc)
+	tp := textproto.NewReader(br)
+	for {
+		// Interleaved data from the client (RTCP receiver reports) is ignored
+		if b, err := br.Peek(1); err == nil && b[0] == '$' {
+			var hdr [4]byte
+			if _, err := io.ReadFull(br, hdr[:]); err != nil {
+				return
+			}
+			if _, err := br.Discard(int(hdr[2])<<8 | int(hdr[3])); err != nil {
+				return
+			}
+			continue
+		}
+
+		req, err := readRequest(tp, nc.RemoteAddr().String())
+		if err != nil {
+			return
+		}
+		resp := s.handle(ctx, c, req)
+		if err := c.write(resp.encode(req.cseq)); err != nil {
+			return
+		}
+	}
+}
+
+// readRequest reads an RTSP request, discarding its body
+func readRequest(tp *textproto.Reader, remoteAddr string) (*request, error) {
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	method, rest, _ := strings.Cut(line, " ")
+	uri, proto, _ := strings.Cut(rest, " ")
+	if !strings.HasPrefix(proto, "RTSP/") {
+		return nil, fmt.Errorf("invalid RTSP request: %q", line)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := strconv.Atoi(header.Get("Content-Length")); n > 0 {
+		if _, err := tp.R.Discard(n); err != nil {
+			return nil, err
+		}
+	}
+	return &request{
+		Request: &http.Request{
+			Method:     method,
+			URL:        u,
+			Proto:      proto,
+			Header:     http.Header(header),
+			Host:       u.Host,
+			RemoteAddr: remoteAddr,
+			RequestURI: uri,
+		},
+		cseq: header.Get("CSeq"),
+	}, nil
+}
+
+// encode returns the response as sent for the request with cseq
+func (r *response) encode(cseq string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "RTSP/1.0 %d %s\r\n", r.status, statusText(r.status))
+	if cseq != "" {
+		fmt.Fprintf(&b, "CSeq: %s\r\n", cseq)
+	}
+	b.WriteString("Server: radiko-tui\r\n")
+	for k, vs := range r.header {
+		for _, v := range vs {
+			fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+		}
+	}
+	if r.body != "" {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n", len(r.body))
+	}
+	b.WriteString("\r\n")
+	b.WriteString(r.body)
+	return []byte(b.String())
+}
+
+// set sets a header, keeping the name as given: RTSP clients are not all
+// case-insensitive
+func (r *response) set(name, value string) {
+	r.header[name] = []string{value}
+}
+
+// reply returns a response with a status and no headers
+func reply(status int) *response {
+	return &response{status: status, header: make(http.Header)}
+}
+
+// RTSP statuses that HTTP does not have
+const (
+	statusSessionNotFound      = 454
+	statusAggregateNotAllowed  = 459
+	statusUnsupportedTransport = 461
+)
+
+// statusText returns the reason phrase of a status
+func statusText(status int) string {
+	switch status {
+	case statusSessionNotFound:
+		return "Session Not Found"
+	case statusAggregateNotAllowed:
+		return "Aggregate Operation Not Allowed"
+	case statusUnsupportedTransport:
+		return "Unsupported Transport"
+	}
+	return http.StatusText(status)
+}
+
+// handle handles a request
+func (s *Server) handle(ctx context.Context, c *conn, req *request) *response {
+	if s.Authorize != nil {
+		if status := s.Authorize(req.Request); status != 0 {
+			resp := reply(status)
+			if status == http.StatusUnauthorized {
+				resp.set("WWW-Authenticate", `Basic realm="radiko-tui"`)
+			}
+			return resp
+		}
+	}
+
+	switch req.Method {
+	case "OPTIONS":
+		resp := reply(http.StatusOK)
+		resp.set("Public", "OPTIONS, DESCRIBE, SETUP, PLAY, TEARDOWN, GET_PARAMETER, SET_PARAMETER")
+		return resp
+	case "DESCRIBE":
+		return s.describe(req)
+	case "SETUP":
+		return s.setup(c, req)
+	case "PLAY":
+		return s.play(ctx, req)
+	case "TEARDOWN":
+		if sess := s.session(req); sess != nil {
+			s.closeSession(sess)
+		}
+		return reply(http.StatusOK)
+	case "GET_PARAMETER", "SET_PARAMETER":
+		// Keep-alive
+		s.session(req)
+		return reply(http.StatusOK)
+	default:
+		return reply(http.StatusNotImplemented)
+	}
+}
+
+// open returns the stream at the request's URL
+func (s *Server) open(req *request) (*Stream, error) {
+	r := req.Request.Clone(req.Context())
+	r.URL.Path = strings.TrimSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/"+trackControl)
+	return s.Open(r)
+}
+
+// describe returns the SDP of a stream
+func (s *Server) describe(req *request) *response {
+	stream, err := s.open(req)
+	if err != nil {
+		return reply(http.StatusNotFound)
+	}
+
+	host := "0.0.0.0"
+	if h, _, err := net.SplitHostPort(req.Host); err == nil {
+		host = h
+	}
+	track := *req.URL
+	track.User = nil
+	track.Path = strings.TrimSuffix(track.Path, "/") + "/" + trackControl
+
+	var b strings.Builder
+	b.WriteString("v=0\r\n")
+	fmt.Fprintf(&b, "o=- %d 1 IN IP4 %s\r\n", time.Now().Unix(), host)
+	fmt.Fprintf(&b, "s=%s\r\n", stream.Name)
+	b.WriteString("c=IN IP4 0.0.0.0\r\n")
+	b.WriteString("t=0 0\r\n")
+	fmt.Fprintf(&b, "m=audio 0 RTP/AVP %d\r\n", payloadType)
+	fmt.Fprintf(&b, "a=rtpmap:%d %s\r\n", payloadType, stream.Encoding)
+	if stream.FMTP != "" {
+		fmt.Fprintf(&b, "a=fmtp:%d %s\r\n", payloadType, stream.FMTP)
+	}
+	fmt.Fprintf(&b, "a=control:%s\r\n", track.String())
+
+	base := *req.URL
+	base.User = nil
+	resp := &response{status: http.StatusOK, header: make(http.Header), body: b.String()}
+	resp.set("Content-Type", "application/sdp")
+	resp.set("Content-Base", base.String())
+	return resp
+}
+
+// setup creates a session sending a stream over the requested transport
+func (s *Server) setup(c *conn, req *request) *response {
+	if req.Header.Get("Session") != "" {
+		// One track per stream: a second SETUP has nothing to add
+		return reply(statusAggregateNotAllowed)
+	}
+	stream, err := s.open(req)
+	if err != nil {
+		return reply(http.StatusNotFound)
+	}
+
+	sess, err := newSession(stream, c, req.Header.Get("Transport"), req.RemoteAddr)
+	if err != nil {
+		return reply(statusUnsupportedTransport)
+	}
+	s.mu.Lock()
+	s.sessions[sess.id] = sess
+	s.mu.Unlock()
+
+	resp := reply(http.StatusOK)
+	resp.set("Transport", sess.transport())
+	resp.set("Session", fmt.Sprintf("%s;timeout=%d", sess.id, int(sessionTimeout.Seconds())))
+	return resp
+}
+
+// play starts sending a session's stream
+func (s *Server) play(ctx context.Context, req *request) *response {
+	sess := s.session(req)
+	if sess == nil {
+		return reply(statusSessionNotFound)
+	}
+	seq, rtptime := sess.start(ctx, func() { s.closeSession(sess) })
+
+	track := *req.URL
+	track.User = nil
+	if !strings.HasSuffix(track.Path, "/"+trackControl) {
+		track.Path = strings.TrimSuffix(track.Path, "/") + "/" + trackControl
+	}
+	resp := reply(http.StatusOK)
+	resp.set("Session", sess.id)
+	resp.set("Range", "npt=0.000-")
+	resp.set("RTP-Info", fmt.Sprintf("url=%s;seq=%d;rtptime=%d", track.String(), seq, rtptime))
+	return resp
+}
+
+// session returns the request's session and marks it as alive, or nil
+func (s *Server) session(req *request) *session {
+	id, _, _ := strings.Cut(req.Header.Get("Session"), ";")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.sessions[strings.TrimSpace(id)]
+	if sess != nil {
+		sess.seen = time.Now()
+	}
+	return sess
+}
+
+// closeSession stops a session and forgets it
+func (s *Server) closeSession(sess *session) {
+	s.mu.Lock()
+	delete(s.sessions, sess.id)
+	s.mu.Unlock()
+	sess.close()
+}
+
+// closeSessions closes the sessions sending on a connection, and those
+// set up on it that never played
+func (s *Server) closeSessions(c *conn) {
+	s.mu.Lock()
+	var closed []*session
+	for id, sess := range s.sessions {
+		if sess.conn == c && (sess.interleaved || !sess.playing()) {
+			delete(s.sessions, id)
+			closed = append(closed, sess)
+		}
+	}
+	s.mu.Unlock()
+	for _, sess := range closed {
+		sess.close()
+	}
+}
+
+// expireSessions closes the UDP sessions whose client stopped sending
+// keep-alives, until ctx ends
+func (s *Server) expireSessions(ctx context.Context) {
+	ticker := time.NewTicker(sessionTimeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			sessions := s.sessions
+			s.sessions = make(map[string]*session)
+			s.mu.Unlock()
+			for _, sess := range sessions {
+				sess.close()
+			}
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		var expired []*session
+		for id, sess := range s.sessions {
+			if !sess.interleaved && time.Since(sess.seen) > sessionTimeout {
+				delete(s.sessions, id)
+				expired = append(expired, sess)
+			}
+		}
+		s.mu.Unlock()
+		for _, sess := range expired {
+			sess.close()
+		}
+	}
+}
+
+// randomID returns n random bytes as hex
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,260 @@
+package rtsp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	queueSize = 8192        // Packets waiting to be paced; more are dropped
+	maxLate   = time.Second // Packets this late restart the pacing instead of being rushed
+	maxEarly  = time.Second // Timestamps jumping this far ahead restart the pacing too
+)
+
+// errTransport is returned for a Transport header with no supported option
+var errTransport = errors.New("unsupported transport")
+
+// session is a client's RTP session for a stream
+type session struct {
+	id     string
+	stream *Stream
+	conn   *conn // RTSP connection it was set up on
+	seen   time.Time
+
+	// RTP over the RTSP connection
+	interleaved bool
+	channel     byte
+
+	// RTP over UDP
+	rtp, rtcp   *net.UDPConn
+	client      *net.UDPAddr // Client's RTP port
+	clientPorts string
+
+	ssrc   uint32
+	seq    uint16 // Sequence number of the first packet
+	tsBase uint32 // RTP timestamp of the stream's timestamp 0
+
+	mu        sync.Mutex
+	cancel    context.CancelFunc // Stops playing, nil before PLAY
+	closed    bool
+	closeOnce sync.Once
+}
+
+// newSession creates a session for the first supported option of a
+// Transport header: RTP/AVP/TCP interleaved or RTP/AVP unicast UDP
+func newSession(stream *Stream, c *conn, transport, remoteAddr string) (*session, error) {
+	sess := &session{
+		id:     randomID(8),
+		stream: stream,
+		conn:   c,
+		seen:   time.Now(),
+		ssrc:   rand.Uint32(),
+		seq:    uint16(rand.Uint32()),
+		tsBase: rand.Uint32(),
+	}
+	for _, option := range strings.Split(transport, ",") {
+		params := strings.Split(strings.TrimSpace(option), ";")
+		if strings.Contains(option, "multicast") {
+			continue
+		}
+		switch strings.ToUpper(params[0]) {
+		case "RTP/AVP/TCP":
+			sess.interleaved = true
+			if ch, ok := transportParam(params, "interleaved"); ok {
+				n, err := strconv.Atoi(strings.Split(ch, "-")[0])
+				if err != nil || n < 0 || n > 254 {
+					continue
+				}
+				sess.channel = byte(n)
+			}
+			return sess, nil
+		case "RTP/AVP", "RTP/AVP/UDP":
+			ports, ok := transportParam(params, "client_port")
+			if !ok {
+				continue
+			}
+			port, err := strconv.Atoi(strings.Split(ports, "-")[0])
+			if err != nil || port <= 0 || port > 65535 {
+				continue
+			}
+			host, _, err := net.SplitHostPort(remoteAddr)
+			if err != nil {
+				return nil, err
+			}
+			if err := sess.listenUDP(); err != nil {
+				return nil, err
+			}
+			sess.client = &net.UDPAddr{IP: net.ParseIP(host), Port: port}
+			sess.clientPorts = ports
+			return sess, nil
+		}
+	}
+	return nil, errTransport
+}
+
+// transportParam returns the value of a Transport parameter
+func transportParam(params []string, name string) (string, bool) {
+	for _, p := range params[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok && strings.EqualFold(strings.TrimSpace(k), name) {
+			return strings.TrimSpace(v), true
+		}
+	}
+	return "", false
+}
+
+// listenUDP opens the session's RTP and RTCP ports. RTCP from the client is
+// not read.
+func (sess *session) listenUDP() error {
+	var err error
+	if sess.rtp, err = net.ListenUDP("udp", nil); err != nil {
+		return err
+	}
+	if sess.rtcp, err = net.ListenUDP("udp", nil); err != nil {
+		sess.rtp.Close()
+		return err
+	}
+	return nil
+}
+
+// transport returns the Transport header of the SETUP response
+func (sess *session) transport() string {
+	if sess.interleaved {
+		return fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d;ssrc=%08X", sess.channel, sess.channel+1, sess.ssrc)
+	}
+	return fmt.Sprintf("RTP/AVP;unicast;client_port=%s;server_port=%d-%d;ssrc=%08X",
+		sess.clientPorts, sess.rtp.LocalAddr().(*net.UDPAddr).Port, sess.rtcp.LocalAddr().(*net.UDPAddr).Port, sess.ssrc)
+}
+
+// playing reports whether the session was started
+func (sess *session) playing() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.cancel != nil
+}
+
+// start plays the stream until ctx ends or the session is closed, calling
+// ended when the stream ends by itself. It returns the sequence number and
+// RTP timestamp of the first packet.
+func (sess *session) start(ctx context.Context, ended func()) (seq uint16, rtptime uint32) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.cancel != nil || sess.closed {
+		return sess.seq, sess.tsBase
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	sess.cancel = cancel
+
+	queue := make(chan Packet, queueSize)
+	send := func(pkt Packet) {
+		select {
+		case queue <- pkt:
+		default:
+		}
+	}
+	go func() {
+		sess.stream.Play(ctx, send)
+		if ctx.Err() == nil {
+			// Let interleaved clients see the end as the connection closing
+			if sess.interleaved {
+				sess.conn.Close()
+			}
+			ended()
+		}
+	}()
+	go sess.pace(ctx, queue)
+	return sess.seq, sess.tsBase
+}
+
+// pace sends the queued packets at the times given by their timestamps
+func (sess *session) pace(ctx context.Context, queue <-chan Packet) {
+	var start time.Time
+	var first uint32
+	seq := sess.seq
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	for {
+		var pkt Packet
+		select {
+		case <-ctx.Done():
+			return
+		case pkt = <-queue:
+		}
+
+		now := time.Now()
+		due := start.Add(time.Duration(pkt.Timestamp-first) * time.Second / time.Duration(sess.stream.ClockRate))
+		if start.IsZero() || now.Sub(due) > maxLate || due.Sub(now) > maxEarly {
+			start, first, due = now, pkt.Timestamp, now
+		}
+		if wait := due.Sub(now); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+		}
+		if err := sess.send(pkt, seq); err != nil {
+			if sess.interleaved {
+				sess.conn.Close()
+			}
+			return
+		}
+		seq++
+	}
+}
+
+// send sends a packet to the client with a sequence number
+func (sess *session) send(pkt Packet, seq uint16) error {
+	const header = 12
+	off := 0
+	if sess.interleaved {
+		off = 4
+	}
+	buf := make([]byte, off+header+len(pkt.Payload))
+	if sess.interleaved {
+		buf[0] = '$'
+		buf[1] = sess.channel
+		binary.BigEndian.PutUint16(buf[2:], uint16(header+len(pkt.Payload)))
+	}
+	rtp := buf[off:]
+	rtp[0] = 0x80 // Version 2
+	rtp[1] = payloadType
+	if sess.stream.Marker {
+		rtp[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(rtp[2:], seq)
+	binary.BigEndian.PutUint32(rtp[4:], sess.tsBase+pkt.Timestamp)
+	binary.BigEndian.PutUint32(rtp[8:], sess.ssrc)
+	copy(rtp[header:], pkt.Payload)
+
+	if sess.interleaved {
+		return sess.conn.write(buf)
+	}
+	_, err := sess.rtp.WriteToUDP(buf, sess.client)
+	return err
+}
+
+// close stops the session and releases its ports
+func (sess *session) close() {
+	sess.closeOnce.Do(func() {
+		sess.mu.Lock()
+		sess.closed = true
+		if sess.cancel != nil {
+			sess.cancel()
+		}
+		sess.mu.Unlock()
+		if sess.rtp != nil {
+			sess.rtp.Close()
+			sess.rtcp.Close()
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// SetAccess restricts the server to clients whose IP (as named by a trusted
+// proxy, see Server.SetTrustedProxies) matches allow, when set, and not deny.
+// Rules are CIDR ranges ("192.168.0.0/16") or single addresses.
+// It can be called while the server is running.
+func (s *Server) SetAccess(allow, deny []string) error {
+	var rules accessRules
+	var err error
+	if rules.allow, err = parsePrefixes(allow); err != nil {
+		return err
+	}
+	if rules.deny, err = parsePrefixes(deny); err != nil {
+		return err
+	}
+	s.access.Store(&rules)
+	return nil
+}
+
+// accessRules are the allow/deny rules, replaced as a whole on reload
+type accessRules struct {
+	allow []netip.Prefix // Only these clients are served (optional)
+	deny  []netip.Prefix // These clients are rejected
+}
+
+// accessRules returns the current allow/deny rules
+func (s *Server) accessRules() accessRules {
+	if rules := s.access.Load(); rules != nil {
+		return *rules
+	}
+	return accessRules{}
+}
+
+// parsePrefixes parses CIDR ranges and single addresses
+func parsePrefixes(rules []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		if !strings.Contains(rule, "/") {
+			addr, err := netip.ParseAddr(rule)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP rule %q: %w", rule, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP rule %q: %w", rule, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// prefixesContain reports whether ip is in one of prefixes
+func prefixesContain(prefixes []netip.Prefix, ip string) bool {
+	if len(prefixes) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether a client IP passes the allow/deny rules.
+// Deny rules win; with allow rules, only matching addresses are let in.
+func (s *Server) allowed(ip string) bool {
+	rules := s.accessRules()
+	if len(rules.allow) == 0 && len(rules.deny) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range rules.deny {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	if len(rules.allow) == 0 {
+		return true
+	}
+	for _, p := range rules.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// restrictAccess rejects clients that do not pass the allow/deny rules with 403
+func (s *Server) restrictAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := s.getRealIP(r); !s.allowed(ip) {
+			log.Printf("⛔ アクセス拒否: %s %s (from %s)", r.Method, r.URL.Path, ip)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		rules []string
+		want  []string
+	}{
+		{nil, nil},
+		{[]string{"192.168.0.0/16", " 10.0.0.1 ", ""}, []string{"192.168.0.0/16", "10.0.0.1/32"}},
+		// Host bits are masked off
+		{[]string{"192.168.1.77/24"}, []string{"192.168.1.0/24"}},
+		{[]string{"2001:db8::1/64", "::1"}, []string{"2001:db8::/64", "::1/128"}},
+		// IPv4-mapped addresses are matched as IPv4
+		{[]string{"::ffff:10.0.0.1"}, []string{"10.0.0.1/32"}},
+	}
+	for _, tt := range tests {
+		prefixes, err := parsePrefixes(tt.rules)
+		if err != nil {
+			t.Errorf("parsePrefixes(%q): %v", tt.rules, err)
+			continue
+		}
+		var got []string
+		for _, p := range prefixes {
+			got = append(got, p.String())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parsePrefixes(%q) = %v, want %v", tt.rules, got, tt.want)
+		}
+	}
+
+	for _, rule := range []string{"192.168.0.0/33", "example.com", "10.0.0", "10.0.0.0/x"} {
+		if _, err := parsePrefixes([]string{rule}); err == nil {
+			t.Errorf("parsePrefixes(%q) succeeded, want an error", rule)
+		}
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		allow, deny []string
+		ip          string
+		want        bool
+	}{
+		{nil, nil, "203.0.113.1", true},
+		{[]string{"192.168.0.0/16"}, nil, "192.168.3.4", true},
+		{[]string{"192.168.0.0/16"}, nil, "203.0.113.1", false},
+		{nil, []string{"203.0.113.0/24"}, "203.0.113.1", false},
+		{nil, []string{"203.0.113.0/24"}, "198.51.100.1", true},
+		// Deny wins over allow
+		{[]string{"192.168.0.0/16"}, []string{"192.168.1.5"}, "192.168.1.5", false},
+		{[]string{"192.168.0.0/16"}, []string{"192.168.1.5"}, "192.168.1.6", true},
+		{[]string{"10.0.0.0/8"}, nil, "::ffff:10.1.2.3", true},
+		{[]string{"2001:db8::/32"}, nil, "2001:db8::5", true},
+		// Unparsable addresses are refused once there are rules
+		{[]string{"10.0.0.0/8"}, nil, "unknown", false},
+		{nil, nil, "unknown", true},
+	}
+	for _, tt := range tests {
+		s := NewServer(0, 1)
+		if err := s.SetAccess(tt.allow, tt.deny); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.allowed(tt.ip); got != tt.want {
+			t.Errorf("allow %v deny %v: allowed(%s) = %v, want %v", tt.allow, tt.deny, tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestGetRealIP(t *testing.T) {
+	s := NewServer(0, 1)
+	if err := s.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"203.0.113.1:5000", nil, "203.0.113.1"},
+		// Headers of untrusted peers are ignored
+		{"203.0.113.1:5000", map[string]string{"X-Real-IP": "198.51.100.1"}, "203.0.113.1"},
+		{"10.0.0.2:5000", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"10.0.0.2:5000", map[string]string{"CF-Connecting-IP": "198.51.100.2", "X-Real-IP": "198.51.100.1"}, "198.51.100.2"},
+		// The last hop not added by a trusted proxy
+		{"10.0.0.2:5000", map[string]string{"X-Forwarded-For": "192.0.2.9, 198.51.100.1, 10.0.0.3"}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		if got := s.getRealIP(r); got != tt.want {
+			t.Errorf("%s %v: getRealIP = %s, want %s", tt.remote, tt.headers, got, tt.want)
+		}
+	}
+
+	// Servers do not share their trusted proxies
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.2:5000"
+	r.Header.Set("X-Real-IP", "198.51.100.1")
+	if got := NewServer(0, 1).getRealIP(r); got != "10.0.0.2" {
+		t.Errorf("getRealIP of another server = %s, want 10.0.0.2", got)
+	}
+}
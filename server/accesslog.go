@@ -0,0 +1,179 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Access log formats
+const (
+	AccessLogCommon   = "common"   // NCSA Common Log Format
+	AccessLogCombined = "combined" // Common plus referer and user agent
+	AccessLogJSON     = "json"     // One JSON object per line, with the duration
+)
+
+// accessLog writes one line per request
+type accessLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+}
+
+// accessEntry is a request in the JSON access log
+type accessEntry struct {
+	Time       time.Time `json:"time"`
+	IP         string    `json:"ip"`
+	User       string    `json:"user,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMS int64     `json:"duration_ms"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// SetAccessLog writes an access log line for every request to w, in the
+// common, combined or json format. Streams are logged when they end.
+func (s *Server) SetAccessLog(w io.Writer, format string) error {
+	switch format {
+	case AccessLogCommon, AccessLogCombined, AccessLogJSON:
+	default:
+		return fmt.Errorf("unknown access log format %q (common, combined, json)", format)
+	}
+	s.accessLog = &accessLog{w: w, format: format}
+	return nil
+}
+
+// logAccess logs every request once its response is complete
+func (s *Server) logAccess(next http.Handler) http.Handler {
+	if s.accessLog == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		s.accessLog.write(r, s.getRealIP(r), rec, start)
+	})
+}
+
+// write formats and writes one request
+func (l *accessLog) write(r *http.Request, ip string, rec *statusRecorder, start time.Time) {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	user, _, _ := r.BasicAuth()
+	entry := accessEntry{
+		Time:       start,
+		IP:         ip,
+		User:       user,
+		Method:     r.Method,
+		Path:       redactedURI(r.URL),
+		Proto:      r.Proto,
+		Status:     status,
+		Bytes:      rec.bytes,
+		DurationMS: time.Since(start).Milliseconds(),
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+	}
+
+	var line string
+	if l.format == AccessLogJSON {
+		var b strings.Builder
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		enc.Encode(entry)
+		line = b.String()
+	} else {
+		line = fmt.Sprintf("%s - %s [%s] %q %d %s",
+			entry.IP, orDash(entry.User), start.Format("02/Jan/2006:15:04:05 -0700"),
+			entry.Method+" "+entry.Path+" "+entry.Proto, status, orDash(fmt.Sprint(entry.Bytes)))
+		if l.format == AccessLogCombined {
+			line += fmt.Sprintf(" %q %q", orDash(entry.Referer), orDash(entry.UserAgent))
+		}
+		line += "\n"
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, line)
+}
+
+// redactedURI returns the request URI with the ?token= secret hidden
+func redactedURI(u *url.URL) string {
+	q := u.Query()
+	if !q.Has("token") {
+		return u.RequestURI()
+	}
+	q.Set("token", "REDACTED")
+	redacted := *u
+	redacted.RawQuery = q.Encode()
+	return redacted.RequestURI()
+}
+
+// orDash returns "-" for empty and zero fields of the common log format
+func orDash(s string) string {
+	if s == "" || s == "0" {
+		return "-"
+	}
+	return s
+}
+
+// statusRecorder records the status and size of a response, passing
+// flushes (streams) and hijacks (WebSocket) through
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush sends buffered data to the client
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection (WebSocket upgrades)
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("connection cannot be hijacked")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the original writer for http.ResponseController
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
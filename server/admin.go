@@ -0,0 +1,77 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// recordingJSON is a scheduled recording in progress
+type recordingJSON struct {
+	ID          string    `json:"id"`
+	StationID   string    `json:"station_id"`
+	StationName string    `json:"station_name,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	File        string    `json:"file"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end,omitzero"`
+	Elapsed     float64   `json:"elapsed"` // Seconds recorded, excluding pauses
+	Paused      bool      `json:"paused"`
+}
+
+// handleRecordings lists the scheduled recordings in progress
+func (s *Server) handleRecordings(w http.ResponseWriter, r *http.Request) {
+	if !s.schedulerReady(w) {
+		return
+	}
+	active := s.scheduler.Active()
+	list := make([]recordingJSON, 0, len(active))
+	for _, rec := range active {
+		list = append(list, recordingJSON{
+			ID:          rec.ID,
+			StationID:   rec.StationID,
+			StationName: rec.StationName,
+			Title:       rec.Title,
+			File:        rec.FilePath,
+			Start:       rec.StartTime,
+			End:         rec.EndTime,
+			Elapsed:     rec.Elapsed().Seconds(),
+			Paused:      rec.Paused(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+	writeJSON(w, list)
+}
+
+// handleStopStream stops a station's ffmpeg in every format and disconnects
+// its clients. Multicast outputs of the station are stopped too, as they
+// would otherwise start the stream again.
+func (s *Server) handleStopStream(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("stationID")
+	stopped := s.stopStationMulticasts(stationID)
+	stopped = s.streamManager.StopStation(stationID) || stopped
+	stopped = s.pcmStreamManager.StopStation(stationID) || stopped
+	s.pcmMu.Lock()
+	for _, m := range s.pcmManagers {
+		stopped = m.StopStation(stationID) || stopped
+	}
+	s.pcmMu.Unlock()
+	s.opusMu.Lock()
+	for _, m := range s.opusManagers {
+		stopped = m.StopStation(stationID) || stopped
+	}
+	s.opusMu.Unlock()
+	s.qualityMu.Lock()
+	for _, m := range s.qualityManagers {
+		stopped = m.StopStation(stationID) || stopped
+	}
+	s.qualityMu.Unlock()
+
+	if !stopped {
+		http.Error(w, "stream not running", http.StatusNotFound)
+		return
+	}
+	log.Printf("🛑 ストリームを強制停止しました: %s (%s)", stationID, s.getRealIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/kanoshiou/radiko-tui/airplay"
+	"github.com/kanoshiou/radiko-tui/player"
+)
+
+// airplayDeviceJSON is an AirPlay speaker in the API, with the station it is playing
+type airplayDeviceJSON struct {
+	airplay.Device
+	StationID string `json:"station_id,omitempty"`
+}
+
+// airplayOutput is a station's PCM stream sent to an AirPlay speaker
+type airplayOutput struct {
+	sender    *airplay.Sender
+	stationID string
+	cancel    context.CancelFunc
+}
+
+// airplayWriter is the PCM stream client feeding an AirPlay output. Writes
+// never block the broadcast: the sender paces itself from a queue.
+type airplayWriter struct {
+	header http.Header
+	queue  chan []byte
+	done   chan struct{} // Closed when the output ends
+	buf    []byte        // Dequeued PCM not yet read
+}
+
+func (w *airplayWriter) Header() http.Header { return w.header }
+func (w *airplayWriter) WriteHeader(int)     {}
+
+// Write queues PCM for the sender, dropping it when the speaker fell behind
+func (w *airplayWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.done:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	select {
+	case w.queue <- append([]byte(nil), p...):
+	default:
+	}
+	return len(p), nil
+}
+
+// Read returns the queued PCM until the output ends
+func (w *airplayWriter) Read(p []byte) (int, error) {
+	if len(w.buf) == 0 {
+		select {
+		case w.buf = <-w.queue:
+		case <-w.done:
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, w.buf)
+	w.buf = w.buf[n:]
+	return n, nil
+}
+
+// handleAirPlayDevices lists the AirPlay speakers on the LAN
+func (s *Server) handleAirPlayDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := airplay.Discover(airplay.DiscoverTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.airplayMu.Lock()
+	list := make([]airplayDeviceJSON, 0, len(devices))
+	for _, d := range devices {
+		entry := airplayDeviceJSON{Device: d}
+		if out, ok := s.airplays[d.Name]; ok {
+			entry.StationID = out.stationID
+		}
+		list = append(list, entry)
+	}
+	s.airplayMu.Unlock()
+	writeJSON(w, list)
+}
+
+// handleAirPlay sends a station to the AirPlay speaker given by ?device=<name>
+func (s *Server) handleAirPlay(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("stationID")
+	device, ok := airplay.FindDevice(r.URL.Query().Get("device"))
+	if !ok {
+		http.Error(w, "AirPlay device not found", http.StatusNotFound)
+		return
+	}
+
+	s.stopAirPlay(device.Name)
+	sender, err := airplay.Dial(device)
+	if err != nil {
+		log.Printf("❌ AirPlay接続エラー [%s]: %v", device.Name, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.baseCtx)
+	out := &airplayOutput{sender: sender, stationID: stationID, cancel: cancel}
+	s.airplayMu.Lock()
+	s.airplays[device.Name] = out
+	s.airplayMu.Unlock()
+
+	writer := &airplayWriter{header: make(http.Header), queue: make(chan []byte, 64), done: make(chan struct{})}
+	go func() {
+		// The PCM streams are 48kHz, AirPlay plays 44.1kHz
+		pcm := player.NewResampler(writer, player.NativeSampleRate, airplay.SampleRate, 2)
+		io.Copy(sender, pcm)
+		cancel()
+	}()
+	go func() {
+		clientID := "airplay-" + device.Name
+		err := s.pcmStreamManager.Subscribe(ctx, writer, stationID, "", clientID, device.Host)
+		if err != nil {
+			log.Printf("❌ AirPlayストリームエラー [%s]: %v", device.Name, err)
+		}
+		close(writer.done)
+		sender.Close()
+
+		s.airplayMu.Lock()
+		if s.airplays[device.Name] == out {
+			delete(s.airplays, device.Name)
+		}
+		s.airplayMu.Unlock()
+		log.Printf("🔈 AirPlay停止: %s", device.Name)
+	}()
+
+	log.Printf("🔈 AirPlay開始: %s → %s", stationID, device.Name)
+	writeJSON(w, airplayDeviceJSON{Device: device, StationID: stationID})
+}
+
+// handleAirPlayStop stops sending to the speaker given by ?device=<name>
+func (s *Server) handleAirPlayStop(w http.ResponseWriter, r *http.Request) {
+	if !s.stopAirPlay(r.URL.Query().Get("device")) {
+		http.Error(w, "not playing on this device", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAirPlayVolume sets the volume (?level=0.0-1.0) of the speaker given by ?device=<name>
+func (s *Server) handleAirPlayVolume(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.airplayMu.Lock()
+	out, ok := s.airplays[q.Get("device")]
+	s.airplayMu.Unlock()
+	if !ok {
+		http.Error(w, "not playing on this device", http.StatusNotFound)
+		return
+	}
+
+	level, err := strconv.ParseFloat(q.Get("level"), 64)
+	if err != nil || level < 0 || level > 1 {
+		http.Error(w, "level must be 0.0-1.0", http.StatusBadRequest)
+		return
+	}
+	if err := out.sender.SetVolume(level); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stopAirPlay stops sending to a speaker, reporting whether it was playing
+func (s *Server) stopAirPlay(name string) bool {
+	s.airplayMu.Lock()
+	out, ok := s.airplays[name]
+	delete(s.airplays, name)
+	s.airplayMu.Unlock()
+	if ok {
+		out.cancel()
+	}
+	return ok
+}
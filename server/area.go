@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// tokenRenewInterval is how often the tokens of the areas being streamed
+// are checked, to renew them before they expire
+const tokenRenewInterval = time.Minute
+
+// resolveStation returns the area of a station and an auth token for that
+// area. Areas and tokens are cached by the api package, so stations from
+// several areas (e.g. ABC in Osaka and TBS in Tokyo) can be streamed at once
+// without authenticating for every new stream.
+func resolveStation(ctx context.Context, stationID string) (areaID, token string, err error) {
+	areaID, err = api.GetStationArea(ctx, stationID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get station area: %w", err)
+	}
+	token, err = api.Token(ctx, areaID)
+	if err != nil {
+		return "", "", err
+	}
+	return areaID, token, nil
+}
+
+// loadStationAreas reads the area of every station from radiko's list of
+// every area, so that streams of any station start without looking its
+// area up
+func loadStationAreas(ctx context.Context) {
+	areas, err := api.GetStationAreas(ctx)
+	if err != nil {
+		log.Printf("⚠️ 放送局エリア一覧の取得に失敗しました: %v", err)
+		return
+	}
+	log.Printf("📍 放送局エリア一覧を取得しました (%d局)", len(areas))
+}
+
+// renewTokens renews the tokens of the areas being streamed before they
+// expire, handing them to the running streams, until ctx ends
+func renewTokens(ctx context.Context) {
+	ticker := time.NewTicker(tokenRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := api.RenewTokens(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("⚠️ 認証トークンの更新に失敗しました: %v", err)
+			}
+		}
+	}
+}
+
+// requestArea returns the auth area requested with ?area=JP27, or "" to use
+// the station's own area
+func requestArea(r *http.Request) (string, error) {
+	areaID := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("area")))
+	if areaID != "" && model.FindAreaByID(areaID) == nil {
+		return "", fmt.Errorf("unknown area: %s", areaID)
+	}
+	return areaID, nil
+}
+
+// resolveLiveStream returns the area, an auth token and the live stream URL
+// of a station, at the given quality ("" for the playlist as it is). The
+// token is for the override area, or the station's own area when it is empty.
+func resolveLiveStream(ctx context.Context, stationID, override, quality string) (areaID, authToken, streamURL string, err error) {
+	if override == "" {
+		areaID, authToken, err = resolveStation(ctx, stationID)
+	} else {
+		areaID = override
+		authToken, err = api.Token(ctx, areaID)
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+	log.Printf("📍 エリア: %s (%s)", areaID, stationID)
+
+	streams, err := api.GetStreamURLs(ctx, stationID, authToken)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to get stream URL: %w", err)
+	}
+	return areaID, authToken, selectVariant(api.PreferredStream(streams), quality), nil
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tokenCookie remembers a token passed in the URL, so that the web UI's
+// follow-up requests (player, status) are authenticated too
+const tokenCookie = "radiko_token"
+
+// authConfig holds the credentials, replaced as a whole on reload
+type authConfig struct {
+	token    string // Bearer token (optional)
+	user     string // Basic auth user (optional)
+	password string // Basic auth password
+}
+
+// SetAuth protects every endpoint with a bearer token and/or basic auth.
+// Empty values disable the corresponding method. It can be called while
+// the server is running.
+func (s *Server) SetAuth(token, username, password string) {
+	s.auth.Store(&authConfig{token: token, user: username, password: password})
+}
+
+// credentials returns the current credentials
+func (s *Server) credentials() authConfig {
+	if a := s.auth.Load(); a != nil {
+		return *a
+	}
+	return authConfig{}
+}
+
+// authEnabled reports whether any authentication is configured
+func (s *Server) authEnabled() bool {
+	a := s.credentials()
+	return a.token != "" || a.user != ""
+}
+
+// requireAuth rejects requests without valid credentials. The token is
+// accepted as "Authorization: Bearer", ?token= (for players that cannot set
+// headers) or the cookie set after a ?token= request.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authEnabled() || s.authorized(w, r) || s.dlnaAuthorized(r) || r.URL.Path == "/healthz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		log.Printf("🔒 認証失敗: %s %s (from %s)", r.Method, r.URL.Path, s.getRealIP(r))
+		if s.credentials().user != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="radiko-tui", charset="UTF-8"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="radiko-tui"`)
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// authorized checks the request's credentials
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	a := s.credentials()
+	if a.token != "" {
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(bearer, a.token) {
+			return true
+		}
+		if token := r.URL.Query().Get("token"); token != "" && secureEqual(token, a.token) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     tokenCookie,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+				Secure:   r.TLS != nil,
+			})
+			return true
+		}
+		if c, err := r.Cookie(tokenCookie); err == nil && secureEqual(c.Value, a.token) {
+			return true
+		}
+	}
+	if a.user != "" {
+		if user, pass, ok := r.BasicAuth(); ok && secureEqual(user, a.user) && secureEqual(pass, a.password) {
+			return true
+		}
+	}
+	return false
+}
+
+// secureEqual compares secrets in constant time
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// tokenQuery returns "?token=..." when the request carried its token in the
+// URL, so that links handed to players (playlists, podcast enclosures) work
+func tokenQuery(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return "?token=" + url.QueryEscape(token)
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SetBasePath serves every route under a path prefix (e.g. "/radiko") so the
+// server can sit behind a reverse proxy at a sub-path without rewrite rules
+func (s *Server) SetBasePath(p string) {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		s.basePath = ""
+		return
+	}
+	s.basePath = "/" + p
+}
+
+// stripBasePath removes the base path before routing, redirecting the bare
+// prefix to its trailing-slash form and rejecting paths outside it
+func (s *Server) stripBasePath(next http.Handler) http.Handler {
+	if s.basePath == "" {
+		return next
+	}
+	strip := http.StripPrefix(s.basePath, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == s.basePath:
+			target := s.basePath + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, s.basePath+"/"):
+			strip.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
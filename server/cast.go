@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/cast"
+)
+
+// castDeviceJSON is a cast device in the API, with the station it is playing
+type castDeviceJSON struct {
+	cast.Device
+	StationID string `json:"station_id,omitempty"`
+}
+
+// castSession is a station being cast by the server
+type castSession struct {
+	*cast.Session
+	stationID string
+}
+
+// handleCastDevices lists the Chromecast/Google Home devices on the LAN
+func (s *Server) handleCastDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := cast.Discover(cast.DiscoverTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.castMu.Lock()
+	list := make([]castDeviceJSON, 0, len(devices))
+	for _, d := range devices {
+		entry := castDeviceJSON{Device: d}
+		if c, ok := s.casts[d.Name]; ok {
+			entry.StationID = c.stationID
+		}
+		list = append(list, entry)
+	}
+	s.castMu.Unlock()
+	writeJSON(w, list)
+}
+
+// handleCast casts a station to the device given by ?device=<name>
+func (s *Server) handleCast(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("stationID")
+	device, ok := cast.FindDevice(r.URL.Query().Get("device"))
+	if !ok {
+		http.Error(w, "cast device not found", http.StatusNotFound)
+		return
+	}
+
+	// The device fetches the stream itself, from our address on its network
+	ip, err := cast.LocalIP(device)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	scheme := "http"
+	if s.tlsEnabled() {
+		scheme = "https"
+	}
+	streamURL := fmt.Sprintf("%s://%s%s/api/play/%s", scheme, net.JoinHostPort(ip.String(), strconv.Itoa(s.port)), s.basePath, stationID)
+	if token := s.credentials().token; token != "" {
+		streamURL += "?token=" + url.QueryEscape(token)
+	}
+
+	s.stopCast(device.Name)
+	session, err := cast.Connect(device)
+	if err != nil {
+		log.Printf("❌ キャスト接続エラー [%s]: %v", device.Name, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := session.Load(streamURL, "audio/aac", stationID, api.GetStationLogoURL(stationID)); err != nil {
+		session.Close()
+		log.Printf("❌ キャストエラー [%s]: %v", device.Name, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("📺 キャスト開始: %s → %s", stationID, device.Name)
+
+	s.castMu.Lock()
+	s.casts[device.Name] = castSession{Session: session, stationID: stationID}
+	s.castMu.Unlock()
+	go func() {
+		<-session.Done()
+		s.castMu.Lock()
+		if c, ok := s.casts[device.Name]; ok && c.Session == session {
+			delete(s.casts, device.Name)
+		}
+		s.castMu.Unlock()
+	}()
+	writeJSON(w, castDeviceJSON{Device: device, StationID: stationID})
+}
+
+// handleCastStop stops casting to the device given by ?device=<name>
+func (s *Server) handleCastStop(w http.ResponseWriter, r *http.Request) {
+	if !s.stopCast(r.URL.Query().Get("device")) {
+		http.Error(w, "not casting to this device", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCastVolume sets the volume (?level=0.0-1.0) or mute (?muted=true|false)
+// of the device given by ?device=<name>
+func (s *Server) handleCastVolume(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.castMu.Lock()
+	c, ok := s.casts[q.Get("device")]
+	s.castMu.Unlock()
+	if !ok {
+		http.Error(w, "not casting to this device", http.StatusNotFound)
+		return
+	}
+
+	var err error
+	if v := q.Get("muted"); v != "" {
+		muted, perr := strconv.ParseBool(v)
+		if perr != nil {
+			http.Error(w, "muted must be true or false", http.StatusBadRequest)
+			return
+		}
+		err = c.SetMuted(muted)
+	} else {
+		level, perr := strconv.ParseFloat(q.Get("level"), 64)
+		if perr != nil || level < 0 || level > 1 {
+			http.Error(w, "level must be 0.0-1.0", http.StatusBadRequest)
+			return
+		}
+		err = c.SetVolume(level)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	level, muted := c.Volume()
+	writeJSON(w, map[string]any{"level": level, "muted": muted})
+}
+
+// stopCast stops casting to a device, reporting whether it was being cast to
+func (s *Server) stopCast(name string) bool {
+	s.castMu.Lock()
+	c, ok := s.casts[name]
+	delete(s.casts, name)
+	s.castMu.Unlock()
+	if !ok {
+		return false
+	}
+	c.Stop()
+	log.Printf("📺 キャスト停止: %s", name)
+	return true
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// defaultCORSMethods are the methods allowed when SetCORS is given none
+var defaultCORSMethods = []string{"GET", "HEAD", "POST", "DELETE"}
+
+// corsHeaders are the request headers browser frontends may send
+const corsHeaders = "Authorization, Content-Type, Icy-MetaData, Range"
+
+// corsExposed are the response headers browser frontends may read
+const corsExposed = "Content-Length, Content-Range, icy-metaint, icy-name"
+
+// SetCORS lets browser frontends on other origins call the API and play the
+// streams. origins are full origins ("https://app.example.com") or "*" for
+// any; methods default to GET, HEAD, POST and DELETE.
+func (s *Server) SetCORS(origins, methods []string) {
+	s.corsOrigins = nil
+	for _, o := range origins {
+		if o = strings.TrimSuffix(strings.TrimSpace(o), "/"); o != "" {
+			s.corsOrigins = append(s.corsOrigins, o)
+		}
+	}
+	s.corsMethods = nil
+	for _, m := range methods {
+		if m = strings.ToUpper(strings.TrimSpace(m)); m != "" {
+			s.corsMethods = append(s.corsMethods, m)
+		}
+	}
+	if len(s.corsMethods) == 0 {
+		s.corsMethods = defaultCORSMethods
+	}
+}
+
+// corsAllowed reports whether an origin may call the server
+func (s *Server) corsAllowed(origin string) bool {
+	return slices.Contains(s.corsOrigins, "*") || slices.Contains(s.corsOrigins, origin)
+}
+
+// handleCORS adds the CORS headers for allowed origins and answers their
+// preflight requests, which carry no credentials, before authentication
+func (s *Server) handleCORS(next http.Handler) http.Handler {
+	if len(s.corsOrigins) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !s.corsAllowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		// Credentials (auth headers, the token cookie) only for listed origins
+		if slices.Contains(s.corsOrigins, origin) {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", strings.Join(s.corsMethods, ", "))
+			h.Set("Access-Control-Allow-Headers", corsHeaders)
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !slices.Contains(s.corsMethods, r.Method) {
+			http.Error(w, "method not allowed by CORS", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposed)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/mdns"
+)
+
+// DiscoveryService is the DNS-SD service type servers announce with -announce
+const DiscoveryService = "_radiko-tui._tcp"
+
+// ssdpServerType is the SSDP type servers announce with -announce
+const ssdpServerType = "urn:radiko-tui:device:Server:1"
+
+// discoveryTimeout is how long /api/servers listens for answers
+const discoveryTimeout = 2 * time.Second
+
+// DiscoveredServer is a radiko-tui server found on the LAN
+type DiscoveredServer struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Auth bool   `json:"auth"` // The server requires a token or password
+}
+
+// EnableAnnounce announces the server on the LAN via mDNS and SSDP, so that
+// clients find it with -server-url auto and the web UIs list it
+func (s *Server) EnableAnnounce() {
+	s.announce = true
+	if s.dlnaUUID == "" {
+		s.dlnaUUID = s.deviceUUID()
+	}
+}
+
+// lanName returns the name the server is announced under
+func (s *Server) lanName() string {
+	if s.dlnaName != "" {
+		return s.dlnaName
+	}
+	return defaultLANName()
+}
+
+// serveMDNS announces the server via mDNS until ctx is done
+func (s *Server) serveMDNS(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	scheme := "http"
+	if s.tlsEnabled() {
+		scheme = "https"
+	}
+	auth := "0"
+	if s.authEnabled() {
+		auth = "1"
+	}
+	err := mdns.Announce(ctx, mdns.Service{
+		Instance: s.lanName(),
+		Service:  DiscoveryService,
+		Port:     s.port,
+		TXT:      map[string]string{"scheme": scheme, "path": s.basePath, "auth": auth},
+	})
+	if err != nil {
+		log.Printf("⚠️ mDNSの告知を開始できません: %v", err)
+	}
+}
+
+// Discover returns the radiko-tui servers announcing themselves on the LAN,
+// listening for the given time
+func Discover(timeout time.Duration) ([]DiscoveredServer, error) {
+	instances, err := mdns.Browse(DiscoveryService, timeout)
+	if err != nil {
+		return nil, err
+	}
+	servers := make([]DiscoveredServer, 0, len(instances))
+	for _, inst := range instances {
+		scheme := inst.TXT["scheme"]
+		if scheme != "https" {
+			scheme = "http"
+		}
+		servers = append(servers, DiscoveredServer{
+			Name: inst.Name,
+			URL:  fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(inst.Host, strconv.Itoa(inst.Port)), inst.TXT["path"]),
+			Auth: inst.TXT["auth"] == "1",
+		})
+	}
+	return servers, nil
+}
+
+// handleServers lists the radiko-tui servers on the LAN
+func (s *Server) handleServers(w http.ResponseWriter, r *http.Request) {
+	servers, err := Discover(discoveryTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, servers)
+}
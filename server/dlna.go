@@ -0,0 +1,413 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// UPnP types announced by the media server
+const (
+	dlnaDeviceType        = "urn:schemas-upnp-org:device:MediaServer:1"
+	dlnaContentDirectory  = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	dlnaConnectionManager = "urn:schemas-upnp-org:service:ConnectionManager:1"
+)
+
+// dlnaProtocolInfo describes the AAC live streams to renderers
+// (no seeking, streaming transfer mode)
+const dlnaProtocolInfo = "http-get:*:audio/aac:DLNA.ORG_OP=00;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=01700000000000000000000000000000"
+
+// EnableDLNA announces the stations on the LAN as a DLNA media server with
+// the given friendly name (the hostname when empty)
+func (s *Server) EnableDLNA(name string) {
+	if name == "" {
+		name = defaultLANName()
+	}
+	s.dlnaName = name
+	s.dlnaUUID = s.deviceUUID()
+}
+
+// defaultLANName returns the name the server is shown under on the LAN
+func defaultLANName() string {
+	host, _ := os.Hostname()
+	return "radiko-tui (" + host + ")"
+}
+
+// deviceUUID returns the server's UPnP device UUID, the same across restarts
+// so that devices remember the server
+func (s *Server) deviceUUID() string {
+	host, _ := os.Hostname()
+	sum := md5.Sum([]byte(fmt.Sprintf("radiko-tui:%s:%d", host, s.port)))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// dlnaEnabled reports whether the DLNA media server is enabled
+func (s *Server) dlnaEnabled() bool {
+	return s.dlnaName != ""
+}
+
+// SetDLNAAllow lets DLNA renderers in these networks (CIDR ranges or single
+// addresses) browse and play the stations without credentials, since they
+// cannot authenticate. Without it, the DLNA endpoints require auth like every
+// other one. It can be called while the server is running.
+func (s *Server) SetDLNAAllow(networks []string) error {
+	prefixes, err := parsePrefixes(networks)
+	if err != nil {
+		return err
+	}
+	s.dlnaAllow.Store(&prefixes)
+	return nil
+}
+
+// dlnaRenderer reports whether a request comes from a network allowed with
+// SetDLNAAllow
+func (s *Server) dlnaRenderer(r *http.Request) bool {
+	if !s.dlnaEnabled() {
+		return false
+	}
+	prefixes := s.dlnaAllow.Load()
+	return prefixes != nil && prefixesContain(*prefixes, s.getRealIP(r))
+}
+
+// dlnaAuthorized reports whether requireAuth lets a renderer's request
+// through: the DLNA endpoints, and the streams listed by them with the DLNA
+// token
+func (s *Server) dlnaAuthorized(r *http.Request) bool {
+	if !s.dlnaRenderer(r) {
+		return false
+	}
+	if strings.HasPrefix(r.URL.Path, "/dlna/") {
+		return true
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	// The AAC stream of a station only, not its /pcm or /opus
+	stationID, ok := strings.CutPrefix(r.URL.Path, "/api/play/")
+	if !ok || stationID == "" || strings.Contains(stationID, "/") {
+		return false
+	}
+	token := r.URL.Query().Get("dlna_token")
+	return token != "" && secureEqual(token, s.dlnaToken())
+}
+
+// dlnaToken returns the token of the stream URLs listed to renderers. It is
+// derived from the credentials, so it changes with them, and only opens the
+// AAC streams to the DLNA networks, unlike the server token.
+func (s *Server) dlnaToken() string {
+	a := s.credentials()
+	mac := hmac.New(sha256.New, []byte(a.token+"\x00"+a.user+"\x00"+a.password))
+	mac.Write([]byte("radiko-tui dlna"))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// dlnaStreamURL returns the URL renderers play a station from
+func (s *Server) dlnaStreamURL(r *http.Request, stationID string) string {
+	u := s.baseURL(r) + "/api/play/" + stationID
+	if s.authEnabled() {
+		u += "?dlna_token=" + url.QueryEscape(s.dlnaToken())
+	}
+	return u
+}
+
+// handleDLNADescription returns the UPnP device description
+func (s *Server) handleDLNADescription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0" xmlns:dlna="urn:schemas-dlna-org:device-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <device>
+    <deviceType>%s</deviceType>
+    <friendlyName>%s</friendlyName>
+    <manufacturer>radiko-tui</manufacturer>
+    <modelName>radiko-tui</modelName>
+    <UDN>uuid:%s</UDN>
+    <dlna:X_DLNADOC>DMS-1.50</dlna:X_DLNADOC>
+    <serviceList>
+      <service>
+        <serviceType>%s</serviceType>
+        <serviceId>urn:upnp-org:serviceId:ContentDirectory</serviceId>
+        <SCPDURL>%[6]s/dlna/ContentDirectory.xml</SCPDURL>
+        <controlURL>%[6]s/dlna/control/ContentDirectory</controlURL>
+        <eventSubURL>%[6]s/dlna/event/ContentDirectory</eventSubURL>
+      </service>
+      <service>
+        <serviceType>%[5]s</serviceType>
+        <serviceId>urn:upnp-org:serviceId:ConnectionManager</serviceId>
+        <SCPDURL>%[6]s/dlna/ConnectionManager.xml</SCPDURL>
+        <controlURL>%[6]s/dlna/control/ConnectionManager</controlURL>
+        <eventSubURL>%[6]s/dlna/event/ConnectionManager</eventSubURL>
+      </service>
+    </serviceList>
+  </device>
+</root>
+`, dlnaDeviceType, xmlEscape(s.dlnaName), s.dlnaUUID, dlnaContentDirectory, dlnaConnectionManager, s.basePath)
+}
+
+// contentDirectorySCPD describes the ContentDirectory actions the server implements
+const contentDirectorySCPD = `<?xml version="1.0" encoding="utf-8"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <actionList>
+    <action>
+      <name>Browse</name>
+      <argumentList>
+        <argument><name>ObjectID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ObjectID</relatedStateVariable></argument>
+        <argument><name>BrowseFlag</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_BrowseFlag</relatedStateVariable></argument>
+        <argument><name>Filter</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Filter</relatedStateVariable></argument>
+        <argument><name>StartingIndex</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Index</relatedStateVariable></argument>
+        <argument><name>RequestedCount</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>SortCriteria</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_SortCriteria</relatedStateVariable></argument>
+        <argument><name>Result</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Result</relatedStateVariable></argument>
+        <argument><name>NumberReturned</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>TotalMatches</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>UpdateID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_UpdateID</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSearchCapabilities</name>
+      <argumentList>
+        <argument><name>SearchCaps</name><direction>out</direction><relatedStateVariable>SearchCapabilities</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSortCapabilities</name>
+      <argumentList>
+        <argument><name>SortCaps</name><direction>out</direction><relatedStateVariable>SortCapabilities</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSystemUpdateID</name>
+      <argumentList>
+        <argument><name>Id</name><direction>out</direction><relatedStateVariable>SystemUpdateID</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+  </actionList>
+  <serviceStateTable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ObjectID</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_BrowseFlag</name><dataType>string</dataType>
+      <allowedValueList><allowedValue>BrowseMetadata</allowedValue><allowedValue>BrowseDirectChildren</allowedValue></allowedValueList>
+    </stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Filter</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Index</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Count</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_SortCriteria</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Result</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_UpdateID</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>SearchCapabilities</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>SortCapabilities</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>SystemUpdateID</name><dataType>ui4</dataType></stateVariable>
+  </serviceStateTable>
+</scpd>
+`
+
+// connectionManagerSCPD describes the ConnectionManager actions the server implements
+const connectionManagerSCPD = `<?xml version="1.0" encoding="utf-8"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <actionList>
+    <action>
+      <name>GetProtocolInfo</name>
+      <argumentList>
+        <argument><name>Source</name><direction>out</direction><relatedStateVariable>SourceProtocolInfo</relatedStateVariable></argument>
+        <argument><name>Sink</name><direction>out</direction><relatedStateVariable>SinkProtocolInfo</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetCurrentConnectionIDs</name>
+      <argumentList>
+        <argument><name>ConnectionIDs</name><direction>out</direction><relatedStateVariable>CurrentConnectionIDs</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+  </actionList>
+  <serviceStateTable>
+    <stateVariable sendEvents="yes"><name>SourceProtocolInfo</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>SinkProtocolInfo</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>CurrentConnectionIDs</name><dataType>string</dataType></stateVariable>
+  </serviceStateTable>
+</scpd>
+`
+
+// handleContentDirectorySCPD returns the ContentDirectory service description
+func (s *Server) handleContentDirectorySCPD(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Write([]byte(contentDirectorySCPD))
+}
+
+// handleConnectionManagerSCPD returns the ConnectionManager service description
+func (s *Server) handleConnectionManagerSCPD(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Write([]byte(connectionManagerSCPD))
+}
+
+// soapAction is the action element in the body of a SOAP request
+type soapAction struct {
+	XMLName        xml.Name
+	ObjectID       string `xml:"ObjectID"`
+	BrowseFlag     string `xml:"BrowseFlag"`
+	StartingIndex  int    `xml:"StartingIndex"`
+	RequestedCount int    `xml:"RequestedCount"`
+}
+
+// readSOAPAction decodes the action of a SOAP request
+func readSOAPAction(w http.ResponseWriter, r *http.Request) (soapAction, error) {
+	var envelope struct {
+		Body struct {
+			Action soapAction `xml:",any"`
+		} `xml:"Body"`
+	}
+	if err := xml.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&envelope); err != nil {
+		return soapAction{}, err
+	}
+	return envelope.Body.Action, nil
+}
+
+// writeSOAPResponse writes the response of an action with its output arguments (name, value pairs)
+func writeSOAPResponse(w http.ResponseWriter, serviceType, action string, args ...string) {
+	var b strings.Builder
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, "<%s>%s</%s>", args[i], xmlEscape(args[i+1]), args[i])
+	}
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("EXT", "")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+<s:Body><u:%sResponse xmlns:u="%s">%s</u:%sResponse></s:Body>
+</s:Envelope>
+`, action, serviceType, b.String(), action)
+}
+
+// writeSOAPFault writes a UPnP error
+func writeSOAPFault(w http.ResponseWriter, code int, description string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+<s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring>
+<detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError></detail>
+</s:Fault></s:Body>
+</s:Envelope>
+`, code, xmlEscape(description))
+}
+
+// handleContentDirectory answers ContentDirectory actions. The root container
+// ("0") holds one audio broadcast item per station of the configured areas.
+func (s *Server) handleContentDirectory(w http.ResponseWriter, r *http.Request) {
+	action, err := readSOAPAction(w, r)
+	if err != nil {
+		writeSOAPFault(w, 402, "Invalid Args")
+		return
+	}
+
+	switch action.XMLName.Local {
+	case "GetSearchCapabilities":
+		writeSOAPResponse(w, dlnaContentDirectory, "GetSearchCapabilities", "SearchCaps", "")
+	case "GetSortCapabilities":
+		writeSOAPResponse(w, dlnaContentDirectory, "GetSortCapabilities", "SortCaps", "")
+	case "GetSystemUpdateID":
+		writeSOAPResponse(w, dlnaContentDirectory, "GetSystemUpdateID", "Id", "1")
+	case "Browse":
+		s.handleBrowse(w, r, action)
+	default:
+		writeSOAPFault(w, 401, "Invalid Action")
+	}
+}
+
+// handleBrowse answers a ContentDirectory Browse action
+func (s *Server) handleBrowse(w http.ResponseWriter, r *http.Request, action soapAction) {
+	stations, err := s.playlistStations(r)
+	if err != nil {
+		log.Printf("❌ 放送局リストの取得に失敗しました: %v", err)
+		writeSOAPFault(w, 501, "Action Failed")
+		return
+	}
+
+	var items []string
+	total := 0
+	switch {
+	case action.ObjectID == "0" && action.BrowseFlag == "BrowseMetadata":
+		items = []string{fmt.Sprintf(`<container id="0" parentID="-1" restricted="1" searchable="0" childCount="%d"><dc:title>radiko</dc:title><upnp:class>object.container.storageFolder</upnp:class></container>`, len(stations))}
+		total = 1
+	case action.ObjectID == "0" && action.BrowseFlag == "BrowseDirectChildren":
+		total = len(stations)
+		start := min(max(action.StartingIndex, 0), total)
+		end := total
+		if action.RequestedCount > 0 {
+			end = min(start+action.RequestedCount, total)
+		}
+		for _, station := range stations[start:end] {
+			items = append(items, s.didlItem(r, station))
+		}
+	case action.BrowseFlag == "BrowseMetadata":
+		for _, station := range stations {
+			if station.ID == action.ObjectID {
+				items = []string{s.didlItem(r, station)}
+				total = 1
+			}
+		}
+		if total == 0 {
+			writeSOAPFault(w, 701, "No such object")
+			return
+		}
+	default:
+		writeSOAPFault(w, 701, "No such object")
+		return
+	}
+
+	didl := `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">` +
+		strings.Join(items, "") + `</DIDL-Lite>`
+	writeSOAPResponse(w, dlnaContentDirectory, "Browse",
+		"Result", didl,
+		"NumberReturned", strconv.Itoa(len(items)),
+		"TotalMatches", strconv.Itoa(total),
+		"UpdateID", "1")
+}
+
+// didlItem returns the DIDL-Lite item of a station
+func (s *Server) didlItem(r *http.Request, station model.Station) string {
+	return fmt.Sprintf(`<item id="%s" parentID="0" restricted="1"><dc:title>%s</dc:title><upnp:class>object.item.audioItem.audioBroadcast</upnp:class><upnp:albumArtURI>%s</upnp:albumArtURI><res protocolInfo="%s">%s</res></item>`,
+		xmlEscape(station.ID), xmlEscape(station.Name), xmlEscape(api.GetStationLogoURL(station.ID)),
+		dlnaProtocolInfo, xmlEscape(s.dlnaStreamURL(r, station.ID)))
+}
+
+// handleConnectionManager answers ConnectionManager actions
+func (s *Server) handleConnectionManager(w http.ResponseWriter, r *http.Request) {
+	action, err := readSOAPAction(w, r)
+	if err != nil {
+		writeSOAPFault(w, 402, "Invalid Args")
+		return
+	}
+
+	switch action.XMLName.Local {
+	case "GetProtocolInfo":
+		writeSOAPResponse(w, dlnaConnectionManager, "GetProtocolInfo", "Source", dlnaProtocolInfo, "Sink", "")
+	case "GetCurrentConnectionIDs":
+		writeSOAPResponse(w, dlnaConnectionManager, "GetCurrentConnectionIDs", "ConnectionIDs", "0")
+	default:
+		writeSOAPFault(w, 401, "Invalid Action")
+	}
+}
+
+// handleDLNAEvent rejects event subscriptions, since nothing changes at runtime
+func (s *Server) handleDLNAEvent(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Event subscriptions are not supported", http.StatusPreconditionFailed)
+}
+
+// xmlEscape escapes text for XML
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
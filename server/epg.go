@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+const (
+	epgTTL     = 10 * time.Minute // Programs of today and later, which may still change
+	epgPastTTL = 6 * time.Hour    // Programs of past broadcast days
+	epgRetry   = time.Minute      // Wait before refetching after radiko failed
+	epgKeep    = 24 * time.Hour   // Expired entries are dropped after this
+)
+
+// epgEntry is a station's cached program list for a broadcast day
+type epgEntry struct {
+	programs []model.Program
+	fetched  time.Time
+	expires  time.Time
+}
+
+// epgCache caches radiko's program lists, so that the TUI clients, the web
+// UI and the stream titles share one fetch per station and day
+type epgCache struct {
+	mu      sync.Mutex
+	entries map[string]epgEntry      // "stationID/YYYYMMDD" → programs
+	pending map[string]chan struct{} // Closed when the fetch of a key ends
+}
+
+// epg is the server's program cache
+var epg = &epgCache{
+	entries: make(map[string]epgEntry),
+	pending: make(map[string]chan struct{}),
+}
+
+// programs returns a station's programs for a broadcast day, fetching them
+// when they are not cached or expired. When radiko fails, expired programs
+// are returned rather than none.
+func (c *epgCache) programs(ctx context.Context, stationID string, day time.Time) (epgEntry, error) {
+	key := stationID + "/" + day.Format("20060102")
+	for {
+		c.mu.Lock()
+		cached, ok := c.entries[key]
+		if ok && time.Now().Before(cached.expires) {
+			c.mu.Unlock()
+			return cached, nil
+		}
+		if wait, ok := c.pending[key]; ok {
+			c.mu.Unlock()
+			select {
+			case <-wait:
+			case <-ctx.Done():
+				return epgEntry{}, ctx.Err()
+			}
+			continue
+		}
+		done := make(chan struct{})
+		c.pending[key] = done
+		c.mu.Unlock()
+
+		progs, err := api.GetPrograms(ctx, stationID, day)
+
+		c.mu.Lock()
+		delete(c.pending, key)
+		close(done)
+		if err != nil && (!ok || ctx.Err() != nil) {
+			c.mu.Unlock()
+			return epgEntry{}, err
+		}
+		if err != nil {
+			cached.expires = time.Now().Add(epgRetry)
+			c.entries[key] = cached
+			c.mu.Unlock()
+			log.Printf("⚠️ 番組表の更新に失敗しました。キャッシュを使用します [%s]: %v", key, err)
+			return cached, nil
+		}
+
+		ttl := epgTTL
+		if day.Before(model.BroadcastDay(time.Now())) {
+			ttl = epgPastTTL
+		}
+		now := time.Now()
+		entry := epgEntry{programs: progs, fetched: now, expires: now.Add(ttl)}
+		c.entries[key] = entry
+		for k, e := range c.entries {
+			if now.Sub(e.expires) > epgKeep {
+				delete(c.entries, k)
+			}
+		}
+		c.mu.Unlock()
+		return entry, nil
+	}
+}
+
+// current returns the program on air on a station, or nil
+func (c *epgCache) current(ctx context.Context, stationID string) (*model.Program, error) {
+	now := time.Now()
+	entry, err := c.programs(ctx, stationID, model.BroadcastDay(now))
+	if err != nil {
+		return nil, err
+	}
+	return onAir(entry.programs, now), nil
+}
+
+// onAir returns the program of progs on air at t, or nil
+func onAir(progs []model.Program, t time.Time) *model.Program {
+	at := t.In(model.JST).Format("20060102150405")
+	for _, prog := range progs {
+		if prog.Ft <= at && at < prog.To {
+			return &prog
+		}
+	}
+	return nil
+}
+
+// epgJSON is the response of /api/epg/{stationID}
+type epgJSON struct {
+	StationID string          `json:"station_id"`
+	Date      string          `json:"date"` // Broadcast day, YYYYMMDD
+	FetchedAt time.Time       `json:"fetched_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Now       *model.Program  `json:"now"` // Program on air, if the day is today
+	Programs  []model.Program `json:"programs"`
+}
+
+// handleEPG returns a station's programs for a broadcast day (?date=YYYYMMDD,
+// default today) from the server's cache, with the program on air
+func (s *Server) handleEPG(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("stationID")
+	day := model.BroadcastDay(time.Now())
+	if v := r.URL.Query().Get("date"); v != "" {
+		d, err := time.ParseInLocation("20060102", v, model.JST)
+		if err != nil {
+			http.Error(w, "date must be YYYYMMDD", http.StatusBadRequest)
+			return
+		}
+		day = d
+	}
+
+	entry, err := epg.programs(r.Context(), stationID, day)
+	if err != nil {
+		log.Printf("❌ 番組表の取得に失敗しました [%s]: %v", stationID, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	resp := epgJSON{
+		StationID: stationID,
+		Date:      day.Format("20060102"),
+		FetchedAt: entry.fetched,
+		ExpiresAt: entry.expires,
+		Now:       onAir(entry.programs, time.Now()),
+		Programs:  entry.programs,
+	}
+	if resp.Programs == nil {
+		resp.Programs = []model.Program{}
+	}
+	if maxAge := int(time.Until(entry.expires).Seconds()); maxAge > 0 {
+		w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(maxAge))
+	}
+	writeJSON(w, resp)
+}
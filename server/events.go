@@ -0,0 +1,114 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Event types pushed on /api/events
+const (
+	EventClientConnected    = "client_connected"
+	EventClientDisconnected = "client_disconnected"
+	EventStreamStarted      = "stream_started"
+	EventStreamStopped      = "stream_stopped"
+	EventProgramChanged     = "program_changed"
+	EventTrackChanged       = "track_changed"
+	EventError              = "error"
+)
+
+// Event is a server event, sent to subscribers as JSON
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	StationID string    `json:"station_id,omitempty"`
+	Format    string    `json:"format,omitempty"`
+	ClientID  string    `json:"client_id,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Clients   int       `json:"clients,omitempty"` // Clients of the stream after a connect/disconnect
+	Title     string    `json:"title,omitempty"`   // Station and program (program_changed), or artist and title (track_changed)
+	Artwork   string    `json:"artwork,omitempty"` // Artwork URL (track_changed)
+	Error     string    `json:"error,omitempty"`
+}
+
+// eventHub fans events out to the subscribers
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// events is the server's event hub
+var events = &eventHub{subs: make(map[chan Event]struct{})}
+
+// publish sends an event to every subscriber, dropping it for subscribers
+// that are not keeping up
+func (h *eventHub) publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel receiving the events and a func to unsubscribe
+func (h *eventHub) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// handleEvents pushes events to a WebSocket client until it disconnects
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	// Events are read-only, so cross-origin clients (other tools) are allowed
+	websocket.Server{Handler: s.streamEvents}.ServeHTTP(w, r)
+}
+
+// streamEvents sends events as JSON messages over a WebSocket
+func (s *Server) streamEvents(ws *websocket.Conn) {
+	defer ws.Close()
+	ip := s.getRealIP(ws.Request())
+	log.Printf("🔔 イベント購読開始: %s", ip)
+
+	ch, unsubscribe := events.subscribe()
+	defer unsubscribe()
+
+	// Reading only notices the client closing the connection
+	closed := make(chan struct{})
+	go func() {
+		var msg string
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+		close(closed)
+	}()
+
+	ctx := ws.Request().Context()
+	for {
+		select {
+		case ev := <-ch:
+			if err := websocket.JSON.Send(ws, ev); err != nil {
+				log.Printf("🔔 イベント購読終了: %s", ip)
+				return
+			}
+		case <-closed:
+			log.Printf("🔔 イベント購読終了: %s", ip)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+)
+
+const (
+	// upstreamCheckInterval is how long an upstream check result is reused,
+	// so that frequent probes do not hit radiko every time
+	upstreamCheckInterval = 30 * time.Second
+	// upstreamTimeout bounds an upstream check
+	upstreamTimeout = 5 * time.Second
+)
+
+// healthJSON is the /healthz response
+type healthJSON struct {
+	Status   string       `json:"status"` // ok, degraded (radiko unreachable) or unhealthy (no ffmpeg)
+	Auth     bool         `json:"auth"`   // Authentication is required on the other endpoints
+	TLS      bool         `json:"tls"`
+	FFmpeg   ffmpegJSON   `json:"ffmpeg"`
+	Upstream upstreamJSON `json:"upstream"`
+}
+
+type ffmpegJSON struct {
+	Available bool `json:"available"`
+}
+
+type upstreamJSON struct {
+	Reachable bool      `json:"reachable"`
+	LatencyMS int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// upstreamHealth caches the last radiko reachability check
+var upstreamHealth struct {
+	mu   sync.Mutex
+	last upstreamJSON
+}
+
+// checkUpstream returns whether radiko is reachable, checking at most every
+// upstreamCheckInterval. The result is shared by every prober, so the check
+// does not end with the request that started it.
+func checkUpstream(ctx context.Context) upstreamJSON {
+	upstreamHealth.mu.Lock()
+	defer upstreamHealth.mu.Unlock()
+
+	if time.Since(upstreamHealth.last.CheckedAt) < upstreamCheckInterval {
+		return upstreamHealth.last
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), upstreamTimeout)
+	defer cancel()
+	start := time.Now()
+	err := api.Ping(ctx, upstreamTimeout)
+	result := upstreamJSON{
+		Reachable: err == nil,
+		LatencyMS: time.Since(start).Milliseconds(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	upstreamHealth.last = result
+	return result
+}
+
+// handleHealth reports whether the server can stream, for Docker HEALTHCHECK
+// and Kubernetes probes. It answers without authentication, so it tells
+// nothing about the host, and returns 503 only without ffmpeg: restarting
+// does not help while radiko is unreachable.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	health := healthJSON{
+		Status:   "ok",
+		Auth:     s.authEnabled(),
+		TLS:      s.tlsEnabled(),
+		Upstream: checkUpstream(r.Context()),
+	}
+	if _, err := exec.LookPath("ffmpeg"); err == nil {
+		health.FFmpeg.Available = true
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	switch {
+	case !health.FFmpeg.Available:
+		health.Status = "unhealthy"
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case !health.Upstream.Reachable:
+		health.Status = "degraded"
+	}
+	writeJSON(w, health)
+}
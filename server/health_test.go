@@ -0,0 +1,19 @@
+package server
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckUpstreamCanceledRequest(t *testing.T) {
+	fakeRadiko(t)
+	upstreamHealth.last = upstreamJSON{}
+	t.Cleanup(func() { upstreamHealth.last = upstreamJSON{} })
+
+	// A prober that went away does not cache radiko as unreachable
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := checkUpstream(ctx); !got.Reachable {
+		t.Errorf("upstream = %+v, want reachable", got)
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+)
+
+const hlsTimeout = 30 * time.Second // Bounds each request to radiko's HLS servers
+
+// hlsMaxRedirects bounds the redirects followed for one HLS request
+const hlsMaxRedirects = 10
+
+// hlsClient returns the client fetching radiko's playlists and segments for
+// the HLS proxy, through the api's proxy and TLS settings. Redirects are
+// only followed to radiko's HLS servers, which the auth token is sent to.
+func hlsClient() *http.Client {
+	return &http.Client{
+		Transport: api.HTTPClient().Transport,
+		Timeout:   hlsTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= hlsMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", hlsMaxRedirects)
+			}
+			if !hlsAllowed(req.URL.String()) {
+				return fmt.Errorf("redirect to %s refused: not a radiko HLS URL", req.URL.Host)
+			}
+			return nil
+		},
+	}
+}
+
+// hlsHosts are the domains radiko serves HLS from; the proxy fetches
+// nothing else
+var hlsHosts = []string{"radiko.jp", "smartstream.ne.jp"}
+
+// hlsURIAttr matches the URI attribute of tags like EXT-X-KEY and EXT-X-MAP
+var hlsURIAttr = regexp.MustCompile(`URI="([^"]*)"`)
+
+// handleHLSPlaylist returns a station's HLS playlist from radiko with its
+// URLs pointing at the proxy, which adds the auth token. Clients that play
+// HLS themselves get the stream without an ffmpeg on the server.
+func (s *Server) handleHLSPlaylist(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("stationID")
+	override, err := requestArea(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("📥 HLS: %s (from %s)", stationID, s.getRealIP(r))
+	release, ok := s.acquireClient(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	areaID, _, streamURL, err := resolveLiveStream(r.Context(), stationID, override, "")
+	if err != nil {
+		log.Printf("❌ HLSエラー [%s]: %v", stationID, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	s.proxyHLS(w, r, streamURL, areaID)
+}
+
+// handleHLSProxy fetches a playlist or segment given as ?url= from radiko,
+// with the auth token of ?area=
+func (s *Server) handleHLSProxy(w http.ResponseWriter, r *http.Request) {
+	areaID, err := requestArea(r)
+	if err != nil || areaID == "" {
+		http.Error(w, "area is required", http.StatusBadRequest)
+		return
+	}
+	target := r.URL.Query().Get("url")
+	if !hlsAllowed(target) {
+		http.Error(w, "not a radiko HLS URL", http.StatusBadRequest)
+		return
+	}
+	release, ok := s.acquireClient(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+	s.proxyHLS(w, r, target, areaID)
+}
+
+// hlsAllowed reports whether a URL is on radiko's HLS servers, over HTTPS
+// since the auth token goes with it
+func hlsAllowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	host := u.Hostname()
+	for _, domain := range hlsHosts {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyHLS fetches a URL from radiko with the area's auth token, rewriting
+// playlists and passing segments through
+func (s *Server) proxyHLS(w http.ResponseWriter, r *http.Request, target, areaID string) {
+	authToken, err := api.Token(r.Context(), areaID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, target, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Header.Set("X-Radiko-AuthToken", authToken)
+	resp, err := hlsClient().Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized {
+			// The token was rejected: get a new one for the next request
+			api.InvalidateToken(areaID)
+		}
+		http.Error(w, fmt.Sprintf("radiko returned %s", resp.Status), http.StatusBadGateway)
+		return
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !isPlaylist(target, contentType) {
+		w.Header().Set("Content-Type", contentType)
+		if n := resp.Header.Get("Content-Length"); n != "" {
+			w.Header().Set("Content-Length", n)
+		}
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	base := resp.Request.URL // After redirects
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	rewritePlaylist(w, resp.Body, func(ref string) string {
+		return hlsProxyURL(r, base, ref, areaID)
+	})
+}
+
+// isPlaylist reports whether a response is an HLS playlist
+func isPlaylist(target, contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.Contains(contentType, "mpegurl") {
+		return true
+	}
+	u, err := url.Parse(target)
+	return err == nil && strings.HasSuffix(u.Path, ".m3u8")
+}
+
+// rewritePlaylist copies a playlist, replacing its URIs with rewrite's
+func rewritePlaylist(w io.Writer, r io.Reader, rewrite func(string) string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			line = hlsURIAttr.ReplaceAllStringFunc(line, func(attr string) string {
+				return `URI="` + rewrite(hlsURIAttr.FindStringSubmatch(attr)[1]) + `"`
+			})
+		default:
+			line = rewrite(line)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// hlsProxyURL returns the proxy URL of a playlist reference. It is relative
+// to the playlist, so it works under a base path and behind proxies, and
+// carries the request's token.
+func hlsProxyURL(r *http.Request, base *url.URL, ref, areaID string) string {
+	abs := ref
+	if u, err := base.Parse(ref); err == nil {
+		abs = u.String()
+	}
+	v := url.Values{"url": {abs}, "area": {areaID}}
+	if token := r.URL.Query().Get("token"); token != "" {
+		v.Set("token", token)
+	}
+	return "proxy?" + v.Encode()
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+)
+
+// icyMetaInt is the number of audio bytes between ICY metadata blocks
+const icyMetaInt = 16000
+
+// titleRefresh is how often the program on air is re-checked, so that EPG
+// changes (overruns, special programs) show up without waiting for the end
+const titleRefresh = 5 * time.Minute
+
+// trackRefresh is how often the now-on-air feed is checked for a new track
+const trackRefresh = 30 * time.Second
+
+// watchProgram keeps the stream title up to date until ffmpeg exits: the
+// track on air ("artist - title") while there is one, "station - program"
+// otherwise
+func (ss *StationPipeline) watchProgram(areaID string) {
+	ctx, cancel := quitContext(ss.stopped)
+	defer cancel()
+
+	name := ss.stationID
+	if stations, err := api.GetStations(ctx, areaID); err == nil {
+		for _, station := range stations {
+			if station.ID == ss.stationID {
+				name = station.Name
+				break
+			}
+		}
+	}
+
+	var program string
+	var checked time.Time // When the program was last checked
+	nextCheck := time.Duration(0)
+	for {
+		if time.Since(checked) >= nextCheck {
+			program = name
+			nextCheck = titleRefresh
+			prog, err := epg.current(ctx, ss.stationID)
+			if err != nil {
+				log.Printf("⚠️ 番組情報の取得に失敗しました [%s]: %v", ss.stationID, err)
+				nextCheck = time.Minute
+			} else if prog != nil {
+				program = name + " - " + prog.Title
+				if end, err := prog.EndTime(); err == nil && time.Until(end) < nextCheck {
+					nextCheck = time.Until(end) + 5*time.Second // Let the EPG move on
+				}
+			}
+			checked = time.Now()
+		}
+
+		// Stations without music, or a feed that fails, keep the program
+		track, _ := api.GetNowOnAir(ctx, ss.stationID)
+		title, artwork := program, ""
+		if track != nil {
+			title, artwork = track.String(), track.Artwork()
+		}
+
+		ss.mu.Lock()
+		programChanged := ss.program != program
+		trackChanged := track != nil && ss.title != title
+		ss.program, ss.title, ss.artwork = program, title, artwork
+		ss.mu.Unlock()
+		if programChanged {
+			log.Printf("📻 番組: %s", program)
+			events.publish(Event{Type: EventProgramChanged, StationID: ss.stationID, Title: program})
+		}
+		if trackChanged {
+			log.Printf("🎵 曲: %s", title)
+			events.publish(Event{Type: EventTrackChanged, StationID: ss.stationID, Title: title, Artwork: artwork})
+		}
+
+		select {
+		case <-ss.stopped:
+			return
+		case <-time.After(min(trackRefresh, nextCheck-time.Since(checked))):
+		}
+	}
+}
+
+// icyWriter interleaves SHOUTcast/Icecast in-band metadata into the audio:
+// a metadata block after every icyMetaInt bytes, carrying the title (and
+// artwork URL) whenever it changes and empty otherwise
+type icyWriter struct {
+	http.ResponseWriter
+	title     func() (title, artwork string)
+	remaining int    // Audio bytes until the next metadata block
+	sent      string // Metadata in the last metadata block
+}
+
+// newICYWriter wraps w to send ICY metadata with the titles and artwork
+// returned by title
+func newICYWriter(w http.ResponseWriter, title func() (title, artwork string)) *icyWriter {
+	return &icyWriter{ResponseWriter: w, title: title, remaining: icyMetaInt}
+}
+
+// Write writes audio, inserting metadata blocks at the metadata interval
+func (w *icyWriter) Write(p []byte) (int, error) {
+	var buf []byte
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), w.remaining)
+		buf = append(buf, p[:n]...)
+		p = p[n:]
+		written += n
+		w.remaining -= n
+		if w.remaining == 0 {
+			buf = append(buf, w.metadata()...)
+			w.remaining = icyMetaInt
+		}
+	}
+	if _, err := w.ResponseWriter.Write(buf); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// Flush sends buffered data to the client
+func (w *icyWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original writer for http.ResponseController
+func (w *icyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// metadata returns the next metadata block: a length byte (in 16-byte units)
+// followed by StreamTitle (and StreamUrl with the artwork) padded with zeros
+func (w *icyWriter) metadata() []byte {
+	title, artwork := w.title()
+	if title == "" {
+		return []byte{0}
+	}
+
+	// Quotes end the value in most players
+	meta := "StreamTitle='" + strings.ReplaceAll(title, "'", "’") + "';"
+	if artwork != "" {
+		meta += "StreamUrl='" + strings.ReplaceAll(artwork, "'", "%27") + "';"
+	}
+	if meta == w.sent {
+		return []byte{0}
+	}
+	w.sent = meta
+	if len(meta) > 255*16 {
+		meta = meta[:255*16]
+	}
+	size := (len(meta) + 15) / 16
+	block := make([]byte, 1+size*16)
+	block[0] = byte(size)
+	copy(block[1:], meta)
+	return block
+}
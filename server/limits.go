@@ -0,0 +1,108 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"net/netip"
+	"sync"
+)
+
+// clientLimits caps the number of concurrent stream clients
+type clientLimits struct {
+	mu            sync.Mutex
+	max           int            // All clients (0 = unlimited)
+	maxPerIP      int            // Clients from one IP (0 = unlimited)
+	maxPerStation map[string]int // Clients of a station, in every format (optional)
+	total         int
+	perIP         map[string]int
+	perStation    map[string]int
+}
+
+// SetLimits caps the concurrent stream clients, overall and per IP.
+// Zero disables a limit.
+func (s *Server) SetLimits(maxClients, maxClientsPerIP int) {
+	s.limits.mu.Lock()
+	defer s.limits.mu.Unlock()
+	s.limits.max = maxClients
+	s.limits.maxPerIP = maxClientsPerIP
+}
+
+// SetStationLimits caps the concurrent stream clients of some stations, e.g.
+// those behind a slow upstream, counting every format. It replaces the caps
+// set before.
+func (s *Server) SetStationLimits(maxClients map[string]int) {
+	s.limits.mu.Lock()
+	defer s.limits.mu.Unlock()
+	s.limits.maxPerStation = maxClients
+}
+
+// acquireClient reserves a stream slot for the request's IP and station. It
+// returns a release func, or writes 503 (server or station full) / 429 (too
+// many from this IP) and returns false.
+func (s *Server) acquireClient(w http.ResponseWriter, r *http.Request) (func(), bool) {
+	l := &s.limits
+	ip := limitKey(s.getRealIP(r))
+	stationID := r.PathValue("stationID")
+
+	l.mu.Lock()
+	if limit := l.maxPerStation[stationID]; limit > 0 && l.perStation[stationID] >= limit {
+		l.mu.Unlock()
+		log.Printf("🚫 %s の接続数の上限 (%d) に達しています: %s", stationID, limit, ip)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Too many clients for this station", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if l.max > 0 && l.total >= l.max {
+		l.mu.Unlock()
+		log.Printf("🚫 接続数の上限 (%d) に達しています: %s", l.max, ip)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Too many clients", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if l.maxPerIP > 0 && l.perIP[ip] >= l.maxPerIP {
+		l.mu.Unlock()
+		log.Printf("🚫 IPごとの接続数の上限 (%d) に達しています: %s", l.maxPerIP, ip)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Too many clients from this address", http.StatusTooManyRequests)
+		return nil, false
+	}
+	if l.perIP == nil {
+		l.perIP = make(map[string]int)
+		l.perStation = make(map[string]int)
+	}
+	l.total++
+	l.perIP[ip]++
+	l.perStation[stationID]++
+	stats.observeClients(l.total)
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.total--
+			if l.perIP[ip]--; l.perIP[ip] <= 0 {
+				delete(l.perIP, ip)
+			}
+			if l.perStation[stationID]--; l.perStation[stationID] <= 0 {
+				delete(l.perStation, stationID)
+			}
+		})
+	}, true
+}
+
+// limitKey is the address counted by the per-IP limit. IPv6 clients are
+// counted per /64, since a single host usually gets a whole /64 to pick
+// addresses from.
+func limitKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	if addr.Is4() {
+		return addr.String()
+	}
+	return netip.PrefixFrom(addr.WithZone(""), 64).Masked().String()
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Log levels of Server.SetLogLevel
+const (
+	LogLevelInfo  = "info"  // Everything
+	LogLevelWarn  = "warn"  // Warnings (⚠) and errors (❌)
+	LogLevelError = "error" // Errors (❌) only
+)
+
+// levelWriter drops log lines below the level, recognized by the ⚠ and ❌
+// markers of the messages
+type levelWriter struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level string
+}
+
+// logFilter filters the standard logger once SetLogLevel is called. The
+// server logs through the standard logger, which the process shares.
+var logFilter = &levelWriter{level: LogLevelInfo}
+
+// SetLogLevel limits the log to warnings and errors ("warn") or errors
+// ("error"); "info" (or "") logs everything. It can be called at any time.
+// The standard logger is process-wide, so the level applies to the log of
+// every server.
+func (s *Server) SetLogLevel(level string) error {
+	level = strings.ToLower(strings.TrimSpace(level))
+	switch level {
+	case "":
+		level = LogLevelInfo
+	case LogLevelInfo, LogLevelWarn, LogLevelError:
+	default:
+		return fmt.Errorf("unknown log level %q (info, warn or error)", level)
+	}
+
+	logFilter.mu.Lock()
+	defer logFilter.mu.Unlock()
+	if logFilter.out == nil {
+		logFilter.out = log.Writer()
+		log.SetOutput(logFilter)
+	}
+	logFilter.level = level
+	return nil
+}
+
+// logLevel returns the current log level
+func logLevel() string {
+	logFilter.mu.Lock()
+	defer logFilter.mu.Unlock()
+	return logFilter.level
+}
+
+// Write passes a log line on when its level is enabled
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	line := string(p)
+	switch w.level {
+	case LogLevelWarn:
+		if !strings.Contains(line, "⚠") && !strings.Contains(line, "❌") {
+			return len(p), nil
+		}
+	case LogLevelError:
+		if !strings.Contains(line, "❌") {
+			return len(p), nil
+		}
+	}
+	return w.out.Write(p)
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Formats of a multicast output
+const (
+	MulticastAAC = "aac" // ADTS AAC as radiko sends it
+	MulticastPCM = "pcm" // s16le, 48kHz, stereo
+)
+
+const (
+	multicastPacketSize = 1280             // Datagram payload: whole PCM frames, within a LAN MTU
+	multicastRetry      = 30 * time.Second // Wait before restarting an output whose stream ended
+)
+
+// multicastOutput is a station's stream sent to a multicast group
+type multicastOutput struct {
+	StationID string `json:"station_id"`
+	Format    string `json:"format"`
+	Group     string `json:"group"` // Address and port
+	cancel    context.CancelFunc
+}
+
+// multicastWriter is the stream client sending to a multicast group. The
+// group has no flow control, so writes never block the stream.
+type multicastWriter struct {
+	header http.Header
+	conn   *net.UDPConn
+}
+
+func (w *multicastWriter) Header() http.Header { return w.header }
+func (w *multicastWriter) WriteHeader(int)     {}
+
+// Write sends p as datagrams of at most multicastPacketSize bytes
+func (w *multicastWriter) Write(p []byte) (int, error) {
+	sent := 0
+	for len(p) > 0 {
+		n := min(len(p), multicastPacketSize)
+		if _, err := w.conn.Write(p[:n]); err != nil {
+			return sent, err
+		}
+		sent += n
+		p = p[n:]
+	}
+	return sent, nil
+}
+
+// ParseMulticast parses an output given as STATION[/pcm]@GROUP:PORT, e.g.
+// QRR@239.255.42.1:5004 (AAC) or TBS/pcm@239.255.42.2:5004
+func ParseMulticast(spec string) (stationID, format, group string, err error) {
+	target, group, ok := strings.Cut(strings.TrimSpace(spec), "@")
+	if !ok || target == "" || group == "" {
+		return "", "", "", fmt.Errorf("multicast output must be STATION[/pcm]@GROUP:PORT: %q", spec)
+	}
+	stationID, format, _ = strings.Cut(target, "/")
+	if format == "" {
+		format = MulticastAAC
+	}
+	return stationID, format, group, nil
+}
+
+// StartMulticast sends a station's AAC or PCM stream to a multicast group
+// (e.g. "239.255.42.1:5004") until it is stopped or the server shuts down,
+// replacing the output previously sent there. The stream keeps running
+// without clients, and is restarted when it ends.
+func (s *Server) StartMulticast(stationID, format, group string) error {
+	_, err := s.startMulticast(stationID, format, group)
+	return err
+}
+
+// startMulticast starts a multicast output, returning it
+func (s *Server) startMulticast(stationID, format, group string) (*multicastOutput, error) {
+	format = strings.ToLower(format)
+	if format != MulticastAAC && format != MulticastPCM {
+		return nil, fmt.Errorf("multicast format must be %s or %s", MulticastAAC, MulticastPCM)
+	}
+	addr, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return nil, fmt.Errorf("invalid multicast group: %w", err)
+	}
+	if !addr.IP.IsMulticast() || addr.Port == 0 {
+		return nil, fmt.Errorf("not a multicast address and port: %s", group)
+	}
+	group = addr.String()
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s.StopMulticast(group)
+	ctx, cancel := context.WithCancel(s.baseCtx)
+	out := &multicastOutput{StationID: stationID, Format: format, Group: group, cancel: cancel}
+	s.multicastMu.Lock()
+	s.multicasts[group] = out
+	s.multicastMu.Unlock()
+
+	writer := &multicastWriter{header: make(http.Header), conn: conn}
+	go func() {
+		defer conn.Close()
+		clientID := "multicast-" + group
+		for ctx.Err() == nil {
+			var err error
+			if format == MulticastPCM {
+				err = s.pcmStreamManager.Subscribe(ctx, writer, stationID, "", clientID, group)
+			} else {
+				err = s.streamManager.Subscribe(ctx, writer, stationID, "", clientID, group)
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			if err != nil {
+				log.Printf("❌ マルチキャストストリームエラー [%s]: %v", group, err)
+			}
+			log.Printf("📡 マルチキャストを%v後に再開します: %s → %s", multicastRetry, stationID, group)
+			select {
+			case <-ctx.Done():
+			case <-time.After(multicastRetry):
+			}
+		}
+
+		s.multicastMu.Lock()
+		if s.multicasts[group] == out {
+			delete(s.multicasts, group)
+		}
+		s.multicastMu.Unlock()
+		log.Printf("📡 マルチキャスト停止: %s", group)
+	}()
+
+	log.Printf("📡 マルチキャスト開始: %s (%s) → %s", stationID, format, group)
+	return out, nil
+}
+
+// StopMulticast stops the output sent to a group, reporting whether there
+// was one
+func (s *Server) StopMulticast(group string) bool {
+	if addr, err := net.ResolveUDPAddr("udp", group); err == nil {
+		group = addr.String()
+	}
+	s.multicastMu.Lock()
+	out, ok := s.multicasts[group]
+	delete(s.multicasts, group)
+	s.multicastMu.Unlock()
+	if ok {
+		out.cancel()
+	}
+	return ok
+}
+
+// stopStationMulticasts stops the outputs sending a station, reporting
+// whether there were any
+func (s *Server) stopStationMulticasts(stationID string) bool {
+	s.multicastMu.Lock()
+	var groups []string
+	for group, out := range s.multicasts {
+		if out.StationID == stationID {
+			groups = append(groups, group)
+		}
+	}
+	s.multicastMu.Unlock()
+	for _, group := range groups {
+		s.StopMulticast(group)
+	}
+	return len(groups) > 0
+}
+
+// handleMulticasts lists the multicast outputs
+func (s *Server) handleMulticasts(w http.ResponseWriter, r *http.Request) {
+	s.multicastMu.Lock()
+	list := make([]*multicastOutput, 0, len(s.multicasts))
+	for _, out := range s.multicasts {
+		list = append(list, out)
+	}
+	s.multicastMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Group < list[j].Group })
+	writeJSON(w, list)
+}
+
+// handleMulticast sends a station to the group given by ?group=ADDR:PORT, as
+// AAC or ?format=pcm
+func (s *Server) handleMulticast(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("stationID")
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = MulticastAAC
+	}
+	out, err := s.startMulticast(stationID, format, q.Get("group"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, out)
+}
+
+// handleMulticastStop stops the output sent to ?group=ADDR:PORT
+func (s *Server) handleMulticastStop(w http.ResponseWriter, r *http.Request) {
+	if !s.StopMulticast(r.URL.Query().Get("group")) {
+		http.Error(w, "no multicast output to this group", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
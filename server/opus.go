@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultOpusBitrate is the Opus bitrate (kbps) used when none is requested
+const DefaultOpusBitrate = 48
+
+// Opus bitrates (kbps) a client may request
+const (
+	minOpusBitrate = 6
+	maxOpusBitrate = 256
+)
+
+// SetOpusBitrate sets the default bitrate (kbps) of the Opus endpoint
+func (s *Server) SetOpusBitrate(kbps int) {
+	if kbps >= minOpusBitrate && kbps <= maxOpusBitrate {
+		s.opusBitrate = kbps
+	}
+}
+
+// NewOpusStreamManager creates a stream manager that re-encodes stations to
+// Opus in Ogg at the given bitrate (kbps)
+func NewOpusStreamManager(graceSeconds, kbps int, policy *slowClientPolicy) *StreamManager {
+	return &StreamManager{
+		streams:      make(map[string]*StationPipeline),
+		graceSeconds: graceSeconds,
+		format: streamFormat{
+			label:     fmt.Sprintf("Opus %dkbps", kbps),
+			name:      fmt.Sprintf("-opus%d", kbps),
+			codecArgs: opusCodecArgs(kbps),
+			ogg:       true,
+		},
+		policy: policy,
+	}
+}
+
+// opusCodecArgs returns the ffmpeg output arguments for Opus in Ogg
+func opusCodecArgs(kbps int) []string {
+	return []string{
+		"-c:a", "libopus",
+		"-b:a", fmt.Sprintf("%dk", kbps),
+		"-application", "audio",
+		"-f", "ogg",
+		"-page_duration", "200000", // Short pages keep latency low
+	}
+}
+
+// requestOpusBitrate returns the bitrate requested with ?bitrate=<kbps>, or the default
+func (s *Server) requestOpusBitrate(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("bitrate")
+	if v == "" {
+		return s.opusBitrate, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < minOpusBitrate || n > maxOpusBitrate {
+		return 0, fmt.Errorf("bitrate must be %d-%d (kbps)", minOpusBitrate, maxOpusBitrate)
+	}
+	return n, nil
+}
+
+// opusManager returns the Opus stream manager for a bitrate
+func (s *Server) opusManager(kbps int) *StreamManager {
+	s.opusMu.Lock()
+	defer s.opusMu.Unlock()
+
+	m, ok := s.opusManagers[kbps]
+	if !ok {
+		m = NewOpusStreamManager(s.graceSeconds, kbps, s.slowClients)
+		s.opusManagers[kbps] = m
+	}
+	return m
+}
+
+// handleOpusPlayRequest streams a station as Opus in Ogg for low-bandwidth
+// listening. The bitrate can be chosen with ?bitrate=<kbps>.
+func (s *Server) handleOpusPlayRequest(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("stationID")
+	clientIP := s.getRealIP(r)
+	log.Printf("📥 Opusリクエスト: %s %s (from %s)", r.Method, r.URL.Path, clientIP)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if stationID == "" {
+		http.Error(w, "stationID is required", http.StatusBadRequest)
+		return
+	}
+
+	kbps, err := s.requestOpusBitrate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	areaID, err := requestArea(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "audio/ogg; codecs=opus")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("icy-name", fmt.Sprintf("Radiko - %s", stationID))
+	w.Header().Set("icy-genre", "Radio")
+	w.Header().Set("icy-br", strconv.Itoa(kbps))
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	release, ok := s.acquireClient(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	clientID := fmt.Sprintf("%s-%d", clientIP, time.Now().UnixNano())
+	log.Printf("🎵 Opusクライアント接続: %s → %s (%dkbps)", clientID, stationID, kbps)
+
+	err = s.opusManager(kbps).Subscribe(r.Context(), w, stationID, areaID, clientID, clientIP)
+	if err != nil {
+		log.Printf("❌ Opusストリームエラー [%s]: %v", clientID, err)
+		events.publish(Event{Type: EventError, StationID: stationID, Format: fmt.Sprintf("Opus %dkbps", kbps), ClientID: clientID, IP: clientIP, Error: err.Error()})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("👋 Opusクライアント切断: %s", clientID)
+}
+
+// readOggPage reads one complete Ogg page
+func readOggPage(r io.Reader) ([]byte, error) {
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != "OggS" {
+		return nil, errors.New("invalid Ogg page")
+	}
+	segments := make([]byte, header[26])
+	if _, err := io.ReadFull(r, segments); err != nil {
+		return nil, err
+	}
+	size := 0
+	for _, n := range segments {
+		size += int(n)
+	}
+
+	page := make([]byte, len(header)+len(segments)+size)
+	copy(page, header)
+	copy(page[len(header):], segments)
+	if _, err := io.ReadFull(r, page[len(header)+len(segments):]); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// oggBOS reports whether an Ogg page begins a logical stream, as the first
+// page of every ffmpeg run does
+func oggBOS(page []byte) bool {
+	return len(page) > 5 && page[5]&0x02 != 0
+}
+
+// oggGranule returns the granule position of an Ogg page (0 for header pages)
+func oggGranule(page []byte) uint64 {
+	if len(page) < 14 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(page[6:14])
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/kanoshiou/radiko-tui/player"
+)
+
+// Sample rates (Hz) a PCM client may request
+const (
+	minPCMRate = 8000
+	maxPCMRate = 192000
+)
+
+// pcmFormat is the sample rate and channel count of a PCM stream, always
+// s16le
+type pcmFormat struct {
+	rate     int
+	channels int
+}
+
+// defaultPCMFormat is what the radiko-tui client plays
+var defaultPCMFormat = pcmFormat{rate: player.NativeSampleRate, channels: 2}
+
+// label returns the format shown in the status and events
+func (f pcmFormat) label() string {
+	if f == defaultPCMFormat {
+		return "PCM"
+	}
+	return fmt.Sprintf("PCM %dHz/%dch", f.rate, f.channels)
+}
+
+// frameSize returns the bytes per frame
+func (f pcmFormat) frameSize() int {
+	return 2 * f.channels
+}
+
+// NewPCMStreamManager creates a stream manager that decodes stations to
+// s16le PCM in a format
+func NewPCMStreamManager(graceSeconds int, f pcmFormat, policy *slowClientPolicy) *StreamManager {
+	name := "-pcm"
+	if f != defaultPCMFormat {
+		name = fmt.Sprintf("-pcm%d-%d", f.rate, f.channels)
+	}
+	return &StreamManager{
+		streams:      make(map[string]*StationPipeline),
+		graceSeconds: graceSeconds,
+		format: streamFormat{
+			label:     f.label(),
+			name:      name,
+			codecArgs: []string{"-f", "s16le", "-ar", strconv.Itoa(f.rate), "-ac", strconv.Itoa(f.channels)},
+			frameSize: f.frameSize(),
+		},
+		policy: policy,
+	}
+}
+
+// requestPCMFormat returns the format requested with ?rate=<Hz> and
+// ?channels=<1|2>, each defaulting to 48kHz stereo
+func requestPCMFormat(r *http.Request) (pcmFormat, error) {
+	f := defaultPCMFormat
+	if v := r.URL.Query().Get("rate"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minPCMRate || n > maxPCMRate {
+			return pcmFormat{}, fmt.Errorf("rate must be %d-%d (Hz)", minPCMRate, maxPCMRate)
+		}
+		f.rate = n
+	}
+	if v := r.URL.Query().Get("channels"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 2 {
+			return pcmFormat{}, fmt.Errorf("channels must be 1 or 2")
+		}
+		f.channels = n
+	}
+	return f, nil
+}
+
+// pcmManager returns the PCM stream manager for a format
+func (s *Server) pcmManager(f pcmFormat) *StreamManager {
+	if f == defaultPCMFormat {
+		return s.pcmStreamManager
+	}
+	s.pcmMu.Lock()
+	defer s.pcmMu.Unlock()
+
+	m, ok := s.pcmManagers[f]
+	if !ok {
+		m = NewPCMStreamManager(s.graceSeconds, f, s.slowClients)
+		s.pcmManagers[f] = m
+	}
+	return m
+}
+
+// setPCMHeaders sets the headers describing a PCM stream
+func setPCMHeaders(w http.ResponseWriter, f pcmFormat) {
+	w.Header().Set("Content-Type", fmt.Sprintf("audio/L16;rate=%d;channels=%d", f.rate, f.channels))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("X-Audio-Format", "s16le")
+	w.Header().Set("X-Sample-Rate", strconv.Itoa(f.rate))
+	w.Header().Set("X-Channels", strconv.Itoa(f.channels))
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// SetAreas sets the areas whose stations are listed in the playlists
+func (s *Server) SetAreas(areaIDs []string) {
+	s.areas = areaIDs
+}
+
+// playlistStations returns the stations of the requested areas (?area=JP13,JP27)
+// or of the configured ones, without duplicates
+func (s *Server) playlistStations(r *http.Request) ([]model.Station, error) {
+	areas := s.requestAreas(r)
+
+	var stations []model.Station
+	seen := make(map[string]bool)
+	for _, areaID := range areas {
+		list, err := api.GetStations(r.Context(), areaID)
+		if err != nil {
+			return nil, err
+		}
+		for _, station := range list {
+			if !seen[station.ID] {
+				seen[station.ID] = true
+				stations = append(stations, station)
+			}
+		}
+	}
+	return stations, nil
+}
+
+// playURL returns the play URL of a station for the requested format
+// (?format=aac|opus) and AAC quality (?quality=low|high)
+func (s *Server) playURL(r *http.Request, stationID string) (string, error) {
+	url := s.baseURL(r) + "/api/play/" + stationID
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "aac":
+		quality, err := requestQuality(r)
+		if err != nil {
+			return "", err
+		}
+		query := tokenQuery(r)
+		if quality != "" {
+			if query == "" {
+				query = "?quality=" + quality
+			} else {
+				query += "&quality=" + quality
+			}
+		}
+		return url + query, nil
+	case "opus":
+		return url + "/opus" + tokenQuery(r), nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// handlePlaylistM3U returns all stations as an extended M3U playlist
+func (s *Server) handlePlaylistM3U(w http.ResponseWriter, r *http.Request) {
+	stations, err := s.playlistStations(r)
+	if err != nil {
+		log.Printf("❌ 放送局リストの取得に失敗しました: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for _, station := range stations {
+		url, err := s.playURL(r, station.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(&b, "#EXTINF:-1 tvg-id=\"%s\" tvg-logo=\"%s\",%s\n%s\n",
+			station.ID, api.GetStationLogoURL(station.ID), station.Name, url)
+	}
+
+	w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="radiko.m3u"`)
+	w.Write([]byte(b.String()))
+}
+
+// handlePlaylistPLS returns all stations as a PLS playlist
+func (s *Server) handlePlaylistPLS(w http.ResponseWriter, r *http.Request) {
+	stations, err := s.playlistStations(r)
+	if err != nil {
+		log.Printf("❌ 放送局リストの取得に失敗しました: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("[playlist]\n")
+	for i, station := range stations {
+		url, err := s.playURL(r, station.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(&b, "File%d=%s\nTitle%d=%s\nLength%d=-1\n", i+1, url, i+1, station.Name, i+1)
+	}
+	fmt.Fprintf(&b, "NumberOfEntries=%d\nVersion=2\n", len(stations))
+
+	w.Header().Set("Content-Type", "audio/x-scpls; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="radiko.pls"`)
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/kanoshiou/radiko-tui/recorder"
+)
+
+// EnablePodcast serves the recordings as a podcast feed at /podcast.xml
+func (s *Server) EnablePodcast() {
+	s.podcast = true
+}
+
+// baseURL returns the URL clients used to reach the server (honoring reverse proxies)
+func (s *Server) baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + s.basePath
+}
+
+// handlePodcastFeed returns an RSS feed of the finished recordings
+func (s *Server) handlePodcastFeed(w http.ResponseWriter, r *http.Request) {
+	entries, err := recorder.History()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := s.baseURL(r)
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	err = recorder.WriteFeed(w, entries, recorder.FeedOptions{
+		Link: base + "/podcast.xml",
+		EnclosureURL: func(e recorder.HistoryEntry) string {
+			return base + "/recordings/" + recorder.EntryID(e) + tokenQuery(r)
+		},
+	})
+	if err != nil {
+		log.Printf("❌ フィード生成エラー: %v", err)
+	}
+}
+
+// handleRecording serves a recorded file (with Range support for podcast apps)
+func (s *Server) handleRecording(w http.ResponseWriter, r *http.Request) {
+	entry, ok := recorder.FindRecording(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	log.Printf("📥 録音ダウンロード: %s (from %s)", entry.Path, s.getRealIP(r))
+	w.Header().Set("Content-Type", recorder.MimeType(entry.Path))
+	http.ServeFile(w, r, entry.Path)
+}
@@ -0,0 +1,23 @@
+package server
+
+import "time"
+
+// DefaultPrebuffer is the default pre-buffer time in seconds
+const DefaultPrebuffer = 3
+
+// SetPrebuffer sends new clients the last seconds of the station's output
+// right away, so that players start within a second instead of filling
+// their buffer in real time (0 = start at the live edge)
+func (s *Server) SetPrebuffer(seconds int) {
+	p := s.slowClients
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prebuffer = time.Duration(max(seconds, 0)) * time.Second
+}
+
+// prebufferTime returns the pre-buffer time
+func (p *slowClientPolicy) prebufferTime() time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.prebuffer
+}
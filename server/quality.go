@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/kanoshiou/radiko-tui/api"
+)
+
+// Stream qualities a client may request with ?quality=
+const (
+	qualityLow  = string(api.QualityLow)  // Lowest bandwidth HLS variant, for mobile data
+	qualityHigh = string(api.QualityHigh) // Highest bandwidth HLS variant
+)
+
+// requestQuality returns the quality requested with ?quality=low|high, or
+// "" for the playlist's default stream
+func requestQuality(r *http.Request) (string, error) {
+	switch q := r.URL.Query().Get("quality"); q {
+	case "", qualityLow, qualityHigh:
+		return q, nil
+	default:
+		return "", fmt.Errorf("quality must be %s or %s", qualityLow, qualityHigh)
+	}
+}
+
+// NewQualityStreamManager creates an AAC stream manager reading the given
+// HLS variant of the stations
+func NewQualityStreamManager(graceSeconds int, quality string, policy *slowClientPolicy) *StreamManager {
+	format := aacFormat
+	format.label = "AAC " + quality
+	format.name = "-" + quality
+	format.quality = quality
+	return &StreamManager{
+		streams:      make(map[string]*StationPipeline),
+		graceSeconds: graceSeconds,
+		format:       format,
+		policy:       policy,
+	}
+}
+
+// aacManager returns the AAC stream manager for a quality
+func (s *Server) aacManager(quality string) *StreamManager {
+	if quality == "" {
+		return s.streamManager
+	}
+
+	s.qualityMu.Lock()
+	defer s.qualityMu.Unlock()
+
+	m, ok := s.qualityManagers[quality]
+	if !ok {
+		m = NewQualityStreamManager(s.graceSeconds, quality, s.slowClients)
+		s.qualityManagers[quality] = m
+	}
+	return m
+}
+
+// selectVariant returns the URL of a stream at a quality ("" for the
+// playlist's default stream)
+func selectVariant(stream api.Stream, quality string) string {
+	if quality == "" {
+		return stream.URL
+	}
+	v := stream.Select(api.Quality(quality))
+	if v.URL == stream.URL {
+		log.Printf("⚠️ バリアントがありません。既定のストリームを使用します")
+	} else {
+		log.Printf("🎚 品質 %s: %d bps (%d 個中)", quality, v.Bandwidth, len(stream.Variants))
+	}
+	return v.URL
+}
@@ -0,0 +1,66 @@
+package server
+
+import "log"
+
+// SetGraceSeconds changes how long ffmpeg keeps running after the last
+// client disconnects, including for the streams already running
+func (s *Server) SetGraceSeconds(seconds int) {
+	if seconds <= 0 {
+		seconds = 10
+	}
+	s.pcmMu.Lock()
+	s.opusMu.Lock()
+	s.qualityMu.Lock()
+	defer s.pcmMu.Unlock()
+	defer s.opusMu.Unlock()
+	defer s.qualityMu.Unlock()
+
+	s.graceSeconds = seconds
+	s.streamManager.setGraceSeconds(seconds)
+	s.pcmStreamManager.setGraceSeconds(seconds)
+	for _, m := range s.pcmManagers {
+		m.setGraceSeconds(seconds)
+	}
+	for _, m := range s.opusManagers {
+		m.setGraceSeconds(seconds)
+	}
+	for _, m := range s.qualityManagers {
+		m.setGraceSeconds(seconds)
+	}
+}
+
+// setGraceSeconds changes the grace period of new and running streams
+func (sm *StreamManager) setGraceSeconds(seconds int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.graceSeconds = seconds
+	for _, stream := range sm.streams {
+		stream.mu.Lock()
+		stream.graceSeconds = seconds
+		stream.mu.Unlock()
+	}
+}
+
+// LogReloaded logs the settings in effect after a configuration reload
+func (s *Server) LogReloaded() {
+	s.opusMu.Lock()
+	grace := s.graceSeconds
+	s.opusMu.Unlock()
+	s.limits.mu.Lock()
+	maxClients, maxPerIP, maxPerStation := s.limits.max, s.limits.maxPerIP, s.limits.maxPerStation
+	s.limits.mu.Unlock()
+	rules := s.accessRules()
+
+	auth := "無効"
+	if s.authEnabled() {
+		auth = "有効"
+	}
+
+	log.Printf("🔄 設定を再読み込みしました")
+	log.Printf("   ffmpeg保持時間: %d秒 / 接続上限: %d (IPごと %d)", grace, maxClients, maxPerIP)
+	if len(maxPerStation) > 0 {
+		log.Printf("   放送局ごとの接続上限: %v", maxPerStation)
+	}
+	log.Printf("   🔒 認証: %s / ⛔ IP制限: 許可 %v / 拒否 %v", auth, rules.allow, rules.deny)
+	log.Printf("   ログレベル: %s / 遅いクライアント: %v", logLevel(), s.slowClients)
+}
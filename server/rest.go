@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// stationJSON is a station in the REST API
+type stationJSON struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	AreaID   string `json:"area_id"`
+	Logo     string `json:"logo"`
+	Banner   string `json:"banner,omitempty"`
+	Website  string `json:"website,omitempty"`
+	AreaFree bool   `json:"areafree"` // Can be heard outside its area with radiko premium
+	TimeFree bool   `json:"timefree"` // Past programs can be played
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("❌ JSONの書き込みに失敗しました: %v", err)
+	}
+}
+
+// requestAreas returns the areas requested with ?area=JP13,JP27, or the
+// configured ones
+func (s *Server) requestAreas(r *http.Request) []string {
+	areas := s.areas
+	if v := r.URL.Query().Get("area"); v != "" {
+		areas = nil
+		for _, areaID := range strings.Split(v, ",") {
+			if areaID = strings.TrimSpace(areaID); areaID != "" {
+				areas = append(areas, areaID)
+			}
+		}
+	}
+	if len(areas) == 0 {
+		areas = []string{"JP13"}
+	}
+	return areas
+}
+
+// handleStations returns the stations of the requested areas
+// (?area=JP13,JP27, default the configured ones)
+func (s *Server) handleStations(w http.ResponseWriter, r *http.Request) {
+	areas := s.requestAreas(r)
+
+	stations := []stationJSON{}
+	for _, areaID := range areas {
+		if model.FindAreaByID(areaID) == nil {
+			http.Error(w, "unknown area: "+areaID, http.StatusBadRequest)
+			return
+		}
+		list, err := api.GetStations(r.Context(), areaID)
+		if err != nil {
+			log.Printf("❌ 放送局リストの取得に失敗しました: %v", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		for _, station := range list {
+			stations = append(stations, stationJSON{
+				ID:       station.ID,
+				Name:     station.Name,
+				AreaID:   areaID,
+				Logo:     api.GetStationLogoURL(station.ID),
+				Banner:   station.Banner,
+				Website:  station.Website,
+				AreaFree: station.AreaFree,
+				TimeFree: station.TimeFree,
+			})
+		}
+	}
+	writeJSON(w, stations)
+}
+
+// handlePrograms returns a station's programs for a broadcast day
+// (?date=YYYYMMDD, default today; days start at 5:00 JST)
+func (s *Server) handlePrograms(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("stationID")
+	date := model.BroadcastDay(time.Now())
+	if v := r.URL.Query().Get("date"); v != "" {
+		d, err := time.ParseInLocation("20060102", v, model.JST)
+		if err != nil {
+			http.Error(w, "date must be YYYYMMDD", http.StatusBadRequest)
+			return
+		}
+		date = d
+	}
+
+	entry, err := epg.programs(r.Context(), stationID, date)
+	if err != nil {
+		log.Printf("❌ 番組表の取得に失敗しました [%s]: %v", stationID, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	progs := entry.programs
+	if progs == nil {
+		progs = []model.Program{}
+	}
+	writeJSON(w, progs)
+}
+
+// handleCurrentProgram returns the program on air
+func (s *Server) handleCurrentProgram(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("stationID")
+	prog, err := epg.current(r.Context(), stationID)
+	if err != nil {
+		log.Printf("❌ 番組情報の取得に失敗しました [%s]: %v", stationID, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if prog == nil {
+		http.Error(w, "no program on air", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, prog)
+}
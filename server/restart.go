@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+)
+
+const (
+	ffmpegRestartDelay    = time.Second      // Wait before the first restart, doubled after each failure
+	ffmpegMaxRestartDelay = 30 * time.Second // Longest wait between restarts
+	ffmpegMaxRestarts     = 6                // Restarts in a row before the clients are disconnected
+	ffmpegStableTime      = time.Minute      // Running this long resets the backoff
+)
+
+// errStreamStopped is returned when ffmpeg is started after Stop
+var errStreamStopped = errors.New("stream stopped")
+
+// ffmpegRestart restarts a station's ffmpeg that exited while clients were
+// listening, with exponential backoff. The stream is re-resolved each time
+// with a fresh auth token, since an expired token is a common cause.
+type ffmpegRestart struct {
+	stationID string
+	areaID    string                                               // Area to authenticate in
+	label     string                                               // Format in the logs and events
+	quality   string                                               // HLS variant to resolve
+	streamURL string                                               // Stream URL ffmpeg plays
+	quit      <-chan struct{}                                      // Closed when the stream is stopped
+	listening func() bool                                          // Reports whether clients are connected
+	renewed   func() string                                        // Takes a renewed auth token ("" = none)
+	start     func(streamURL, authToken string) (io.Reader, error) // Starts ffmpeg
+	failed    func(err error)                                      // Records why ffmpeg exited or failed to restart
+	failures  int                                                  // Restarts since ffmpeg last ran stably
+}
+
+// quitContext returns a context canceled when quit is closed
+func quitContext(quit <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// run restarts ffmpeg after it exited with exitErr, returning its output,
+// or nil when the stream should end instead
+func (r *ffmpegRestart) run(exitErr error, ranFor time.Duration) io.Reader {
+	if ranFor >= ffmpegStableTime {
+		r.failures = 0
+	}
+	if exitErr == nil {
+		exitErr = errors.New("ffmpeg exited")
+	}
+
+	// ffmpeg was stopped to switch to a renewed token
+	if token := r.renewed(); token != "" {
+		log.Printf("🔑 新しい認証トークンでffmpegを再起動します [%s %s]", r.stationID, r.label)
+		stdout, err := r.start(r.streamURL, token)
+		if err == nil {
+			return stdout
+		}
+		exitErr = err
+	}
+
+	for {
+		select {
+		case <-r.quit:
+			return nil
+		default:
+		}
+		if !r.listening() {
+			return nil
+		}
+		r.failed(exitErr)
+		if r.failures >= ffmpegMaxRestarts {
+			log.Printf("❌ ffmpegの再起動を中止しました [%s %s]: %v", r.stationID, r.label, exitErr)
+			events.publish(Event{Type: EventError, StationID: r.stationID, Format: r.label, Error: exitErr.Error()})
+			return nil
+		}
+
+		delay := min(ffmpegRestartDelay<<r.failures, ffmpegMaxRestartDelay)
+		r.failures++
+		log.Printf("🔁 ffmpegが終了しました [%s %s]: %v (%v後に再起動 %d/%d)", r.stationID, r.label, exitErr, delay, r.failures, ffmpegMaxRestarts)
+		select {
+		case <-r.quit:
+			return nil
+		case <-time.After(delay):
+		}
+
+		api.InvalidateToken(r.areaID)
+		ctx, cancel := quitContext(r.quit)
+		_, authToken, streamURL, err := resolveLiveStream(ctx, r.stationID, r.areaID, r.quality)
+		cancel()
+		if err == nil {
+			r.streamURL = streamURL
+			var stdout io.Reader
+			if stdout, err = r.start(streamURL, authToken); err == nil {
+				return stdout
+			}
+		}
+		exitErr = err
+	}
+}
+
+// supervise feeds ffmpeg's output to the clients and restarts ffmpeg when it
+// exits while clients are listening. When the stream ends for good, the
+// clients are disconnected so that they can reconnect.
+func (ss *StationPipeline) supervise(stdout io.Reader, areaID, streamURL string) {
+	restart := &ffmpegRestart{
+		stationID: ss.stationID,
+		areaID:    areaID,
+		label:     ss.format.label,
+		quality:   ss.format.quality,
+		streamURL: streamURL,
+		quit:      ss.quit,
+		listening: func() bool {
+			ss.mu.RLock()
+			defer ss.mu.RUnlock()
+			return len(ss.clients) > 0
+		},
+		renewed: func() string {
+			ss.mu.Lock()
+			defer ss.mu.Unlock()
+			token := ss.newToken
+			ss.newToken = ""
+			return token
+		},
+		start:  ss.startFFmpeg,
+		failed: func(err error) { ss.info.setError(err.Error()) },
+	}
+	unsubscribe := api.SubscribeToken(areaID, ss.refreshToken)
+	for stdout != nil {
+		started := time.Now()
+		ss.readAndBroadcast(stdout)
+		ss.mu.RLock()
+		cmd := ss.cmd
+		ss.mu.RUnlock()
+		err := cmd.Wait()
+		stdout = restart.run(err, time.Since(started))
+	}
+	unsubscribe()
+
+	ss.mu.Lock()
+	ss.running = false
+	for _, c := range ss.clients {
+		c.close()
+	}
+	ss.mu.Unlock()
+
+	ss.ring.close()
+	close(ss.stopped)
+	log.Printf("⏹ ffmpeg終了: %s (%s)", ss.stationID, ss.format.label)
+	events.publish(Event{Type: EventStreamStopped, StationID: ss.stationID, Format: ss.format.label})
+}
+
+// refreshToken restarts ffmpeg with a renewed auth token of the station's area
+func (ss *StationPipeline) refreshToken(token string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if !ss.running || ss.cancel == nil || token == ss.authToken {
+		return
+	}
+	ss.newToken = token
+	ss.cancel()
+}
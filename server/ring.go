@@ -0,0 +1,121 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// ringBuffer keeps the latest chunks of a station's output. Every client
+// reads from it at its own cursor, so a client that can't keep up only
+// falls behind (and eventually skips) on its own instead of holding up or
+// dropping data for the other clients.
+type ringBuffer struct {
+	mu     sync.Mutex
+	chunks []ringChunk
+	next   uint64        // Sequence number of the next chunk written
+	wake   chan struct{} // Closed and replaced when a chunk is written
+	closed bool
+}
+
+// ringChunk is a piece of ffmpeg's output
+type ringChunk struct {
+	data []byte
+	at   time.Time // When it was written, to tell how far behind a reader is
+}
+
+// newRingBuffer creates a ring buffer keeping the last size chunks
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{
+		chunks: make([]ringChunk, size),
+		wake:   make(chan struct{}),
+	}
+}
+
+// write appends a chunk, overwriting the oldest one when the buffer is full
+func (rb *ringBuffer) write(chunk []byte) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.closed {
+		return
+	}
+	rb.chunks[rb.next%uint64(len(rb.chunks))] = ringChunk{data: chunk, at: time.Now()}
+	rb.next++
+	close(rb.wake)
+	rb.wake = make(chan struct{})
+}
+
+// close ends the buffer; readers get the remaining chunks and then stop
+func (rb *ringBuffer) close() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if !rb.closed {
+		rb.closed = true
+		close(rb.wake)
+	}
+}
+
+// cursor returns the cursor of a reader starting with the next chunk
+func (rb *ringBuffer) cursor() uint64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.next
+}
+
+// cursorSince returns the cursor of a reader starting with the oldest chunk
+// kept that was written at or after t
+func (rb *ringBuffer) cursorSince(t time.Time) uint64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	size := uint64(len(rb.chunks))
+	cursor := rb.next
+	for cursor > rb.oldest() && !rb.chunks[(cursor-1)%size].at.Before(t) {
+		cursor--
+	}
+	return cursor
+}
+
+// read returns the chunk at cursor, waiting for it to be written, and the
+// cursor of the chunk after it. A cursor that fell behind the oldest chunk
+// kept moves up to it, and skipped reports how many chunks were lost. ok is
+// false when the buffer was closed or done was closed.
+func (rb *ringBuffer) read(cursor uint64, done <-chan struct{}) (chunk ringChunk, next, skipped uint64, ok bool) {
+	for {
+		select {
+		case <-done:
+			return ringChunk{}, cursor, skipped, false
+		default:
+		}
+
+		rb.mu.Lock()
+		if cursor < rb.next {
+			if oldest := rb.oldest(); cursor < oldest {
+				skipped = oldest - cursor
+				cursor = oldest
+			}
+			chunk = rb.chunks[cursor%uint64(len(rb.chunks))]
+			rb.mu.Unlock()
+			return chunk, cursor + 1, skipped, true
+		}
+		if rb.closed {
+			rb.mu.Unlock()
+			return ringChunk{}, cursor, skipped, false
+		}
+		wake := rb.wake
+		rb.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-done:
+			return ringChunk{}, cursor, skipped, false
+		}
+	}
+}
+
+// oldest returns the sequence number of the oldest chunk kept. rb.mu must
+// be held.
+func (rb *ringBuffer) oldest() uint64 {
+	if size := uint64(len(rb.chunks)); rb.next > size {
+		return rb.next - size
+	}
+	return 0
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRingBufferRead(t *testing.T) {
+	tests := []struct {
+		size, writes int
+		cursor       uint64
+		want         string
+		skipped      uint64
+	}{
+		{4, 3, 0, "0", 0},
+		{4, 3, 2, "2", 0},
+		{4, 4, 0, "0", 0},
+		// Overwritten chunks are skipped up to the oldest kept
+		{4, 6, 0, "2", 2},
+		{4, 6, 1, "2", 1},
+		{4, 6, 5, "5", 0},
+		{1, 3, 0, "2", 2},
+	}
+	for _, tt := range tests {
+		rb := newRingBuffer(tt.size)
+		for i := range tt.writes {
+			rb.write([]byte{'0' + byte(i)})
+		}
+		chunk, next, skipped, ok := rb.read(tt.cursor, nil)
+		if !ok {
+			t.Errorf("size %d, %d writes: read(%d) not ok", tt.size, tt.writes, tt.cursor)
+			continue
+		}
+		if string(chunk.data) != tt.want || skipped != tt.skipped || next != tt.cursor+tt.skipped+1 {
+			t.Errorf("size %d, %d writes: read(%d) = %q, next %d, skipped %d; want %q, skipped %d",
+				tt.size, tt.writes, tt.cursor, chunk.data, next, skipped, tt.want, tt.skipped)
+		}
+	}
+}
+
+func TestRingBufferCursors(t *testing.T) {
+	rb := newRingBuffer(3)
+	fast, slow := rb.cursor(), rb.cursor()
+	for i := range 5 {
+		rb.write([]byte{'0' + byte(i)})
+		chunk, next, skipped, ok := rb.read(fast, nil)
+		if !ok || string(chunk.data) != string(rune('0'+i)) || skipped != 0 {
+			t.Fatalf("fast reader got %q, skipped %d, ok %v at %d", chunk.data, skipped, ok, i)
+		}
+		fast = next
+	}
+
+	// The slow reader loses what was overwritten, the fast one nothing
+	chunk, next, skipped, _ := rb.read(slow, nil)
+	if string(chunk.data) != "2" || skipped != 2 {
+		t.Errorf("slow reader got %q, skipped %d; want \"2\", skipped 2", chunk.data, skipped)
+	}
+	if next != 3 || rb.cursor() != 5 || fast != 5 {
+		t.Errorf("cursors slow %d, fast %d, buffer %d; want 3, 5, 5", next, fast, rb.cursor())
+	}
+}
+
+func TestRingBufferWait(t *testing.T) {
+	rb := newRingBuffer(4)
+	got := make(chan string)
+	go func() {
+		chunk, _, _, _ := rb.read(rb.cursor(), nil)
+		got <- string(chunk.data)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	rb.write([]byte("a"))
+	select {
+	case data := <-got:
+		if data != "a" {
+			t.Errorf("waiting reader got %q, want \"a\"", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader not woken by write")
+	}
+
+	// A closed buffer still hands out what it holds, then ends
+	rb.close()
+	if chunk, _, _, ok := rb.read(0, nil); !ok || string(chunk.data) != "a" {
+		t.Errorf("read after close = %q, %v", chunk.data, ok)
+	}
+	if _, _, _, ok := rb.read(1, nil); ok {
+		t.Error("read past the end of a closed buffer succeeded")
+	}
+
+	done := make(chan struct{})
+	close(done)
+	if _, _, _, ok := newRingBuffer(4).read(0, done); ok {
+		t.Error("read with done closed succeeded")
+	}
+}
+
+func TestRingBufferCursorSince(t *testing.T) {
+	rb := newRingBuffer(8)
+	for range 3 {
+		rb.write([]byte("old"))
+	}
+	time.Sleep(20 * time.Millisecond)
+	since := time.Now()
+	for range 2 {
+		rb.write([]byte("new"))
+	}
+	if c := rb.cursorSince(since); c != 3 {
+		t.Errorf("cursorSince = %d, want 3", c)
+	}
+	if c := rb.cursorSince(time.Now().Add(time.Hour)); c != 5 {
+		t.Errorf("cursorSince in the future = %d, want 5 (live)", c)
+	}
+	if c := rb.cursorSince(time.Time{}); c != 0 {
+		t.Errorf("cursorSince the beginning = %d, want 0", c)
+	}
+}
+
+func TestSlowClientCheck(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		policy                string
+		age                   time.Duration // How long ago the chunk read was written
+		skipped               uint64
+		wantSkip, wantDisconn bool
+	}{
+		{SlowClientBuffer, 0, 0, false, false},
+		{SlowClientBuffer, 5 * time.Second, 0, false, false},
+		{SlowClientBuffer, 20 * time.Second, 0, true, false},
+		{SlowClientBuffer, 0, 3, true, false},
+		{SlowClientDrop, 2 * time.Second, 0, true, false},
+		{SlowClientDrop, 500 * time.Millisecond, 0, false, false},
+		{SlowClientDisconnect, 20 * time.Second, 0, false, true},
+		{SlowClientDisconnect, 0, 1, false, true},
+	}
+	for _, tt := range tests {
+		s := NewServer(0, 1)
+		if err := s.SetSlowClientPolicy(tt.policy, 10); err != nil {
+			t.Fatal(err)
+		}
+		skip, disconnect := s.slowClients.check(now.Add(-tt.age), tt.skipped, 0)
+		if skip != tt.wantSkip || disconnect != tt.wantDisconn {
+			t.Errorf("%s, %v behind, %d skipped: skip %v, disconnect %v; want %v, %v",
+				tt.policy, tt.age, tt.skipped, skip, disconnect, tt.wantSkip, tt.wantDisconn)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/config"
+	"github.com/kanoshiou/radiko-tui/model"
+	"github.com/kanoshiou/radiko-tui/recorder"
+)
+
+// maxUpcomingHours bounds ?hours= of the upcoming recordings
+const maxUpcomingHours = 7 * 24
+
+// upcomingJSON is a recording the scheduler will start
+type upcomingJSON struct {
+	ID        string    `json:"id"`
+	StationID string    `json:"station_id"`
+	Name      string    `json:"name,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
+
+// scheduleJSON is a cron or weekly schedule
+type scheduleJSON struct {
+	config.Schedule
+	Enabled bool `json:"enabled"`
+}
+
+// programScheduleRequest asks to record one EPG program
+type programScheduleRequest struct {
+	StationID string `json:"station_id"`
+	Ft        string `json:"ft"`               // Program start YYYYMMDDHHMMSS
+	Name      string `json:"name,omitempty"`   // Label used in the filename (default the station ID)
+	Format    string `json:"format,omitempty"` // Output format (default record_format)
+}
+
+// SetScheduler exposes the recording scheduler at /api/schedules
+func (s *Server) SetScheduler(sched *recorder.Scheduler) {
+	s.scheduler = sched
+}
+
+// schedulerReady rejects requests when no scheduler is set
+func (s *Server) schedulerReady(w http.ResponseWriter) bool {
+	if s.scheduler == nil {
+		http.Error(w, "scheduler not available", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// handleSchedules lists the cron and weekly schedules
+func (s *Server) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	if !s.schedulerReady(w) {
+		return
+	}
+	list := []scheduleJSON{}
+	for _, sched := range s.scheduler.Schedules() {
+		list = append(list, scheduleJSON{Schedule: sched, Enabled: !sched.Disabled})
+	}
+	writeJSON(w, list)
+}
+
+// handleCreateSchedule adds a cron or weekly schedule (a config.json
+// schedule as the body) and saves it to config.json
+func (s *Server) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	if !s.schedulerReady(w) {
+		return
+	}
+	var sched config.Schedule
+	if err := json.NewDecoder(r.Body).Decode(&sched); err != nil {
+		http.Error(w, "invalid schedule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sched.Disabled = false
+
+	sched, err := s.scheduler.AddSchedule(sched)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := saveSchedules(func(list []config.Schedule) []config.Schedule {
+		return append(list, sched)
+	}); err != nil {
+		log.Printf("⚠️ 予約の保存に失敗しました: %v", err)
+	}
+
+	log.Printf("📅 予約追加 [%s]: %s", sched.ID, sched.StationID)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, sched)
+}
+
+// handleScheduleProgram records one EPG program, given its station and start time
+func (s *Server) handleScheduleProgram(w http.ResponseWriter, r *http.Request) {
+	if !s.schedulerReady(w) {
+		return
+	}
+	var req programScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ft, err := time.ParseInLocation("20060102150405", req.Ft, model.JST)
+	if req.StationID == "" || err != nil {
+		http.Error(w, "station_id and ft (YYYYMMDDHHMMSS) are required", http.StatusBadRequest)
+		return
+	}
+	var format recorder.Format // Empty uses record_format
+	if req.Format != "" {
+		if format, err = recorder.ParseFormat(req.Format); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	entry, err := epg.programs(r.Context(), req.StationID, model.BroadcastDay(ft))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	progs := entry.programs
+	i := slices.IndexFunc(progs, func(p model.Program) bool { return p.Ft == req.Ft })
+	if i < 0 {
+		http.Error(w, "program not found", http.StatusNotFound)
+		return
+	}
+	name := req.Name
+	if name == "" {
+		name = req.StationID
+	}
+	if err := s.scheduler.ScheduleProgram(req.StationID, name, progs[i], format); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	log.Printf("📅 番組予約 [%s]: %s %s", req.StationID, req.Ft, progs[i].Title)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, progs[i])
+}
+
+// handleDeleteSchedule removes a schedule or a pending program recording
+func (s *Server) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	if !s.schedulerReady(w) {
+		return
+	}
+	id := r.PathValue("id")
+	if !s.scheduler.RemoveSchedule(id) {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+		return
+	}
+	if err := saveSchedules(func(list []config.Schedule) []config.Schedule {
+		return slices.DeleteFunc(list, func(sched config.Schedule) bool { return sched.ID == id })
+	}); err != nil {
+		log.Printf("⚠️ 予約の保存に失敗しました: %v", err)
+	}
+
+	log.Printf("🗑️ 予約削除 [%s]", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUpcoming lists the recordings starting within ?hours= (default 24)
+func (s *Server) handleUpcoming(w http.ResponseWriter, r *http.Request) {
+	if !s.schedulerReady(w) {
+		return
+	}
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxUpcomingHours {
+			http.Error(w, "hours must be 1-168", http.StatusBadRequest)
+			return
+		}
+		hours = n
+	}
+
+	list := []upcomingJSON{}
+	for _, u := range s.scheduler.Upcoming(time.Now().Add(time.Duration(hours) * time.Hour)) {
+		list = append(list, upcomingJSON{
+			ID:        u.Schedule.ID,
+			StationID: u.Schedule.StationID,
+			Name:      u.Schedule.Name,
+			Title:     u.Title,
+			Start:     u.Start,
+			End:       u.End,
+		})
+	}
+	writeJSON(w, list)
+}
+
+// saveSchedules updates the schedules in config.json, keeping the other settings
+func saveSchedules(update func([]config.Schedule) []config.Schedule) error {
+	_, err := config.Update(func(cfg *config.Config) {
+		cfg.Schedules = update(cfg.Schedules)
+	})
+	return err
+}
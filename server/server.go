@@ -3,51 +3,85 @@ package server
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
+	"net/netip"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"radiko-tui/api"
-	"radiko-tui/model"
+	"github.com/kanoshiou/radiko-tui/recorder"
 )
 
+// SetTrustedProxies sets the reverse proxies (CIDR ranges or single
+// addresses) whose CF-Connecting-IP, X-Real-IP and X-Forwarded-For headers
+// name the client. Requests from any other peer are identified by their
+// RemoteAddr. It can be called while the server is running.
+func (s *Server) SetTrustedProxies(proxies []string) error {
+	prefixes, err := parsePrefixes(proxies)
+	if err != nil {
+		return err
+	}
+	s.trustedProxies.Store(&prefixes)
+	return nil
+}
+
+// trustedProxy reports whether ip is one of the trusted proxies
+func (s *Server) trustedProxy(ip string) bool {
+	prefixes := s.trustedProxies.Load()
+	return prefixes != nil && prefixesContain(*prefixes, ip)
+}
+
 // getRealIP extracts the real client IP from the request.
-// It checks headers in the following priority order:
+// Headers are only believed when RemoteAddr is a trusted proxy, in this
+// priority order:
 // 1. CF-Connecting-IP (Cloudflare)
 // 2. X-Real-IP (nginx)
-// 3. X-Forwarded-For (standard proxy, first IP in the list)
-// 4. RemoteAddr (fallback)
-func getRealIP(r *http.Request) string {
+// 3. X-Forwarded-For (the last address not added by a trusted proxy)
+// Otherwise, or without such headers, RemoteAddr is used.
+func (s *Server) getRealIP(r *http.Request) string {
+	// Strip the port of RemoteAddr if present
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr // Use as-is if parsing fails
+	}
+	if !s.trustedProxy(ip) {
+		return ip
+	}
+
 	// Cloudflare: CF-Connecting-IP is the most reliable when using Cloudflare
 	if cfIP := r.Header.Get("CF-Connecting-IP"); cfIP != "" {
-		return cfIP
+		return strings.TrimSpace(cfIP)
 	}
 
 	// nginx: X-Real-IP is typically set by nginx
 	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-		return realIP
+		return strings.TrimSpace(realIP)
 	}
 
-	// Standard proxy: X-Forwarded-For can contain multiple IPs (client, proxy1, proxy2, ...)
-	// The first IP is the original client
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+	// Standard proxy: X-Forwarded-For lists client, proxy1, proxy2, ... and
+	// each proxy appends its peer, so the entries before the last untrusted
+	// one may have been sent by the client itself
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		ips := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(ips) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(ips[i])
+			if hop == "" {
+				continue
+			}
+			ip = hop
+			if !s.trustedProxy(hop) {
+				break
+			}
 		}
 	}
-
-	// Fallback: use RemoteAddr (strip port if present)
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr // Return as-is if parsing fails
-	}
 	return ip
 }
 
@@ -55,8 +89,64 @@ func getRealIP(r *http.Request) string {
 type Server struct {
 	port             int
 	streamManager    *StreamManager
-	pcmStreamManager *PCMStreamManager
-	graceSeconds     int // Grace period before killing ffmpeg after last client disconnects
+	pcmStreamManager *StreamManager
+	graceSeconds     int      // Grace period before killing ffmpeg after last client disconnects
+	podcast          bool     // Serve recordings as a podcast feed
+	areas            []string // Areas listed in the playlists
+	certFile         string   // TLS certificate (optional)
+	keyFile          string   // TLS private key
+	autocertHosts    []string // Hostnames to get Let's Encrypt certificates for (optional)
+	autocertCache    string   // Directory caching Let's Encrypt certificates
+	corsOrigins      []string // Origins of browser frontends allowed to call the server
+	corsMethods      []string // Methods they may use
+	dlnaName         string   // Friendly name of the DLNA media server (optional)
+	dlnaUUID         string   // Unique device name of the DLNA media server, also used by -announce
+	announce         bool     // Announce the server via mDNS and SSDP
+
+	scheduler *recorder.Scheduler // Recording scheduler managed at /api/schedules (optional)
+	accessLog *accessLog          // Per-request log (optional)
+	basePath  string              // Path prefix of every route, e.g. "/radiko" (optional)
+
+	srvMu        sync.Mutex
+	srv          *http.Server
+	baseCtx      context.Context // Parent of every request context, canceled on shutdown
+	cancelBase   context.CancelFunc
+	shutdownDone chan struct{}
+	startedAt    time.Time     // For the uptime in /api/status
+	ssdpDone     chan struct{} // Closed once the DLNA server said goodbye
+	rtspDone     chan struct{} // Closed once the RTSP server stopped
+	mdnsDone     chan struct{} // Closed once the mDNS responder said goodbye
+
+	auth      atomic.Pointer[authConfig]     // Credentials required on every endpoint (optional)
+	access    atomic.Pointer[accessRules]    // Client IP rules (optional)
+	dlnaAllow atomic.Pointer[[]netip.Prefix] // Networks whose DLNA renderers skip auth (optional)
+	limits    clientLimits
+
+	trustedProxies atomic.Pointer[[]netip.Prefix] // Peers whose client IP headers are believed
+	slowClients    *slowClientPolicy              // Shared with the stream managers
+
+	castMu sync.Mutex
+	casts  map[string]castSession // Stations being cast, by device name
+
+	airplayMu sync.Mutex
+	airplays  map[string]*airplayOutput // Stations sent to AirPlay speakers, by device name
+
+	multicastMu sync.Mutex
+	multicasts  map[string]*multicastOutput // Stations sent to multicast groups, by group
+
+	rtspPort    int // RTSP port (0 = disabled)
+	rtspMu      sync.Mutex
+	rtspHeaders map[string]adtsHeader // ADTS header of each station's AAC stream, for the SDP
+
+	pcmMu       sync.Mutex
+	pcmManagers map[pcmFormat]*StreamManager // PCM streams of other formats than 48kHz stereo
+
+	opusMu       sync.Mutex
+	opusManagers map[int]*StreamManager // Opus streams per bitrate (kbps)
+	opusBitrate  int                    // Default Opus bitrate (kbps)
+
+	qualityMu       sync.Mutex
+	qualityManagers map[string]*StreamManager // AAC streams of a chosen HLS variant, by quality
 }
 
 // NewServer creates a new streaming server
@@ -64,41 +154,172 @@ func NewServer(port int, graceSeconds int) *Server {
 	if graceSeconds <= 0 {
 		graceSeconds = 10 // Default 10 seconds grace period
 	}
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	slowClients := newSlowClientPolicy()
 	return &Server{
 		port:             port,
-		streamManager:    NewStreamManager(graceSeconds),
-		pcmStreamManager: NewPCMStreamManager(graceSeconds),
+		baseCtx:          baseCtx,
+		cancelBase:       cancelBase,
+		shutdownDone:     make(chan struct{}),
+		startedAt:        time.Now(),
+		slowClients:      slowClients,
+		streamManager:    NewStreamManager(graceSeconds, slowClients),
+		pcmStreamManager: NewPCMStreamManager(graceSeconds, defaultPCMFormat, slowClients),
 		graceSeconds:     graceSeconds,
+		pcmManagers:      make(map[pcmFormat]*StreamManager),
+		opusManagers:     make(map[int]*StreamManager),
+		qualityManagers:  make(map[string]*StreamManager),
+		casts:            make(map[string]castSession),
+		airplays:         make(map[string]*airplayOutput),
+		multicasts:       make(map[string]*multicastOutput),
+		rtspHeaders:      make(map[string]adtsHeader),
+		opusBitrate:      DefaultOpusBitrate,
 	}
 }
 
-// Start starts the HTTP server
-func (s *Server) Start() error {
+// handler returns the server's endpoints behind its logging, access rules,
+// CORS and authentication
+func (s *Server) handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/play/{stationID}", s.handlePlayRequest)
 	mux.HandleFunc("/api/play/{stationID}/pcm", s.handlePCMPlayRequest)
+	mux.HandleFunc("/api/play/{stationID}/opus", s.handleOpusPlayRequest)
+	mux.HandleFunc("GET /api/hls/{stationID}/playlist.m3u8", s.handleHLSPlaylist)
+	mux.HandleFunc("GET /api/hls/{stationID}/proxy", s.handleHLSProxy)
+	mux.HandleFunc("GET /api/timefree/{stationID}", s.handleTimefree)
 	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	mux.HandleFunc("DELETE /api/streams/{stationID}", s.handleStopStream)
+	mux.HandleFunc("GET /api/recordings", s.handleRecordings)
+	mux.HandleFunc("GET /api/stations", s.handleStations)
+	mux.HandleFunc("GET /api/servers", s.handleServers)
+	mux.HandleFunc("GET /api/programs/{stationID}", s.handlePrograms)
+	mux.HandleFunc("GET /api/programs/{stationID}/now", s.handleCurrentProgram)
+	mux.HandleFunc("GET /api/epg/{stationID}", s.handleEPG)
+	mux.HandleFunc("GET /api/schedules", s.handleSchedules)
+	mux.HandleFunc("POST /api/schedules", s.handleCreateSchedule)
+	mux.HandleFunc("POST /api/schedules/program", s.handleScheduleProgram)
+	mux.HandleFunc("GET /api/schedules/upcoming", s.handleUpcoming)
+	mux.HandleFunc("DELETE /api/schedules/{id}", s.handleDeleteSchedule)
+	mux.HandleFunc("GET /api/cast/devices", s.handleCastDevices)
+	mux.HandleFunc("POST /api/cast/stop", s.handleCastStop)
+	mux.HandleFunc("POST /api/cast/volume", s.handleCastVolume)
+	mux.HandleFunc("POST /api/cast/{stationID}", s.handleCast)
+	mux.HandleFunc("GET /api/airplay/devices", s.handleAirPlayDevices)
+	mux.HandleFunc("POST /api/airplay/stop", s.handleAirPlayStop)
+	mux.HandleFunc("POST /api/airplay/volume", s.handleAirPlayVolume)
+	mux.HandleFunc("POST /api/airplay/{stationID}", s.handleAirPlay)
+	mux.HandleFunc("GET /api/multicast", s.handleMulticasts)
+	mux.HandleFunc("POST /api/multicast/stop", s.handleMulticastStop)
+	mux.HandleFunc("POST /api/multicast/{stationID}", s.handleMulticast)
+	mux.HandleFunc("GET /{$}", s.handleDashboard)
+	mux.HandleFunc("GET /playlist.m3u", s.handlePlaylistM3U)
+	mux.HandleFunc("GET /playlist.pls", s.handlePlaylistPLS)
+	if s.podcast {
+		mux.HandleFunc("GET /podcast.xml", s.handlePodcastFeed)
+		mux.HandleFunc("GET /recordings/{id}", s.handleRecording)
+	}
+	if s.dlnaEnabled() {
+		mux.HandleFunc("GET /dlna/description.xml", s.handleDLNADescription)
+		mux.HandleFunc("GET /dlna/ContentDirectory.xml", s.handleContentDirectorySCPD)
+		mux.HandleFunc("GET /dlna/ConnectionManager.xml", s.handleConnectionManagerSCPD)
+		mux.HandleFunc("POST /dlna/control/ContentDirectory", s.handleContentDirectory)
+		mux.HandleFunc("POST /dlna/control/ConnectionManager", s.handleConnectionManager)
+		mux.HandleFunc("/dlna/event/", s.handleDLNAEvent)
+	}
+	return s.logAccess(s.stripBasePath(s.restrictAccess(s.handleCORS(s.requireAuth(mux)))))
+}
 
+// Start starts the HTTP server
+func (s *Server) Start() error {
 	addr := fmt.Sprintf(":%d", s.port)
-	log.Printf("📡 サーバーを開始しました: http://localhost%s", addr)
-	log.Printf("   AAC: vlc http://localhost%s/api/play/QRR", addr)
-	log.Printf("   PCM: radiko-tui --server-url http://localhost%s", addr)
+	base := s.publicURL()
+	log.Printf("📡 サーバーを開始しました: %s", base)
+	log.Printf("   Web UI: %s/", base)
+	log.Printf("   AAC: vlc %s/api/play/QRR", base)
+	log.Printf("   PCM: radiko-tui --server-url %s", base)
+	log.Printf("   Opus: vlc %s/api/play/QRR/opus (%dkbps)", base, s.opusBitrate)
+	log.Printf("   HLS: %s/api/hls/QRR/playlist.m3u8 (ffmpegなし)", base)
+	log.Printf("   プレイリスト: %s/playlist.m3u", base)
 	log.Printf("   ffmpeg保持時間: %d秒", s.graceSeconds)
+	if s.podcast {
+		log.Printf("   Podcast: %s/podcast.xml", base)
+	}
+	if s.dlnaEnabled() {
+		log.Printf("   📺 DLNA: %s", s.dlnaName)
+	}
+	if s.announce {
+		log.Printf("   🔎 LANに告知: %s (%s)", s.lanName(), DiscoveryService)
+	}
+	if s.rtspPort > 0 {
+		log.Printf("   🎥 RTSP: vlc rtsp://localhost:%d/QRR", s.rtspPort)
+	}
+	if s.authEnabled() {
+		log.Printf("   🔒 認証: 有効")
+	}
+	if rules := s.accessRules(); len(rules.allow) > 0 || len(rules.deny) > 0 {
+		log.Printf("   ⛔ IP制限: 許可 %v / 拒否 %v", rules.allow, rules.deny)
+	}
+	if len(s.corsOrigins) > 0 {
+		log.Printf("   🌍 CORS: %v (%s)", s.corsOrigins, strings.Join(s.corsMethods, ", "))
+	}
+
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     s.handler(),
+		BaseContext: func(net.Listener) context.Context { return s.baseCtx },
+	}
+	var rtspLn net.Listener
+	if s.rtspPort > 0 {
+		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", s.rtspPort))
+		if err != nil {
+			return fmt.Errorf("RTSP: %w", err)
+		}
+		rtspLn = ln
+	}
+	go stats.saveLoop(s.baseCtx)
+	go renewTokens(s.baseCtx)
+	go loadStationAreas(s.baseCtx)
+	s.srvMu.Lock()
+	s.srv = srv
+	if s.dlnaEnabled() || s.announce {
+		s.ssdpDone = make(chan struct{})
+		go s.serveSSDP(s.baseCtx, s.ssdpDone)
+	}
+	if s.announce {
+		s.mdnsDone = make(chan struct{})
+		go s.serveMDNS(s.baseCtx, s.mdnsDone)
+	}
+	if rtspLn != nil {
+		s.rtspDone = make(chan struct{})
+		go s.serveRTSP(rtspLn, s.rtspDone)
+	}
+	s.srvMu.Unlock()
 
-	return http.ListenAndServe(addr, mux)
+	err := s.listen(srv)
+	if errors.Is(err, http.ErrServerClosed) {
+		<-s.shutdownDone
+		return nil
+	}
+	return err
 }
 
-// handleStatus returns the current stream status
+// handleStatus returns the status of the streams of every format and their clients
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	status := s.streamManager.GetStatus()
-	w.Write([]byte(status))
+	streams := s.status()
+	writeJSON(w, struct {
+		StartedAt     time.Time      `json:"started_at"`
+		UptimeSeconds int64          `json:"uptime_seconds"`
+		Streams       []StreamStatus `json:"streams"`
+		Stats         Stats          `json:"stats"`
+	}{s.startedAt, int64(time.Since(s.startedAt).Seconds()), streams, stats.snapshot(streams)})
 }
 
 // handlePlayRequest routes different HTTP methods
 func (s *Server) handlePlayRequest(w http.ResponseWriter, r *http.Request) {
 	stationID := r.PathValue("stationID")
-	clientIP := getRealIP(r)
+	clientIP := s.getRealIP(r)
 	log.Printf("📥 リクエスト: %s %s (from %s)", r.Method, r.URL.Path, clientIP)
 
 	switch r.Method {
@@ -130,7 +351,25 @@ func (s *Server) handlePlay(w http.ResponseWriter, r *http.Request, stationID st
 		return
 	}
 
-	clientIP := getRealIP(r)
+	quality, err := requestQuality(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	areaID, err := requestArea(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	manager := s.aacManager(quality)
+
+	clientIP := s.getRealIP(r)
+	release, ok := s.acquireClient(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
 	clientID := fmt.Sprintf("%s-%d", clientIP, time.Now().UnixNano())
 	log.Printf("🎵 クライアント接続: %s → %s", clientID, stationID)
 
@@ -142,10 +381,20 @@ func (s *Server) handlePlay(w http.ResponseWriter, r *http.Request, stationID st
 	w.Header().Set("icy-name", fmt.Sprintf("Radiko - %s", stationID))
 	w.Header().Set("icy-genre", "Radio")
 
+	// Interleave the program title for players that ask for ICY metadata
+	out := http.ResponseWriter(w)
+	if r.Header.Get("Icy-MetaData") == "1" {
+		w.Header().Set("icy-metaint", fmt.Sprint(icyMetaInt))
+		out = newICYWriter(w, func() (string, string) {
+			return manager.Title(stationID)
+		})
+	}
+
 	// Subscribe to stream
-	err := s.streamManager.Subscribe(r.Context(), w, stationID, clientID)
+	err = manager.Subscribe(r.Context(), out, stationID, areaID, clientID, clientIP)
 	if err != nil {
 		log.Printf("❌ ストリームエラー [%s]: %v", clientID, err)
+		events.publish(Event{Type: EventError, StationID: stationID, Format: manager.format.label, ClientID: clientID, IP: clientIP, Error: err.Error()})
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -156,35 +405,64 @@ func (s *Server) handlePlay(w http.ResponseWriter, r *http.Request, stationID st
 // handlePCMPlayRequest handles PCM format streaming requests
 func (s *Server) handlePCMPlayRequest(w http.ResponseWriter, r *http.Request) {
 	stationID := r.PathValue("stationID")
-	clientIP := getRealIP(r)
+	clientIP := s.getRealIP(r)
 	log.Printf("📥 PCMリクエスト: %s %s (from %s)", r.Method, r.URL.Path, clientIP)
 
-	if r.Method != http.MethodGet {
+	switch r.Method {
+	case http.MethodHead:
+		format, err := requestPCMFormat(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		setPCMHeaders(w, format)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		s.handlePCMPlay(w, r, stationID)
+	case http.MethodOptions:
+		w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		w.WriteHeader(http.StatusOK)
+	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
 	}
+}
 
+// handlePCMPlay handles GET requests - stream PCM audio
+func (s *Server) handlePCMPlay(w http.ResponseWriter, r *http.Request, stationID string) {
 	if stationID == "" {
 		http.Error(w, "stationID is required", http.StatusBadRequest)
 		return
 	}
+	areaID, err := requestArea(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := requestPCMFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	clientIP := s.getRealIP(r)
+	release, ok := s.acquireClient(w, r)
+	if !ok {
+		return
+	}
+	defer release()
 
 	clientID := fmt.Sprintf("%s-%d", clientIP, time.Now().UnixNano())
-	log.Printf("🎵 PCMクライアント接続: %s → %s", clientID, stationID)
+	log.Printf("🎵 PCMクライアント接続: %s → %s (%dHz/%dch)", clientID, stationID, format.rate, format.channels)
 
 	// Set headers for PCM streaming
-	w.Header().Set("Content-Type", "audio/L16;rate=48000;channels=2")
-	w.Header().Set("Cache-Control", "no-cache")
+	setPCMHeaders(w, format)
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Accept-Ranges", "none")
-	w.Header().Set("X-Audio-Format", "s16le")
-	w.Header().Set("X-Sample-Rate", "48000")
-	w.Header().Set("X-Channels", "2")
 
 	// Subscribe to PCM stream
-	err := s.pcmStreamManager.Subscribe(r.Context(), w, stationID, clientID)
+	err = s.pcmManager(format).Subscribe(r.Context(), w, stationID, areaID, clientID, clientIP)
 	if err != nil {
 		log.Printf("❌ PCMストリームエラー [%s]: %v", clientID, err)
+		events.publish(Event{Type: EventError, StationID: stationID, Format: format.label(), ClientID: clientID, IP: clientIP, Error: err.Error()})
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -193,103 +471,201 @@ func (s *Server) handlePCMPlayRequest(w http.ResponseWriter, r *http.Request) {
 }
 
 // ============================================================================
-// StreamManager - Manages ffmpeg instances per station
+// StreamManager - Manages the ffmpeg pipelines of a format per station
 // ============================================================================
 
-// StreamManager manages all active streams
+// StreamManager manages the active streams of one output format
 type StreamManager struct {
 	mu           sync.RWMutex
-	streams      map[string]*StationStream
+	streams      map[string]*StationPipeline
+	pending      map[string]chan struct{} // Station ID → closed when its stream is created or failed
 	graceSeconds int
+	format       streamFormat
+	policy       *slowClientPolicy
+}
+
+// streamFormat describes what ffmpeg outputs for a stream manager
+type streamFormat struct {
+	label     string   // Format shown in the status
+	name      string   // Suffix for ffmpeg log lines
+	codecArgs []string // ffmpeg output codec and container arguments
+	ogg       bool     // Output is Ogg: broadcast whole pages and replay the headers to late clients
+	frameSize int      // Bytes per frame of raw PCM output, broadcast in whole frames (0 = any chunks)
+	titles    bool     // Follow the program on air for ICY metadata
+	quality   string   // HLS variant read: qualityLow, qualityHigh or "" for the playlist's default
+}
+
+// aacFormat copies the station's AAC as ADTS
+var aacFormat = streamFormat{label: "AAC", codecArgs: []string{"-c:a", "copy", "-f", "adts"}, titles: true}
+
+// minChunks returns the number of chunks the ring buffer keeps at least.
+// Raw PCM comes in many more, smaller chunks than compressed audio.
+func (f streamFormat) minChunks() int {
+	if f.frameSize > 0 {
+		return 500
+	}
+	return 100
 }
 
 // NewStreamManager creates a new stream manager
-func NewStreamManager(graceSeconds int) *StreamManager {
+func NewStreamManager(graceSeconds int, policy *slowClientPolicy) *StreamManager {
 	return &StreamManager{
-		streams:      make(map[string]*StationStream),
+		streams:      make(map[string]*StationPipeline),
 		graceSeconds: graceSeconds,
+		format:       aacFormat,
+		policy:       policy,
 	}
 }
 
-// GetStatus returns JSON status of all streams
-func (sm *StreamManager) GetStatus() string {
+// GetStatus returns the status of all streams
+func (sm *StreamManager) GetStatus() []StreamStatus {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
-	result := "{"
-	first := true
+	statuses := make([]StreamStatus, 0, len(sm.streams))
 	for stationID, stream := range sm.streams {
-		if !first {
-			result += ","
-		}
-		first = false
 		stream.mu.RLock()
-		clientCount := len(stream.clients)
+		statuses = append(statuses, stream.info.status(stationID, sm.format.label, stream.running, stream.cmd, stream.clients))
 		stream.mu.RUnlock()
-		result += fmt.Sprintf(`"%s":{"clients":%d,"running":%t}`, stationID, clientCount, stream.running)
 	}
-	result += "}"
-	return result
+	return statuses
+}
+
+// Title returns the title of a running stream, the track on air or
+// "station - program", and the track's artwork URL if any
+func (sm *StreamManager) Title(stationID string) (title, artwork string) {
+	sm.mu.RLock()
+	stream, ok := sm.streams[stationID]
+	sm.mu.RUnlock()
+	if !ok {
+		return "", ""
+	}
+	stream.mu.RLock()
+	defer stream.mu.RUnlock()
+	return stream.title, stream.artwork
 }
 
-// Subscribe adds a client to a station stream
-func (sm *StreamManager) Subscribe(ctx context.Context, w http.ResponseWriter, stationID, clientID string) error {
-	stream, err := sm.getOrCreateStream(stationID)
+// Subscribe adds a client to a station stream. A new stream authenticates
+// in areaID, or in the station's own area when it is empty.
+func (sm *StreamManager) Subscribe(ctx context.Context, w http.ResponseWriter, stationID, areaID, clientID, clientIP string) error {
+	stream, err := sm.getOrCreateStream(ctx, stationID, areaID)
 	if err != nil {
 		return err
 	}
 
-	return stream.AddClient(ctx, w, clientID)
+	return stream.AddClient(ctx, w, clientID, clientIP)
 }
 
-// getOrCreateStream gets an existing stream or creates a new one
-func (sm *StreamManager) getOrCreateStream(stationID string) (*StationStream, error) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// getOrCreateStream gets an existing stream or creates a new one. Creating
+// it is abandoned when ctx ends while authenticating. The stream is created
+// without holding sm.mu, so other stations are not held up by the
+// authentication; clients of the same station wait for it instead.
+func (sm *StreamManager) getOrCreateStream(ctx context.Context, stationID, areaID string) (*StationPipeline, error) {
+	for {
+		sm.mu.Lock()
+		// Check if stream already exists
+		if stream, exists := sm.streams[stationID]; exists {
+			stream.CancelGracePeriod() // Cancel any pending shutdown
+			if stream.running {
+				sm.mu.Unlock()
+				log.Printf("♻️ 既存のffmpegを再利用: %s", stationID)
+				return stream, nil
+			}
+		}
+		wait, creating := sm.pending[stationID]
+		if !creating {
+			break
+		}
+		sm.mu.Unlock()
 
-	// Check if stream already exists
-	if stream, exists := sm.streams[stationID]; exists {
-		stream.CancelGracePeriod() // Cancel any pending shutdown
-		if stream.running {
-			log.Printf("♻️ 既存のffmpegを再利用: %s", stationID)
-			return stream, nil
+		// Another client is starting the stream; if that fails, try again
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
 	}
+	if sm.pending == nil {
+		sm.pending = make(map[string]chan struct{})
+	}
+	done := make(chan struct{})
+	sm.pending[stationID] = done
+	format, graceSeconds := sm.format, sm.graceSeconds
+	sm.mu.Unlock()
 
 	// Create new stream
 	log.Printf("🆕 新しいffmpegを開始: %s", stationID)
-	stream, err := NewStationStream(stationID, sm.graceSeconds, func() {
-		sm.removeStream(stationID)
+	var stream *StationPipeline
+	stream, err := NewStationPipeline(ctx, stationID, areaID, format, graceSeconds, sm.policy, func() {
+		sm.removeStream(stationID, stream)
 	})
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.pending, stationID)
+	close(done)
 	if err != nil {
 		return nil, err
 	}
-
 	sm.streams[stationID] = stream
 	return stream, nil
 }
 
-// removeStream removes a stream from the manager
-func (sm *StreamManager) removeStream(stationID string) {
+// removeStream removes a stream from the manager, unless a newer stream of
+// the station replaced it
+func (sm *StreamManager) removeStream(stationID string, stream *StationPipeline) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	if sm.streams[stationID] != stream {
+		return
+	}
 	delete(sm.streams, stationID)
 	log.Printf("🗑️ ストリーム削除: %s", stationID)
 }
 
 // ============================================================================
-// StationStream - Manages a single station's ffmpeg process and clients
+// StationPipeline - Runs a single station's ffmpeg in a format for its clients
 // ============================================================================
 
 // Client represents a connected client
 type Client struct {
-	id     string
-	writer http.ResponseWriter
-	done   chan struct{}
+	id          string
+	ip          string
+	connectedAt time.Time
+	writer      http.ResponseWriter
+	done        chan struct{}
+	started     bool         // Stream headers were sent (Ogg)
+	bytesSent   atomic.Int64 // Audio bytes written to the client
+	closeOnce   sync.Once
+}
+
+// close disconnects the client
+func (c *Client) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// write writes to the client, failing with os.ErrDeadlineExceeded when the
+// client hasn't read for timeout. Writers without deadlines (multicast,
+// RTSP) block as before.
+func (c *Client) write(b []byte, timeout time.Duration) (int, error) {
+	http.NewResponseController(c.writer).SetWriteDeadline(time.Now().Add(timeout))
+	return c.writer.Write(b)
+}
+
+// clearDeadline removes the write deadline, so that a kept-alive
+// connection serves its next request normally
+func (c *Client) clearDeadline() {
+	http.NewResponseController(c.writer).SetWriteDeadline(time.Time{})
+}
+
+// idle reports whether a write failed because the client stopped reading
+func idle(err error) bool {
+	return errors.Is(err, os.ErrDeadlineExceeded)
 }
 
-// StationStream manages a single station's stream
-type StationStream struct {
+// StationPipeline runs a station's ffmpeg with the output of a streamFormat
+// and sends it to the station's clients
+type StationPipeline struct {
 	stationID    string
 	mu           sync.RWMutex
 	clients      map[string]*Client
@@ -299,198 +675,252 @@ type StationStream struct {
 	graceTimer   *time.Timer
 	graceSeconds int
 	onClose      func()
-
-	// Broadcast channel
-	broadcast chan []byte
-}
-
-// NewStationStream creates and starts a new station stream
-func NewStationStream(stationID string, graceSeconds int, onClose func()) (*StationStream, error) {
-	// Get area for this station
-	areaID, err := api.GetStationArea(stationID)
+	format       streamFormat
+	header       []byte        // Ogg header pages, sent first to every client
+	program      string        // Station and program on air
+	title        string        // Track on air, or the program (ICY metadata)
+	artwork      string        // Artwork URL of the track on air
+	authToken    string        // Token ffmpeg was started with
+	newToken     string        // Renewed token to restart ffmpeg with
+	quit         chan struct{} // Closed by Stop
+	quitOnce     sync.Once
+	stopped      chan struct{} // Closed when ffmpeg exits for good
+	ring         *ringBuffer   // ffmpeg's output, read by every client at its own pace
+	policy       *slowClientPolicy
+	info         streamInfo
+}
+
+// NewStationPipeline creates and starts a station's pipeline in a format,
+// authenticating in areaID ("" = the station's area) unless ctx ends first
+func NewStationPipeline(ctx context.Context, stationID, areaID string, format streamFormat, graceSeconds int, policy *slowClientPolicy, onClose func()) (*StationPipeline, error) {
+	areaID, authToken, streamURL, err := resolveLiveStream(ctx, stationID, areaID, format.quality)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get station area: %w", err)
-	}
-	log.Printf("📍 エリア: %s", areaID)
-
-	// Authenticate
-	log.Printf("🔐 認証中...")
-	authToken := api.Auth(areaID)
-	if authToken == "" {
-		return nil, fmt.Errorf("authentication failed")
-	}
-	log.Printf("✓ 認証成功")
-
-	// Get stream URLs
-	playlistURLs, err := api.GetStreamURLs(stationID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get stream URL: %w", err)
-	}
-	if len(playlistURLs) == 0 {
-		return nil, fmt.Errorf("no stream URLs found")
+		return nil, err
 	}
 
-	// Build final stream URL
-	lsid := model.GenLsid()
-	lastURL := playlistURLs[len(playlistURLs)-1]
-	streamURL := fmt.Sprintf("%s?station_id=%s&l=30&lsid=%s&type=b", lastURL, stationID, lsid)
-
 	// Create stream
-	stream := &StationStream{
+	stream := &StationPipeline{
 		stationID:    stationID,
 		clients:      make(map[string]*Client),
 		graceSeconds: graceSeconds,
 		onClose:      onClose,
-		broadcast:    make(chan []byte, 100),
+		format:       format,
+		quit:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+		ring:         newRingBuffer(policy.ringSize(format.minChunks())),
+		policy:       policy,
+		info:         streamInfo{startedAt: time.Now()},
 	}
 
 	// Start ffmpeg
-	if err := stream.startFFmpeg(streamURL, authToken); err != nil {
+	stdout, err := stream.startFFmpeg(streamURL, authToken)
+	if err != nil {
 		return nil, err
 	}
+	go stream.supervise(stdout, areaID, streamURL)
+	if format.titles {
+		go stream.watchProgram(areaID)
+	}
 
 	return stream, nil
 }
 
-// startFFmpeg starts the ffmpeg process
-func (ss *StationStream) startFFmpeg(streamURL, authToken string) error {
+// startFFmpeg starts the ffmpeg process, returning its output
+func (ss *StationPipeline) startFFmpeg(streamURL, authToken string) (io.Reader, error) {
 	ctx, cancel := context.WithCancel(context.Background())
-	ss.cancel = cancel
 
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	args := []string{
 		"-reconnect", "1",
 		"-reconnect_streamed", "1",
 		"-reconnect_delay_max", "10",
 		"-timeout", "30000000",
 		"-headers", fmt.Sprintf("X-Radiko-AuthToken: %s\r\n", authToken),
 		"-i", streamURL,
-		"-c:a", "copy",
-		"-f", "adts",
+	}
+	args = append(args, ss.format.codecArgs...)
+	args = append(args,
 		"-fflags", "+nobuffer+flush_packets",
 		"-flags", "low_delay",
 		"-loglevel", "warning",
 		"pipe:1",
 	)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		cancel()
-		return fmt.Errorf("failed to get stdout pipe: %w", err)
+		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		cancel()
-		return fmt.Errorf("failed to get stderr pipe: %w", err)
+		return nil, fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
+	// Stop may have been called while a restart was waiting
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	select {
+	case <-ss.quit:
+		cancel()
+		return nil, errStreamStopped
+	default:
+	}
 	if err := cmd.Start(); err != nil {
 		cancel()
-		return fmt.Errorf("failed to start ffmpeg: %w", err)
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
 	}
 
 	ss.cmd = cmd
+	ss.cancel = cancel
 	ss.running = true
+	ss.authToken = authToken
+	ss.newToken = ""
 
 	// Log ffmpeg errors
 	go func() {
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
-			log.Printf("ffmpeg [%s]: %s", ss.stationID, scanner.Text())
+			log.Printf("ffmpeg%s [%s]: %s", ss.format.name, ss.stationID, scanner.Text())
+			ss.info.setError(scanner.Text())
 		}
 	}()
 
-	// Read from ffmpeg and broadcast to clients
-	go ss.readAndBroadcast(stdout)
-
-	// Broadcast to clients
-	go ss.broadcastLoop()
-
-	log.Printf("▶ ffmpeg開始: %s", ss.stationID)
-	return nil
+	log.Printf("▶ ffmpeg開始: %s (%s)", ss.stationID, ss.format.label)
+	events.publish(Event{Type: EventStreamStarted, StationID: ss.stationID, Format: ss.format.label})
+	return stdout, nil
 }
 
-// readAndBroadcast reads from ffmpeg stdout and sends to broadcast channel
-func (ss *StationStream) readAndBroadcast(stdout io.Reader) {
+// readAndBroadcast reads from ffmpeg stdout and writes to the ring buffer
+// until ffmpeg exits
+func (ss *StationPipeline) readAndBroadcast(stdout io.Reader) {
 	reader := bufio.NewReaderSize(stdout, 32768)
 	buf := make([]byte, 8192)
+	var residue []byte // Incomplete frame of raw PCM
 	firstData := true
+	headerDone := false
 
 	for {
-		n, err := reader.Read(buf)
-		if n > 0 {
+		var data []byte
+		var err error
+		if ss.format.ogg {
+			data, err = readOggPage(reader)
+		} else {
+			var n int
+			n, err = reader.Read(buf)
+			// Copy data to avoid race conditions, holding back an
+			// incomplete frame until the next read
+			data = append(residue, buf[:n]...)
+			residue = nil
+			if size := ss.format.frameSize; size > 0 {
+				aligned := len(data) / size * size
+				residue = append(residue, data[aligned:]...)
+				data = data[:aligned]
+			}
+		}
+		if len(data) > 0 {
 			if firstData {
 				log.Printf("📦 最初のデータ受信: %s", ss.stationID)
 				firstData = false
 			}
 
-			// Copy data to avoid race conditions
-			data := make([]byte, n)
-			copy(data, buf[:n])
-
-			// Non-blocking send to broadcast channel
-			select {
-			case ss.broadcast <- data:
-			default:
-				// Channel full, drop oldest data
-				select {
-				case <-ss.broadcast:
-				default:
+			// Ogg streams start with header pages (granule position 0)
+			// that clients joining later need before any audio. A
+			// restarted ffmpeg starts a new chained stream with headers
+			// of its own.
+			if ss.format.ogg && oggBOS(data) {
+				ss.mu.Lock()
+				ss.header = nil
+				ss.mu.Unlock()
+				headerDone = false
+			}
+			if ss.format.ogg && !headerDone {
+				if oggGranule(data) == 0 {
+					ss.mu.Lock()
+					ss.header = append(ss.header, data...)
+					ss.mu.Unlock()
+				} else {
+					headerDone = true
 				}
-				ss.broadcast <- data
 			}
+			ss.ring.write(data)
+			ss.info.bytesRead.Add(int64(len(data)))
 		}
 
 		if err != nil {
-			if err != io.EOF {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
 				log.Printf("❌ ffmpeg読み取りエラー [%s]: %v", ss.stationID, err)
+				ss.info.setError(err.Error())
 			}
-			break
+			return
 		}
 	}
-
-	ss.mu.Lock()
-	ss.running = false
-	ss.mu.Unlock()
-
-	close(ss.broadcast)
-	log.Printf("⏹ ffmpeg終了: %s", ss.stationID)
 }
 
-// broadcastLoop sends data to all connected clients
-func (ss *StationStream) broadcastLoop() {
-	for data := range ss.broadcast {
-		ss.mu.RLock()
-		clients := make([]*Client, 0, len(ss.clients))
-		for _, c := range ss.clients {
-			clients = append(clients, c)
+// sendTo writes the stream to a client, reading the ring buffer at the
+// client's own cursor, until the client disconnects or the stream ends
+func (ss *StationPipeline) sendTo(client *Client) {
+	head := ss.policy.prebufferTime()
+	cursor := ss.ring.cursorSince(time.Now().Add(-head))
+	lagging := false
+	for {
+		chunk, next, skipped, ok := ss.ring.read(cursor, client.done)
+		if !ok {
+			return
 		}
-		ss.mu.RUnlock()
-
-		for _, client := range clients {
-			select {
-			case <-client.done:
+		cursor = next
+		skip, disconnect := ss.policy.check(chunk.at, skipped, head)
+		if disconnect {
+			log.Printf("⚠️ クライアントが追いつけないため切断します [%s]: %s", ss.stationID, client.id)
+			return
+		}
+		if skip {
+			if !lagging {
+				log.Printf("⚠️ クライアントが追いつけません [%s]: %s (ライブまでスキップします)", ss.stationID, client.id)
+				lagging = true
+			}
+			cursor = ss.ring.cursor()
+			continue
+		}
+		data := chunk.data
+
+		out := data
+		if ss.format.ogg && !client.started {
+			// Header pages are replayed in front of the first audio page,
+			// and those of a restarted ffmpeg pass through to clients
+			// that already started
+			if oggGranule(data) == 0 {
 				continue
-			default:
-				_, err := client.writer.Write(data)
-				if err != nil {
-					close(client.done)
-					continue
-				}
-				if f, ok := client.writer.(http.Flusher); ok {
-					f.Flush()
-				}
 			}
+			ss.mu.RLock()
+			out = append(append([]byte(nil), ss.header...), data...)
+			ss.mu.RUnlock()
+		}
+		client.started = true
+		n, err := client.write(out, ss.policy.idleTimeout())
+		client.bytesSent.Add(int64(n))
+		ss.info.bytesSent.Add(int64(n))
+		if err != nil {
+			if idle(err) {
+				log.Printf("⚠️ クライアントが読み取りを停止したため切断します [%s]: %s", ss.stationID, client.id)
+			}
+			return
+		}
+		if f, ok := client.writer.(http.Flusher); ok {
+			f.Flush()
 		}
 	}
 }
 
 // AddClient adds a client to this stream
-func (ss *StationStream) AddClient(ctx context.Context, w http.ResponseWriter, clientID string) error {
+func (ss *StationPipeline) AddClient(ctx context.Context, w http.ResponseWriter, clientID, clientIP string) error {
 	client := &Client{
-		id:     clientID,
-		writer: w,
-		done:   make(chan struct{}),
+		id:          clientID,
+		ip:          clientIP,
+		connectedAt: time.Now(),
+		writer:      w,
+		done:        make(chan struct{}),
 	}
 
 	ss.mu.Lock()
@@ -499,27 +929,32 @@ func (ss *StationStream) AddClient(ctx context.Context, w http.ResponseWriter, c
 	ss.mu.Unlock()
 
 	log.Printf("📊 クライアント追加 [%s]: %d 接続中", ss.stationID, clientCount)
+	events.publish(Event{Type: EventClientConnected, StationID: ss.stationID, Format: ss.format.label, ClientID: clientID, IP: clientIP, Clients: clientCount})
 
-	// Wait for client disconnect or stream end
-	select {
-	case <-ctx.Done():
-		// Client disconnected
-	case <-client.done:
-		// Write error occurred
-	}
+	// Stream until the client disconnects or the stream ends
+	stop := context.AfterFunc(ctx, client.close)
+	ss.sendTo(client)
+	stop()
+	client.clearDeadline()
 
 	ss.removeClient(clientID)
 	return nil
 }
 
 // removeClient removes a client from this stream
-func (ss *StationStream) removeClient(clientID string) {
+func (ss *StationPipeline) removeClient(clientID string) {
 	ss.mu.Lock()
+	var clientIP string
+	if c, ok := ss.clients[clientID]; ok {
+		clientIP = c.ip
+		stats.recordClient(ss.stationID, c.bytesSent.Load(), time.Since(c.connectedAt))
+	}
 	delete(ss.clients, clientID)
 	clientCount := len(ss.clients)
 	ss.mu.Unlock()
 
 	log.Printf("📊 クライアント削除 [%s]: %d 接続中", ss.stationID, clientCount)
+	events.publish(Event{Type: EventClientDisconnected, StationID: ss.stationID, Format: ss.format.label, ClientID: clientID, IP: clientIP, Clients: clientCount})
 
 	// If no clients left, start grace period
 	if clientCount == 0 {
@@ -528,7 +963,7 @@ func (ss *StationStream) removeClient(clientID string) {
 }
 
 // startGracePeriod starts the grace period timer
-func (ss *StationStream) startGracePeriod() {
+func (ss *StationPipeline) startGracePeriod() {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
 
@@ -551,7 +986,7 @@ func (ss *StationStream) startGracePeriod() {
 }
 
 // CancelGracePeriod cancels the grace period timer
-func (ss *StationStream) CancelGracePeriod() {
+func (ss *StationPipeline) CancelGracePeriod() {
 	ss.mu.Lock()
 	defer ss.mu.Unlock()
 
@@ -563,402 +998,18 @@ func (ss *StationStream) CancelGracePeriod() {
 }
 
 // Stop stops the ffmpeg process and cleans up
-func (ss *StationStream) Stop() {
+func (ss *StationPipeline) Stop() {
 	ss.mu.Lock()
+	ss.quitOnce.Do(func() { close(ss.quit) })
 	if ss.cancel != nil {
 		ss.cancel()
 	}
 	ss.running = false
 	ss.mu.Unlock()
 
-	if ss.cmd != nil {
-		ss.cmd.Wait()
-	}
+	<-ss.stopped
 
 	if ss.onClose != nil {
 		ss.onClose()
 	}
 }
-
-// ============================================================================
-// PCMStreamManager - Manages PCM format ffmpeg instances per station
-// ============================================================================
-
-// PCMStreamManager manages all active PCM streams
-type PCMStreamManager struct {
-	mu           sync.RWMutex
-	streams      map[string]*PCMStationStream
-	graceSeconds int
-}
-
-// NewPCMStreamManager creates a new PCM stream manager
-func NewPCMStreamManager(graceSeconds int) *PCMStreamManager {
-	return &PCMStreamManager{
-		streams:      make(map[string]*PCMStationStream),
-		graceSeconds: graceSeconds,
-	}
-}
-
-// Subscribe adds a client to a PCM station stream
-func (pm *PCMStreamManager) Subscribe(ctx context.Context, w http.ResponseWriter, stationID, clientID string) error {
-	stream, err := pm.getOrCreateStream(stationID)
-	if err != nil {
-		return err
-	}
-
-	return stream.AddClient(ctx, w, clientID)
-}
-
-// getOrCreateStream gets an existing stream or creates a new one
-func (pm *PCMStreamManager) getOrCreateStream(stationID string) (*PCMStationStream, error) {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-
-	// Check if stream already exists
-	if stream, exists := pm.streams[stationID]; exists {
-		stream.CancelGracePeriod()
-		if stream.running {
-			log.Printf("♻️ 既存のPCM ffmpegを再利用: %s", stationID)
-			return stream, nil
-		}
-	}
-
-	// Create new stream
-	log.Printf("🆕 新しいPCM ffmpegを開始: %s", stationID)
-	stream, err := NewPCMStationStream(stationID, pm.graceSeconds, func() {
-		pm.removeStream(stationID)
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	pm.streams[stationID] = stream
-	return stream, nil
-}
-
-// removeStream removes a stream from the manager
-func (pm *PCMStreamManager) removeStream(stationID string) {
-	pm.mu.Lock()
-	defer pm.mu.Unlock()
-	delete(pm.streams, stationID)
-	log.Printf("🗑️ PCMストリーム削除: %s", stationID)
-}
-
-// ============================================================================
-// PCMStationStream - Manages a single station's PCM ffmpeg process
-// ============================================================================
-
-// PCMStationStream manages a single station's PCM stream
-type PCMStationStream struct {
-	stationID    string
-	mu           sync.RWMutex
-	clients      map[string]*Client
-	running      bool
-	cmd          *exec.Cmd
-	cancel       context.CancelFunc
-	graceTimer   *time.Timer
-	graceSeconds int
-	onClose      func()
-	broadcast    chan []byte
-}
-
-// NewPCMStationStream creates and starts a new PCM station stream
-func NewPCMStationStream(stationID string, graceSeconds int, onClose func()) (*PCMStationStream, error) {
-	// Get area for this station
-	areaID, err := api.GetStationArea(stationID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get station area: %w", err)
-	}
-	log.Printf("📍 PCMエリア: %s", areaID)
-
-	// Authenticate
-	log.Printf("🔐 PCM認証中...")
-	authToken := api.Auth(areaID)
-	if authToken == "" {
-		return nil, fmt.Errorf("authentication failed")
-	}
-	log.Printf("✓ PCM認証成功")
-
-	// Get stream URLs
-	playlistURLs, err := api.GetStreamURLs(stationID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get stream URL: %w", err)
-	}
-	if len(playlistURLs) == 0 {
-		return nil, fmt.Errorf("no stream URLs found")
-	}
-
-	// Build final stream URL
-	lsid := model.GenLsid()
-	lastURL := playlistURLs[len(playlistURLs)-1]
-	streamURL := fmt.Sprintf("%s?station_id=%s&l=30&lsid=%s&type=b", lastURL, stationID, lsid)
-
-	// Create stream
-	stream := &PCMStationStream{
-		stationID:    stationID,
-		clients:      make(map[string]*Client),
-		graceSeconds: graceSeconds,
-		onClose:      onClose,
-		broadcast:    make(chan []byte, 500),
-	}
-
-	// Start ffmpeg with PCM output
-	if err := stream.startFFmpegPCM(streamURL, authToken); err != nil {
-		return nil, err
-	}
-
-	return stream, nil
-}
-
-// startFFmpegPCM starts the ffmpeg process with PCM output
-func (ps *PCMStationStream) startFFmpegPCM(streamURL, authToken string) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	ps.cancel = cancel
-
-	// Output PCM format: s16le, 48kHz, stereo
-	cmd := exec.CommandContext(ctx, "ffmpeg",
-		"-reconnect", "1",
-		"-reconnect_streamed", "1",
-		"-reconnect_delay_max", "10",
-		"-timeout", "30000000",
-		"-headers", fmt.Sprintf("X-Radiko-AuthToken: %s\r\n", authToken),
-		"-i", streamURL,
-		"-f", "s16le",
-		"-ar", "48000",
-		"-ac", "2",
-		"-fflags", "+nobuffer+flush_packets",
-		"-flags", "low_delay",
-		"-loglevel", "error",
-		"pipe:1",
-	)
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		cancel()
-		return fmt.Errorf("failed to get stdout pipe: %w", err)
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		cancel()
-		return fmt.Errorf("failed to get stderr pipe: %w", err)
-	}
-
-	if err := cmd.Start(); err != nil {
-		cancel()
-		return fmt.Errorf("failed to start ffmpeg: %w", err)
-	}
-
-	ps.cmd = cmd
-	ps.running = true
-
-	// Log ffmpeg errors
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			log.Printf("ffmpeg-pcm [%s]: %s", ps.stationID, scanner.Text())
-		}
-	}()
-
-	// Read from ffmpeg and broadcast to clients
-	go ps.readAndBroadcast(stdout)
-
-	// Broadcast to clients
-	go ps.broadcastLoop()
-
-	log.Printf("▶ PCM ffmpeg開始: %s", ps.stationID)
-	return nil
-}
-
-// readAndBroadcast reads from ffmpeg stdout and sends to broadcast channel
-func (ps *PCMStationStream) readAndBroadcast(stdout io.Reader) {
-	reader := bufio.NewReaderSize(stdout, 32768)
-	// PCM frame size: 2 bytes per sample * 2 channels = 4 bytes per frame
-	const frameSize = 4
-	buf := make([]byte, 8192)
-	residue := make([]byte, 0, frameSize) // Buffer for incomplete frames
-	firstData := true
-
-	for {
-		n, err := reader.Read(buf)
-		if n > 0 {
-			if firstData {
-				log.Printf("📦 PCM最初のデータ受信: %s", ps.stationID)
-				firstData = false
-			}
-
-			// Combine residue from previous read with new data
-			var dataToSend []byte
-			if len(residue) > 0 {
-				dataToSend = make([]byte, len(residue)+n)
-				copy(dataToSend, residue)
-				copy(dataToSend[len(residue):], buf[:n])
-				residue = residue[:0]
-			} else {
-				dataToSend = buf[:n]
-			}
-
-			// Ensure we only send frame-aligned data (multiple of 4 bytes)
-			alignedLen := (len(dataToSend) / frameSize) * frameSize
-			if alignedLen < len(dataToSend) {
-				// Save incomplete frame for next iteration
-				residue = append(residue, dataToSend[alignedLen:]...)
-			}
-
-			if alignedLen > 0 {
-				// Copy aligned data to avoid race conditions
-				data := make([]byte, alignedLen)
-				copy(data, dataToSend[:alignedLen])
-
-				// Non-blocking send to broadcast channel
-				select {
-				case ps.broadcast <- data:
-				default:
-					// Channel full, drop oldest data
-					select {
-					case <-ps.broadcast:
-					default:
-					}
-					ps.broadcast <- data
-				}
-			}
-		}
-
-		if err != nil {
-			if err != io.EOF {
-				log.Printf("❌ PCM ffmpeg読み取りエラー [%s]: %v", ps.stationID, err)
-			}
-			break
-		}
-	}
-
-	ps.mu.Lock()
-	ps.running = false
-	ps.mu.Unlock()
-
-	close(ps.broadcast)
-	log.Printf("⏹ PCM ffmpeg終了: %s", ps.stationID)
-}
-
-// broadcastLoop sends data to all connected clients
-func (ps *PCMStationStream) broadcastLoop() {
-	for data := range ps.broadcast {
-		ps.mu.RLock()
-		clients := make([]*Client, 0, len(ps.clients))
-		for _, c := range ps.clients {
-			clients = append(clients, c)
-		}
-		ps.mu.RUnlock()
-
-		for _, client := range clients {
-			select {
-			case <-client.done:
-				continue
-			default:
-				_, err := client.writer.Write(data)
-				if err != nil {
-					close(client.done)
-					continue
-				}
-				if f, ok := client.writer.(http.Flusher); ok {
-					f.Flush()
-				}
-			}
-		}
-	}
-}
-
-// AddClient adds a client to this PCM stream
-func (ps *PCMStationStream) AddClient(ctx context.Context, w http.ResponseWriter, clientID string) error {
-	client := &Client{
-		id:     clientID,
-		writer: w,
-		done:   make(chan struct{}),
-	}
-
-	ps.mu.Lock()
-	ps.clients[clientID] = client
-	clientCount := len(ps.clients)
-	ps.mu.Unlock()
-
-	log.Printf("📊 PCMクライアント追加 [%s]: %d 接続中", ps.stationID, clientCount)
-
-	// Wait for client disconnect or stream end
-	select {
-	case <-ctx.Done():
-		// Client disconnected
-	case <-client.done:
-		// Write error occurred
-	}
-
-	ps.removeClient(clientID)
-	return nil
-}
-
-// removeClient removes a client from this stream
-func (ps *PCMStationStream) removeClient(clientID string) {
-	ps.mu.Lock()
-	delete(ps.clients, clientID)
-	clientCount := len(ps.clients)
-	ps.mu.Unlock()
-
-	log.Printf("📊 PCMクライアント削除 [%s]: %d 接続中", ps.stationID, clientCount)
-
-	// If no clients left, start grace period
-	if clientCount == 0 {
-		ps.startGracePeriod()
-	}
-}
-
-// startGracePeriod starts the grace period timer
-func (ps *PCMStationStream) startGracePeriod() {
-	ps.mu.Lock()
-	defer ps.mu.Unlock()
-
-	if ps.graceTimer != nil {
-		return // Already running
-	}
-
-	log.Printf("⏰ PCM猶予期間開始 [%s]: %d秒", ps.stationID, ps.graceSeconds)
-
-	ps.graceTimer = time.AfterFunc(time.Duration(ps.graceSeconds)*time.Second, func() {
-		ps.mu.Lock()
-		clientCount := len(ps.clients)
-		ps.mu.Unlock()
-
-		if clientCount == 0 {
-			log.Printf("⏰ PCM猶予期間終了、ffmpeg停止: %s", ps.stationID)
-			ps.Stop()
-		}
-	})
-}
-
-// CancelGracePeriod cancels the grace period timer
-func (ps *PCMStationStream) CancelGracePeriod() {
-	ps.mu.Lock()
-	defer ps.mu.Unlock()
-
-	if ps.graceTimer != nil {
-		ps.graceTimer.Stop()
-		ps.graceTimer = nil
-		log.Printf("⏰ PCM猶予期間キャンセル: %s", ps.stationID)
-	}
-}
-
-// Stop stops the ffmpeg process and cleans up
-func (ps *PCMStationStream) Stop() {
-	ps.mu.Lock()
-	if ps.cancel != nil {
-		ps.cancel()
-	}
-	ps.running = false
-	ps.mu.Unlock()
-
-	if ps.cmd != nil {
-		ps.cmd.Wait()
-	}
-
-	if ps.onClose != nil {
-		ps.onClose()
-	}
-}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/radikotest"
+)
+
+// fakeFFmpeg puts an ffmpeg first on PATH that streams zeros, and returns
+// the file it writes its arguments to
+func fakeFFmpeg(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	args := filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > '" + args + "'\nexec cat /dev/zero\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return args
+}
+
+// fakeRadiko points the api requests at a fake radiko until the test ends
+func fakeRadiko(t *testing.T) *radikotest.Server {
+	t.Helper()
+	radiko := radikotest.NewServer()
+	api.SetHTTPClient(radiko.Client(), "")
+	t.Cleanup(func() {
+		api.SetHTTPClient(http.DefaultClient, "")
+		for areaID := range radiko.Stations {
+			api.InvalidateToken(areaID)
+		}
+		radiko.Close()
+	})
+	return radiko
+}
+
+func TestPlay(t *testing.T) {
+	argsFile := fakeFFmpeg(t)
+	radiko := fakeRadiko(t)
+
+	s := NewServer(0, 1)
+	t.Cleanup(s.streamManager.StopAll)
+	ts := httptest.NewServer(s.handler())
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/play/TBS", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status = %s: %s", resp.Status, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "audio/aac" {
+		t.Errorf("Content-Type = %q, want audio/aac", ct)
+	}
+	if _, err := io.ReadFull(resp.Body, make([]byte, 4096)); err != nil {
+		t.Fatalf("reading the stream: %v", err)
+	}
+
+	// ffmpeg was started on TBS's stream with a token of Tokyo
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	i := slices.Index(args, "-i")
+	if i < 0 || i+1 == len(args) || !strings.Contains(args[i+1], "station_id=TBS") {
+		t.Errorf("ffmpeg input is not TBS's stream: %q", args)
+	}
+	i = slices.Index(args, "-headers")
+	if i < 0 || i+1 == len(args) {
+		t.Fatalf("ffmpeg was started without headers: %q", args)
+	}
+	token := strings.TrimPrefix(strings.TrimSpace(args[i+1]), "X-Radiko-AuthToken: ")
+	if !radiko.ValidToken(token) {
+		t.Errorf("ffmpeg got token %q, which radiko did not issue", token)
+	}
+	if area := radiko.TokenArea(token); area != "JP13" {
+		t.Errorf("token area = %q, want JP13", area)
+	}
+
+	status := s.streamManager.GetStatus()
+	if len(status) != 1 || status[0].StationID != "TBS" || len(status[0].Clients) != 1 {
+		t.Errorf("streams = %+v, want TBS with one client", status)
+	}
+}
+
+func TestPlayUnknownArea(t *testing.T) {
+	s := NewServer(0, 1)
+	ts := httptest.NewServer(s.handler())
+	t.Cleanup(ts.Close)
+
+	resp, err := http.Get(ts.URL + "/api/play/TBS?area=JP99")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %s, want 400 Bad Request", resp.Status)
+	}
+}
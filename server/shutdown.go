@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ShutdownTimeout bounds how long a graceful shutdown may take
+const ShutdownTimeout = 10 * time.Second
+
+// Shutdown stops accepting connections, disconnects every client, stops all
+// ffmpeg processes and makes Start return. Connections still open when ctx
+// expires are closed forcibly.
+func (s *Server) Shutdown(ctx context.Context) error {
+	defer close(s.shutdownDone)
+	log.Printf("🛑 サーバーを停止しています...")
+	notifySystemd("STOPPING=1")
+
+	// Ending the request contexts ends the streaming handlers, which would
+	// otherwise keep Shutdown waiting forever
+	s.cancelBase()
+
+	s.srvMu.Lock()
+	srv := s.srv
+	ssdpDone := s.ssdpDone
+	rtspDone := s.rtspDone
+	mdnsDone := s.mdnsDone
+	s.srvMu.Unlock()
+	var err error
+	if srv != nil {
+		if err = srv.Shutdown(ctx); err != nil {
+			log.Printf("⚠️ 接続を待たずに停止します: %v", err)
+			srv.Close()
+		}
+	}
+
+	if ssdpDone != nil {
+		<-ssdpDone
+	}
+	if rtspDone != nil {
+		<-rtspDone
+	}
+	if mdnsDone != nil {
+		<-mdnsDone
+	}
+
+	s.streamManager.StopAll()
+	s.pcmStreamManager.StopAll()
+	s.pcmMu.Lock()
+	for _, m := range s.pcmManagers {
+		m.StopAll()
+	}
+	s.pcmMu.Unlock()
+	s.opusMu.Lock()
+	for _, m := range s.opusManagers {
+		m.StopAll()
+	}
+	s.opusMu.Unlock()
+	s.qualityMu.Lock()
+	for _, m := range s.qualityManagers {
+		m.StopAll()
+	}
+	s.qualityMu.Unlock()
+	stats.save()
+
+	log.Printf("👋 サーバーを停止しました")
+	return err
+}
+
+// StopAll disconnects all clients and stops every ffmpeg process
+func (sm *StreamManager) StopAll() {
+	sm.mu.RLock()
+	streams := make([]*StationPipeline, 0, len(sm.streams))
+	for _, stream := range sm.streams {
+		streams = append(streams, stream)
+	}
+	sm.mu.RUnlock()
+
+	for _, stream := range streams {
+		stream.CancelGracePeriod()
+		stream.mu.RLock()
+		for _, c := range stream.clients {
+			c.close()
+		}
+		stream.mu.RUnlock()
+		stream.Stop()
+	}
+}
+
+// StopStation disconnects a station's clients and stops its ffmpeg,
+// reporting whether it was running
+func (sm *StreamManager) StopStation(stationID string) bool {
+	sm.mu.RLock()
+	stream, ok := sm.streams[stationID]
+	sm.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	stream.CancelGracePeriod()
+	stream.mu.RLock()
+	for _, c := range stream.clients {
+		c.close()
+	}
+	stream.mu.RUnlock()
+	stream.Stop()
+	return true
+}
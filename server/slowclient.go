@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policies of Server.SetSlowClientPolicy for clients that can't keep up with the
+// stream
+const (
+	SlowClientBuffer     = "buffer"     // Let the client fall up to the buffer time behind, then skip to live
+	SlowClientDrop       = "drop"       // Skip to live as soon as the client falls behind
+	SlowClientDisconnect = "disconnect" // Disconnect the client once it is the buffer time behind
+)
+
+// DefaultSlowClientBuffer is the default buffer time in seconds
+const DefaultSlowClientBuffer = 10
+
+// DefaultIdleTimeout is the default time in seconds a client may stop
+// reading before it is disconnected
+const DefaultIdleTimeout = 30
+
+const (
+	slowClientDropLag   = time.Second // How far behind a client may fall with the drop policy
+	ringChunksPerSecond = 50          // Upper bound of ffmpeg output chunks per second
+)
+
+// slowClientPolicy decides what happens to clients that fall behind, and
+// how far behind live new clients start
+type slowClientPolicy struct {
+	mu        sync.RWMutex
+	policy    string
+	buffer    time.Duration // How far behind a client may fall
+	idle      time.Duration // How long a single write to a client may block
+	prebuffer time.Duration // How much of the recent output new clients get at once
+}
+
+// newSlowClientPolicy returns the default policy
+func newSlowClientPolicy() *slowClientPolicy {
+	return &slowClientPolicy{
+		policy:    SlowClientBuffer,
+		buffer:    DefaultSlowClientBuffer * time.Second,
+		idle:      DefaultIdleTimeout * time.Second,
+		prebuffer: DefaultPrebuffer * time.Second,
+	}
+}
+
+// SetSlowClientPolicy sets what happens when a client can't keep up: it is
+// buffered for up to bufferSeconds and then skips to live ("buffer"), skips
+// to live right away ("drop"), or is disconnected after bufferSeconds
+// ("disconnect"). It can be called at any time; a longer buffer applies to
+// streams started afterwards.
+func (s *Server) SetSlowClientPolicy(policy string, bufferSeconds int) error {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case "":
+		policy = SlowClientBuffer
+	case SlowClientBuffer, SlowClientDrop, SlowClientDisconnect:
+	default:
+		return fmt.Errorf("unknown slow client policy %q (buffer, drop or disconnect)", policy)
+	}
+	if bufferSeconds <= 0 {
+		bufferSeconds = DefaultSlowClientBuffer
+	}
+
+	p := s.slowClients
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = policy
+	p.buffer = time.Duration(bufferSeconds) * time.Second
+	return nil
+}
+
+// SetIdleTimeout sets how long a client may stop reading before it is
+// disconnected. Such clients would otherwise keep their stream's ffmpeg
+// running, since the grace period only starts once the last client is gone.
+func (s *Server) SetIdleTimeout(seconds int) {
+	if seconds <= 0 {
+		seconds = DefaultIdleTimeout
+	}
+	p := s.slowClients
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle = time.Duration(seconds) * time.Second
+}
+
+// idleTimeout returns how long a write to a client may block
+func (p *slowClientPolicy) idleTimeout() time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.idle
+}
+
+// String describes the policy for the log
+func (p *slowClientPolicy) String() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.policy == SlowClientDrop {
+		return fmt.Sprintf("%s, idle %v", p.policy, p.idle)
+	}
+	return fmt.Sprintf("%s (%v), idle %v", p.policy, p.buffer, p.idle)
+}
+
+// ringSize returns the number of chunks a stream keeps, at least atLeast
+// and enough for the buffer and pre-buffer times
+func (p *slowClientPolicy) ringSize(atLeast int) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return max(atLeast, int((p.buffer+p.prebuffer).Seconds())*ringChunksPerSecond)
+}
+
+// check tells what to do with a client that read a chunk written at at,
+// after skipped chunks were lost to the ring buffer overflowing: skip to
+// live, disconnect it, or neither. head is how far behind live the client
+// started (the pre-buffer).
+func (p *slowClientPolicy) check(at time.Time, skipped uint64, head time.Duration) (skip, disconnect bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	maxLag := p.buffer
+	if p.policy == SlowClientDrop {
+		maxLag = slowClientDropLag
+	}
+	maxLag += head
+	if skipped == 0 && time.Since(at) <= maxLag {
+		return false, false
+	}
+	if p.policy == SlowClientDisconnect {
+		return false, true
+	}
+	return true, false
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// ssdpGroup is the SSDP multicast address devices search and listen on
+var ssdpGroup = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// ssdpMaxAge is how long (seconds) devices may cache an announcement
+const ssdpMaxAge = 1800
+
+// ssdpServer is the SERVER header of SSDP messages
+var ssdpServer = runtime.GOOS + "/1.0 UPnP/1.0 radiko-tui/1.0"
+
+// serveSSDP announces the server on every LAN interface and answers
+// searches until ctx is done, then says goodbye
+func (s *Server) serveSSDP(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, ssdpGroup)
+	if err != nil {
+		log.Printf("⚠️ SSDPの告知を開始できません: %v", err)
+		return
+	}
+	pc := ipv4.NewPacketConn(conn)
+	ifaces := ssdpInterfaces()
+	for _, ifi := range ifaces {
+		pc.JoinGroup(&ifi, ssdpGroup) // Fails harmlessly when already joined
+	}
+	pc.SetMulticastTTL(2)
+
+	go func() {
+		s.notifySSDP(pc, ifaces, "ssdp:alive")
+		ticker := time.NewTicker(ssdpMaxAge / 3 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				s.notifySSDP(pc, ifaces, "ssdp:byebye")
+				conn.Close()
+				return
+			case <-ticker.C:
+				s.notifySSDP(pc, ifaces, "ssdp:alive")
+			}
+		}
+	}()
+
+	buf := make([]byte, 4096)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		s.answerSearch(conn, buf[:n], from)
+	}
+}
+
+// ssdpInterfaces returns the interfaces to announce on
+func ssdpInterfaces() []net.Interface {
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var ifaces []net.Interface
+	for _, ifi := range all {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 || ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if interfaceIPv4(ifi) != nil {
+			ifaces = append(ifaces, ifi)
+		}
+	}
+	return ifaces
+}
+
+// interfaceIPv4 returns the first IPv4 address of an interface
+func interfaceIPv4(ifi net.Interface) net.IP {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return ipnet.IP.To4()
+		}
+	}
+	return nil
+}
+
+// ssdpTargets returns the notification types the server answers to
+func (s *Server) ssdpTargets() []string {
+	targets := []string{"uuid:" + s.dlnaUUID}
+	if s.dlnaEnabled() {
+		targets = append(targets, "upnp:rootdevice", dlnaDeviceType, dlnaContentDirectory, dlnaConnectionManager)
+	}
+	if s.announce {
+		targets = append(targets, ssdpServerType)
+	}
+	return targets
+}
+
+// ssdpUSN returns the unique service name for a notification type
+func (s *Server) ssdpUSN(target string) string {
+	if target == "uuid:"+s.dlnaUUID {
+		return target
+	}
+	return "uuid:" + s.dlnaUUID + "::" + target
+}
+
+// rootURL returns the server's URL reachable at ip
+func (s *Server) rootURL(ip net.IP) string {
+	scheme := "http"
+	if s.tlsEnabled() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(ip.String(), strconv.Itoa(s.port)), s.basePath)
+}
+
+// ssdpLocation returns the LOCATION of a notification type reachable at ip:
+// the device description for DLNA, the web UI for the radiko-tui type
+func (s *Server) ssdpLocation(ip net.IP, target string) string {
+	if target == ssdpServerType || !s.dlnaEnabled() {
+		return s.rootURL(ip) + "/"
+	}
+	return s.rootURL(ip) + "/dlna/description.xml"
+}
+
+// notifySSDP multicasts an announcement (ssdp:alive or ssdp:byebye) of every
+// notification type on each interface
+func (s *Server) notifySSDP(pc *ipv4.PacketConn, ifaces []net.Interface, nts string) {
+	for _, ifi := range ifaces {
+		if err := pc.SetMulticastInterface(&ifi); err != nil {
+			continue
+		}
+		ip := interfaceIPv4(ifi)
+		for _, target := range s.ssdpTargets() {
+			msg := "NOTIFY * HTTP/1.1\r\n" +
+				"HOST: " + ssdpGroup.String() + "\r\n" +
+				"NT: " + target + "\r\n" +
+				"NTS: " + nts + "\r\n" +
+				"USN: " + s.ssdpUSN(target) + "\r\n"
+			if nts == "ssdp:alive" {
+				msg += fmt.Sprintf("CACHE-CONTROL: max-age=%d\r\n", ssdpMaxAge) +
+					"LOCATION: " + s.ssdpLocation(ip, target) + "\r\n" +
+					"SERVER: " + ssdpServer + "\r\n"
+			}
+			pc.WriteTo([]byte(msg+"\r\n"), nil, ssdpGroup)
+		}
+	}
+}
+
+// answerSearch replies to an M-SEARCH request for the server's types
+func (s *Server) answerSearch(conn *net.UDPConn, packet []byte, from *net.UDPAddr) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(packet)))
+	if err != nil || req.Method != "M-SEARCH" || req.Header.Get("MAN") != `"ssdp:discover"` {
+		return
+	}
+
+	st := req.Header.Get("ST")
+	var targets []string
+	for _, target := range s.ssdpTargets() {
+		if st == "ssdp:all" || st == target {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	// The address the searcher reached us at is the one to hand back
+	c, err := net.DialUDP("udp4", nil, from)
+	if err != nil {
+		return
+	}
+	local := c.LocalAddr().(*net.UDPAddr).IP
+	c.Close()
+
+	// Spread replies over MX seconds as the spec asks
+	mx, _ := strconv.Atoi(req.Header.Get("MX"))
+	delay := time.Duration(0)
+	if mx = min(mx, 5); mx > 0 {
+		delay = time.Duration(rand.Int63n(int64(mx) * int64(time.Second)))
+	}
+	time.AfterFunc(delay, func() {
+		for _, target := range targets {
+			msg := "HTTP/1.1 200 OK\r\n" +
+				fmt.Sprintf("CACHE-CONTROL: max-age=%d\r\n", ssdpMaxAge) +
+				"EXT:\r\n" +
+				"LOCATION: " + s.ssdpLocation(local, target) + "\r\n" +
+				"SERVER: " + ssdpServer + "\r\n" +
+				"ST: " + target + "\r\n" +
+				"USN: " + s.ssdpUSN(target) + "\r\n\r\n"
+			conn.WriteToUDP([]byte(msg), from)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// statsSaveInterval is how often changed statistics are written to disk
+const statsSaveInterval = time.Minute
+
+// StationStats are the cumulative listening statistics of a station
+type StationStats struct {
+	BytesSent     int64   `json:"bytes_sent"`
+	ListenerHours float64 `json:"listener_hours"`
+	Sessions      int64   `json:"sessions"` // Clients that listened
+}
+
+// Stats are the listening statistics kept across restarts
+type Stats struct {
+	Since         time.Time                `json:"since"` // When counting started
+	BytesSent     int64                    `json:"bytes_sent"`
+	ListenerHours float64                  `json:"listener_hours"`
+	PeakClients   int                      `json:"peak_clients"` // Most concurrent stream clients
+	PeakClientsAt time.Time                `json:"peak_clients_at,omitzero"`
+	Stations      map[string]*StationStats `json:"stations"`
+}
+
+// statsStore accumulates the statistics and saves them to a file
+type statsStore struct {
+	mu    sync.Mutex
+	path  string // File the statistics are saved to ("" = memory only)
+	dirty bool   // Changed since the last save
+	data  Stats
+}
+
+// stats are the server's statistics
+var stats = &statsStore{data: Stats{Since: time.Now(), Stations: make(map[string]*StationStats)}}
+
+// SetStatsFile keeps the statistics in a file, loading the totals of
+// previous runs from it
+func (s *Server) SetStatsFile(path string) error {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.path = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var loaded Stats
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+	if loaded.Stations == nil {
+		loaded.Stations = make(map[string]*StationStats)
+	}
+	if loaded.Since.IsZero() {
+		loaded.Since = stats.data.Since
+	}
+	stats.data = loaded
+	return nil
+}
+
+// recordClient adds a disconnected client's listening to its station
+func (st *statsStore) recordClient(stationID string, bytes int64, listened time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	station, ok := st.data.Stations[stationID]
+	if !ok {
+		station = &StationStats{}
+		st.data.Stations[stationID] = station
+	}
+	station.BytesSent += bytes
+	station.ListenerHours += listened.Hours()
+	station.Sessions++
+	st.data.BytesSent += bytes
+	st.data.ListenerHours += listened.Hours()
+	st.dirty = true
+}
+
+// observeClients records a new peak of concurrent stream clients
+func (st *statsStore) observeClients(n int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if n > st.data.PeakClients {
+		st.data.PeakClients = n
+		st.data.PeakClientsAt = time.Now()
+		st.dirty = true
+	}
+}
+
+// snapshot returns a copy of the statistics including the listening of the
+// clients still connected
+func (st *statsStore) snapshot(streams []StreamStatus) Stats {
+	st.mu.Lock()
+	snap := st.data
+	snap.Stations = make(map[string]*StationStats, len(st.data.Stations))
+	for id, station := range st.data.Stations {
+		copied := *station
+		snap.Stations[id] = &copied
+	}
+	st.mu.Unlock()
+
+	for _, stream := range streams {
+		for _, c := range stream.Clients {
+			station, ok := snap.Stations[stream.StationID]
+			if !ok {
+				station = &StationStats{}
+				snap.Stations[stream.StationID] = station
+			}
+			listened := time.Since(c.ConnectedAt).Hours()
+			station.BytesSent += c.BytesSent
+			station.ListenerHours += listened
+			station.Sessions++
+			snap.BytesSent += c.BytesSent
+			snap.ListenerHours += listened
+		}
+	}
+	return snap
+}
+
+// save writes the statistics to the file if they changed
+func (st *statsStore) save() {
+	st.mu.Lock()
+	if st.path == "" || !st.dirty {
+		st.mu.Unlock()
+		return
+	}
+	path := st.path
+	data, err := json.MarshalIndent(st.data, "", "  ")
+	st.dirty = false
+	st.mu.Unlock()
+
+	// Write a temporary file first so a crash never leaves a truncated file
+	tmp := path + ".tmp"
+	if err == nil {
+		err = os.WriteFile(tmp, data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		log.Printf("❌ 統計の保存に失敗しました: %v", err)
+		st.mu.Lock()
+		st.dirty = true
+		st.mu.Unlock()
+	}
+}
+
+// saveLoop saves the statistics periodically until ctx ends
+func (st *statsStore) saveLoop(ctx context.Context) {
+	ticker := time.NewTicker(statsSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			st.save()
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StreamStatus describes a running stream and its clients
+type StreamStatus struct {
+	StationID string         `json:"station_id"`
+	Format    string         `json:"format"` // AAC, PCM or Opus <bitrate>
+	Running   bool           `json:"running"`
+	StartedAt time.Time      `json:"started_at"`
+	PID       int            `json:"pid,omitempty"`        // ffmpeg's process ID while running
+	BytesRead int64          `json:"bytes_read"`           // Read from ffmpeg
+	BytesSent int64          `json:"bytes_sent"`           // Sent to clients, including those gone
+	LastError string         `json:"last_error,omitempty"` // Last ffmpeg error or restart failure
+	Clients   []ClientStatus `json:"clients"`
+}
+
+// streamInfo keeps the counters and the last error of a stream for its
+// status
+type streamInfo struct {
+	startedAt time.Time
+	bytesRead atomic.Int64
+	bytesSent atomic.Int64
+
+	mu        sync.Mutex
+	lastError string
+}
+
+// setError records the last error of the stream
+func (i *streamInfo) setError(msg string) {
+	i.mu.Lock()
+	i.lastError = strings.TrimSpace(msg)
+	i.mu.Unlock()
+}
+
+// status returns the status of a stream with its info and ffmpeg command
+// (the caller must hold the stream's lock)
+func (i *streamInfo) status(stationID, format string, running bool, cmd *exec.Cmd, clients map[string]*Client) StreamStatus {
+	st := StreamStatus{
+		StationID: stationID,
+		Format:    format,
+		Running:   running,
+		StartedAt: i.startedAt,
+		BytesRead: i.bytesRead.Load(),
+		BytesSent: i.bytesSent.Load(),
+		Clients:   clientStatuses(clients),
+	}
+	if running && cmd != nil && cmd.Process != nil {
+		st.PID = cmd.Process.Pid
+	}
+	i.mu.Lock()
+	st.LastError = i.lastError
+	i.mu.Unlock()
+	return st
+}
+
+// ClientStatus describes a client listening to a stream
+type ClientStatus struct {
+	ID          string    `json:"id"`
+	IP          string    `json:"ip"`
+	ConnectedAt time.Time `json:"connected_at"`
+	BytesSent   int64     `json:"bytes_sent"`
+}
+
+// clientStatuses returns the status of a stream's clients, oldest first
+// (the caller must hold the stream's lock)
+func clientStatuses(clients map[string]*Client) []ClientStatus {
+	statuses := make([]ClientStatus, 0, len(clients))
+	for _, c := range clients {
+		statuses = append(statuses, ClientStatus{
+			ID:          c.id,
+			IP:          c.ip,
+			ConnectedAt: c.connectedAt,
+			BytesSent:   c.bytesSent.Load(),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ConnectedAt.Before(statuses[j].ConnectedAt)
+	})
+	return statuses
+}
+
+// status returns the streams of every format, ordered by station and format
+func (s *Server) status() []StreamStatus {
+	statuses := s.streamManager.GetStatus()
+	statuses = append(statuses, s.pcmStreamManager.GetStatus()...)
+	s.pcmMu.Lock()
+	for _, m := range s.pcmManagers {
+		statuses = append(statuses, m.GetStatus()...)
+	}
+	s.pcmMu.Unlock()
+	s.opusMu.Lock()
+	for _, m := range s.opusManagers {
+		statuses = append(statuses, m.GetStatus()...)
+	}
+	s.opusMu.Unlock()
+	s.qualityMu.Lock()
+	for _, m := range s.qualityManagers {
+		statuses = append(statuses, m.GetStatus()...)
+	}
+	s.qualityMu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].StationID != statuses[j].StationID {
+			return statuses[i].StationID < statuses[j].StationID
+		}
+		return statuses[i].Format < statuses[j].Format
+	})
+	return statuses
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation
+const listenFDsStart = 3
+
+// listener returns the socket passed by systemd (LISTEN_FDS) when the
+// server is socket-activated, or listens on addr
+func listener(addr string) (net.Listener, error) {
+	pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	fds, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if pid != os.Getpid() || fds < 1 {
+		return net.Listen("tcp", addr)
+	}
+
+	// Child processes (ffmpeg) must not take the sockets for themselves
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if fds > 1 {
+		log.Printf("⚠️ systemdから%d個のソケットを受け取りました。最初のソケットのみ使用します", fds)
+	}
+
+	f := os.NewFile(listenFDsStart, "LISTEN_FD_3")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("🔌 systemdのソケットで待ち受けます: %s", ln.Addr())
+	return ln, nil
+}
+
+// notifySystemd sends a state ("READY=1", "STOPPING=1"...) to systemd when
+// running as a Type=notify service
+func notifySystemd(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // Abstract socket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		log.Printf("⚠️ systemdへの通知に失敗しました: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte(state))
+}
+
+// watchdogSystemd pings systemd's watchdog (WatchdogSec=) until ctx ends
+func watchdogSystemd(ctx context.Context) {
+	usec, _ := strconv.Atoi(os.Getenv("WATCHDOG_USEC"))
+	if pid, err := strconv.Atoi(os.Getenv("WATCHDOG_PID")); err == nil && pid != os.Getpid() {
+		return
+	}
+	if usec <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(usec) * time.Microsecond / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			notifySystemd("WATCHDOG=1")
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+	"github.com/kanoshiou/radiko-tui/recorder"
+)
+
+// parseTimefreeRange parses ?ft=...&to=... (YYYYMMDDHHMMSS, JST) and checks
+// that the range has been broadcast and is still available
+func parseTimefreeRange(r *http.Request) (time.Time, time.Time, error) {
+	ft, err := time.ParseInLocation("20060102150405", r.URL.Query().Get("ft"), model.JST)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("ft must be YYYYMMDDHHMMSS")
+	}
+	to, err := time.ParseInLocation("20060102150405", r.URL.Query().Get("to"), model.JST)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must be YYYYMMDDHHMMSS")
+	}
+
+	now := time.Now()
+	switch {
+	case !to.After(ft):
+		return time.Time{}, time.Time{}, fmt.Errorf("to must be after ft")
+	case to.After(now):
+		return time.Time{}, time.Time{}, fmt.Errorf("the program has not been broadcast yet")
+	case now.Sub(ft) > recorder.TimefreeWindow:
+		return time.Time{}, time.Time{}, fmt.Errorf("the program is no longer available on timefree (7 days)")
+	}
+	return ft, to, nil
+}
+
+// handleTimefree streams a past program (?ft=...&to=...) through timefree.
+// Each client gets its own ffmpeg, which the client's reading paces.
+// ?format=opus re-encodes to Opus in Ogg (?bitrate=<kbps>).
+func (s *Server) handleTimefree(w http.ResponseWriter, r *http.Request) {
+	stationID := r.PathValue("stationID")
+	clientIP := s.getRealIP(r)
+	log.Printf("📥 タイムフリーリクエスト: %s %s (from %s)", r.Method, r.URL.String(), clientIP)
+
+	ft, to, err := parseTimefreeRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	release, ok := s.acquireClient(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	contentType := "audio/aac"
+	codecArgs := aacFormat.codecArgs
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "aac":
+	case "opus":
+		kbps, err := s.requestOpusBitrate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		contentType = "audio/ogg; codecs=opus"
+		codecArgs = opusCodecArgs(kbps)
+	default:
+		http.Error(w, "unsupported format: "+format, http.StatusBadRequest)
+		return
+	}
+
+	_, authToken, err := resolveStation(r.Context(), stationID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	playlistURL, err := api.GetTimefreeStreamURL(r.Context(), stationID, ft, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	args := []string{
+		"-headers", fmt.Sprintf("X-Radiko-AuthToken: %s\r\n", authToken),
+		"-i", playlistURL,
+	}
+	args = append(args, codecArgs...)
+	args = append(args, "-loglevel", "warning", "pipe:1")
+	cmd := exec.CommandContext(r.Context(), "ffmpeg", args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		http.Error(w, fmt.Sprintf("failed to start ffmpeg: %v", err), http.StatusInternalServerError)
+		return
+	}
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			log.Printf("ffmpeg-timefree [%s]: %s", stationID, scanner.Text())
+		}
+	}()
+
+	log.Printf("⏪ タイムフリー配信開始: %s %s-%s (%s)", stationID,
+		ft.Format("2006/01/02 15:04"), to.Format("15:04"), clientIP)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("icy-name", fmt.Sprintf("Radiko - %s", stationID))
+
+	buf := make([]byte, 32768)
+	flusher, _ := w.(http.Flusher)
+	for {
+		n, err := stdout.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				break
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("❌ タイムフリー読み取りエラー [%s]: %v", stationID, err)
+			}
+			break
+		}
+	}
+
+	cmd.Cancel()
+	cmd.Wait()
+	log.Printf("⏹ タイムフリー配信終了: %s (%s)", stationID, clientIP)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"embed"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+//go:embed web
+var webFS embed.FS
+
+// webTemplate is the dashboard page
+var webTemplate = template.Must(template.New("index.html").Funcs(template.FuncMap{
+	"logo": api.GetStationLogoURL,
+}).ParseFS(webFS, "web/index.html"))
+
+// handleDashboard serves the web UI: active streams and a player for the
+// stations of an area (?area=JP13, default the configured one)
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	areaID := r.URL.Query().Get("area")
+	if areaID == "" && len(s.areas) > 0 {
+		areaID = s.areas[0]
+	}
+	if model.FindAreaByID(areaID) == nil {
+		areaID = "JP13"
+	}
+
+	stations, err := api.GetStations(r.Context(), areaID)
+	if err != nil {
+		log.Printf("❌ 放送局リストの取得に失敗しました: %v", err)
+	}
+
+	data := struct {
+		Regions     []model.Region
+		AreaID      string
+		Stations    []model.Station
+		StationsErr error
+		Streams     []StreamStatus
+		OpusBitrate int
+		Podcast     bool
+		Schedules   bool
+	}{
+		Regions:     model.AllRegions,
+		AreaID:      areaID,
+		Stations:    stations,
+		StationsErr: err,
+		Streams:     s.status(),
+		OpusBitrate: s.opusBitrate,
+		Podcast:     s.podcast,
+		Schedules:   s.scheduler != nil,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := webTemplate.Execute(w, data); err != nil {
+		log.Printf("❌ Web UIの表示に失敗しました: %v", err)
+	}
+}
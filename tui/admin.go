@@ -0,0 +1,450 @@
+package tui
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	adminPollInterval   = 2 * time.Second // How often the status is refreshed
+	adminRetryInterval  = 5 * time.Second // Wait before reconnecting to the events
+	adminEventLines     = 8               // Recent events shown
+	adminRequestTimeout = 10 * time.Second
+)
+
+// serverStream is a stream in the server's /api/status
+type serverStream struct {
+	StationID string         `json:"station_id"`
+	Format    string         `json:"format"`
+	Running   bool           `json:"running"`
+	StartedAt time.Time      `json:"started_at"`
+	BytesRead int64          `json:"bytes_read"`
+	LastError string         `json:"last_error"`
+	Clients   []serverClient `json:"clients"`
+}
+
+// serverClient is a client of a stream in the server's /api/status
+type serverClient struct {
+	ID          string    `json:"id"`
+	IP          string    `json:"ip"`
+	ConnectedAt time.Time `json:"connected_at"`
+	BytesSent   int64     `json:"bytes_sent"`
+}
+
+// serverRecording is a recording in the server's /api/recordings
+type serverRecording struct {
+	StationID   string    `json:"station_id"`
+	StationName string    `json:"station_name"`
+	Title       string    `json:"title"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Elapsed     float64   `json:"elapsed"`
+	Paused      bool      `json:"paused"`
+}
+
+// serverEvent is an event pushed on the server's /api/events
+type serverEvent struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	StationID string    `json:"station_id"`
+	Format    string    `json:"format"`
+	ClientID  string    `json:"client_id"`
+	IP        string    `json:"ip"`
+	Clients   int       `json:"clients"`
+	Title     string    `json:"title"`
+	Error     string    `json:"error"`
+}
+
+type adminStatusMsg struct {
+	streams    []serverStream
+	recordings []serverRecording
+	err        error
+}
+type adminTickMsg struct{}
+type adminEventMsg struct{ event serverEvent }
+type adminConnMsg struct{ err error } // Events connection opened (nil) or lost
+type adminStopMsg struct {
+	stationID string
+	err       error
+}
+
+// adminKeys are the admin view shortcuts
+var adminKeys = struct {
+	Up, Down, Stop, Confirm, Refresh, Quit key.Binding
+}{
+	Up:      key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑", "上へ")),
+	Down:    key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓", "下へ")),
+	Stop:    key.NewBinding(key.WithKeys("x", "delete"), key.WithHelp("x", "強制停止")),
+	Confirm: key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "確定")),
+	Refresh: key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "更新")),
+	Quit:    key.NewBinding(key.WithKeys("q", "ctrl+c", "esc"), key.WithHelp("q", "終了")),
+}
+
+// adminAPI calls the admin endpoints of a server
+type adminAPI struct {
+	base   *url.URL // Server URL without credentials
+	user   *url.Userinfo
+	token  string
+	client *http.Client
+}
+
+// newAdminAPI parses a server URL, which may carry Basic auth credentials
+func newAdminAPI(serverURL, token string) (*adminAPI, error) {
+	u, err := url.Parse(strings.TrimSuffix(serverURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("サーバーURLが不正です: %s", serverURL)
+	}
+	user := u.User
+	u.User = nil
+	return &adminAPI{base: u, user: user, token: token, client: &http.Client{Timeout: adminRequestTimeout}}, nil
+}
+
+// authorize adds the token or Basic auth credentials to a request's headers
+func (a *adminAPI) authorize(h http.Header) {
+	switch {
+	case a.token != "":
+		h.Set("Authorization", "Bearer "+a.token)
+	case a.user != nil:
+		pass, _ := a.user.Password()
+		h.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(a.user.Username()+":"+pass)))
+	}
+}
+
+// do sends a request and decodes the JSON response into v (if not nil)
+func (a *adminAPI) do(method, path string, v any) error {
+	req, err := http.NewRequest(method, a.base.String()+path, nil)
+	if err != nil {
+		return err
+	}
+	a.authorize(req.Header)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("サーバーの認証に失敗しました (server_auth を確認してください)")
+	case resp.StatusCode >= 300:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(body)))
+	case v != nil:
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+	return nil
+}
+
+// fetchStatus reads the streams and the recordings in progress. Servers
+// without a scheduler have no recordings.
+func (a *adminAPI) fetchStatus() tea.Cmd {
+	return func() tea.Msg {
+		var status struct {
+			Streams []serverStream `json:"streams"`
+		}
+		if err := a.do(http.MethodGet, "/api/status", &status); err != nil {
+			return adminStatusMsg{err: err}
+		}
+		var recordings []serverRecording
+		a.do(http.MethodGet, "/api/recordings", &recordings)
+		return adminStatusMsg{streams: status.Streams, recordings: recordings}
+	}
+}
+
+// stopStream force-stops a station's streams
+func (a *adminAPI) stopStream(stationID string) tea.Cmd {
+	return func() tea.Msg {
+		err := a.do(http.MethodDelete, "/api/streams/"+url.PathEscape(stationID), nil)
+		return adminStopMsg{stationID: stationID, err: err}
+	}
+}
+
+// watchEvents sends the server's events to the program until ctx ends,
+// reconnecting when the connection drops
+func (a *adminAPI) watchEvents(ctx context.Context, p *tea.Program) {
+	ws := *a.base
+	ws.Scheme = map[string]string{"http": "ws", "https": "wss"}[a.base.Scheme]
+	ws.Path += "/api/events"
+
+	for {
+		config, err := websocket.NewConfig(ws.String(), a.base.String())
+		if err != nil {
+			p.Send(adminConnMsg{err: err})
+			return
+		}
+		a.authorize(config.Header)
+		conn, err := config.DialContext(ctx)
+		if err == nil {
+			p.Send(adminConnMsg{})
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			for err == nil {
+				var ev serverEvent
+				if err = websocket.JSON.Receive(conn, &ev); err == nil {
+					p.Send(adminEventMsg{event: ev})
+				}
+			}
+			stop()
+			conn.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		p.Send(adminConnMsg{err: err})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(adminRetryInterval):
+		}
+	}
+}
+
+// AdminModel is the remote control panel of a server
+type AdminModel struct {
+	api        *adminAPI
+	streams    []serverStream
+	recordings []serverRecording
+	events     []serverEvent // Most recent last
+	connected  bool          // Receiving events
+	cursor     int           // Selected stream
+	confirm    string        // Station waiting for the stop confirmation
+	message    string
+	err        error
+}
+
+// Init loads the status and starts polling
+func (m AdminModel) Init() tea.Cmd {
+	return tea.Batch(m.api.fetchStatus(), adminTick())
+}
+
+// adminTick schedules the next status refresh
+func adminTick() tea.Cmd {
+	return tea.Tick(adminPollInterval, func(time.Time) tea.Msg { return adminTickMsg{} })
+}
+
+// Update handles keys, status refreshes and events
+func (m AdminModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case adminTickMsg:
+		return m, tea.Batch(m.api.fetchStatus(), adminTick())
+
+	case adminStatusMsg:
+		m.err = msg.err
+		if msg.err == nil {
+			m.streams = msg.streams
+			m.recordings = msg.recordings
+			m.cursor = min(m.cursor, max(len(m.streams)-1, 0))
+		}
+
+	case adminEventMsg:
+		m.events = append(m.events, msg.event)
+		if len(m.events) > adminEventLines {
+			m.events = m.events[len(m.events)-adminEventLines:]
+		}
+		return m, m.api.fetchStatus()
+
+	case adminConnMsg:
+		m.connected = msg.err == nil
+
+	case adminStopMsg:
+		if msg.err != nil {
+			m.message = fmt.Sprintf("❌ 強制停止に失敗しました [%s]: %v", msg.stationID, msg.err)
+		} else {
+			m.message = fmt.Sprintf("🛑 強制停止しました: %s", msg.stationID)
+		}
+		return m, m.api.fetchStatus()
+
+	case tea.KeyMsg:
+		if m.confirm != "" {
+			stationID := m.confirm
+			m.confirm = ""
+			if key.Matches(msg, adminKeys.Confirm) {
+				m.message = fmt.Sprintf("⏳ 停止中: %s", stationID)
+				return m, m.api.stopStream(stationID)
+			}
+			m.message = ""
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, adminKeys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, adminKeys.Up):
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case key.Matches(msg, adminKeys.Down):
+			if m.cursor < len(m.streams)-1 {
+				m.cursor++
+			}
+		case key.Matches(msg, adminKeys.Refresh):
+			return m, m.api.fetchStatus()
+		case key.Matches(msg, adminKeys.Stop):
+			if m.cursor < len(m.streams) {
+				m.confirm = m.streams[m.cursor].StationID
+				m.message = fmt.Sprintf("⚠ %s の全形式のストリームを停止し、クライアントを切断しますか? (y/n)", m.confirm)
+			}
+		}
+	}
+	return m, nil
+}
+
+// View renders the streams, recordings and recent events
+func (m AdminModel) View() string {
+	var b strings.Builder
+
+	conn := errorStyle.Render("○ イベント未接続")
+	if m.connected {
+		conn = nowPlayingStyle.Render("● イベント受信中")
+	}
+	b.WriteString(titleStyle.Render("📻 Radiko サーバー管理") + "  " + statusStyle.Render(m.api.base.String()) + "  " + conn + "\n")
+	b.WriteString(strings.Repeat("─", 50) + "\n")
+
+	clients := 0
+	for _, st := range m.streams {
+		clients += len(st.Clients)
+	}
+	b.WriteString(focusIndicatorStyle.Render(fmt.Sprintf("ストリーム (%d) / クライアント (%d)", len(m.streams), clients)) + "\n")
+	if len(m.streams) == 0 {
+		b.WriteString(statusStyle.Render("  再生中のストリームはありません") + "\n")
+	}
+	for i, st := range m.streams {
+		state := stationPlayingStyle.Render("▶")
+		if !st.Running {
+			state = reconnectStyle.Render("⏸")
+		}
+		line := fmt.Sprintf("%-8s %-12s %d クライアント", st.StationID, st.Format, len(st.Clients))
+		if !st.StartedAt.IsZero() {
+			line += fmt.Sprintf("  %s %s", adminDuration(time.Since(st.StartedAt)), adminBytes(st.BytesRead))
+		}
+		if i == m.cursor {
+			b.WriteString(focusIndicatorStyle.Render("> ") + state + " " + stationSelectedStyle.Render(line) + "\n")
+		} else {
+			b.WriteString("  " + state + " " + stationNameStyle.Render(line) + "\n")
+		}
+		for _, c := range st.Clients {
+			b.WriteString(statusStyle.Render(fmt.Sprintf("      %-15s %8s %9s  %s",
+				c.IP, adminDuration(time.Since(c.ConnectedAt)), adminBytes(c.BytesSent), c.ID)) + "\n")
+		}
+		if st.LastError != "" {
+			b.WriteString(reconnectStyle.Render("      ⚠️ "+st.LastError) + "\n")
+		}
+	}
+
+	b.WriteString("\n" + focusIndicatorStyle.Render(fmt.Sprintf("録音中 (%d)", len(m.recordings))) + "\n")
+	if len(m.recordings) == 0 {
+		b.WriteString(statusStyle.Render("  録音中の番組はありません") + "\n")
+	}
+	for _, rec := range m.recordings {
+		name := rec.StationName
+		if name == "" {
+			name = rec.StationID
+		}
+		mark := recordingStyle.Render("●")
+		if rec.Paused {
+			mark = reconnectStyle.Render("⏸")
+		}
+		until := ""
+		if !rec.End.IsZero() {
+			until = " 〜" + rec.End.Local().Format("15:04")
+		}
+		b.WriteString(fmt.Sprintf("  %s %s %s %s\n", mark, stationNameStyle.Render(name), programStyle.Render(rec.Title),
+			statusStyle.Render(adminDuration(time.Duration(rec.Elapsed*float64(time.Second)))+until)))
+	}
+
+	b.WriteString("\n" + focusIndicatorStyle.Render("イベント") + "\n")
+	if len(m.events) == 0 {
+		b.WriteString(statusStyle.Render("  まだイベントはありません") + "\n")
+	}
+	for _, ev := range m.events {
+		b.WriteString(statusStyle.Render("  "+ev.Time.Local().Format("15:04:05")) + " " + adminEventText(ev) + "\n")
+	}
+
+	b.WriteString(strings.Repeat("─", 50) + "\n")
+	switch {
+	case m.err != nil:
+		b.WriteString(errorStyle.Render("❌ "+m.err.Error()) + "\n")
+	case m.message != "":
+		b.WriteString(volumeStyle.Render(m.message) + "\n")
+	default:
+		b.WriteString("\n")
+	}
+	b.WriteString(statusStyle.Render("↑↓ 選択  x 強制停止  r 更新  q 終了"))
+	return b.String()
+}
+
+// adminEventText describes an event in one line
+func adminEventText(ev serverEvent) string {
+	stream := strings.TrimSpace(ev.StationID + " " + ev.Format)
+	switch ev.Type {
+	case "client_connected":
+		return fmt.Sprintf("🔗 接続 %s ← %s (%d)", stream, ev.IP, ev.Clients)
+	case "client_disconnected":
+		return fmt.Sprintf("👋 切断 %s ← %s (%d)", stream, ev.IP, ev.Clients)
+	case "stream_started":
+		return "🆕 開始 " + stream
+	case "stream_stopped":
+		return "🗑️ 停止 " + stream
+	case "program_changed":
+		return programStyle.Render("🎵 " + ev.Title)
+	case "track_changed":
+		return programStyle.Render(fmt.Sprintf("🎶 %s %s", ev.StationID, ev.Title))
+	case "error":
+		return errorStyle.Render(fmt.Sprintf("❌ %s: %s", stream, ev.Error))
+	}
+	return ev.Type + " " + stream
+}
+
+// adminDuration formats a duration as h:mm:ss or m:ss
+func adminDuration(d time.Duration) string {
+	s := int(d.Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// adminBytes formats a byte count with a binary unit
+func adminBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// RunAdmin starts the admin view of the server at serverURL. token is the
+// server_auth token; Basic auth credentials can be given in the URL.
+func RunAdmin(serverURL, token string) error {
+	a, err := newAdminAPI(serverURL, token)
+	if err != nil {
+		return err
+	}
+	// Fail before entering the alt screen when the server is unreachable
+	if err := a.do(http.MethodGet, "/api/status", nil); err != nil {
+		return err
+	}
+
+	p := tea.NewProgram(AdminModel{api: a}, tea.WithAltScreen())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.watchEvents(ctx, p)
+
+	_, err = p.Run()
+	return err
+}
@@ -0,0 +1,250 @@
+//go:build !noaudio
+
+package tui
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/cast"
+	"github.com/kanoshiou/radiko-tui/model"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type castDevicesMsg struct {
+	devices []cast.Device
+	err     error
+}
+type castResultMsg struct {
+	session *cast.Session
+	station model.Station
+	err     error
+}
+type castEndedMsg struct{ session *cast.Session }
+type castVolumeMsg struct{ err error }
+
+// discoverCastDevices searches the LAN for cast devices
+func (m *Model) discoverCastDevices() tea.Cmd {
+	m.isLoading = true
+	m.statusMessage = "キャスト先を検索中..."
+	return func() tea.Msg {
+		devices, err := cast.Discover(cast.DiscoverTimeout)
+		return castDevicesMsg{devices: devices, err: err}
+	}
+}
+
+// castStation casts a station's stream from the server to a device.
+// The device fetches the stream itself, so it needs the server (client mode).
+func (m *Model) castStation(device cast.Device, station model.Station) tea.Cmd {
+	shared := m.shared
+	m.isLoading = true
+	m.statusMessage = fmt.Sprintf("%s に接続中...", device.Name)
+	return func() tea.Msg {
+		streamURL, err := castStreamURL(shared.ServerURL, shared.ServerToken, device, station.ID)
+		if err != nil {
+			return castResultMsg{station: station, err: err}
+		}
+		session, err := cast.Connect(device)
+		if err != nil {
+			return castResultMsg{station: station, err: err}
+		}
+		if err := session.Load(streamURL, "audio/aac", station.Name, api.GetStationLogoURL(station.ID)); err != nil {
+			session.Close()
+			return castResultMsg{station: station, err: err}
+		}
+		return castResultMsg{session: session, station: station}
+	}
+}
+
+// castStreamURL returns the server's AAC stream URL as seen from the device
+func castStreamURL(serverURL, token string, device cast.Device, stationID string) (string, error) {
+	if serverURL == "" {
+		return "", fmt.Errorf("キャストするには --server-url でサーバーに接続してください")
+	}
+	u, err := url.Parse(strings.TrimSuffix(serverURL, "/") + "/api/play/" + stationID)
+	if err != nil {
+		return "", err
+	}
+	// localhost on this machine is not localhost on the device
+	if ip := net.ParseIP(u.Hostname()); u.Hostname() == "localhost" || (ip != nil && ip.IsLoopback()) {
+		local, err := cast.LocalIP(device)
+		if err != nil {
+			return "", err
+		}
+		host := local.String()
+		if port := u.Port(); port != "" {
+			host = net.JoinHostPort(host, port)
+		}
+		u.Host = host
+	}
+	if token != "" {
+		u.RawQuery = "token=" + url.QueryEscape(token)
+	}
+	return u.String(), nil
+}
+
+// waitCastEnd reports when the device stops playing or the connection is lost
+func waitCastEnd(session *cast.Session) tea.Cmd {
+	return func() tea.Msg {
+		<-session.Done()
+		return castEndedMsg{session: session}
+	}
+}
+
+// stopCast stops playback on the device
+func (m *Model) stopCast() tea.Cmd {
+	session := m.shared.Cast
+	m.shared.Cast = nil
+	m.statusMessage = fmt.Sprintf("キャスト停止: %s", session.Device.Name)
+	return func() tea.Msg {
+		session.Stop()
+		return nil
+	}
+}
+
+// castVolume changes the device volume by delta
+func (m *Model) castVolume(delta float64) tea.Cmd {
+	session := m.shared.Cast
+	return func() tea.Msg {
+		level, _ := session.Volume()
+		return castVolumeMsg{err: session.SetVolume(level + delta)}
+	}
+}
+
+// castSetVolume sets the device volume
+func (m *Model) castSetVolume(level float64) tea.Cmd {
+	session := m.shared.Cast
+	return func() tea.Msg {
+		return castVolumeMsg{err: session.SetVolume(level)}
+	}
+}
+
+// castToggleMute mutes or unmutes the device
+func (m *Model) castToggleMute() tea.Cmd {
+	session := m.shared.Cast
+	return func() tea.Msg {
+		_, muted := session.Volume()
+		return castVolumeMsg{err: session.SetMuted(!muted)}
+	}
+}
+
+// handleCastMsg handles the results of cast commands
+func (m Model) handleCastMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case castDevicesMsg:
+		m.isLoading = false
+		m.statusMessage = ""
+		if msg.err != nil {
+			m.errorMessage = fmt.Sprintf("キャスト先の検索に失敗: %v", msg.err)
+			return m, nil
+		}
+		if len(msg.devices) == 0 {
+			m.errorMessage = "キャスト先が見つかりません"
+			return m, nil
+		}
+		m.castDevices = msg.devices
+		m.castCursor = 0
+		m.focus = FocusCast
+		return m, nil
+
+	case castResultMsg:
+		m.isLoading = false
+		m.statusMessage = ""
+		if msg.err != nil {
+			m.errorMessage = fmt.Sprintf("キャスト失敗: %v", msg.err)
+			return m, nil
+		}
+		// Play on the device only
+		if m.shared.Player != nil {
+			m.shared.Player.Stop()
+		}
+		if m.shared.Cast != nil && m.shared.Cast != msg.session {
+			m.shared.Cast.Close()
+		}
+		m.shared.Cast = msg.session
+		m.shared.Playing = &PlayingInfo{StationID: msg.station.ID, StationName: m.displayName(msg.station)}
+		m.statusMessage = fmt.Sprintf("キャスト開始: %s → %s", m.displayName(msg.station), msg.session.Device.Name)
+		m.saveConfig()
+		return m, tea.Batch(waitCastEnd(msg.session), fetchProgramCmd(m.shared, msg.station.ID))
+
+	case castEndedMsg:
+		if m.shared.Cast == msg.session {
+			m.shared.Cast = nil
+			m.shared.Playing = nil
+			m.statusMessage = fmt.Sprintf("キャストが終了しました: %s", msg.session.Device.Name)
+		}
+		return m, nil
+
+	case castVolumeMsg:
+		if msg.err != nil {
+			m.errorMessage = fmt.Sprintf("キャスト音量の変更に失敗: %v", msg.err)
+		}
+		return m, nil
+	}
+	return m, nil
+}
+
+// handleCastKeys handles keyboard input in the cast device list
+func (m Model) handleCastKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.castCursor > 0 {
+			m.castCursor--
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Down):
+		if m.castCursor < len(m.castDevices)-1 {
+			m.castCursor++
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Select):
+		m.focus = FocusStations
+		if m.castCursor < len(m.castDevices) && len(m.stations) > 0 {
+			return m, m.castStation(m.castDevices[m.castCursor], m.stations[m.cursor])
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Quit), key.Matches(msg, m.keys.Cast):
+		m.focus = FocusStations
+		return m, nil
+	}
+	return m, nil
+}
+
+// renderCastDevices renders the cast device list
+func (m Model) renderCastDevices() string {
+	var lines []string
+	title := "📺 キャスト先"
+	if len(m.stations) > 0 {
+		title += " ← " + m.stations[m.cursor].Name
+	}
+	lines = append(lines, titleStyle.Render(title))
+	for i, d := range m.castDevices {
+		text := fmt.Sprintf("%s  %s", d.Name, d.Host)
+		if i == m.castCursor {
+			lines = append(lines, stationSelectedStyle.Render(text))
+		} else {
+			lines = append(lines, "  "+stationNameStyle.Render(text))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// renderCastVolume renders the device volume while casting
+func (m Model) renderCastVolume() string {
+	level, muted := m.shared.Cast.Volume()
+	if level < 0 {
+		return volumeStyle.Render("📺 --%")
+	}
+	if muted {
+		return statusStyle.Render(fmt.Sprintf("📺🔇 %d%%", int(level*100+0.5)))
+	}
+	return volumeStyle.Render(fmt.Sprintf("📺 %d%%", int(level*100+0.5)))
+}
@@ -0,0 +1,60 @@
+//go:build !noaudio
+
+package tui
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+)
+
+// serverEPG is a station's program list from the server's EPG cache
+type serverEPG struct {
+	Now      *model.Program  `json:"now"`
+	Programs []model.Program `json:"programs"`
+}
+
+// programs returns a station's programs for a broadcast day. In client mode
+// they come from the server's cache, shared with its other clients, falling
+// back to radiko for servers without it.
+func (s *SharedState) programs(ctx context.Context, stationID string, date time.Time) ([]model.Program, error) {
+	if s.ServerURL != "" {
+		if epg, err := s.serverEPG(stationID, date); err == nil {
+			return epg.Programs, nil
+		}
+	}
+	return api.GetPrograms(ctx, stationID, date)
+}
+
+// currentProgram returns the program on air on a station, from the
+// server's cache in client mode
+func (s *SharedState) currentProgram(stationID string) (*model.Program, error) {
+	if s.ServerURL != "" {
+		if epg, err := s.serverEPG(stationID, time.Time{}); err == nil {
+			return epg.Now, nil
+		}
+	}
+	return api.GetCurrentProgram(s.ctx, stationID)
+}
+
+// serverEPG fetches a station's programs for a broadcast day (zero for
+// today) from the server
+func (s *SharedState) serverEPG(stationID string, date time.Time) (*serverEPG, error) {
+	a, err := newAdminAPI(s.ServerURL, s.ServerToken)
+	if err != nil {
+		return nil, err
+	}
+	path := "/api/epg/" + url.PathEscape(stationID)
+	if !date.IsZero() {
+		path += "?date=" + date.In(model.JST).Format("20060102")
+	}
+	var epg serverEPG
+	if err := a.do(http.MethodGet, path, &epg); err != nil {
+		return nil, err
+	}
+	return &epg, nil
+}
@@ -0,0 +1,112 @@
+//go:build !noaudio
+
+package tui
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/kanoshiou/radiko-tui/config"
+	"github.com/kanoshiou/radiko-tui/model"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type favoritesSavedMsg struct {
+	err error
+}
+
+// favoritesFirst returns the stations with the favorites first, in the
+// order of favorites, and the others in their own order after them
+func favoritesFirst(stations []model.Station, favorites []string) []model.Station {
+	rank := func(stationID string) int {
+		if i := slices.Index(favorites, stationID); i >= 0 {
+			return i
+		}
+		return len(favorites)
+	}
+	sorted := slices.Clone(stations)
+	slices.SortStableFunc(sorted, func(a, b model.Station) int {
+		return rank(a.ID) - rank(b.ID)
+	})
+	return sorted
+}
+
+// setStations lists stations loaded from radiko, favorites first
+func (m *Model) setStations(stations []model.Station) {
+	m.loadedStations = stations
+	m.sortStations()
+}
+
+// sortStations lists the favorites first, keeping the cursor and the
+// station to autoplay on their stations
+func (m *Model) sortStations() {
+	stationAt := func(i int) string {
+		if i >= 0 && i < len(m.stations) {
+			return m.stations[i].ID
+		}
+		return ""
+	}
+	selected, autoPlay := stationAt(m.cursor), stationAt(m.autoPlayIdx)
+	m.stations = favoritesFirst(m.loadedStations, m.favorites)
+	for i, s := range m.stations {
+		switch s.ID {
+		case selected:
+			m.cursor = i
+		case autoPlay:
+			m.autoPlayIdx = i
+		}
+	}
+	if selected == autoPlay {
+		m.autoPlayIdx = m.cursor
+	}
+}
+
+// isFavorite reports whether a station is a favorite
+func (m Model) isFavorite(stationID string) bool {
+	return slices.Contains(m.favorites, stationID)
+}
+
+// toggleFavorite adds the selected station to the favorites, or removes it
+func (m *Model) toggleFavorite() tea.Cmd {
+	if len(m.stations) == 0 {
+		return nil
+	}
+	station := m.stations[m.cursor]
+	if m.isFavorite(station.ID) {
+		m.statusMessage = fmt.Sprintf("☆ %s をお気に入りから外しました", m.displayName(station))
+		return m.updateFavorites(func(cfg *config.Config) bool {
+			return cfg.RemoveFavorite(station.ID)
+		})
+	}
+	m.statusMessage = fmt.Sprintf("★ %s をお気に入りに追加しました", m.displayName(station))
+	return m.updateFavorites(func(cfg *config.Config) bool {
+		return cfg.AddFavorite(station.ID)
+	})
+}
+
+// moveFavorite moves the selected favorite by offset places
+func (m *Model) moveFavorite(offset int) tea.Cmd {
+	if len(m.stations) == 0 || !m.isFavorite(m.stations[m.cursor].ID) {
+		return nil
+	}
+	stationID := m.stations[m.cursor].ID
+	return m.updateFavorites(func(cfg *config.Config) bool {
+		return cfg.MoveFavorite(stationID, offset)
+	})
+}
+
+// updateFavorites changes the favorites with fn, then applies the same
+// change to config.json in the background
+func (m *Model) updateFavorites(fn func(cfg *config.Config) bool) tea.Cmd {
+	cfg := config.Config{Favorites: slices.Clone(m.favorites)}
+	if !fn(&cfg) {
+		return nil
+	}
+	m.favorites = cfg.Favorites
+	m.sortStations()
+	saved := config.Queue(func(cfg *config.Config) { fn(cfg) })
+	return func() tea.Msg {
+		return favoritesSavedMsg{err: <-saved}
+	}
+}
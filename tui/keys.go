@@ -0,0 +1,148 @@
+//go:build !noaudio
+
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keyActions are the names of the KeyMap's bindings in config.json
+var keyActions = []struct {
+	name    string
+	binding func(k *KeyMap) *key.Binding
+}{
+	{"up", func(k *KeyMap) *key.Binding { return &k.Up }},
+	{"down", func(k *KeyMap) *key.Binding { return &k.Down }},
+	{"left", func(k *KeyMap) *key.Binding { return &k.Left }},
+	{"right", func(k *KeyMap) *key.Binding { return &k.Right }},
+	{"select", func(k *KeyMap) *key.Binding { return &k.Select }},
+	{"volume_up", func(k *KeyMap) *key.Binding { return &k.VolUp }},
+	{"volume_down", func(k *KeyMap) *key.Binding { return &k.VolDown }},
+	{"mute", func(k *KeyMap) *key.Binding { return &k.Mute }},
+	{"reconnect", func(k *KeyMap) *key.Binding { return &k.Reconnect }},
+	{"record", func(k *KeyMap) *key.Binding { return &k.Record }},
+	{"programs", func(k *KeyMap) *key.Binding { return &k.Programs }},
+	{"format", func(k *KeyMap) *key.Binding { return &k.Format }},
+	{"recordings", func(k *KeyMap) *key.Binding { return &k.Recordings }},
+	{"pause", func(k *KeyMap) *key.Binding { return &k.Pause }},
+	{"search", func(k *KeyMap) *key.Binding { return &k.Search }},
+	{"cast", func(k *KeyMap) *key.Binding { return &k.Cast }},
+	{"detect", func(k *KeyMap) *key.Binding { return &k.Detect }},
+	{"confirm", func(k *KeyMap) *key.Binding { return &k.Confirm }},
+	{"favorite", func(k *KeyMap) *key.Binding { return &k.Favorite }},
+	{"move_up", func(k *KeyMap) *key.Binding { return &k.MoveUp }},
+	{"move_down", func(k *KeyMap) *key.Binding { return &k.MoveDown }},
+	{"quit", func(k *KeyMap) *key.Binding { return &k.Quit }},
+}
+
+// keyNames are the names bubbletea gives special keys, e.g. "enter" or "ctrl+x"
+var keyNames = sync.OnceValue(func() map[string]bool {
+	names := make(map[string]bool)
+	for t := tea.KeyType(-256); t < 256; t++ {
+		if name := t.String(); name != "" {
+			names[name] = true
+		}
+	}
+	return names
+})
+
+// keyHelpNames are how keys are shown in the help
+var keyHelpNames = map[string]string{
+	"up":    "↑",
+	"down":  "↓",
+	"left":  "←",
+	"right": "→",
+	" ":     "Space",
+	"enter": "Enter",
+	"esc":   "Esc",
+	"tab":   "Tab",
+}
+
+// parseKey checks a key name of config.json, returning it as bubbletea names it
+func parseKey(name string) (string, error) {
+	if name == "space" {
+		return " ", nil
+	}
+	base := strings.TrimPrefix(name, "alt+")
+	if r, size := utf8.DecodeRuneInString(base); size == len(base) && r != utf8.RuneError && unicode.IsPrint(r) && r != ' ' {
+		return name, nil
+	}
+	if keyNames()[base] {
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown key %q", name)
+}
+
+// KeyMapFromConfig returns DefaultKeyMap with the actions of config.json's
+// keys section bound to their keys instead
+func KeyMapFromConfig(keys map[string][]string) (KeyMap, error) {
+	km := DefaultKeyMap
+	known := make(map[string]bool, len(keyActions))
+	for _, action := range keyActions {
+		known[action.name] = true
+	}
+	for name := range keys {
+		if !known[name] {
+			names := make([]string, len(keyActions))
+			for i, action := range keyActions {
+				names[i] = action.name
+			}
+			return km, fmt.Errorf("unknown action %q (%s)", name, strings.Join(names, ", "))
+		}
+	}
+
+	bound := make(map[string]string) // Action of each key
+	for _, action := range keyActions {
+		binding := action.binding(&km)
+		names, ok := keys[action.name]
+		if ok {
+			if len(names) == 0 {
+				return km, fmt.Errorf("%s has no key", action.name)
+			}
+			parsed := make([]string, len(names))
+			for i, name := range names {
+				k, err := parseKey(name)
+				if err != nil {
+					return km, fmt.Errorf("%s: %w", action.name, err)
+				}
+				if k >= "0" && k <= "9" {
+					return km, fmt.Errorf("%s: key %q sets the volume", action.name, name)
+				}
+				parsed[i] = k
+			}
+			help := parsed[0]
+			if name, ok := keyHelpNames[help]; ok {
+				help = name
+			}
+			*binding = key.NewBinding(key.WithKeys(parsed...), key.WithHelp(help, binding.Help().Desc))
+		}
+		for _, k := range binding.Keys() {
+			if other, ok := bound[k]; ok {
+				return km, fmt.Errorf("key %q is bound to both %s and %s", k, other, action.name)
+			}
+			bound[k] = action.name
+		}
+	}
+	return km, nil
+}
+
+// helpKeys shows the keys of bindings, e.g. "↑↓", or "w/s" when one has a
+// longer name
+func helpKeys(bindings ...key.Binding) string {
+	keys := make([]string, len(bindings))
+	sep := ""
+	for i, b := range bindings {
+		keys[i] = b.Help().Key
+		if utf8.RuneCountInString(keys[i]) > 1 {
+			sep = "/"
+		}
+	}
+	return strings.Join(keys, sep)
+}
@@ -0,0 +1,155 @@
+//go:build !noaudio
+
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/model"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// searchLimit is the number of programs a search lists
+const searchLimit = 50
+
+type searchResultsMsg struct {
+	results []model.SearchResult
+	err     error
+}
+
+// searchPrograms searches the programs of the current area, or of every
+// area while browsing them all
+func (m *Model) searchPrograms() tea.Cmd {
+	m.isLoading = true
+	m.statusMessage = fmt.Sprintf("「%s」を検索中...", m.searchQuery)
+	query := api.SearchQuery{Keyword: m.searchQuery, Limit: searchLimit}
+	if !m.allAreas() {
+		query.AreaID = m.getCurrentAreaID()
+	}
+	ctx := m.shared.ctx
+	return func() tea.Msg {
+		results, err := api.SearchPrograms(ctx, query)
+		return searchResultsMsg{results: results, err: err}
+	}
+}
+
+// stationName returns the name of a listed station, or its ID
+func (m Model) stationName(stationID string) string {
+	for _, st := range m.stations {
+		if st.ID == stationID {
+			return m.displayName(st)
+		}
+	}
+	return stationID
+}
+
+// handleSearchKeys handles keyboard input in the program search
+func (m Model) handleSearchKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.searchTyping {
+		switch msg.Type {
+		case tea.KeyEnter:
+			if strings.TrimSpace(m.searchQuery) == "" {
+				return m, nil
+			}
+			m.searchTyping = false
+			return m, m.searchPrograms()
+		case tea.KeyEsc, tea.KeyCtrlC:
+			m.searchTyping = false
+			if len(m.searchResults) == 0 {
+				m.focus = FocusStations
+			}
+		case tea.KeyBackspace:
+			if runes := []rune(m.searchQuery); len(runes) > 0 {
+				m.searchQuery = string(runes[:len(runes)-1])
+			}
+		case tea.KeyRunes, tea.KeySpace:
+			m.searchQuery += string(msg.Runes)
+		}
+		return m, nil
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.searchCursor > 0 {
+			m.searchCursor--
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Down):
+		if m.searchCursor < len(m.searchResults)-1 {
+			m.searchCursor++
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Select), key.Matches(msg, m.keys.Record):
+		if m.searchCursor >= len(m.searchResults) || m.shared.Scheduler == nil {
+			return m, nil
+		}
+		r := m.searchResults[m.searchCursor]
+		m.recordProgram(r.StationID, m.stationName(r.StationID), r.Program)
+		return m, nil
+
+	case key.Matches(msg, m.keys.Format):
+		m.cycleRecordFormat()
+		return m, nil
+
+	case key.Matches(msg, m.keys.Search):
+		m.searchTyping = true
+		return m, nil
+
+	case key.Matches(msg, m.keys.Quit):
+		m.focus = FocusStations
+		return m, nil
+	}
+	return m, nil
+}
+
+// renderSearch renders the search prompt and the programs found
+func (m Model) renderSearch(maxHeight int) string {
+	var lines []string
+	header := "🔍 番組検索  " + m.searchQuery
+	if m.searchTyping {
+		header += "▏"
+	}
+	lines = append(lines, titleStyle.Render(header))
+
+	maxVisible := maxHeight - 3 // Leave space for title and status messages
+	startIdx := 0
+	if m.searchCursor >= maxVisible {
+		startIdx = m.searchCursor - maxVisible + 1
+	}
+
+	now := time.Now()
+	for i := startIdx; i < len(m.searchResults) && i < startIdx+maxVisible; i++ {
+		r := m.searchResults[i]
+		start, _ := r.StartTime()
+		end, _ := r.EndTime()
+		text := fmt.Sprintf("%s-%s %s  %s", start.Format("01/02 15:04"), end.Format("15:04"), m.stationName(r.StationID), r.Title)
+
+		var styled string
+		switch {
+		case i == m.searchCursor && !m.searchTyping:
+			styled = stationSelectedStyle.Render(text)
+		case !now.Before(start) && now.Before(end):
+			styled = stationPlayingStyle.Render("▶ " + text)
+		case !now.Before(end):
+			styled = stationIDStyle.Render("  " + text)
+		default:
+			styled = stationNameStyle.Render("  " + text)
+		}
+		lines = append(lines, styled)
+	}
+
+	if m.errorMessage != "" {
+		lines = append(lines, errorStyle.Render("✗ "+m.errorMessage))
+	} else if m.statusMessage != "" {
+		lines = append(lines, statusStyle.Render(m.statusMessage))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
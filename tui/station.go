@@ -0,0 +1,86 @@
+//go:build !noaudio
+
+package tui
+
+import (
+	"fmt"
+
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/config"
+	"github.com/kanoshiou/radiko-tui/model"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// checkStationSettings validates the stations section of config.json
+func checkStationSettings(settings map[string]config.StationSettings) error {
+	for stationID, s := range settings {
+		if s.Volume != nil && (*s.Volume < 0 || *s.Volume > 1) {
+			return fmt.Errorf("%s: volume must be 0.0-1.0", stationID)
+		}
+		switch api.Quality(s.Quality) {
+		case api.QualityDefault, api.QualityLow, api.QualityHigh:
+		default:
+			return fmt.Errorf("%s: unknown quality %q (low, high)", stationID, s.Quality)
+		}
+	}
+	return nil
+}
+
+// displayName returns the name a station is shown with: its alias, or
+// radiko's name
+func (m Model) displayName(station model.Station) string {
+	if alias := m.stationSettings[station.ID].Alias; alias != "" {
+		return alias
+	}
+	return station.Name
+}
+
+// applyStationVolume sets the volume of a station that has its own, or
+// goes back to the global volume
+func (m *Model) applyStationVolume(stationID string) {
+	if m.shared.Player == nil {
+		return
+	}
+	volume := m.globalVolume
+	if v := m.stationSettings[stationID].Volume; v != nil {
+		volume = *v
+	}
+	if volume == m.shared.Player.GetVolume() {
+		return
+	}
+	muted := m.shared.Player.IsMuted()
+	m.shared.Player.SetVolume(volume)
+	if muted {
+		m.shared.Player.ToggleMute()
+	}
+	m.shared.Volume = m.shared.Player.GetVolume()
+}
+
+// savedVolume returns the volume to save in config.json. A station's own
+// volume lasts only while it plays, so the global one is kept then.
+func (m *Model) savedVolume() float64 {
+	volume := m.shared.Volume
+	if m.shared.Player != nil {
+		volume = m.shared.Player.GetVolume()
+	}
+	if m.shared.Playing != nil && m.stationSettings[m.shared.Playing.StationID].Volume != nil {
+		return m.globalVolume
+	}
+	m.globalVolume = volume
+	return volume
+}
+
+// autoRecord starts recording a station set to be recorded whenever it is
+// played
+func (m *Model) autoRecord(stationID string) tea.Cmd {
+	if !m.stationSettings[stationID].AutoRecord || m.shared.Recorder == nil || m.shared.Recorder.Get(stationID) != nil {
+		return nil
+	}
+	for _, s := range m.stations {
+		if s.ID == stationID {
+			return m.toggleRecording(s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/kanoshiou/radiko-tui/config"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme colors
+var (
+	primaryColor      = lipgloss.Color("#7C3AED")
+	secondaryColor    = lipgloss.Color("#10B981")
+	accentColor       = lipgloss.Color("#F59E0B")
+	textColor         = lipgloss.Color("#CDD6F4")
+	dimTextColor      = lipgloss.Color("#6C7086")
+	playingColor      = lipgloss.Color("#A6E3A1")
+	regionColor       = lipgloss.Color("#89B4FA")
+	warningColor      = lipgloss.Color("#FAB387")
+	recordingColor    = lipgloss.Color("#F38BA8")
+	errorColor        = lipgloss.Color("#F38BA8")
+	programColor      = lipgloss.Color("#CBA6F7")
+	selectedTextColor = lipgloss.Color("#1E1E2E")
+)
+
+// Styles
+var (
+	titleStyle                  lipgloss.Style
+	regionItemStyle             lipgloss.Style
+	regionSelectedStyle         lipgloss.Style
+	regionCurrentStyle          lipgloss.Style
+	stationNameStyle            lipgloss.Style
+	stationIDStyle              lipgloss.Style
+	stationSelectedStyle        lipgloss.Style
+	stationPlayingStyle         lipgloss.Style
+	stationSelectedPlayingStyle lipgloss.Style
+	statusStyle                 lipgloss.Style
+	errorStyle                  lipgloss.Style
+	volumeStyle                 lipgloss.Style
+	focusIndicatorStyle         lipgloss.Style
+	programStyle                lipgloss.Style
+	nowPlayingStyle             lipgloss.Style
+	reconnectStyle              lipgloss.Style
+	recordingStyle              lipgloss.Style
+	favoriteStyle               lipgloss.Style
+)
+
+func init() {
+	setStyles()
+}
+
+// setStyles builds the styles from the theme colors
+func setStyles() {
+	titleStyle = lipgloss.NewStyle().Foreground(primaryColor).Bold(true)
+	regionItemStyle = lipgloss.NewStyle().Foreground(textColor)
+	regionSelectedStyle = lipgloss.NewStyle().Foreground(selectedTextColor).Background(regionColor).Bold(true).Padding(0, 1)
+	regionCurrentStyle = lipgloss.NewStyle().Foreground(secondaryColor).Bold(true)
+	stationNameStyle = lipgloss.NewStyle().Foreground(textColor)
+	stationIDStyle = lipgloss.NewStyle().Foreground(dimTextColor)
+	stationSelectedStyle = lipgloss.NewStyle().Foreground(selectedTextColor).Background(primaryColor).Bold(true).Padding(0, 1)
+	stationPlayingStyle = lipgloss.NewStyle().Foreground(playingColor).Bold(true)
+	stationSelectedPlayingStyle = lipgloss.NewStyle().Foreground(selectedTextColor).Background(secondaryColor).Bold(true).Padding(0, 1)
+	statusStyle = lipgloss.NewStyle().Foreground(dimTextColor)
+	errorStyle = lipgloss.NewStyle().Foreground(errorColor)
+	volumeStyle = lipgloss.NewStyle().Foreground(accentColor)
+	focusIndicatorStyle = lipgloss.NewStyle().Foreground(accentColor).Bold(true)
+	programStyle = lipgloss.NewStyle().Foreground(programColor)
+	nowPlayingStyle = lipgloss.NewStyle().Foreground(playingColor).Bold(true)
+	reconnectStyle = lipgloss.NewStyle().Foreground(warningColor)
+	recordingStyle = lipgloss.NewStyle().Foreground(recordingColor).Bold(true)
+	favoriteStyle = lipgloss.NewStyle().Foreground(accentColor)
+}
+
+// hexColor matches "#RGB" and "#RRGGBB"
+var hexColor = regexp.MustCompile(`^#([0-9A-Fa-f]{3}|[0-9A-Fa-f]{6})$`)
+
+// ApplyTheme colors the interface with the theme selected in config.json.
+// Colors the theme leaves out keep their defaults.
+func ApplyTheme(cfg config.Config) error {
+	if cfg.Theme == "" || cfg.Theme == "default" {
+		return nil
+	}
+	theme, ok := cfg.Themes[cfg.Theme]
+	if !ok {
+		return fmt.Errorf("unknown theme %q", cfg.Theme)
+	}
+	colors := []struct {
+		role  string
+		value string
+		color *lipgloss.Color
+	}{
+		{"primary", theme.Primary, &primaryColor},
+		{"secondary", theme.Secondary, &secondaryColor},
+		{"accent", theme.Accent, &accentColor},
+		{"text", theme.Text, &textColor},
+		{"dim_text", theme.DimText, &dimTextColor},
+		{"playing", theme.Playing, &playingColor},
+		{"region", theme.Region, &regionColor},
+		{"warning", theme.Warning, &warningColor},
+		{"recording", theme.Recording, &recordingColor},
+		{"error", theme.Error, &errorColor},
+		{"program", theme.Program, &programColor},
+		{"selected_text", theme.SelectedText, &selectedTextColor},
+	}
+	for _, c := range colors {
+		if c.value != "" && !hexColor.MatchString(c.value) {
+			return fmt.Errorf("theme %s: %s must be a hex color like #RRGGBB: %q", cfg.Theme, c.role, c.value)
+		}
+	}
+	for _, c := range colors {
+		if c.value != "" {
+			*c.color = lipgloss.Color(c.value)
+		}
+	}
+	setStyles()
+	return nil
+}
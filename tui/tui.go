@@ -3,18 +3,26 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
-	"radiko-tui/api"
-	"radiko-tui/config"
-	"radiko-tui/model"
-	"radiko-tui/player"
+	"github.com/kanoshiou/radiko-tui/api"
+	"github.com/kanoshiou/radiko-tui/cast"
+	"github.com/kanoshiou/radiko-tui/config"
+	"github.com/kanoshiou/radiko-tui/model"
+	"github.com/kanoshiou/radiko-tui/player"
+	"github.com/kanoshiou/radiko-tui/recorder"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 )
 
 // FocusMode represents the current focus area
@@ -24,21 +32,36 @@ const (
 	FocusStations FocusMode = iota
 	FocusRegion
 	FocusVolume
+	FocusPrograms
+	FocusRecordings
+	FocusCast
+	FocusSearch
 )
 
 // KeyMap defines keyboard shortcuts
 type KeyMap struct {
-	Up        key.Binding
-	Down      key.Binding
-	Left      key.Binding
-	Right     key.Binding
-	Select    key.Binding
-	VolUp     key.Binding
-	VolDown   key.Binding
-	Mute      key.Binding
-	Reconnect key.Binding
-	Record    key.Binding // Defines record key, used as 'Stop' when recording
-	Quit      key.Binding
+	Up         key.Binding
+	Down       key.Binding
+	Left       key.Binding
+	Right      key.Binding
+	Select     key.Binding
+	VolUp      key.Binding
+	VolDown    key.Binding
+	Mute       key.Binding
+	Reconnect  key.Binding
+	Record     key.Binding // Defines record key, used as 'Stop' when recording
+	Programs   key.Binding
+	Format     key.Binding
+	Recordings key.Binding
+	Pause      key.Binding
+	Search     key.Binding
+	Cast       key.Binding
+	Detect     key.Binding
+	Confirm    key.Binding // Takes the offered switch to a station's home area
+	Favorite   key.Binding
+	MoveUp     key.Binding // Moves the selected favorite up
+	MoveDown   key.Binding // Moves the selected favorite down
+	Quit       key.Binding
 }
 
 func (k KeyMap) ShortHelp() []key.Binding {
@@ -47,61 +70,42 @@ func (k KeyMap) ShortHelp() []key.Binding {
 
 func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Up, k.Down, k.Left, k.Right, k.Select},
-		{k.VolUp, k.VolDown, k.Mute, k.Reconnect, k.Quit},
+		{k.Up, k.Down, k.Left, k.Right, k.Select, k.Detect, k.Favorite, k.MoveUp, k.MoveDown},
+		{k.VolUp, k.VolDown, k.Mute, k.Reconnect, k.Programs, k.Format, k.Recordings, k.Pause, k.Search, k.Cast, k.Quit},
 	}
 }
 
 var DefaultKeyMap = KeyMap{
-	Up:        key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑", "上へ")),
-	Down:      key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓", "下へ")),
-	Left:      key.NewBinding(key.WithKeys("left", "h"), key.WithHelp("←", "左")),
-	Right:     key.NewBinding(key.WithKeys("right", "l"), key.WithHelp("→", "右")),
-	Select:    key.NewBinding(key.WithKeys("enter", " "), key.WithHelp("Enter", "選択")),
-	VolUp:     key.NewBinding(key.WithKeys("+", "="), key.WithHelp("+", "音量+")),
-	VolDown:   key.NewBinding(key.WithKeys("-", "_"), key.WithHelp("-", "音量-")),
-	Mute:      key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "ミュート")),
-	Reconnect: key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "再接続")),
-	Record:    key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "録音/停止")),
-	Quit:      key.NewBinding(key.WithKeys("ctrl+c", "esc"), key.WithHelp("Esc", "終了/戻る")),
-}
-
-// Styles
-var (
-	primaryColor   = lipgloss.Color("#7C3AED")
-	secondaryColor = lipgloss.Color("#10B981")
-	accentColor    = lipgloss.Color("#F59E0B")
-	textColor      = lipgloss.Color("#CDD6F4")
-	dimTextColor   = lipgloss.Color("#6C7086")
-	playingColor   = lipgloss.Color("#A6E3A1")
-	regionColor    = lipgloss.Color("#89B4FA")
-	warningColor   = lipgloss.Color("#FAB387")
-	recordingColor = lipgloss.Color("#F38BA8")
-
-	titleStyle                  = lipgloss.NewStyle().Foreground(primaryColor).Bold(true)
-	regionItemStyle             = lipgloss.NewStyle().Foreground(textColor)
-	regionSelectedStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("#1E1E2E")).Background(regionColor).Bold(true).Padding(0, 1)
-	regionCurrentStyle          = lipgloss.NewStyle().Foreground(secondaryColor).Bold(true)
-	stationNameStyle            = lipgloss.NewStyle().Foreground(textColor)
-	stationIDStyle              = lipgloss.NewStyle().Foreground(dimTextColor)
-	stationSelectedStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("#1E1E2E")).Background(primaryColor).Bold(true).Padding(0, 1)
-	stationPlayingStyle         = lipgloss.NewStyle().Foreground(playingColor).Bold(true)
-	stationSelectedPlayingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#1E1E2E")).Background(secondaryColor).Bold(true).Padding(0, 1)
-	statusStyle                 = lipgloss.NewStyle().Foreground(dimTextColor)
-	errorStyle                  = lipgloss.NewStyle().Foreground(lipgloss.Color("#F38BA8"))
-	volumeStyle                 = lipgloss.NewStyle().Foreground(accentColor)
-	focusIndicatorStyle         = lipgloss.NewStyle().Foreground(accentColor).Bold(true)
-	programStyle                = lipgloss.NewStyle().Foreground(lipgloss.Color("#CBA6F7"))
-	nowPlayingStyle             = lipgloss.NewStyle().Foreground(playingColor).Bold(true)
-	reconnectStyle              = lipgloss.NewStyle().Foreground(warningColor)
-	recordingStyle              = lipgloss.NewStyle().Foreground(recordingColor).Bold(true)
-)
+	Up:         key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑", "上へ")),
+	Down:       key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓", "下へ")),
+	Left:       key.NewBinding(key.WithKeys("left", "h"), key.WithHelp("←", "左")),
+	Right:      key.NewBinding(key.WithKeys("right", "l"), key.WithHelp("→", "右")),
+	Select:     key.NewBinding(key.WithKeys("enter", " "), key.WithHelp("Enter", "選択")),
+	VolUp:      key.NewBinding(key.WithKeys("+", "="), key.WithHelp("+", "音量+")),
+	VolDown:    key.NewBinding(key.WithKeys("-", "_"), key.WithHelp("-", "音量-")),
+	Mute:       key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "ミュート")),
+	Reconnect:  key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "再接続")),
+	Record:     key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "録音/停止")),
+	Programs:   key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "番組表")),
+	Format:     key.NewBinding(key.WithKeys("f"), key.WithHelp("f", "録音形式")),
+	Recordings: key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "録音一覧")),
+	Pause:      key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "一時停止")),
+	Search:     key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "検索")),
+	Cast:       key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "キャスト")),
+	Detect:     key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "エリア検出")),
+	Confirm:    key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "確定")),
+	Favorite:   key.NewBinding(key.WithKeys("*"), key.WithHelp("*", "お気に入り")),
+	MoveUp:     key.NewBinding(key.WithKeys("K"), key.WithHelp("K", "お気に入りを上へ")),
+	MoveDown:   key.NewBinding(key.WithKeys("J"), key.WithHelp("J", "お気に入りを下へ")),
+	Quit:       key.NewBinding(key.WithKeys("ctrl+c", "esc"), key.WithHelp("Esc", "終了/戻る")),
+}
 
 // PlayingInfo holds information about the currently playing station
 type PlayingInfo struct {
 	StationID      string
 	StationName    string
 	CurrentProgram string
+	CurrentTrack   string // "artist - title" of the song on air
 }
 
 // SharedState holds shared state between components
@@ -112,12 +116,35 @@ type SharedState struct {
 	Muted         bool
 	CurrentAreaID string
 	Playing       *PlayingInfo
-	ServerURL     string // If set, we are in client mode
+	ServerURL     string            // If set, we are in client mode
+	ServerToken   string            // Token of servers with authentication
+	Cast          *cast.Session     // Chromecast playing instead of the player (optional)
+	Recorder      *recorder.Manager // Recordings independent of the player
+	Scheduler     *recorder.Scheduler
+	RecordFormat  recorder.Format // Output format for new recordings
+
+	ctx        context.Context    // Canceled when the TUI quits, ending requests in flight
+	cancel     context.CancelFunc //
+	playMu     sync.Mutex         //
+	playCancel context.CancelFunc // Cancels resolving the station being switched to
+}
+
+// playContext returns the context of a new station switch, canceling the
+// previous one if it is still resolving
+func (s *SharedState) playContext() context.Context {
+	s.playMu.Lock()
+	defer s.playMu.Unlock()
+	if s.playCancel != nil {
+		s.playCancel()
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.playCancel = cancel
+	return ctx
 }
 
 // Model is the TUI model
 type Model struct {
-	stations      []model.Station
+	stations      []model.Station // Listed stations, favorites first
 	cursor        int
 	width         int
 	height        int
@@ -128,19 +155,65 @@ type Model struct {
 	autoPlay      bool
 	autoPlayIdx   int
 
+	loadedStations  []model.Station                   // Stations in radiko's order
+	stationSettings map[string]config.StationSettings // Per-station overrides by station ID
+	globalVolume    float64                           // Volume of stations without their own
+	favorites       []string                          // Favorite station IDs, in the order they are listed
+
+	stationsCached time.Time // When the station list was cached, if radiko was unreachable (zero = fresh)
+	offline        bool      // radiko was unreachable at startup; retried every offlineRetry
+	lastStationID  string    // Station to resume once back online
+
+	areaRetry     *api.AreaError // Station refused to the current area, offered to play in its home area
+	playAfterLoad string         // Station to play once the stations of the switched area are loaded
+
 	areas        []model.Area
 	currentArea  int
 	selectedArea int
+	envArea      string // Area set by RADIKO_TUI_AREA, not saved until the user switches away from it
 	isLoading    bool
 	focus        FocusMode
+
+	// Program guide (EPG) view
+	programs       []model.Program
+	programCursor  int
+	programStation model.Station
+	programDay     int       // Days before today shown in the guide (0 = today)
+	programsCached time.Time // When the guide shown was cached, if radiko was unreachable (zero = fresh)
+
+	// Recording list view
+	recordingCursor int
+	library         []recorder.HistoryEntry // Finished recordings shown below the active ones
+	libraryQuery    string                  // Search filter for finished recordings
+	librarySearch   bool                    // Typing into libraryQuery
+
+	// Cast device list
+	castDevices []cast.Device
+	castCursor  int
+
+	// Program search
+	searchQuery   string
+	searchTyping  bool // Typing into searchQuery
+	searchResults []model.SearchResult
+	searchCursor  int
 }
 
 // Message types
 type autoPlayMsg struct{}
+type offlineRetryMsg struct{}
+type onlineMsg struct {
+	stations []model.Station
+	err      error
+}
 type stationsLoadedMsg struct {
 	stations []model.Station
+	cached   time.Time // Zero unless served from the cache
 	err      error
 }
+type areaDetectedMsg struct {
+	areaID string
+	err    error
+}
 type playResultMsg struct {
 	err         error
 	stationIdx  int
@@ -148,8 +221,23 @@ type playResultMsg struct {
 	stationName string
 }
 type reconnectResultMsg struct{ err error }
-type programUpdateMsg struct{ program string }
+type recordResultMsg struct {
+	started     bool
+	stationName string
+	filePath    string
+	err         error
+}
+type programUpdateMsg struct{ program, track string }
 type tickMsg struct{}
+type scheduleEventMsg struct{ event recorder.Event }
+type libraryLoadedMsg struct{ entries []recorder.HistoryEntry }
+type programsLoadedMsg struct {
+	station  model.Station
+	day      int
+	programs []model.Program
+	cached   time.Time // Zero unless served from the cache
+	err      error
+}
 
 func NewModel(stations []model.Station, authToken string, initialVolume float64, lastStationID string, areaID string, serverURL string) Model {
 	areas := model.AllAreas()
@@ -197,7 +285,10 @@ func NewModel(stations []model.Station, authToken string, initialVolume float64,
 		p = fp
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	shared := &SharedState{
+		ctx:           ctx,
+		cancel:        cancel,
 		Player:        p,
 		AuthToken:     authToken,
 		Volume:        initialVolume,
@@ -210,45 +301,90 @@ func NewModel(stations []model.Station, authToken string, initialVolume float64,
 	// Set callback for FFmpegPlayer
 	if fp, ok := p.(*player.FFmpegPlayer); ok {
 		fp.SetReconnectCallback(func() string {
-			return api.Auth(shared.CurrentAreaID)
+			// The stream may have dropped because the token expired
+			api.InvalidateToken(shared.CurrentAreaID)
+			token, _ := api.Token(shared.ctx, shared.CurrentAreaID)
+			return token
 		})
 	}
 
 	return Model{
-		stations:      stations,
-		cursor:        defaultIdx,
-		keys:          DefaultKeyMap,
-		statusMessage: "",
-		shared:        shared,
-		autoPlay:      true,
-		autoPlayIdx:   autoPlayIdx,
-		areas:         areas,
-		currentArea:   currentAreaIdx,
-		selectedArea:  currentAreaIdx,
-		focus:         FocusStations,
+		stations:       stations,
+		loadedStations: stations,
+		globalVolume:   initialVolume,
+		cursor:         defaultIdx,
+		keys:           DefaultKeyMap,
+		statusMessage:  "",
+		shared:         shared,
+		autoPlay:       true,
+		autoPlayIdx:    autoPlayIdx,
+		lastStationID:  lastStationID,
+		areas:          areas,
+		currentArea:    currentAreaIdx,
+		selectedArea:   currentAreaIdx,
+		envArea:        os.Getenv(config.EnvArea),
+		focus:          FocusStations,
 	}
 }
 
 func (m Model) Init() tea.Cmd {
+	var retry tea.Cmd
+	if m.offline {
+		retry = offlineRetryCmd()
+	}
 	return tea.Batch(
 		func() tea.Msg { return autoPlayMsg{} },
 		tickCmd(),
+		retry,
 	)
 }
 
+// offlineRetry is how often radiko is tried again while offline
+const offlineRetry = 30 * time.Second
+
+func offlineRetryCmd() tea.Cmd {
+	return tea.Tick(offlineRetry, func(t time.Time) tea.Msg {
+		return offlineRetryMsg{}
+	})
+}
+
+// checkOnline fetches the station list of the current area from radiko,
+// failing while only the cache answers
+func (m *Model) checkOnline() tea.Cmd {
+	areaID := m.getCurrentAreaID()
+	ctx := m.shared.ctx
+	return func() tea.Msg {
+		ctx, cache := api.WithCacheStatus(ctx)
+		var stations []model.Station
+		var err error
+		if areaID == model.AllAreasID {
+			stations, err = api.GetAllStations(ctx)
+		} else {
+			stations, err = api.GetStations(ctx, areaID)
+		}
+		if _, cached := cache.Cached(); err == nil && cached {
+			err = fmt.Errorf("radiko is unreachable")
+		}
+		return onlineMsg{stations: stations, err: err}
+	}
+}
+
 func tickCmd() tea.Cmd {
 	return tea.Tick(1*time.Second, func(t time.Time) tea.Msg {
 		return tickMsg{}
 	})
 }
 
-func fetchProgramCmd(stationID string) tea.Cmd {
+func fetchProgramCmd(shared *SharedState, stationID string) tea.Cmd {
 	return func() tea.Msg {
-		prog, err := api.GetCurrentProgram(stationID)
-		if err != nil || prog == nil {
-			return programUpdateMsg{program: ""}
+		var msg programUpdateMsg
+		if prog, err := shared.currentProgram(stationID); err == nil && prog != nil {
+			msg.program = prog.Title
+		}
+		if track, err := api.GetNowOnAir(shared.ctx, stationID); err == nil && track != nil {
+			msg.track = track.String()
 		}
-		return programUpdateMsg{program: prog.Title}
+		return msg
 	}
 }
 
@@ -269,13 +405,52 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Refresh program info every 30 seconds
 		var cmd tea.Cmd
 		if m.shared.Playing != nil && time.Now().Second()%30 == 0 {
-			cmd = fetchProgramCmd(m.shared.Playing.StationID)
+			cmd = fetchProgramCmd(m.shared, m.shared.Playing.StationID)
+		}
+		// Pick up recordings and transcripts finished in the background
+		var libraryCmd tea.Cmd
+		if m.focus == FocusRecordings && time.Now().Second()%5 == 0 {
+			libraryCmd = loadLibrary()
 		}
-		return m, tea.Batch(cmd, tickCmd())
+		return m, tea.Batch(cmd, libraryCmd, tickCmd())
+
+	case libraryLoadedMsg:
+		m.library = msg.entries
+		return m, nil
 
 	case programUpdateMsg:
 		if m.shared.Playing != nil {
 			m.shared.Playing.CurrentProgram = msg.program
+			m.shared.Playing.CurrentTrack = msg.track
+		}
+		return m, nil
+
+	case offlineRetryMsg:
+		if !m.offline {
+			return m, nil
+		}
+		return m, m.checkOnline()
+
+	case onlineMsg:
+		if msg.err != nil || len(msg.stations) == 0 {
+			return m, offlineRetryCmd()
+		}
+		m.offline = false
+		m.stationsCached = time.Time{}
+		m.setStations(msg.stations)
+		m.statusMessage = "✓ radiko に接続しました"
+		m.errorMessage = ""
+		if m.cursor >= len(m.stations) {
+			m.cursor = 0
+		}
+		// Resume the last station if nothing plays yet
+		if m.shared.Playing == nil {
+			for i, s := range m.stations {
+				if s.ID == m.lastStationID {
+					m.cursor = i
+					return m, m.playStation()
+				}
+			}
 		}
 		return m, nil
 
@@ -287,21 +462,60 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case areaDetectedMsg:
+		m.isLoading = false
+		if msg.err != nil {
+			m.errorMessage = fmt.Sprintf("エリア検出失敗: %v", msg.err)
+			m.statusMessage = ""
+			return m, nil
+		}
+		for i, area := range m.areas {
+			if area.ID != msg.areaID {
+				continue
+			}
+			m.selectedArea = i
+			if i == m.currentArea {
+				m.statusMessage = fmt.Sprintf("現在地は %s です", area.Name)
+				return m, nil
+			}
+			m.currentArea = i
+			return m, m.loadStationsForCurrentArea()
+		}
+		return m, nil
+
 	case stationsLoadedMsg:
 		m.isLoading = false
 		if msg.err != nil {
 			m.errorMessage = fmt.Sprintf("読み込み失敗: %v", msg.err)
+			m.playAfterLoad = ""
 		} else {
-			m.stations = msg.stations
-			m.shared.CurrentAreaID = m.getCurrentAreaID()
+			m.setStations(msg.stations)
+			m.stationsCached = msg.cached
+			m.offline = m.offline && !msg.cached.IsZero()
+			if !m.allAreas() {
+				m.shared.CurrentAreaID = m.getCurrentAreaID()
+			}
 			m.cursor = 0
 			m.statusMessage = fmt.Sprintf("%s に切り替えました", m.getCurrentAreaName())
 			m.saveAreaConfig()
+			if stationID := m.playAfterLoad; stationID != "" {
+				m.playAfterLoad = ""
+				for i, s := range m.stations {
+					if s.ID == stationID {
+						m.cursor = i
+						return m, m.playStation()
+					}
+				}
+			}
 		}
 		return m, nil
 
 	case playResultMsg:
-		if msg.err != nil {
+		var areaErr *api.AreaError
+		if errors.As(msg.err, &areaErr) {
+			m.errorMessage = m.areaErrorMessage(areaErr)
+			m.statusMessage = ""
+		} else if msg.err != nil {
 			m.errorMessage = fmt.Sprintf("再生失敗: %v", msg.err)
 			m.statusMessage = ""
 		} else {
@@ -311,11 +525,82 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			m.statusMessage = ""
 			m.errorMessage = ""
+			m.applyStationVolume(msg.stationID)
 			m.saveConfig()
-			return m, fetchProgramCmd(msg.stationID)
+			return m, tea.Batch(fetchProgramCmd(m.shared, msg.stationID), m.autoRecord(msg.stationID))
+		}
+		return m, nil
+
+	case favoritesSavedMsg:
+		if msg.err != nil {
+			m.errorMessage = fmt.Sprintf("お気に入りの保存に失敗: %v", msg.err)
+		}
+		return m, nil
+
+	case searchResultsMsg:
+		m.isLoading = false
+		if msg.err != nil {
+			m.errorMessage = fmt.Sprintf("番組検索に失敗: %v", msg.err)
+			return m, nil
+		}
+		m.searchResults = msg.results
+		m.searchCursor = 0
+		m.statusMessage = fmt.Sprintf("%d件見つかりました", len(msg.results))
+		return m, nil
+
+	case programsLoadedMsg:
+		m.isLoading = false
+		if msg.err != nil {
+			m.errorMessage = fmt.Sprintf("番組表の取得に失敗: %v", msg.err)
+			return m, nil
+		}
+		m.programs = msg.programs
+		m.programStation = msg.station
+		m.programDay = msg.day
+		m.programsCached = msg.cached
+		m.programCursor = 0
+		// Start at the program currently on air
+		now := time.Now()
+		for i, prog := range m.programs {
+			if end, err := prog.EndTime(); err == nil && now.Before(end) {
+				m.programCursor = i
+				break
+			}
+		}
+		m.focus = FocusPrograms
+		return m, nil
+
+	case scheduleEventMsg:
+		e := msg.event
+		switch e.Type {
+		case recorder.EventStarted:
+			m.statusMessage = fmt.Sprintf("予約録音開始: %s", e.Schedule.StationID)
+		case recorder.EventFinished:
+			if e.Recording.Length > 0 {
+				m.statusMessage = fmt.Sprintf("ダウンロード完了: %s", e.Recording.FilePath)
+			} else {
+				m.statusMessage = fmt.Sprintf("予約録音保存: %s", e.Recording.FilePath)
+			}
+		case recorder.EventFailed:
+			m.errorMessage = fmt.Sprintf("予約録音失敗 [%s]: %v", e.Schedule.StationID, e.Err)
+		case recorder.EventScheduled:
+			m.statusMessage = fmt.Sprintf("自動予約: %s %s", e.Schedule.Name, e.Program.Title)
+		}
+		return m, nil
+
+	case recordResultMsg:
+		if msg.err != nil {
+			m.errorMessage = msg.err.Error()
+		} else if msg.started {
+			m.statusMessage = fmt.Sprintf("録音開始: %s", msg.stationName)
+		} else {
+			m.statusMessage = fmt.Sprintf("録音保存: %s", msg.filePath)
 		}
 		return m, nil
 
+	case castDevicesMsg, castResultMsg, castEndedMsg, castVolumeMsg:
+		return m.handleCastMsg(msg)
+
 	case reconnectResultMsg:
 		if msg.err != nil {
 			m.errorMessage = fmt.Sprintf("再接続失敗: %v", msg.err)
@@ -331,12 +616,31 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.errorMessage = ""
 		m.statusMessage = ""
 
+		if retry := m.areaRetry; retry != nil {
+			m.areaRetry = nil
+			if key.Matches(msg, m.keys.Confirm) {
+				return m, m.playInHomeArea(retry)
+			}
+		}
+
 		if m.focus == FocusVolume {
 			return m.handleVolumeKeys(msg)
 		}
 		if m.focus == FocusRegion {
 			return m.handleRegionKeys(msg)
 		}
+		if m.focus == FocusPrograms {
+			return m.handleProgramKeys(msg)
+		}
+		if m.focus == FocusRecordings {
+			return m.handleRecordingKeys(msg)
+		}
+		if m.focus == FocusCast {
+			return m.handleCastKeys(msg)
+		}
+		if m.focus == FocusSearch {
+			return m.handleSearchKeys(msg)
+		}
 		return m.handleStationKeys(msg)
 	}
 
@@ -377,9 +681,28 @@ func (m Model) handleStationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case key.Matches(msg, m.keys.Select):
+		if len(m.stations) == 0 {
+			return m, nil
+		}
+		// While casting, switch the station on the device
+		if m.shared.Cast != nil {
+			return m, m.castStation(m.shared.Cast.Device, m.stations[m.cursor])
+		}
 		return m, m.playStation()
 
+	case key.Matches(msg, m.keys.Favorite):
+		return m, m.toggleFavorite()
+
+	case key.Matches(msg, m.keys.MoveUp):
+		return m, m.moveFavorite(-1)
+
+	case key.Matches(msg, m.keys.MoveDown):
+		return m, m.moveFavorite(1)
+
 	case key.Matches(msg, m.keys.VolUp):
+		if m.shared.Cast != nil {
+			return m, m.castVolume(0.05)
+		}
 		if m.shared.Player != nil {
 			m.shared.Player.IncreaseVolume(0.05)
 			m.shared.Volume = m.shared.Player.GetVolume()
@@ -389,6 +712,9 @@ func (m Model) handleStationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case key.Matches(msg, m.keys.VolDown):
+		if m.shared.Cast != nil {
+			return m, m.castVolume(-0.05)
+		}
 		if m.shared.Player != nil {
 			m.shared.Player.DecreaseVolume(0.05)
 			m.shared.Volume = m.shared.Player.GetVolume()
@@ -398,12 +724,29 @@ func (m Model) handleStationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case key.Matches(msg, m.keys.Mute):
+		if m.shared.Cast != nil {
+			return m, m.castToggleMute()
+		}
 		if m.shared.Player != nil {
 			m.shared.Player.ToggleMute()
 			m.shared.Muted = m.shared.Player.IsMuted()
 		}
 		return m, nil
 
+	case key.Matches(msg, m.keys.Cast):
+		if m.shared.Cast != nil {
+			return m, m.stopCast()
+		}
+		if len(m.stations) > 0 {
+			return m, m.discoverCastDevices()
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Search):
+		m.focus = FocusSearch
+		m.searchTyping = true
+		return m, nil
+
 	case key.Matches(msg, m.keys.Reconnect):
 		if m.shared.Player != nil && m.shared.Playing != nil {
 			return m, m.reconnect()
@@ -411,30 +754,42 @@ func (m Model) handleStationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case key.Matches(msg, m.keys.Record):
-		if m.shared.Player != nil && m.shared.Playing != nil {
-			started, filePath, err := m.shared.Player.ToggleRecording(m.shared.Playing.StationName)
-			if err != nil {
-				m.errorMessage = err.Error()
-			} else if started {
-				m.statusMessage = "録音開始"
-			} else {
-				m.statusMessage = fmt.Sprintf("録音保存: %s", filePath)
-			}
+		// Records the station under the cursor, which need not be the one playing
+		if len(m.stations) > 0 && m.shared.Recorder != nil {
+			return m, m.toggleRecording(m.stations[m.cursor])
 		}
 		return m, nil
 
+	case key.Matches(msg, m.keys.Format):
+		m.cycleRecordFormat()
+		return m, nil
+
+	case key.Matches(msg, m.keys.Programs):
+		if len(m.stations) > 0 {
+			return m, m.loadPrograms(m.stations[m.cursor], 0)
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Recordings):
+		m.focus = FocusRecordings
+		m.recordingCursor = 0
+		return m, loadLibrary()
+
 	case key.Matches(msg, m.keys.Quit):
 		m.saveConfig()
 		if m.shared.Player != nil {
-			// Stop recording if active
-			if m.shared.Player.IsRecording() {
-				m.shared.Player.StopRecording()
-			}
 			m.shared.Player.Stop()
 		}
+		// The device keeps playing from the server
+		if m.shared.Cast != nil {
+			m.shared.Cast.Close()
+		}
 		return m, tea.Quit
 
 	case msg.String() >= "0" && msg.String() <= "9":
+		if m.shared.Cast != nil {
+			return m, m.castSetVolume(float64(msg.String()[0]-'0') / 10.0)
+		}
 		if m.shared.Player != nil {
 			vol := float64(msg.String()[0]-'0') / 10.0
 			m.shared.Player.SetVolume(vol)
@@ -471,6 +826,18 @@ func (m Model) handleRegionKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.selectedArea = m.currentArea
 		return m, nil
 
+	case key.Matches(msg, m.keys.Detect):
+		m.focus = FocusStations
+		m.selectedArea = m.currentArea
+		m.isLoading = true
+		m.statusMessage = "エリアを検出中..."
+		m.errorMessage = ""
+		ctx := m.shared.ctx
+		return m, func() tea.Msg {
+			areaID, err := api.DetectArea(ctx)
+			return areaDetectedMsg{areaID: areaID, err: err}
+		}
+
 	case key.Matches(msg, m.keys.Select):
 		if m.selectedArea != m.currentArea {
 			m.currentArea = m.selectedArea
@@ -483,6 +850,149 @@ func (m Model) handleRegionKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// handleProgramKeys handles keyboard input in the program guide
+func (m Model) handleProgramKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.programCursor > 0 {
+			m.programCursor--
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Down):
+		if m.programCursor < len(m.programs)-1 {
+			m.programCursor++
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Left):
+		// Previous day, within the timefree window
+		if m.programDay < 6 {
+			return m, m.loadPrograms(m.programStation, m.programDay+1)
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Right):
+		if m.programDay > 0 {
+			return m, m.loadPrograms(m.programStation, m.programDay-1)
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Select), key.Matches(msg, m.keys.Record):
+		if m.programCursor >= len(m.programs) || m.shared.Scheduler == nil {
+			return m, nil
+		}
+		m.recordProgram(m.programStation.ID, m.programStation.Name, m.programs[m.programCursor])
+		return m, nil
+
+	case key.Matches(msg, m.keys.Format):
+		m.cycleRecordFormat()
+		return m, nil
+
+	case key.Matches(msg, m.keys.Quit), key.Matches(msg, m.keys.Programs):
+		m.focus = FocusStations
+		return m, nil
+	}
+	return m, nil
+}
+
+// recordProgram downloads a past program through timefree, or schedules a
+// live recording stopped automatically at its end time
+func (m *Model) recordProgram(stationID, stationName string, prog model.Program) {
+	var err error
+	if end, _ := prog.EndTime(); time.Now().After(end) {
+		err = m.shared.Scheduler.DownloadProgram(stationID, stationName, prog, m.shared.RecordFormat)
+		if err == nil {
+			m.statusMessage = fmt.Sprintf("タイムフリーダウンロード開始: %s", prog.Title)
+		}
+	} else {
+		err = m.shared.Scheduler.ScheduleProgram(stationID, stationName, prog, m.shared.RecordFormat)
+		if err == nil {
+			m.statusMessage = fmt.Sprintf("録音予約: %s", prog.Title)
+		}
+	}
+	if err != nil {
+		m.errorMessage = err.Error()
+	}
+}
+
+// handleRecordingKeys handles keyboard input in the recording list
+func (m Model) handleRecordingKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.librarySearch {
+		return m.handleLibrarySearchKeys(msg)
+	}
+
+	recs := m.shared.Recorder.Recordings()
+	// Recordings may have finished since the last key press
+	if m.recordingCursor >= len(recs) && len(recs) > 0 {
+		m.recordingCursor = len(recs) - 1
+	}
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.recordingCursor > 0 {
+			m.recordingCursor--
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Down):
+		if m.recordingCursor < len(recs)-1 {
+			m.recordingCursor++
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Select), key.Matches(msg, m.keys.Record):
+		if m.recordingCursor < len(recs) {
+			return m, stopRecording(recs[m.recordingCursor])
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Pause):
+		if m.recordingCursor < len(recs) {
+			rec := recs[m.recordingCursor]
+			if rec.Paused() {
+				rec.Resume()
+				m.statusMessage = fmt.Sprintf("録音再開: %s", rec.StationName)
+			} else if err := rec.Pause(); err != nil {
+				m.errorMessage = err.Error()
+			} else {
+				m.statusMessage = fmt.Sprintf("録音一時停止: %s", rec.StationName)
+			}
+		}
+		return m, nil
+
+	case key.Matches(msg, m.keys.Format):
+		m.cycleRecordFormat()
+		return m, nil
+
+	case key.Matches(msg, m.keys.Search):
+		m.librarySearch = true
+		return m, nil
+
+	case key.Matches(msg, m.keys.Quit), key.Matches(msg, m.keys.Recordings):
+		m.focus = FocusStations
+		return m, nil
+	}
+	return m, nil
+}
+
+// handleLibrarySearchKeys edits the search filter of finished recordings
+func (m Model) handleLibrarySearchKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.librarySearch = false
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.librarySearch = false
+		m.libraryQuery = ""
+	case tea.KeyBackspace:
+		if runes := []rune(m.libraryQuery); len(runes) > 0 {
+			m.libraryQuery = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.libraryQuery += string(msg.Runes)
+	}
+	return m, nil
+}
+
 // handleVolumeKeys handles keyboard input when volume control is focused
 func (m Model) handleVolumeKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch {
@@ -547,36 +1057,148 @@ func (m *Model) getCurrentAreaName() string {
 	return "東京"
 }
 
+// allAreas reports whether the stations of every area are listed, which
+// radiko premium (area-free) members can browse
+func (m *Model) allAreas() bool {
+	return m.getCurrentAreaID() == model.AllAreasID
+}
+
+// configAreaID returns the area saved to the config: the last real area
+// while browsing every area, or "" to keep the saved one while the area is
+// still the one RADIKO_TUI_AREA set
+func (m *Model) configAreaID() string {
+	areaID := m.getCurrentAreaID()
+	if m.allAreas() {
+		areaID = m.shared.CurrentAreaID
+	}
+	if areaID == m.envArea {
+		return ""
+	}
+	m.envArea = "" // The user picked another area, which is saved from now on
+	return areaID
+}
+
 func (m *Model) loadStationsForCurrentArea() tea.Cmd {
 	m.isLoading = true
 	m.statusMessage = fmt.Sprintf("%s を読み込み中...", m.getCurrentAreaName())
 	areaID := m.getCurrentAreaID()
+	shared := m.shared
+	return func() tea.Msg {
+		ctx, cache := api.WithCacheStatus(shared.ctx)
+		var stations []model.Station
+		var err error
+		if areaID == model.AllAreasID {
+			stations, err = api.GetAllStations(ctx)
+		} else {
+			stations, err = api.GetStations(ctx, areaID)
+		}
+		stored, _ := cache.Cached()
+		return stationsLoadedMsg{stations: stations, cached: stored, err: err}
+	}
+}
+
+// loadPrograms fetches the program guide for a station, day days before today
+func (m *Model) loadPrograms(station model.Station, day int) tea.Cmd {
+	m.isLoading = true
+	m.statusMessage = fmt.Sprintf("%s の番組表を読み込み中...", m.displayName(station))
+	shared := m.shared
 	return func() tea.Msg {
-		stations, err := api.GetStations(areaID)
-		return stationsLoadedMsg{stations: stations, err: err}
+		// radiko's broadcast day runs from 05:00 to 29:00 JST
+		date := time.Now().Add(-5*time.Hour).AddDate(0, 0, -day)
+		ctx, cache := api.WithCacheStatus(shared.ctx)
+		programs, err := shared.programs(ctx, station.ID, date)
+		stored, _ := cache.Cached()
+		return programsLoadedMsg{station: station, day: day, programs: programs, cached: stored, err: err}
 	}
 }
 
 func (m *Model) saveConfig() {
 	if m.shared.Playing != nil {
-		volume := m.shared.Volume
-		if m.shared.Player != nil {
-			volume = m.shared.Player.GetVolume()
-		}
-		go config.SaveConfig(m.shared.Playing.StationID, volume, m.getCurrentAreaID())
+		queueConfig(m.shared.Playing.StationID, m.savedVolume(), m.configAreaID())
 	}
 }
 
 func (m *Model) saveAreaConfig() {
-	volume := m.shared.Volume
-	if m.shared.Player != nil {
-		volume = m.shared.Player.GetVolume()
-	}
+	volume := m.savedVolume()
 	stationID := ""
 	if m.shared.Playing != nil {
 		stationID = m.shared.Playing.StationID
 	}
-	go config.SaveConfig(stationID, volume, m.getCurrentAreaID())
+	queueConfig(stationID, volume, m.configAreaID())
+}
+
+// queueConfig saves the station, volume and area ("" keeps the saved one)
+// in the background, in the order they changed
+func queueConfig(stationID string, volume float64, areaID string) {
+	config.Queue(func(cfg *config.Config) {
+		cfg.LastStationID = stationID
+		cfg.Volume = volume
+		if areaID != "" {
+			cfg.AreaID = areaID
+		}
+	})
+}
+
+// availability returns whether a listed station plays for the listener's
+// area and premium session
+func (m Model) availability(station model.Station) model.Availability {
+	if m.shared.ServerURL != "" {
+		return model.FreeArea // The server authenticates on its own
+	}
+	return station.Availability(m.shared.CurrentAreaID, api.AreaFree())
+}
+
+// unavailable returns why a station doesn't play for the listener, or nil
+func (m Model) unavailable(station model.Station) error {
+	switch m.availability(station) {
+	case model.AreaRestricted:
+		return &api.AreaError{StationID: station.ID, AreaID: m.shared.CurrentAreaID, HomeArea: station.AreaID}
+	case model.PremiumOnly:
+		return fmt.Errorf("%s をエリア外で聴くには radiko プレミアムが必要です", m.displayName(station))
+	}
+	return nil
+}
+
+// areaErrorMessage describes a station refused to the current area, and
+// offers to play it in its home area when that is known
+func (m *Model) areaErrorMessage(e *api.AreaError) string {
+	name := e.StationID
+	for _, s := range m.stations {
+		if s.ID == e.StationID {
+			name = m.displayName(s)
+			break
+		}
+	}
+	home := model.FindAreaByID(e.HomeArea)
+	if home == nil || m.areaIndex(e.HomeArea) < 0 {
+		return fmt.Sprintf("再生失敗: %s はこのエリアでは聴けません", name)
+	}
+	m.areaRetry = e
+	return fmt.Sprintf("%s は%sでのみ聴けます — %s: %sに切り替えて再生", name, home.Name, m.keys.Confirm.Help().Key, home.Name)
+}
+
+// areaIndex returns the index of an area in the region bar, or -1
+func (m *Model) areaIndex(areaID string) int {
+	for i, area := range m.areas {
+		if area.ID == areaID {
+			return i
+		}
+	}
+	return -1
+}
+
+// playInHomeArea switches to the home area of a refused station and plays
+// it once the area's stations are loaded
+func (m *Model) playInHomeArea(e *api.AreaError) tea.Cmd {
+	i := m.areaIndex(e.HomeArea)
+	if i < 0 {
+		return nil
+	}
+	m.focus = FocusStations
+	m.currentArea = i
+	m.selectedArea = i
+	m.playAfterLoad = e.StationID
+	return m.loadStationsForCurrentArea()
 }
 
 func (m *Model) playStation() tea.Cmd {
@@ -584,6 +1206,19 @@ func (m *Model) playStation() tea.Cmd {
 	station := m.stations[stationIdx]
 	shared := m.shared
 	currentAreaID := m.getCurrentAreaID()
+	if station.AreaID != "" {
+		// Listed with every area: authenticate in the station's own area
+		currentAreaID = station.AreaID
+	} else if currentAreaID == model.AllAreasID {
+		currentAreaID = shared.CurrentAreaID
+	}
+	if err := m.unavailable(station); err != nil {
+		return func() tea.Msg {
+			return playResultMsg{err: err, stationIdx: stationIdx}
+		}
+	}
+	ctx := shared.playContext()
+	quality := api.Quality(m.stationSettings[station.ID].Quality)
 
 	return func() tea.Msg {
 		var playTarget string
@@ -594,29 +1229,28 @@ func (m *Model) playStation() tea.Cmd {
 			time.Sleep(100 * time.Millisecond)
 		} else {
 			// Local mode: resolve stream URL
-			playlistURLs, err := api.GetStreamURLs(station.ID)
+			// Use a token of the current area to ensure it matches the region
+			token := shared.AuthToken
+			if newToken, err := api.Token(ctx, currentAreaID); err == nil {
+				token = newToken
+			}
+			streams, err := api.GetStreamURLs(ctx, station.ID, token)
+			if ctx.Err() != nil {
+				// Another station was picked meanwhile
+				return nil
+			}
 			if err != nil {
 				return playResultMsg{err: err, stationIdx: stationIdx}
 			}
-			if len(playlistURLs) == 0 {
-				return playResultMsg{err: fmt.Errorf("利用可能なストリームがありません"), stationIdx: stationIdx}
-			}
-
-			lsid := model.GenLsid()
-			lastUrl := playlistURLs[len(playlistURLs)-1]
-			playTarget = fmt.Sprintf("%s?station_id=%s&l=30&lsid=%s&type=b", lastUrl, station.ID, lsid)
+			playTarget = api.PreferredStream(streams).Select(quality).URL
 
 			shared.Player.Stop()
 			time.Sleep(100 * time.Millisecond)
 
-			// Re-authenticate for the current area to ensure token matches the region
-			newToken := api.Auth(currentAreaID)
-			if newToken != "" {
-				shared.AuthToken = newToken
-				// Update auth token for FFmpegPlayer
-				if fp, ok := shared.Player.(*player.FFmpegPlayer); ok {
-					fp.UpdateAuthToken(newToken)
-				}
+			shared.AuthToken = token
+			// Update auth token for FFmpegPlayer
+			if fp, ok := shared.Player.(*player.FFmpegPlayer); ok {
+				fp.UpdateAuthToken(token)
 			}
 		}
 
@@ -625,11 +1259,59 @@ func (m *Model) playStation() tea.Cmd {
 			err:         err,
 			stationIdx:  stationIdx,
 			stationID:   station.ID,
-			stationName: station.Name,
+			stationName: m.displayName(station),
 		}
 	}
 }
 
+// toggleRecording starts or stops recording a station independent of the player.
+// Starting authenticates and stopping may transcode, so both run as a command.
+func (m *Model) toggleRecording(station model.Station) tea.Cmd {
+	manager := m.shared.Recorder
+	if rec := manager.Get(station.ID); rec != nil {
+		return stopRecording(rec)
+	}
+	return func() tea.Msg {
+		_, err := manager.Start(station.ID, recorder.Options{
+			StationID:   station.ID,
+			StationName: station.Name,
+		})
+		return recordResultMsg{started: err == nil, stationName: station.Name, err: err}
+	}
+}
+
+// stopRecording stops a recording and reports the saved file
+func stopRecording(rec *recorder.Recording) tea.Cmd {
+	return func() tea.Msg {
+		rec.Stop()
+		return recordResultMsg{stationName: rec.StationName, filePath: rec.FilePath, err: rec.Err()}
+	}
+}
+
+// loadLibrary reads the finished recordings from the recording history
+func loadLibrary() tea.Cmd {
+	return func() tea.Msg {
+		entries, _ := recorder.History()
+		return libraryLoadedMsg{entries: entries}
+	}
+}
+
+// cycleRecordFormat switches to the next recording output format
+func (m *Model) cycleRecordFormat() {
+	next := recorder.Formats[0]
+	for i, f := range recorder.Formats {
+		if f == m.shared.RecordFormat {
+			next = recorder.Formats[(i+1)%len(recorder.Formats)]
+			break
+		}
+	}
+	m.shared.RecordFormat = next
+	if m.shared.Recorder != nil {
+		m.shared.Recorder.SetFormat(next)
+	}
+	m.statusMessage = fmt.Sprintf("録音形式: %s", next)
+}
+
 func (m *Model) reconnect() tea.Cmd {
 	shared := m.shared
 	return func() tea.Msg {
@@ -666,6 +1348,11 @@ func (m Model) View() string {
 	if m.shared.ServerURL != "" {
 		title += statusStyle.Render(" [サーバー接続]")
 	}
+	if m.offline {
+		title += errorStyle.Render(" [オフライン]")
+	} else if !m.stationsCached.IsZero() {
+		title += statusStyle.Render(fmt.Sprintf(" [キャッシュ %s]", m.stationsCached.Format("01/02 15:04")))
+	}
 
 	volBar := m.renderVolume()
 	content.WriteString(fmt.Sprintf("%s  %s\n", title, volBar))
@@ -700,6 +1387,19 @@ func (m Model) renderContent(maxHeight int) string {
 		return strings.Join(lines, "\n") + "\n"
 	}
 
+	if m.focus == FocusPrograms {
+		return m.renderPrograms(maxHeight)
+	}
+	if m.focus == FocusRecordings {
+		return m.renderRecordings(maxHeight)
+	}
+	if m.focus == FocusCast {
+		return m.renderCastDevices()
+	}
+	if m.focus == FocusSearch {
+		return m.renderSearch(maxHeight)
+	}
+
 	// Station list
 	maxVisible := maxHeight - 2 // Leave space for status messages
 	if maxVisible > len(m.stations) {
@@ -725,29 +1425,54 @@ func (m Model) renderContent(maxHeight int) string {
 	if startIdx > 0 {
 		lines = append(lines, statusStyle.Render("  ↑ さらに表示"))
 	}
+	if len(m.stations) == 0 && m.offline {
+		lines = append(lines, statusStyle.Render("  radiko に接続できません。接続を再試行しています..."))
+	}
 
 	for i := startIdx; i < endIdx; i++ {
 		station := m.stations[i]
+		name := m.displayName(station)
 		isSelected := i == m.cursor && m.focus == FocusStations
 		isPlaying := m.shared.Playing != nil && m.shared.Playing.StationID == station.ID
+		isRecording := m.shared.Recorder != nil && m.shared.Recorder.Get(station.ID) != nil
 
 		prefix := "  "
 		if isPlaying {
 			prefix = "▶ "
 		}
 
+		// Stations that won't play are greyed out
+		var lock string
+		switch m.availability(station) {
+		case model.AreaRestricted:
+			lock = " 🔒"
+		case model.PremiumOnly:
+			lock = " 💎"
+		}
+
 		var styled string
 		switch {
+		case isSelected && lock != "":
+			text := fmt.Sprintf("%s%s %s%s", prefix, name, station.ID, lock)
+			styled = stationSelectedStyle.Render(text)
+		case lock != "":
+			styled = stationIDStyle.Render(prefix+name+" "+station.ID) + lock
 		case isSelected && isPlaying:
-			text := fmt.Sprintf("%s%s %s", prefix, station.Name, station.ID)
+			text := fmt.Sprintf("%s%s %s", prefix, name, station.ID)
 			styled = stationSelectedPlayingStyle.Render(text)
 		case isSelected:
-			text := fmt.Sprintf("%s%s %s", prefix, station.Name, station.ID)
+			text := fmt.Sprintf("%s%s %s", prefix, name, station.ID)
 			styled = stationSelectedStyle.Render(text)
 		case isPlaying:
-			styled = stationPlayingStyle.Render(prefix+station.Name) + " " + stationIDStyle.Render(station.ID)
+			styled = stationPlayingStyle.Render(prefix+name) + " " + stationIDStyle.Render(station.ID)
 		default:
-			styled = stationNameStyle.Render(prefix+station.Name) + " " + stationIDStyle.Render(station.ID)
+			styled = stationNameStyle.Render(prefix+name) + " " + stationIDStyle.Render(station.ID)
+		}
+		if m.isFavorite(station.ID) {
+			styled += " " + favoriteStyle.Render("★")
+		}
+		if isRecording {
+			styled += " " + recordingStyle.Render("⏺")
 		}
 		lines = append(lines, styled)
 	}
@@ -766,6 +1491,192 @@ func (m Model) renderContent(maxHeight int) string {
 	return strings.Join(lines, "\n") + "\n"
 }
 
+// renderPrograms renders the program guide of the selected station
+func (m Model) renderPrograms(maxHeight int) string {
+	var lines []string
+	date := time.Now().Add(-5*time.Hour).AddDate(0, 0, -m.programDay)
+	header := titleStyle.Render(fmt.Sprintf("📋 %s 番組表", m.programStation.Name)) + " " + statusStyle.Render(date.Format("01/02 (Mon)"))
+	if !m.programsCached.IsZero() {
+		header += statusStyle.Render(fmt.Sprintf(" [キャッシュ %s]", m.programsCached.Format("01/02 15:04")))
+	}
+	lines = append(lines, header)
+
+	maxVisible := maxHeight - 3 // Leave space for title and status messages
+	if maxVisible > len(m.programs) {
+		maxVisible = len(m.programs)
+	}
+	if maxVisible < 3 {
+		maxVisible = 3
+	}
+
+	startIdx := 0
+	if m.programCursor >= maxVisible {
+		startIdx = m.programCursor - maxVisible + 1
+	}
+	endIdx := startIdx + maxVisible
+	if endIdx > len(m.programs) {
+		endIdx = len(m.programs)
+	}
+
+	now := time.Now()
+	for i := startIdx; i < endIdx; i++ {
+		prog := m.programs[i]
+		start, _ := prog.StartTime()
+		end, _ := prog.EndTime()
+		text := fmt.Sprintf("%s-%s %s", start.Format("15:04"), end.Format("15:04"), prog.Title)
+
+		var styled string
+		switch {
+		case i == m.programCursor:
+			styled = stationSelectedStyle.Render(text)
+		case !now.Before(start) && now.Before(end):
+			styled = stationPlayingStyle.Render("▶ " + text)
+		case !now.Before(end):
+			styled = stationIDStyle.Render("  " + text)
+		default:
+			styled = stationNameStyle.Render("  " + text)
+		}
+		lines = append(lines, styled)
+	}
+
+	if m.errorMessage != "" {
+		lines = append(lines, errorStyle.Render("✗ "+m.errorMessage))
+	} else if m.statusMessage != "" {
+		lines = append(lines, statusStyle.Render(m.statusMessage))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// renderRecordings renders the recordings in progress
+func (m Model) renderRecordings(maxHeight int) string {
+	var lines []string
+	lines = append(lines, titleStyle.Render("⏺ 録音一覧"))
+
+	recs := m.shared.Recorder.Recordings()
+	if len(recs) == 0 {
+		lines = append(lines, statusStyle.Render("  録音中の番組はありません"))
+	}
+
+	maxVisible := maxHeight - 3 // Leave space for title and status messages
+	startIdx := 0
+	if m.recordingCursor >= maxVisible {
+		startIdx = m.recordingCursor - maxVisible + 1
+	}
+	for i := startIdx; i < len(recs) && i < startIdx+maxVisible; i++ {
+		rec := recs[i]
+		var progress string
+		if rec.Length > 0 {
+			progress = fmt.Sprintf("⬇ %3d%%", int(rec.Written()*100/rec.Length))
+		} else {
+			elapsed := rec.Elapsed()
+			progress = fmt.Sprintf("%02d:%02d", int(elapsed.Minutes()), int(elapsed.Seconds())%60)
+			if !rec.EndTime.IsZero() {
+				progress += " → " + rec.EndTime.Format("15:04")
+			}
+		}
+		text := fmt.Sprintf("%s  %s", progress, rec.StationName)
+		if rec.Title != "" {
+			text += "  " + rec.Title
+		}
+		if gaps := rec.Gaps(); len(gaps) > 0 {
+			text += fmt.Sprintf("  ⚠ 再接続 %d回", len(gaps))
+		}
+		if rec.Paused() {
+			text = "⏸ " + text
+		}
+
+		if i == m.recordingCursor {
+			lines = append(lines, stationSelectedStyle.Render(text))
+		} else {
+			lines = append(lines, recordingStyle.Render("⏺ ")+stationNameStyle.Render(text))
+		}
+	}
+
+	// Finished recordings, newest first, with 📝 marking those with a transcript
+	var library []recorder.HistoryEntry
+	for _, e := range m.library {
+		if recorder.MatchEntry(e, m.libraryQuery) {
+			library = append(library, e)
+		}
+	}
+	if remaining := maxHeight - len(lines) - 2; remaining > 1 && (len(m.library) > 0 || m.libraryQuery != "") {
+		header := fmt.Sprintf("📁 保存済み %d件", len(library))
+		if m.librarySearch || m.libraryQuery != "" {
+			header += "  🔍 " + m.libraryQuery
+			if m.librarySearch {
+				header += "▏"
+			}
+		}
+		lines = append(lines, "", titleStyle.Render(header))
+		for i := 0; i < len(library) && i < remaining-1; i++ {
+			e := library[i]
+			aired := e.AirDate
+			if aired.IsZero() {
+				aired = e.Created
+			}
+			text := fmt.Sprintf("%s  %s", aired.Local().Format("01/02 15:04"), e.Station)
+			if e.Title != "" {
+				text += "  " + e.Title
+			}
+			if e.Duration > 0 {
+				text += fmt.Sprintf("  %d:%02d:%02d", e.Duration/3600, e.Duration/60%60, e.Duration%60)
+			}
+			if e.Size > 0 {
+				text += fmt.Sprintf("  %.1fMB", float64(e.Size)/(1<<20))
+			}
+			if e.Transcript != "" {
+				text += "  📝"
+			}
+			lines = append(lines, "  "+statusStyle.Render(text))
+		}
+	}
+
+	if m.errorMessage != "" {
+		lines = append(lines, errorStyle.Render("✗ "+m.errorMessage))
+	} else if m.statusMessage != "" {
+		lines = append(lines, statusStyle.Render(m.statusMessage))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// renderRecordingStatus summarizes recordings in progress for the footer
+func (m Model) renderRecordingStatus() string {
+	var status string
+	if m.shared.Recorder != nil {
+		var live, downloads []*recorder.Recording
+		for _, rec := range m.shared.Recorder.Recordings() {
+			if rec.Length > 0 {
+				downloads = append(downloads, rec)
+			} else {
+				live = append(live, rec)
+			}
+		}
+		switch {
+		case len(live) == 1:
+			elapsed := live[0].Elapsed()
+			state := "⏺ 録音中"
+			if live[0].Paused() {
+				state = "⏸ 一時停止"
+			}
+			status += "  " + recordingStyle.Render(fmt.Sprintf("%s[%s] %02d:%02d", state, live[0].StationName, int(elapsed.Minutes()), int(elapsed.Seconds())%60))
+		case len(live) > 1:
+			status += "  " + recordingStyle.Render(fmt.Sprintf("⏺ 録音中 %d件", len(live)))
+		}
+		for _, rec := range downloads {
+			percent := int(rec.Written() * 100 / rec.Length)
+			status += "  " + statusStyle.Render(fmt.Sprintf("⬇ %s %d%%", rec.Title, percent))
+		}
+	}
+	if m.shared.Scheduler != nil {
+		if n := m.shared.Scheduler.PendingPrograms(); n > 0 {
+			status += "  " + statusStyle.Render(fmt.Sprintf("⏰ 予約 %d件", n))
+		}
+	}
+	return status
+}
+
 // renderFooter renders the fixed bottom area
 func (m Model) renderFooter() string {
 	var lines []string
@@ -779,6 +1690,12 @@ func (m Model) renderFooter() string {
 		if m.shared.Playing.CurrentProgram != "" {
 			playLine += "  " + programStyle.Render("♪ "+m.shared.Playing.CurrentProgram)
 		}
+		if m.shared.Playing.CurrentTrack != "" {
+			playLine += "  " + programStyle.Render("🎶 "+m.shared.Playing.CurrentTrack)
+		}
+		if m.shared.Cast != nil {
+			playLine += "  " + volumeStyle.Render("📺 "+m.shared.Cast.Device.Name)
+		}
 
 		// Check status using type assertion for specific details if needed
 		// For general status, we trust tickMsg to update m.statusMessage if it was supported
@@ -795,37 +1712,44 @@ func (m Model) renderFooter() string {
 					playLine += "  " + reconnectStyle.Render("▶ 再生を再開中...")
 				}
 			}
-
-			// Check recording status
-			if m.shared.Player.IsRecording() {
-				_, duration, recordingStation := m.shared.Player.GetRecordingInfo()
-				mins := int(duration.Minutes())
-				secs := int(duration.Seconds()) % 60
-				// Check if recording station is different from playing station
-				if m.shared.Playing != nil && recordingStation != m.shared.Playing.StationName {
-					playLine += "  " + recordingStyle.Render(fmt.Sprintf("⏺ 録音中[%s] %02d:%02d", recordingStation, mins, secs))
-				} else {
-					playLine += "  " + recordingStyle.Render(fmt.Sprintf("⏺ 録音中 %02d:%02d", mins, secs))
-				}
-			}
 		}
 	} else {
 		playLine = statusStyle.Render("再生していません")
 	}
+	playLine += m.renderRecordingStatus()
 	lines = append(lines, playLine)
 
-	// Help - change "s 録音" to "s 停止" when recording
-	isRecording := m.shared.Player != nil && m.shared.Player.IsRecording()
+	// Help - change "s 録音" to "s 停止" when the selected station is being recorded
+	isRecording := m.shared.Recorder != nil && len(m.stations) > 0 && m.shared.Recorder.Get(m.stations[m.cursor].ID) != nil
+	k := m.keys
+	back := k.Quit.Help().Key + " 戻る"
 	switch m.focus {
 	case FocusVolume:
-		lines = append(lines, statusStyle.Render("← → 音量調整  m ミュート  ↓ 地域へ  Esc 戻る"))
+		lines = append(lines, statusStyle.Render(fmt.Sprintf("%s 音量調整  %s ミュート  %s 地域へ  %s", helpKeys(k.Left, k.Right), k.Mute.Help().Key, k.Down.Help().Key, back)))
 	case FocusRegion:
-		lines = append(lines, statusStyle.Render("← → 選択  Enter 確定  ↑ 音量へ  ↓/Esc 戻る"))
+		lines = append(lines, statusStyle.Render(fmt.Sprintf("%s 選択  %s 確定  %s 現在地  %s 音量へ  %s 戻る", helpKeys(k.Left, k.Right), k.Select.Help().Key, k.Detect.Help().Key, k.Up.Help().Key, helpKeys(k.Down, k.Quit))))
+	case FocusPrograms:
+		lines = append(lines, statusStyle.Render(fmt.Sprintf("%s 選択  %s 日付  %s 録音/タイムフリー保存  %s 形式[%s]  %s", helpKeys(k.Up, k.Down), helpKeys(k.Left, k.Right), helpKeys(k.Select, k.Record), k.Format.Help().Key, m.shared.RecordFormat, back)))
+	case FocusCast:
+		lines = append(lines, statusStyle.Render(fmt.Sprintf("%s 選択  %s キャスト  %s", helpKeys(k.Up, k.Down), k.Select.Help().Key, back)))
+	case FocusSearch:
+		if m.searchTyping {
+			lines = append(lines, statusStyle.Render("番組名・出演者・内容で検索  Enter 検索  Esc 戻る"))
+			break
+		}
+		lines = append(lines, statusStyle.Render(fmt.Sprintf("%s 選択  %s 録音/タイムフリー保存  %s 再検索  %s 形式[%s]  %s", helpKeys(k.Up, k.Down), helpKeys(k.Select, k.Record), k.Search.Help().Key, k.Format.Help().Key, m.shared.RecordFormat, back)))
+	case FocusRecordings:
+		if m.librarySearch {
+			lines = append(lines, statusStyle.Render("放送局・番組名・出演者・タグで検索  Enter 確定  Esc クリア"))
+			break
+		}
+		lines = append(lines, statusStyle.Render(fmt.Sprintf("%s 選択  %s 停止  %s 一時停止/再開  %s 検索  %s 形式[%s]  %s", helpKeys(k.Up, k.Down), helpKeys(k.Select, k.Record), k.Pause.Help().Key, k.Search.Help().Key, k.Format.Help().Key, m.shared.RecordFormat, back)))
 	default:
+		common := fmt.Sprintf("%s 選択  %s 再生  %s 地域切替  %s 音量  %s ミュート  ", helpKeys(k.Up, k.Down), k.Select.Help().Key, helpKeys(k.Left, k.Right), helpKeys(k.VolUp, k.VolDown), k.Mute.Help().Key)
 		if isRecording {
-			lines = append(lines, statusStyle.Render("↑↓ 選択  Enter 再生  ←→ 地域切替  +- 音量  m ミュート  ")+recordingStyle.Render("s 停止")+statusStyle.Render("  r 再接続  Esc 終了"))
+			lines = append(lines, statusStyle.Render(common)+recordingStyle.Render(k.Record.Help().Key+" 停止")+statusStyle.Render(fmt.Sprintf("  %s 録音一覧  %s キャスト  %s 再接続  %s 終了", k.Recordings.Help().Key, k.Cast.Help().Key, k.Reconnect.Help().Key, k.Quit.Help().Key)))
 		} else {
-			lines = append(lines, statusStyle.Render("↑↓ 選択  Enter 再生  ←→ 地域切替  +- 音量  m ミュート  s 録音  r 再接続  Esc 終了"))
+			lines = append(lines, statusStyle.Render(common+fmt.Sprintf("%s 録音  %s 番組表  %s 番組検索  %s お気に入り  %s 録音一覧  %s キャスト  %s 再接続  %s 終了", k.Record.Help().Key, k.Programs.Help().Key, k.Search.Help().Key, k.Favorite.Help().Key, k.Recordings.Help().Key, k.Cast.Help().Key, k.Reconnect.Help().Key, k.Quit.Help().Key)))
 		}
 	}
 
@@ -833,6 +1757,10 @@ func (m Model) renderFooter() string {
 }
 
 func (m Model) renderVolume() string {
+	if m.shared.Cast != nil {
+		return m.renderCastVolume()
+	}
+
 	vol := int(m.shared.Volume * 100)
 	if m.shared.Player != nil {
 		vol = int(m.shared.Player.GetVolume() * 100)
@@ -939,11 +1867,115 @@ func (m Model) renderRegionLine() string {
 	return strings.Join(parts, "")
 }
 
+const (
+	logFileName    = "radiko-tui.log" // Log of the TUI in the config directory
+	logFileMaxSize = 10 << 20         // Size past which the log starts over
+)
+
+// openLogFile opens the TUI's log, appending to earlier sessions until it
+// grows past logFileMaxSize
+func openLogFile() (*os.File, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, logFileName)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if info, err := os.Stat(path); err == nil && info.Size() > logFileMaxSize {
+		flags |= os.O_TRUNC
+	}
+	return os.OpenFile(path, flags, 0600)
+}
+
 // Run starts the TUI
-func Run(stations []model.Station, authToken string, cfg config.Config, serverURL string) error {
+func Run(stations []model.Station, offline bool, authToken string, cfg config.Config, serverURL string) error {
+	keys, err := KeyMapFromConfig(cfg.Keys)
+	if err != nil {
+		return fmt.Errorf("キー設定エラー: %w", err)
+	}
+	if err := ApplyTheme(cfg); err != nil {
+		return fmt.Errorf("テーマ設定エラー: %w", err)
+	}
+	if err := checkStationSettings(cfg.Stations); err != nil {
+		return fmt.Errorf("局設定エラー: %w", err)
+	}
+
 	m := NewModel(stations, authToken, cfg.Volume, cfg.LastStationID, cfg.AreaID, serverURL)
+	m.keys = keys
+	m.offline = offline
+	m.favorites = cfg.Favorites
+	m.sortStations()
+	m.stationSettings = cfg.Stations
+
+	// Premium (area-free) members can browse the stations of every area
+	if serverURL == "" && api.AreaFree() {
+		m.areas = append(m.areas, model.Area{ID: model.AllAreasID, Name: "全エリア"})
+	}
+
+	// Resample in-process when the audio device prefers a different rate,
+	// set by hand or detected
+	if cfg.SampleRate > 0 {
+		m.shared.Player.SetOutputSampleRate(cfg.SampleRate)
+	} else if rate := player.DeviceSampleRate(); rate > 0 {
+		m.shared.Player.SetOutputSampleRate(rate)
+	}
+
+	// Authenticate to servers protected with server_auth
+	if p, ok := m.shared.Player.(*player.HTTPPlayer); ok && cfg.ServerAuth != nil {
+		p.SetAuthToken(cfg.ServerAuth.Token)
+		m.shared.ServerToken = cfg.ServerAuth.Token
+	}
+
+	// Background recorder logs would corrupt the alt screen, so they go to
+	// a file in the config directory
+	if logFile, err := openLogFile(); err == nil {
+		log.SetOutput(logFile)
+		defer func() {
+			log.SetOutput(os.Stderr)
+			logFile.Close()
+		}()
+	} else {
+		log.SetOutput(io.Discard)
+	}
+
+	// Recording defaults (output format, filename template, retention) from config
+	defaults, err := recorder.OptionsFromConfig(cfg)
+	if err != nil {
+		m.errorMessage = fmt.Sprintf("録音設定エラー: %v", err)
+	}
+	go defaults.Retention.Apply()
+	if defaults.Uploader != nil {
+		// Retry uploads that did not finish in a previous session
+		go recorder.UploadPending(defaults.Uploader, recorder.OutputDir(cfg))
+	}
+	m.shared.RecordFormat = defaults.Format
+
+	// Recordings and scheduled recordings run regardless of which station is playing
+	m.shared.Recorder = recorder.NewManager(defaults)
+	if err := config.AssignScheduleIDs(&cfg); err != nil {
+		m.errorMessage = fmt.Sprintf("予約IDの保存に失敗しました: %v", err)
+	}
+	sched, schedErr := recorder.NewScheduler(cfg.Schedules, m.shared.Recorder)
+	if schedErr != nil {
+		m.errorMessage = fmt.Sprintf("予約設定エラー: %v", schedErr)
+	}
+	sched.SetMargin(cfg.RecordMargin)
+	if err := sched.SetRules(cfg.Rules, cfg.AreaID); err != nil {
+		m.errorMessage = fmt.Sprintf("自動予約ルールエラー: %v", err)
+	}
+	m.shared.Scheduler = sched
+
 	p := tea.NewProgram(m, tea.WithAltScreen())
-	_, err := p.Run()
+	sched.SetEventHandler(func(e recorder.Event) {
+		p.Send(scheduleEventMsg{event: e})
+	})
+	sched.Start()
+
+	_, err = p.Run()
+	config.Flush()
+	m.shared.cancel()
+	sched.Stop()
+	m.shared.Recorder.StopAll()
 
 	if m.shared.Player != nil {
 		m.shared.Player.Stop()
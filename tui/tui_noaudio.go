@@ -5,12 +5,12 @@ package tui
 import (
 	"fmt"
 
-	"radiko-tui/config"
-	"radiko-tui/model"
+	"github.com/kanoshiou/radiko-tui/config"
+	"github.com/kanoshiou/radiko-tui/model"
 )
 
 // Run is a stub that returns an error for noaudio builds
 // The TUI requires audio support and is not available in server-only mode
-func Run(stations []model.Station, authToken string, cfg config.Config, serverURL string) error {
+func Run(stations []model.Station, offline bool, authToken string, cfg config.Config, serverURL string) error {
 	return fmt.Errorf("TUI モードは noaudio ビルドではサポートされていません。--server フラグを使用してください")
 }